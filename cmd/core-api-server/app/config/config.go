@@ -34,10 +34,237 @@ type CoreApiConfig struct {
 	LogLevel       string `json:"log_level,omitempty" yaml:"logLevel,omitempty"`
 	ReleaseVersion string `json:"release_version,omitempty" yaml:"releaseVersion,omitempty"`
 	GitVersion     string `json:"git_version,omitempty" yaml:"gitVersion,omitempty"`
+	// key used to sign session token issued by login api
+	// a random key is generated on start up if it is left blank
+	// which means all issued session token will be invalid after restart
+	TokenSigningKey    string `json:"token_signing_key,omitempty" yaml:"tokenSigningKey,omitempty"`
+	TokenExpireMinutes int    `json:"token_expire_minutes,omitempty" yaml:"tokenExpireMinutes,omitempty"`
+	// file revoked sessions are kept in, revoked session token is accepted again after restart if it is left blank
+	// the file is locked by one server, a second replica using it fails on start up as revocations are not shared between replicas
+	SessionPath string `json:"session_path,omitempty" yaml:"sessionPath,omitempty"`
+	// ips or cidrs of reverse proxies in front of core-api, e.g. 10.0.0.0/8
+	// X-Forwarded-For and X-Real-IP are only taken from them, otherwise client ip is the remote address
+	TrustedProxies []string `json:"trusted_proxies,omitempty" yaml:"trustedProxies,omitempty"`
+	// accept legacy token 'Bearer base64(username:password)'
+	// should only be enabled while front end is migrating to session token
+	EnableLegacyBasicToken bool `json:"enable_legacy_basic_token,omitempty" yaml:"enableLegacyBasicToken,omitempty"`
 }
 
 type AuthConfig struct {
+	// identity provider of users, roles and groups, one of 'keystone', 'ldap' and 'local'
+	// default to 'keystone' if it is left blank
+	Provider string          `json:"provider,omitempty" yaml:"provider,omitempty"`
 	Keystone *KeystoneConfig `json:"keystone,omitempty" yaml:"keystone,omitempty"`
+	Ldap     *LdapConfig     `json:"ldap,omitempty" yaml:"ldap,omitempty"`
+	Local    *LocalConfig    `json:"local,omitempty" yaml:"local,omitempty"`
+	// single sign-on with an openid connect identity provider, disabled if it is nil
+	OIDC *OIDCConfig `json:"oidc,omitempty" yaml:"oidc,omitempty"`
+	// brute-force protection of password login
+	Lockout *LockoutConfig `json:"lockout,omitempty" yaml:"lockout,omitempty"`
+	// rules every new password has to follow, checked on create, update and upload of users
+	PasswordPolicy *PasswordPolicyConfig `json:"password_policy,omitempty" yaml:"passwordPolicy,omitempty"`
+	// named, expiring and scoped tokens users create for scripts
+	PersonalAccessToken *PersonalAccessTokenConfig `json:"personal_access_token,omitempty" yaml:"personalAccessToken,omitempty"`
+	// interval of full reload of user cache kept by auth middleware
+	// changes made through core-api are applied at once, reload only catches changes made elsewhere
+	UserCacheResyncSeconds int `json:"user_cache_resync_seconds,omitempty" yaml:"userCacheResyncSeconds,omitempty"`
+	// partition users, groups, roles and knowledge bases by tenant, disabled if it is nil
+	Tenant *TenantConfig `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+	// acting as another user with header 'Impersonate-User', it is granted by permission 'impersonate' of module 'user'
+	Impersonation *ImpersonationConfig `json:"impersonation,omitempty" yaml:"impersonation,omitempty"`
+	// bulk import of users from csv or xlsx file
+	UserImport *UserImportConfig `json:"user_import,omitempty" yaml:"userImport,omitempty"`
+	// owners share conversations, knowledge bases and devices with users and groups
+	Share *ShareConfig `json:"share,omitempty" yaml:"share,omitempty"`
+	// users designated administrator of groups manage members of them without global permission
+	GroupAdmin *GroupAdminConfig `json:"group_admin,omitempty" yaml:"groupAdmin,omitempty"`
+	// overrides permission and white list of routes without a rebuild, routes keep what they are built with if it is nil
+	RoutePolicy *RoutePolicyConfig `json:"route_policy,omitempty" yaml:"routePolicy,omitempty"`
+}
+
+// ShareConfig configures resource shares
+type ShareConfig struct {
+	// file to keep shares, shares are kept in memory and lost after restart if it is left blank
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// most users and groups a resource can be shared with
+	MaxSharesPerResource int `json:"max_shares_per_resource,omitempty" yaml:"maxSharesPerResource,omitempty"`
+}
+
+// GroupAdminConfig configures group administrators
+type GroupAdminConfig struct {
+	// file to keep group administrators, they are kept in memory and lost after restart if it is left blank
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// RoutePolicyConfig holds rules changing authorization of routes, it is checked against registered routes at startup
+// core-api refuses to start if a rule names a route or method that is not registered
+type RoutePolicyConfig struct {
+	// yaml file with a list of rules under 'rules', they are applied after rules given here
+	Path  string            `json:"path,omitempty" yaml:"path,omitempty"`
+	Rules []RoutePolicyRule `json:"rules,omitempty" yaml:"rules,omitempty"`
+}
+
+// RoutePolicyRule changes authorization of a route pattern
+type RoutePolicyRule struct {
+	// route pattern as it is registered, e.g. '/apis/core-api.openhydra.io/v1/users/{userId}'
+	Pattern string `json:"pattern" yaml:"pattern"`
+	// methods rule applies to, every registered method of pattern if it is empty
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	// true serves route without authentication, false takes route off white list, white list is kept if it is nil
+	Public *bool `json:"public,omitempty" yaml:"public,omitempty"`
+	// module permission is checked against, module of route is kept if it is left blank
+	Module string `json:"module,omitempty" yaml:"module,omitempty"`
+	// required bits of module, use either this or permission names
+	Permission *uint64 `json:"permission,omitempty" yaml:"permission,omitempty"`
+	// required permissions of module by name as listed by GET /permissions, e.g. ['view_page', 'list']
+	PermissionNames []string `json:"permission_names,omitempty" yaml:"permissionNames,omitempty"`
+}
+
+// UserImportConfig configures upload of users
+type UserImportConfig struct {
+	// permission of a role that does not exist yet and is created by import
+	// a student like template is used if it is left empty, it is not merged with the template
+	DefaultRolePermission map[string]uint64 `json:"default_role_permission,omitempty" yaml:"defaultRolePermission,omitempty"`
+}
+
+// ImpersonationConfig limits requests support staff make on behalf of another user
+// impersonation is enabled and read only if it is nil
+type ImpersonationConfig struct {
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// impersonated request can only use GET, HEAD and OPTIONS unless it is set
+	AllowWrite bool `json:"allow_write,omitempty" yaml:"allowWrite,omitempty"`
+}
+
+// TenantConfig lets one core-api serve several schools, each of them is a tenant
+// tenant of keystone provider is keystone domain, default tenant is keystone domainId
+// ldap and local provider only have default tenant
+type TenantConfig struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// ids of tenants served besides default one, e.g. keystone domain ids, user of any other tenant is refused
+	Tenants []string `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+	// names of users of default tenant who administer all tenants
+	// a super admin selects tenant to act on with header 'X-Tenant-Id', own tenant is used if it is not set
+	SuperAdmins []string `json:"super_admins,omitempty" yaml:"superAdmins,omitempty"`
+}
+
+// PersonalAccessTokenConfig configures tokens users create to call api from scripts and notebooks
+type PersonalAccessTokenConfig struct {
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// file to keep tokens, tokens are kept in memory and lost after restart if it is left blank
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// longest lifetime of a token
+	MaxExpireDays int `json:"max_expire_days,omitempty" yaml:"maxExpireDays,omitempty"`
+	// most tokens a user can hold, expired ones are not counted
+	MaxTokensPerUser int `json:"max_tokens_per_user,omitempty" yaml:"maxTokensPerUser,omitempty"`
+}
+
+// PasswordPolicyConfig is checked whenever a password is set by core-api
+// password managed by ldap directory is out of scope
+type PasswordPolicyConfig struct {
+	Disabled  bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	MinLength int  `json:"min_length,omitempty" yaml:"minLength,omitempty"`
+	// bcrypt only uses first 72 bytes of a password, longer one is rejected
+	MaxLength int `json:"max_length,omitempty" yaml:"maxLength,omitempty"`
+	// least kinds of characters out of upper case, lower case, digit and symbol
+	MinCharacterClasses int  `json:"min_character_classes,omitempty" yaml:"minCharacterClasses,omitempty"`
+	RequireUpper        bool `json:"require_upper,omitempty" yaml:"requireUpper,omitempty"`
+	RequireLower        bool `json:"require_lower,omitempty" yaml:"requireLower,omitempty"`
+	RequireDigit        bool `json:"require_digit,omitempty" yaml:"requireDigit,omitempty"`
+	RequireSymbol       bool `json:"require_symbol,omitempty" yaml:"requireSymbol,omitempty"`
+	// passwords that are refused regardless of case, a built-in list of common passwords is always applied
+	DenyList []string `json:"deny_list,omitempty" yaml:"denyList,omitempty"`
+	// file with one denied password per line, appended to deny list
+	DenyListPath string `json:"deny_list_path,omitempty" yaml:"denyListPath,omitempty"`
+	// number of previous passwords of a user that can not be reused, 0 disables the check
+	HistorySize int `json:"history_size,omitempty" yaml:"historySize,omitempty"`
+	// file to keep hashes of previous passwords, history is kept in memory and lost after restart if it is left blank
+	HistoryPath string `json:"history_path,omitempty" yaml:"historyPath,omitempty"`
+}
+
+// LockoutConfig limits failed password logins per user name and per client ip
+// every failure makes caller wait twice as long as last one before next attempt
+// an account or client ip is locked for a while once it reaches max failures
+type LockoutConfig struct {
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// failures of a user name before account is locked
+	MaxUserFailures int `json:"max_user_failures,omitempty" yaml:"maxUserFailures,omitempty"`
+	// failures from a client ip before client ip is locked, regardless of user name
+	MaxIpFailures int `json:"max_ip_failures,omitempty" yaml:"maxIpFailures,omitempty"`
+	// wait after first failure, doubled on every further failure
+	BackoffBaseSeconds int `json:"backoff_base_seconds,omitempty" yaml:"backoffBaseSeconds,omitempty"`
+	BackoffMaxSeconds  int `json:"backoff_max_seconds,omitempty" yaml:"backoffMaxSeconds,omitempty"`
+	LockoutMinutes     int `json:"lockout_minutes,omitempty" yaml:"lockoutMinutes,omitempty"`
+	// failures older than this are forgotten
+	FailureWindowMinutes int `json:"failure_window_minutes,omitempty" yaml:"failureWindowMinutes,omitempty"`
+	// file failed logins are kept in, they are kept in memory and forgotten after restart if it is left blank
+	// the file is locked by one server, a second replica using it fails on start up as failures are not shared between replicas
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+}
+
+// OIDCConfig configures authorization code flow with PKCE against an openid connect identity provider
+type OIDCConfig struct {
+	// issuer url, discovery document is read from <issuerUrl>/.well-known/openid-configuration
+	IssuerUrl    string `json:"issuer_url,omitempty" yaml:"issuerUrl,omitempty"`
+	ClientId     string `json:"client_id,omitempty" yaml:"clientId,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty" yaml:"clientSecret,omitempty"`
+	// callback url registered at identity provider
+	// e.g. https://core-api.example.com/apis/core-api.openhydra.io/v1/users/login/oidc/callback
+	RedirectUrl string `json:"redirect_url,omitempty" yaml:"redirectUrl,omitempty"`
+	// default to openid, profile, email and groups
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// claim used as user name, default to preferred_username
+	UsernameClaim string `json:"username_claim,omitempty" yaml:"usernameClaim,omitempty"`
+	// claim holding group names, default to groups
+	GroupsClaim string `json:"groups_claim,omitempty" yaml:"groupsClaim,omitempty"`
+	// name of role bound to user provisioned on first login
+	DefaultRole string `json:"default_role,omitempty" yaml:"defaultRole,omitempty"`
+	// reject user that does not exist in user provider instead of creating it
+	DisableAutoProvision bool `json:"disable_auto_provision,omitempty" yaml:"disableAutoProvision,omitempty"`
+	// file keeping issuer and subject each user is linked to, links are kept in memory if it is not set
+	// single sign-on logs in linked user only, an existing user not created by single sign-on has to be linked by an administrator
+	LinkPath string `json:"link_path,omitempty" yaml:"linkPath,omitempty"`
+	// front end page to redirect to after login, session token is carried in url fragment
+	// session token is written as json if it is left blank
+	PostLoginRedirectUrl string `json:"post_login_redirect_url,omitempty" yaml:"postLoginRedirectUrl,omitempty"`
+}
+
+// LdapConfig maps users and groups of a ldap or active directory to core user and core group
+// attributes left blank fall back to openldap defaults, for active directory set
+// userNameAttribute and userIdAttribute to 'sAMAccountName' and groupFilter to '(objectClass=group)'
+type LdapConfig struct {
+	// e.g. ldap://ldap.example.com:389 or ldaps://ldap.example.com:636
+	Url                string `json:"url,omitempty" yaml:"url,omitempty"`
+	StartTLS           bool   `json:"start_tls,omitempty" yaml:"startTLS,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+	// service account used to search directory
+	BindDN                    string `json:"bind_dn,omitempty" yaml:"bindDN,omitempty"`
+	BindPassword              string `json:"bind_password,omitempty" yaml:"bindPassword,omitempty"`
+	UserBaseDN                string `json:"user_base_dn,omitempty" yaml:"userBaseDN,omitempty"`
+	UserFilter                string `json:"user_filter,omitempty" yaml:"userFilter,omitempty"`
+	UserIdAttribute           string `json:"user_id_attribute,omitempty" yaml:"userIdAttribute,omitempty"`
+	UserNameAttribute         string `json:"user_name_attribute,omitempty" yaml:"userNameAttribute,omitempty"`
+	UserEmailAttribute        string `json:"user_email_attribute,omitempty" yaml:"userEmailAttribute,omitempty"`
+	UserDescriptionAttribute  string `json:"user_description_attribute,omitempty" yaml:"userDescriptionAttribute,omitempty"`
+	GroupBaseDN               string `json:"group_base_dn,omitempty" yaml:"groupBaseDN,omitempty"`
+	GroupFilter               string `json:"group_filter,omitempty" yaml:"groupFilter,omitempty"`
+	GroupIdAttribute          string `json:"group_id_attribute,omitempty" yaml:"groupIdAttribute,omitempty"`
+	GroupNameAttribute        string `json:"group_name_attribute,omitempty" yaml:"groupNameAttribute,omitempty"`
+	GroupDescriptionAttribute string `json:"group_description_attribute,omitempty" yaml:"groupDescriptionAttribute,omitempty"`
+	// member attribute holds either dn or user name of member, e.g. 'member' or 'memberUid'
+	GroupMemberAttribute string `json:"group_member_attribute,omitempty" yaml:"groupMemberAttribute,omitempty"`
+	// json file keeping core-api roles and role bindings of directory users
+	// overlay is kept in memory only if it is left blank
+	OverlayPath string `json:"overlay_path,omitempty" yaml:"overlayPath,omitempty"`
+	// name of directory users that are always bound to build-in admin role
+	AdminUsers []string `json:"admin_users,omitempty" yaml:"adminUsers,omitempty"`
+}
+
+// LocalConfig configures the embedded identity store used by 'local' provider
+type LocalConfig struct {
+	// path of bbolt database file, e.g. /var/lib/core-api/identity.db
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// password of build-in admin user, it is only used to create admin user when store has none
+	BootstrapAdminPassword string `json:"bootstrap_admin_password,omitempty" yaml:"bootstrapAdminPassword,omitempty"`
 }
 
 type KeystoneConfig struct {
@@ -48,6 +275,12 @@ type KeystoneConfig struct {
 	ProjectId          string `json:"project_id,omitempty" yaml:"projectId,omitempty"`
 	TokenKeyInResponse string `json:"token_key_in_response,omitempty" yaml:"tokenKeyInResponse,omitempty"`
 	TokenKeyInRequest  string `json:"token_key_in_request,omitempty" yaml:"tokenKeyInRequest,omitempty"`
+	// max number of entries kept by local lookup index of each object kind, 4096 if left blank
+	IndexSize int `json:"index_size,omitempty" yaml:"indexSize,omitempty"`
+	// how long an index entry is trusted, 60 if left blank
+	// roles are cached as well but never longer than 60 seconds, a role changed through another replica takes effect
+	// after role entry expires, which is the bound of how long old permission is granted
+	IndexTTLSeconds int `json:"index_ttl_seconds,omitempty" yaml:"indexTTLSeconds,omitempty"`
 }
 
 type RayLLM struct {
@@ -81,6 +314,28 @@ func DefaultConfig() *Config {
 				TokenKeyInResponse: "X-Subject-Token",
 				TokenKeyInRequest:  "X-Auth-Token",
 			},
+			Lockout: &LockoutConfig{
+				MaxUserFailures:      5,
+				MaxIpFailures:        50,
+				BackoffBaseSeconds:   1,
+				BackoffMaxSeconds:    60,
+				LockoutMinutes:       15,
+				FailureWindowMinutes: 15,
+			},
+			PasswordPolicy: &PasswordPolicyConfig{
+				MinLength:           8,
+				MaxLength:           72,
+				MinCharacterClasses: 2,
+				HistorySize:         5,
+			},
+			PersonalAccessToken: &PersonalAccessTokenConfig{
+				MaxExpireDays:    90,
+				MaxTokensPerUser: 20,
+			},
+			Share: &ShareConfig{
+				MaxSharesPerResource: 100,
+			},
+			UserCacheResyncSeconds: 300,
 		},
 		KubeConfig: &KubeConfig{
 			QPS:   100,
@@ -91,6 +346,8 @@ func DefaultConfig() *Config {
 			LogLevel:       "info",
 			ReleaseVersion: "v0.0.1-debug",
 			GitVersion:     "v0.0.1-debug",
+			// 8 hours
+			TokenExpireMinutes: 480,
 		},
 		RayLLM: &RayLLM{
 			Endpoint: "http://localhost:8081",
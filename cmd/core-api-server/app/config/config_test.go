@@ -16,6 +16,14 @@ var _ = Describe("Config", func() {
 			Expect(config.AuthConfig.Keystone.ProjectId).To(Equal("default"))
 			Expect(config.AuthConfig.Keystone.TokenKeyInResponse).To(Equal("X-Subject-Token"))
 			Expect(config.AuthConfig.Keystone.TokenKeyInRequest).To(Equal("X-Auth-Token"))
+			Expect(config.CoreApiConfig.TokenExpireMinutes).To(Equal(480))
+			Expect(config.CoreApiConfig.EnableLegacyBasicToken).To(BeFalse())
+			Expect(config.AuthConfig.Lockout.MaxUserFailures).To(Equal(5))
+			Expect(config.AuthConfig.Lockout.LockoutMinutes).To(Equal(15))
+			Expect(config.AuthConfig.PasswordPolicy.MinLength).To(Equal(8))
+			Expect(config.AuthConfig.PasswordPolicy.HistorySize).To(Equal(5))
+			Expect(config.AuthConfig.PersonalAccessToken.MaxExpireDays).To(Equal(90))
+			Expect(config.AuthConfig.UserCacheResyncSeconds).To(Equal(300))
 		})
 	})
 })
@@ -63,5 +63,6 @@ func NewCommand(version string) *cobra.Command {
 
 	cmd.AddCommand(versionCmd)
 	cmd.AddCommand(runCommand)
+	cmd.AddCommand(newMigrateKeystoneCommand(option))
 	return cmd
 }
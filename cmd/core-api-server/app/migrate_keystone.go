@@ -0,0 +1,80 @@
+package app
+
+import (
+	"core-api/cmd/core-api-server/app/option"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/provider/local"
+	coreApiLog "core-api/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// newMigrateKeystoneCommand copies users, roles and groups kept in keystone into local identity store
+// plain text passwords former versions kept in keystone are hashed into local store and scrubbed from keystone
+// both auth.keystone and auth.local should be set in config file
+func newMigrateKeystoneCommand(option *option.Option) *cobra.Command {
+	overwrite := false
+	cmd := &cobra.Command{
+		Use:     "migrate-keystone",
+		Short:   "Copy users, roles and groups from keystone into local identity store",
+		Long:    "Copy users, roles and groups from keystone into local identity store, run it once before switching auth provider to local. Plain text passwords former versions kept in keystone are hashed into local store and then removed from keystone, users without one need a password reset",
+		Example: "core-api-server --config /etc/core-api-server-config.yaml migrate-keystone",
+		RunE: func(_ *cobra.Command, args []string) error {
+			config, err := option.GenerateConfig(false)
+			if err != nil {
+				return fmt.Errorf("failed to generate config: %w", err)
+			}
+			coreApiLog.InitLogger(config.CoreApiConfig.LogLevel)
+
+			if config.AuthConfig == nil || config.AuthConfig.Keystone == nil || config.AuthConfig.Local == nil {
+				return fmt.Errorf("both auth.keystone and auth.local should be configured")
+			}
+
+			roles, err := (&keystone.RoleProvider{Config: config}).GetRoles(nil)
+			if err != nil {
+				return fmt.Errorf("failed to read roles from keystone: %w", err)
+			}
+
+			groups, err := (&keystone.GroupProvider{Config: config}).GetGroups(nil)
+			if err != nil {
+				return fmt.Errorf("failed to read groups from keystone: %w", err)
+			}
+
+			// keystone does not hand out password it keeps itself, but former versions kept a plain text copy in core user
+			// that copy is read this once so it can be hashed into local store, then it is scrubbed from keystone
+			// users without it are listed in report and need a password reset
+			// disabled users are migrated as disabled
+			userProvider := &keystone.UserProvider{Config: config}
+			users, err := userProvider.GetUsers(map[string]struct{}{keystone.LoadLegacyPassword: {}, keystone.IncludeDisabledUsers: {}})
+			if err != nil {
+				return fmt.Errorf("failed to read users from keystone: %w", err)
+			}
+
+			report, err := local.Import(config.AuthConfig.Local, roles, groups, users, overwrite)
+			if err != nil {
+				return fmt.Errorf("failed to import into local identity store: %w", err)
+			}
+
+			// scrubbed only once import succeeded, so a failed migration can be run again
+			scrubbed, err := userProvider.ScrubLegacyPasswords()
+			if err != nil {
+				return fmt.Errorf("failed to scrub legacy passwords from keystone, run migration again to finish it: %w", err)
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(&struct {
+				*local.ImportReport
+				// users whose plain text password is removed from keystone
+				ScrubbedKeystoneUsers []string `json:"scrubbedKeystoneUsers"`
+			}{ImportReport: report, ScrubbedKeystoneUsers: scrubbed})
+		},
+	}
+
+	option.BindFlags(cmd.Flags())
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "replace users that already exist in local identity store")
+	return cmd
+}
@@ -1363,12 +1363,6 @@ const docTemplate = `{
                         "description": "load permission or not e.g. ?loadPermission=1",
                         "name": "loadPermission",
                         "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "load password or not e.g. ?loadPassword=1",
-                        "name": "loadPassword",
-                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -2,11 +2,19 @@ package custom_middleware
 
 import (
 	"core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/accesstoken"
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/auth/event"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/auth/lockout"
+	"core-api/pkg/core/auth/session"
+	"core-api/pkg/core/auth/tenant"
+	authToken "core-api/pkg/core/auth/token"
 	"core-api/pkg/core/privileges"
 	northApiRoute "core-api/pkg/north/api/route"
 	"fmt"
 	"net/http"
-	"sync"
+	"time"
 )
 
 var defaultCoreBasicAuthInstance *defaultCoreBasicAuth
@@ -17,6 +25,19 @@ type IBasicAuth interface {
 	StopBackgroundCache()
 	GetWhiteListedRoutes() []string
 	SetWhiteListedRoutes(routes []string)
+	// SetWhiteList replaces white list with routes open to given methods only, e.g. after route policy is applied
+	SetWhiteList(whiteList northApiRoute.WhiteList)
+	// SetPasswordChangeRoutes sets routes that are still allowed for a user who must change password
+	SetPasswordChangeRoutes(routes []string)
+	SetLegacyBasicTokenEnabled(enabled bool)
+	// SetCacheResyncInterval sets interval of full reload of user cache, changes published by handlers are applied at once
+	SetCacheResyncInterval(interval time.Duration)
+	// SetTenantResolver enables tenant isolation, request is bound to tenant of its user unless a super admin selects another
+	SetTenantResolver(resolver tenant.ITenantResolver)
+	// SetImpersonation enables header 'Impersonate-User', impersonated request is read only unless allowWrite is set
+	SetImpersonation(enabled, allowWrite bool)
+	// SetGroupAdminStore lets administrators of groups call routes open to them without permission
+	SetGroupAdminStore(store groupadmin.IGroupAdminStore)
 }
 
 type CoreBaseAuthType string
@@ -25,7 +46,7 @@ const (
 	DefaultCoreBaseAuth CoreBaseAuthType = "default"
 )
 
-func NewCoreBaseAuth(priProvider privileges.IPrivilegeProvider, authProvider auth.IUserProvider, baType CoreBaseAuthType, routeProvider northApiRoute.IRouteProvider, stopChan <-chan struct{}) (IBasicAuth, error) {
+func NewCoreBaseAuth(priProvider privileges.IPrivilegeProvider, authProvider auth.IUserProvider, tokenIssuer authToken.ITokenIssuer, sessionStore session.ISessionStore, loginLimiter lockout.ILoginLimiter, accessTokenStore accesstoken.IAccessTokenStore, baType CoreBaseAuthType, routeProvider northApiRoute.IRouteProvider, stopChan <-chan struct{}) (IBasicAuth, error) {
 	if priProvider == nil {
 		return nil, fmt.Errorf("privilege provider is nil")
 	}
@@ -34,25 +55,49 @@ func NewCoreBaseAuth(priProvider privileges.IPrivilegeProvider, authProvider aut
 		return nil, fmt.Errorf("auth provider is nil")
 	}
 
+	if tokenIssuer == nil {
+		return nil, fmt.Errorf("token issuer is nil")
+	}
+
+	if sessionStore == nil {
+		return nil, fmt.Errorf("session store is nil")
+	}
+
+	if loginLimiter == nil {
+		return nil, fmt.Errorf("login limiter is nil")
+	}
+
+	if accessTokenStore == nil {
+		return nil, fmt.Errorf("access token store is nil")
+	}
+
 	switch baType {
 	case DefaultCoreBaseAuth:
-		return newOrGetDefaultCoreBasicAuth(priProvider, authProvider, routeProvider, stopChan), nil
+		return newOrGetDefaultCoreBasicAuth(priProvider, authProvider, tokenIssuer, sessionStore, loginLimiter, accessTokenStore, routeProvider, stopChan), nil
 	}
 
 	return nil, fmt.Errorf("unknown core base auth type: '%s'", baType)
 }
 
 // make it singleton
-func newOrGetDefaultCoreBasicAuth(priProvider privileges.IPrivilegeProvider, authProvider auth.IUserProvider, routeProvider northApiRoute.IRouteProvider, stopChan <-chan struct{}) *defaultCoreBasicAuth {
+func newOrGetDefaultCoreBasicAuth(priProvider privileges.IPrivilegeProvider, authProvider auth.IUserProvider, tokenIssuer authToken.ITokenIssuer, sessionStore session.ISessionStore, loginLimiter lockout.ILoginLimiter, accessTokenStore accesstoken.IAccessTokenStore, routeProvider northApiRoute.IRouteProvider, stopChan <-chan struct{}) *defaultCoreBasicAuth {
 	if defaultCoreBasicAuthInstance == nil {
 		defaultCoreBasicAuthInstance = &defaultCoreBasicAuth{
-			PrivilegesProvider:      priProvider,
-			UserProvider:            authProvider,
-			userAuthenticationCache: &sync.Map{},
-			routeProvider:           routeProvider,
-			stopChan:                stopChan,
-			whiteList:               make(map[string]struct{}),
+			PrivilegesProvider:   priProvider,
+			UserProvider:         authProvider,
+			TokenIssuer:          tokenIssuer,
+			SessionStore:         sessionStore,
+			credentialCache:      credential.InitOrGetCache(),
+			eventBus:             event.InitOrGetEventBus(),
+			loginLimiter:         loginLimiter,
+			accessTokenStore:     accessTokenStore,
+			routeProvider:        routeProvider,
+			stopChan:             stopChan,
+			innerStopChan:        make(chan struct{}),
+			whiteList:            make(map[string]map[string]struct{}),
+			passwordChangeRoutes: make(map[string]struct{}),
 		}
+		defaultCoreBasicAuthInstance.userAuthenticationCache.Store(newCachedUsers())
 	}
 
 	return defaultCoreBasicAuthInstance
@@ -0,0 +1,13 @@
+package custom_middleware_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCustomMiddleware(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "CustomMiddleware Suite")
+}
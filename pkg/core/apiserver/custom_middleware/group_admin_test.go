@@ -0,0 +1,56 @@
+package custom_middleware
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	northApiRoute "core-api/pkg/north/api/route"
+	v1 "core-api/pkg/north/api/user/core/v1"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("group admin test", func() {
+	var cba *defaultCoreBasicAuth
+	var teacher *v1.CoreUser
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		store, err := groupadmin.CreateGroupAdminStore(config.DefaultConfig(), groupadmin.MemoryGroupAdminStore)
+		Expect(err).To(BeNil())
+
+		routeProvider := northApiRoute.NewRouteProvider(northApiRoute.DefaultRootRouteType)
+		routeProvider.SetRouteAuthorization(map[string]map[string]northApiRoute.ModuleAndPermission{
+			"/v1/users/{userId}": {
+				http.MethodPut:    {Module: "user", Permission: privileges.PermissionUserUpdate, GroupAdmin: true},
+				http.MethodDelete: {Module: "user", Permission: privileges.PermissionUserDelete},
+			},
+		})
+		cba = &defaultCoreBasicAuth{routeProvider: routeProvider}
+		cba.SetGroupAdminStore(store)
+
+		teacher = &v1.CoreUser{Id: "teacher", Name: "teacher", Permission: map[string]uint64{"user": privileges.PermissionUserViewPage}}
+		_, err = store.Add(&v1.CoreGroupAdmin{GroupId: "class-1", UserId: teacher.Id})
+		Expect(err).To(BeNil())
+	})
+
+	It("should let group administrator through route open to group administrators only", func() {
+		status, err := cba.authorization(teacher, "/v1/users/{userId}", http.MethodPut)
+		Expect(err).To(BeNil())
+		Expect(status).To(Equal(http.StatusOK))
+
+		status, err = cba.authorization(teacher, "/v1/users/{userId}", http.MethodDelete)
+		Expect(err).NotTo(BeNil())
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+
+	It("should not let user administering no group through", func() {
+		student := &v1.CoreUser{Id: "student", Name: "student", Permission: map[string]uint64{"user": privileges.PermissionUserViewPage}}
+		status, err := cba.authorization(student, "/v1/users/{userId}", http.MethodPut)
+		Expect(err).NotTo(BeNil())
+		Expect(status).To(Equal(http.StatusForbidden))
+	})
+})
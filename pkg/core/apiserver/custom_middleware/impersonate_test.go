@@ -0,0 +1,118 @@
+package custom_middleware
+
+import (
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/auth/impersonation"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	v1 "core-api/pkg/north/api/user/core/v1"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("impersonate test", func() {
+	var provider *fakeUserProvider
+	var cba *defaultCoreBasicAuth
+	var support *v1.CoreUser
+
+	request := func(method string) *http.Request {
+		r, _ := http.NewRequest(method, "/apis/core-api.openhydra.io/v1/users/me", nil)
+		r.Header.Set(impersonation.HeaderName, "student1")
+		return r
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		provider = &fakeUserProvider{users: map[string]v1.CoreUser{}}
+		for i := 0; i < 3; i++ {
+			provider.set(student(i, "student-role-id"))
+		}
+
+		support = &v1.CoreUser{
+			Id:   "support-id",
+			Name: "support",
+			Permission: map[string]uint64{
+				"user":   privileges.PermissionUserImpersonate,
+				"course": privileges.PermissionCourseViewPage,
+			},
+		}
+
+		cba = &defaultCoreBasicAuth{
+			PrivilegesProvider:   &privileges.DefaultPrivilegeProvider{},
+			UserProvider:         provider,
+			credentialCache:      credential.NewCache(credential.DefaultExpire),
+			impersonationEnabled: true,
+		}
+		cba.userAuthenticationCache.Store(newCachedUsers())
+	})
+
+	It("should act as user found by name", func() {
+		target, code, err := cba.impersonate(support, nil, "student1", request(http.MethodGet))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(target.Id).To(Equal("student1-id"))
+	})
+
+	It("should take user from user cache", func() {
+		cba.renewUserAuthenticationCache()
+		gets := provider.gets
+		target, _, err := cba.impersonate(support, nil, "student1", request(http.MethodGet))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(target.Id).To(Equal("student1-id"))
+		Expect(provider.gets).To(Equal(gets))
+	})
+
+	It("should refuse when impersonation is disabled", func() {
+		cba.impersonationEnabled = false
+		_, code, err := cba.impersonate(support, nil, "student1", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should refuse write unless it is allowed", func() {
+		_, code, err := cba.impersonate(support, nil, "student1", request(http.MethodPost))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+
+		cba.impersonationAllowWrite = true
+		_, _, err = cba.impersonate(support, nil, "student1", request(http.MethodPost))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should refuse request made with personal access token", func() {
+		_, code, err := cba.impersonate(support, &v1.CorePersonalAccessToken{Id: "token-id"}, "student1", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should refuse user without permission before looking up target", func() {
+		support.Permission["user"] = 0
+		_, code, err := cba.impersonate(support, nil, "nobody", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should be not found if target does not exist", func() {
+		_, code, err := cba.impersonate(support, nil, "nobody", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should refuse disabled target found by name", func() {
+		disabled := provider.users["student1-id"]
+		disabled.Disabled = true
+		provider.set(disabled)
+		_, code, err := cba.impersonate(support, nil, "student1", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should refuse target holding more permission", func() {
+		support.Permission["course"] = 0
+		_, code, err := cba.impersonate(support, nil, "student1", request(http.MethodGet))
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+})
@@ -0,0 +1,250 @@
+package custom_middleware
+
+import (
+	"core-api/pkg/core/auth/event"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	coreApiLog "core-api/pkg/logger"
+	v1 "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"sync"
+	"time"
+)
+
+const (
+	// full resync only catches changes made out of core-api, e.g. directly in keystone
+	defaultResyncInterval = 5 * time.Minute
+	// a role or group held by more users than this is applied with a full resync, which costs one listing
+	maxTargetedReload = 50
+)
+
+// cachedUsers indexes cached users by name for credentials and by id for tokens
+type cachedUsers struct {
+	byName sync.Map
+	byId   sync.Map
+}
+
+func newCachedUsers() *cachedUsers {
+	return &cachedUsers{}
+}
+
+func (c *cachedUsers) Load(name string) (*v1.CoreUser, bool) {
+	value, found := c.byName.Load(name)
+	if !found {
+		return nil, false
+	}
+	return value.(*v1.CoreUser), true
+}
+
+func (c *cachedUsers) LoadById(id string) (*v1.CoreUser, bool) {
+	value, found := c.byId.Load(id)
+	if !found {
+		return nil, false
+	}
+	return value.(*v1.CoreUser), true
+}
+
+func (c *cachedUsers) Store(user *v1.CoreUser) {
+	c.byId.Store(user.Id, user)
+	c.byName.Store(user.Name, user)
+}
+
+// Remove removes user unless it is still named keep, name of a renamed user is freed
+func (c *cachedUsers) Remove(userId, keep string) {
+	value, found := c.byId.Load(userId)
+	if !found {
+		return
+	}
+	old := value.(*v1.CoreUser)
+	if old.Name == keep {
+		return
+	}
+	// name may be taken by another user already
+	c.byName.CompareAndDelete(old.Name, old)
+	if keep == "" {
+		c.byId.CompareAndDelete(userId, old)
+	}
+}
+
+func (c *cachedUsers) Range(f func(user *v1.CoreUser) bool) {
+	c.byId.Range(func(_, value interface{}) bool {
+		return f(value.(*v1.CoreUser))
+	})
+}
+
+func (cba *defaultCoreBasicAuth) userCache() *cachedUsers {
+	return cba.userAuthenticationCache.Load()
+}
+
+func (cba *defaultCoreBasicAuth) SetCacheResyncInterval(interval time.Duration) {
+	cba.resyncInterval = interval
+}
+
+// RunBackgroundCache loads all users once, then applies changes published by handlers as they happen
+// a full resync still runs at a slow interval as safety net
+func (cba *defaultCoreBasicAuth) RunBackgroundCache() {
+	if cba.stopChan == nil {
+		coreApiLog.Logger.Warn("stop channel is nil, background cache will not run")
+		return
+	}
+
+	unsubscribe := cba.eventBus.Subscribe(cba.applyChange)
+	defer unsubscribe()
+
+	interval := cba.resyncInterval
+	if interval <= 0 {
+		interval = defaultResyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	cba.renewUserAuthenticationCache()
+	for {
+		select {
+		case <-ticker.C:
+			coreApiLog.Logger.Debug("background worker resyncing user cache")
+			cba.renewUserAuthenticationCache()
+		case <-cba.stopChan:
+			coreApiLog.Logger.Info("stop channel is closed, stopping background cache")
+			return
+		case <-cba.innerStopChan:
+			coreApiLog.Logger.Info("inner stop channel is closed, stopping background cache")
+			return
+		}
+	}
+}
+
+func (cba *defaultCoreBasicAuth) StopBackgroundCache() {
+	if cba.stopChan == nil {
+		coreApiLog.Logger.Warn("stop channel is nil, background cache not running")
+		return
+	}
+	close(cba.innerStopChan)
+}
+
+// renewUserAuthenticationCache replaces user cache with a full list of users from the user provider api
+// user cache holds no password, credentials are verified with credential cache
+func (cba *defaultCoreBasicAuth) renewUserAuthenticationCache() {
+	cba.credentialCache.RemoveExpired()
+
+	cba.cacheLock.Lock()
+	if cba.resyncing {
+		cba.cacheLock.Unlock()
+		return
+	}
+	cba.resyncing = true
+	cba.cacheLock.Unlock()
+
+	users, err := cba.UserProvider.GetUsers(map[string]struct{}{keystone.LoadPermission: {}})
+
+	tempCache := newCachedUsers()
+	if err == nil {
+		coreApiLog.Logger.Debug("Attempting to renewing user cache with", "total", len(users))
+		for index := range users {
+			tempCache.Store(&users[index])
+		}
+	}
+
+	cba.cacheLock.Lock()
+	changed := cba.changedDuringResync
+	cba.changedDuringResync = nil
+	cba.resyncing = false
+	if err == nil {
+		// replace the old cache with the new one
+		cba.userAuthenticationCache.Store(tempCache)
+	}
+	cba.cacheLock.Unlock()
+
+	if err != nil {
+		coreApiLog.Logger.Error("failed to renew user cache", "error", err)
+		return
+	}
+	coreApiLog.Logger.Debug("renew user cache success with", "total", len(users))
+
+	// users may be listed before a change is applied, so apply it again on the new cache
+	for _, change := range changed {
+		cba.applyChange(change)
+	}
+}
+
+// applyChange reloads only users affected by a change published by handlers
+func (cba *defaultCoreBasicAuth) applyChange(change event.Event) {
+	cba.cacheLock.Lock()
+	if cba.resyncing {
+		cba.changedDuringResync = append(cba.changedDuringResync, change)
+	}
+	cba.cacheLock.Unlock()
+
+	switch change.Kind {
+	case event.UserKind:
+		if change.Action == event.Deleted {
+			cba.removeCachedUser(change.Id, "")
+			return
+		}
+		cba.reloadCachedUser(change.Id)
+	case event.RoleKind, event.GroupKind:
+		if change.Action == event.Created {
+			// no user holds it yet
+			return
+		}
+
+		affected := cba.cachedUsersHolding(change.Kind, change.Id)
+		if len(affected) > maxTargetedReload {
+			cba.renewUserAuthenticationCache()
+			return
+		}
+		for _, userId := range affected {
+			cba.reloadCachedUser(userId)
+		}
+	}
+}
+
+// reloadCachedUser keeps old entry if provider fails, it is fixed by next resync
+func (cba *defaultCoreBasicAuth) reloadCachedUser(userId string) {
+	user, err := cba.UserProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		if customError.IsNotFound(err) {
+			cba.removeCachedUser(userId, "")
+			return
+		}
+		coreApiLog.Logger.Warn("failed to reload changed user", "user", userId, "error", err)
+		return
+	}
+
+	// cache only holds enabled users like a full resync does
+	if user.Disabled {
+		cba.removeCachedUser(userId, "")
+		return
+	}
+
+	// user may be renamed
+	cba.removeCachedUser(userId, user.Name)
+	cba.userCache().Store(user)
+}
+
+// removeCachedUser removes entries of user except the one named keep
+func (cba *defaultCoreBasicAuth) removeCachedUser(userId, keep string) {
+	cba.userCache().Remove(userId, keep)
+}
+
+func (cba *defaultCoreBasicAuth) cachedUsersHolding(kind event.Kind, id string) []string {
+	var userIds []string
+	cba.userCache().Range(func(user *v1.CoreUser) bool {
+		if kind == event.RoleKind {
+			for _, role := range user.Roles {
+				if role.Id == id {
+					userIds = append(userIds, user.Id)
+					break
+				}
+			}
+		} else {
+			for _, group := range user.Groups {
+				if group.Id == id {
+					userIds = append(userIds, user.Id)
+					break
+				}
+			}
+		}
+		return true
+	})
+	return userIds
+}
@@ -0,0 +1,304 @@
+package custom_middleware
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/accesstoken"
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/auth/event"
+	"core-api/pkg/core/auth/session"
+	authToken "core-api/pkg/core/auth/token"
+	coreApiLog "core-api/pkg/logger"
+	v1 "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeUserProvider keeps users in memory and counts listings and single user loads
+type fakeUserProvider struct {
+	lock   sync.Mutex
+	users  map[string]v1.CoreUser
+	lists  int
+	gets   int
+	onList func()
+}
+
+func (p *fakeUserProvider) SearchUserByName(name string, options map[string]struct{}) (*v1.CoreUser, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, user := range p.users {
+		if user.Name == name {
+			return &user, nil
+		}
+	}
+	return nil, customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
+}
+
+func (p *fakeUserProvider) GetUsers(options map[string]struct{}) ([]v1.CoreUser, error) {
+	p.lock.Lock()
+	p.lists++
+	var users []v1.CoreUser
+	for _, user := range p.users {
+		users = append(users, user)
+	}
+	onList := p.onList
+	p.lock.Unlock()
+
+	if onList != nil {
+		onList()
+	}
+	return users, nil
+}
+
+func (p *fakeUserProvider) GetUser(id string, options map[string]struct{}) (*v1.CoreUser, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.gets++
+	user, found := p.users[id]
+	if !found {
+		return nil, customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", id))
+	}
+	return &user, nil
+}
+
+func (p *fakeUserProvider) UpdateUser(user *v1.CoreUser, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (p *fakeUserProvider) DeleteUser(id string, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (p *fakeUserProvider) CreateUser(user *v1.CoreUser, options map[string]struct{}) (*v1.CoreUser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *fakeUserProvider) LoginUser(username, password string) (*v1.CoreUser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (p *fakeUserProvider) set(user v1.CoreUser) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.users[user.Id] = user
+}
+
+func (p *fakeUserProvider) remove(id string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.users, id)
+}
+
+func student(i int, roleId string) v1.CoreUser {
+	return v1.CoreUser{
+		Id:         fmt.Sprintf("student%d-id", i),
+		Name:       fmt.Sprintf("student%d", i),
+		Roles:      []v1.CoreRole{{Id: roleId}},
+		Groups:     []v1.CoreGroup{{Id: "class1-id"}},
+		Permission: map[string]uint64{"course": 1},
+	}
+}
+
+var _ = Describe("user cache test", func() {
+	var provider *fakeUserProvider
+	var cba *defaultCoreBasicAuth
+	var bus event.IEventBus
+
+	cached := func(name string) *v1.CoreUser {
+		user, found := cba.userCache().Load(name)
+		if !found {
+			return nil
+		}
+		return user
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		provider = &fakeUserProvider{users: map[string]v1.CoreUser{}}
+		for i := 0; i < 3; i++ {
+			provider.set(student(i, "student-role-id"))
+		}
+		teacher := student(100, "teacher-role-id")
+		teacher.Name = "teacher"
+		provider.set(teacher)
+
+		bus, _ = event.CreateEventBus(event.MemoryEventBus)
+		cba = &defaultCoreBasicAuth{
+			UserProvider:    provider,
+			credentialCache: credential.NewCache(credential.DefaultExpire),
+			eventBus:        bus,
+		}
+		cba.userAuthenticationCache.Store(newCachedUsers())
+		cba.renewUserAuthenticationCache()
+		bus.Subscribe(cba.applyChange)
+		Expect(provider.lists).To(Equal(1))
+	})
+
+	It("should reload updated user only", func() {
+		changed := student(1, "teacher-role-id")
+		changed.Permission = map[string]uint64{"course": 3}
+		provider.set(changed)
+
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Updated, Id: changed.Id})
+		Expect(cached("student1").Permission["course"]).To(Equal(uint64(3)))
+		Expect(provider.gets).To(Equal(1))
+		Expect(provider.lists).To(Equal(1))
+	})
+
+	It("should add created user", func() {
+		provider.set(student(3, "student-role-id"))
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Created, Id: "student3-id"})
+		Expect(cached("student3")).NotTo(BeNil())
+	})
+
+	It("should drop old name of renamed user", func() {
+		renamed := student(1, "student-role-id")
+		renamed.Name = "renamed"
+		provider.set(renamed)
+
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Updated, Id: renamed.Id})
+		Expect(cached("student1")).To(BeNil())
+		Expect(cached("renamed")).NotTo(BeNil())
+		user, found := cba.userCache().LoadById(renamed.Id)
+		Expect(found).To(BeTrue())
+		Expect(user.Name).To(Equal("renamed"))
+	})
+
+	It("should remove deleted user", func() {
+		provider.remove("student2-id")
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Deleted, Id: "student2-id"})
+		Expect(cached("student2")).To(BeNil())
+		_, found := cba.userCache().LoadById("student2-id")
+		Expect(found).To(BeFalse())
+		Expect(provider.gets).To(Equal(0))
+
+		// a user not found on reload is removed as well
+		provider.remove("student1-id")
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Updated, Id: "student1-id"})
+		Expect(cached("student1")).To(BeNil())
+	})
+
+	It("should remove disabled user", func() {
+		disabled := student(1, "student-role-id")
+		disabled.Disabled = true
+		provider.set(disabled)
+
+		bus.Publish(event.Event{Kind: event.UserKind, Action: event.Updated, Id: disabled.Id})
+		Expect(cached("student1")).To(BeNil())
+		Expect(cached("student0")).NotTo(BeNil())
+	})
+
+	It("should take user of personal access token from user cache by id", func() {
+		var err error
+		cba.accessTokenStore, err = accesstoken.CreateAccessTokenStore(config.DefaultConfig(), accesstoken.MemoryAccessTokenStore)
+		Expect(err).NotTo(HaveOccurred())
+		owner := cached("student1")
+		created, err := cba.accessTokenStore.Create(owner, &v1.CorePersonalAccessToken{Name: "ci", ExpiresInDays: 1, Scope: map[string]uint64{"course": 1}})
+		Expect(err).NotTo(HaveOccurred())
+
+		code, user, _, _, err := cba.accessTokenAuthentication(created.Token)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(user.Id).To(Equal("student1-id"))
+		Expect(provider.gets).To(Equal(0))
+	})
+
+	It("should load user of session missing from cache and refuse disabled user", func() {
+		serverConfig := config.DefaultConfig()
+		serverConfig.CoreApiConfig.TokenSigningKey = "signing-key"
+		var err error
+		cba.TokenIssuer, err = authToken.CreateTokenIssuer(serverConfig, authToken.HMACTokenIssuer)
+		Expect(err).NotTo(HaveOccurred())
+		cba.SessionStore, err = session.CreateSessionStore(serverConfig, session.MemorySessionStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		issue := func(user v1.CoreUser) string {
+			token, _, err := cba.TokenIssuer.Issue(&user)
+			Expect(err).NotTo(HaveOccurred())
+			return token
+		}
+
+		// disabled directly in identity provider, token still carries permission signed at login
+		disabled := student(1, "student-role-id")
+		token := issue(disabled)
+		disabled.Disabled = true
+		provider.set(disabled)
+		cba.removeCachedUser(disabled.Id, "")
+		code, _, _, err := cba.sessionTokenAuthentication(token)
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusUnauthorized))
+
+		deleted := student(2, "student-role-id")
+		token = issue(deleted)
+		provider.remove(deleted.Id)
+		cba.removeCachedUser(deleted.Id, "")
+		code, _, _, err = cba.sessionTokenAuthentication(token)
+		Expect(err).To(HaveOccurred())
+		Expect(code).To(Equal(http.StatusUnauthorized))
+
+		// created after last resync
+		created := student(3, "student-role-id")
+		provider.set(created)
+		code, user, _, err := cba.sessionTokenAuthentication(issue(created))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(user.Permission["course"]).To(Equal(uint64(1)))
+	})
+
+	It("should reload users holding updated role only", func() {
+		for i := 0; i < 3; i++ {
+			changed := student(i, "student-role-id")
+			changed.Permission = map[string]uint64{"course": 7}
+			provider.set(changed)
+		}
+
+		bus.Publish(event.Event{Kind: event.RoleKind, Action: event.Updated, Id: "student-role-id"})
+		Expect(provider.gets).To(Equal(3))
+		Expect(provider.lists).To(Equal(1))
+		Expect(cached("student0").Permission["course"]).To(Equal(uint64(7)))
+		Expect(cached("teacher").Permission["course"]).To(Equal(uint64(1)))
+	})
+
+	It("should reload users in deleted group", func() {
+		bus.Publish(event.Event{Kind: event.GroupKind, Action: event.Deleted, Id: "class1-id"})
+		Expect(provider.gets).To(Equal(4))
+	})
+
+	It("should ignore created role", func() {
+		bus.Publish(event.Event{Kind: event.RoleKind, Action: event.Created, Id: "new-role-id"})
+		Expect(provider.gets).To(Equal(0))
+		Expect(provider.lists).To(Equal(1))
+	})
+
+	It("should resync once if role is held by many users", func() {
+		for i := 3; i <= maxTargetedReload+1; i++ {
+			provider.set(student(i, "student-role-id"))
+		}
+		cba.renewUserAuthenticationCache()
+		Expect(provider.lists).To(Equal(2))
+
+		bus.Publish(event.Event{Kind: event.RoleKind, Action: event.Updated, Id: "student-role-id"})
+		Expect(provider.lists).To(Equal(3))
+		Expect(provider.gets).To(Equal(0))
+	})
+
+	It("should apply change published during resync again", func() {
+		changed := student(1, "student-role-id")
+		changed.Permission = map[string]uint64{"course": 15}
+		provider.onList = func() {
+			// users are listed before the change, then the change is published while resync is running
+			provider.lock.Lock()
+			provider.onList = nil
+			provider.lock.Unlock()
+			provider.set(changed)
+			bus.Publish(event.Event{Kind: event.UserKind, Action: event.Updated, Id: changed.Id})
+		}
+
+		cba.renewUserAuthenticationCache()
+		Expect(cached("student1").Permission["course"]).To(Equal(uint64(15)))
+	})
+})
@@ -0,0 +1,28 @@
+package custom_middleware
+
+import (
+	northApiRoute "core-api/pkg/north/api/route"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("white list test", func() {
+	It("should open every method of route set without methods", func() {
+		cba := &defaultCoreBasicAuth{}
+		cba.SetWhiteListedRoutes([]string{"/doc/*"})
+		Expect(cba.isWhiteListed("/doc/*", http.MethodGet)).To(BeTrue())
+		Expect(cba.isWhiteListed("/doc/*", http.MethodPost)).To(BeTrue())
+		Expect(cba.isWhiteListed("/v1/users", http.MethodGet)).To(BeFalse())
+	})
+
+	It("should open given methods of route only", func() {
+		cba := &defaultCoreBasicAuth{}
+		cba.SetWhiteList(northApiRoute.WhiteList{"/doc/*": nil, "/v1/versions": {http.MethodGet}})
+		Expect(cba.isWhiteListed("/doc/*", http.MethodDelete)).To(BeTrue())
+		Expect(cba.isWhiteListed("/v1/versions", http.MethodGet)).To(BeTrue())
+		Expect(cba.isWhiteListed("/v1/versions", http.MethodPost)).To(BeFalse())
+		Expect(cba.GetWhiteListedRoutes()).To(ConsistOf("/doc/*", "/v1/versions"))
+	})
+})
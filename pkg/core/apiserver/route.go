@@ -2,6 +2,7 @@ package apiserver
 
 import (
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/lockout"
 	northApiRoute "core-api/pkg/north/api/route"
 	"net/http"
 )
@@ -9,6 +10,12 @@ import (
 func GetRootRouteProvider(serverConfig *config.Config, stopChan <-chan struct{}, middlewares ...func(http.Handler) http.Handler) northApiRoute.IRouteProvider {
 	// Run the server
 	routeBuilder := northApiRoute.NewDefaultRootRoute()
+	// client ip is resolved before anything logs or limits by it
+	var trustedProxies []string
+	if serverConfig.CoreApiConfig != nil {
+		trustedProxies = serverConfig.CoreApiConfig.TrustedProxies
+	}
+	routeBuilder.AddGlobalMiddlewares(lockout.RealIp(trustedProxies))
 	// load all common middlewares
 	routeBuilder.AddCommonMiddlewares()
 	routeBuilder.AddGlobalMiddlewares(middlewares...)
@@ -4,6 +4,14 @@ import (
 	"core-api/cmd/core-api-server/app/config"
 	customMiddleware "core-api/pkg/core/apiserver/custom_middleware"
 	"core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/accesstoken"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/auth/lockout"
+	"core-api/pkg/core/auth/oidc"
+	"core-api/pkg/core/auth/session"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
+	authToken "core-api/pkg/core/auth/token"
 	"core-api/pkg/core/privileges"
 	"core-api/pkg/k8s"
 	coreApiLog "core-api/pkg/logger"
@@ -59,27 +67,108 @@ func RunServer(serverConfig *config.Config) error {
 			close(c)
 		}()
 		// init user provider
-		userProver, err := auth.CreateUserProvider(serverConfig, auth.KeystoneAuthProvider)
+		userProver, err := auth.CreateUserProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
 		if err != nil {
 			coreApiLog.Logger.Error("Failed to create user provider", "error", err)
 			return err
 		}
 
+		// init token issuer shared with login handler
+		tokenIssuer, err := authToken.InitOrGetTokenIssuer(serverConfig)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to create token issuer", "error", err)
+			return err
+		}
+
+		// init session store shared with login, refresh and logout handler
+		sessionStore, err := session.InitOrGetSessionStore(serverConfig)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to create session store", "error", err)
+			return err
+		}
+
+		// init login limiter shared with login handler
+		loginLimiter, err := lockout.InitOrGetLoginLimiter(serverConfig)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to create login limiter", "error", err)
+			return err
+		}
+
+		// init personal access token store shared with token handlers
+		accessTokenStore, err := accesstoken.InitOrGetAccessTokenStore(serverConfig)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to create personal access token store", "error", err)
+			return err
+		}
+
+		// init share store shared with share handlers and south handlers, shares of users and groups deleted from now on are removed with them
+		if _, err := share.InitOrGetShareStore(serverConfig); err != nil {
+			coreApiLog.Logger.Error("Failed to create share store", "error", err)
+			return err
+		}
+
+		// init oidc link store, links of users deleted from now on are removed with them
+		if serverConfig.AuthConfig.OIDC != nil {
+			if _, err := oidc.InitOrGetLinkStore(serverConfig); err != nil {
+				coreApiLog.Logger.Error("Failed to create oidc link store", "error", err)
+				return err
+			}
+		}
+
+		// init group admin store shared with auth middleware and handlers, administrators of users and groups deleted from now on are removed with them
+		groupAdminStore, err := groupadmin.InitOrGetGroupAdminStore(serverConfig)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to create group admin store", "error", err)
+			return err
+		}
+
 		// init basic auth middleware
-		basicAuthMiddleware, err := customMiddleware.NewCoreBaseAuth(&privileges.DefaultPrivilegeProvider{}, userProver, customMiddleware.DefaultCoreBaseAuth, rootRouteProvider, c)
+		basicAuthMiddleware, err := customMiddleware.NewCoreBaseAuth(&privileges.DefaultPrivilegeProvider{}, userProver, tokenIssuer, sessionStore, loginLimiter, accessTokenStore, customMiddleware.DefaultCoreBaseAuth, rootRouteProvider, c)
 		if err != nil {
 			coreApiLog.Logger.Error("Failed to create basic auth middleware", "error", err)
 			return err
 		}
 
-		basicAuthMiddleware.SetWhiteListedRoutes([]string{
-			"/doc/*",
-			"/apis/core-api.openhydra.io/v1/users/login",
-			"/apis/core-api.openhydra.io/v1/licenses/{licenseId}",
-			"/apis/core-api.openhydra.io/v1/licenses",
-			"/apis/core-api.openhydra.io/v1/versions/{versionId}",
-			"/apis/core-api.openhydra.io/v1/versions",
+		// route policy of config changes permission and white list of routes, core-api does not start with an invalid one
+		routePolicyRules, err := northApiRoute.LoadRoutePolicyRules(serverConfig.AuthConfig.RoutePolicy)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to load route policy", "error", err)
+			return err
+		}
+		whiteList, err := northApiRoute.ApplyRoutePolicy(rootRouteProvider, northApiRoute.WhiteList{
+			"/doc/*": nil,
+			"/apis/core-api.openhydra.io/v1/users/login":               nil,
+			"/apis/core-api.openhydra.io/v1/users/login/oidc":          nil,
+			"/apis/core-api.openhydra.io/v1/users/login/oidc/callback": nil,
+			"/apis/core-api.openhydra.io/v1/licenses/{licenseId}":      nil,
+			"/apis/core-api.openhydra.io/v1/licenses":                  nil,
+			"/apis/core-api.openhydra.io/v1/versions/{versionId}":      nil,
+			"/apis/core-api.openhydra.io/v1/versions":                  nil,
+		}, routePolicyRules)
+		if err != nil {
+			coreApiLog.Logger.Error("Invalid route policy", "error", err)
+			return err
+		}
+		basicAuthMiddleware.SetWhiteList(whiteList)
+		northApiRoute.SetAccessReviewRoutes(rootRouteProvider, whiteList)
+		basicAuthMiddleware.SetPasswordChangeRoutes([]string{
+			"/apis/core-api.openhydra.io/v1/users/me",
+			"/apis/core-api.openhydra.io/v1/users/me/password",
+			"/apis/core-api.openhydra.io/v1/users/login/refresh",
+			"/apis/core-api.openhydra.io/v1/users/logout",
 		})
+		basicAuthMiddleware.SetLegacyBasicTokenEnabled(serverConfig.CoreApiConfig.EnableLegacyBasicToken)
+		if serverConfig.CoreApiConfig.EnableLegacyBasicToken {
+			coreApiLog.Logger.Warn("legacy basic token is enabled, password is carried by every request")
+		}
+		basicAuthMiddleware.SetTenantResolver(tenant.InitOrGetTenantResolver(serverConfig))
+		basicAuthMiddleware.SetGroupAdminStore(groupAdminStore)
+		if impersonationConfig := serverConfig.AuthConfig.Impersonation; impersonationConfig != nil {
+			basicAuthMiddleware.SetImpersonation(!impersonationConfig.Disabled, impersonationConfig.AllowWrite)
+		} else {
+			basicAuthMiddleware.SetImpersonation(true, false)
+		}
+		basicAuthMiddleware.SetCacheResyncInterval(time.Duration(serverConfig.AuthConfig.UserCacheResyncSeconds) * time.Second)
 		go basicAuthMiddleware.RunBackgroundCache()
 		rootRouteProvider = GetRootRouteProvider(serverConfig, c, basicAuthMiddleware.BasicAuth)
 	} else {
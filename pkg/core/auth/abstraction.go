@@ -3,6 +3,8 @@ package auth
 import (
 	"core-api/cmd/core-api-server/app/config"
 	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/provider/ldap"
+	"core-api/pkg/core/auth/provider/local"
 	core "core-api/pkg/north/api/user/core/v1"
 	"fmt"
 )
@@ -19,6 +21,43 @@ type IUserProvider interface {
 	LoginUser(username, password string) (*core.CoreUser, error)
 }
 
+// ITenantUserProvider is implemented by providers keeping users of several tenants, e.g. keystone domains
+// user name is only unique in its tenant, so login and search by name need tenant
+type ITenantUserProvider interface {
+	SearchTenantUserByName(tenantId, name string, options map[string]struct{}) (*core.CoreUser, error)
+	LoginTenantUser(tenantId, username, password string) (*core.CoreUser, error)
+}
+
+// SearchTenantUserByName searches user in tenant, provider without tenants only has default tenant
+func SearchTenantUserByName(userProvider IUserProvider, tenantId, name string, options map[string]struct{}) (*core.CoreUser, error) {
+	if tenantProvider, ok := userProvider.(ITenantUserProvider); ok && tenantId != "" {
+		return tenantProvider.SearchTenantUserByName(tenantId, name, options)
+	}
+	return userProvider.SearchUserByName(name, options)
+}
+
+// LoginTenantUser logs in user of tenant, provider without tenants only has default tenant
+func LoginTenantUser(userProvider IUserProvider, tenantId, name, password string) (*core.CoreUser, error) {
+	if tenantProvider, ok := userProvider.(ITenantUserProvider); ok && tenantId != "" {
+		return tenantProvider.LoginTenantUser(tenantId, name, password)
+	}
+	return userProvider.LoginUser(name, password)
+}
+
+// IDisableUserProvider is implemented by providers that can deactivate a user instead of deleting it
+// a disabled user keeps roles, groups and history but can not log in
+type IDisableUserProvider interface {
+	SetUserDisabled(id string, disabled bool) error
+}
+
+// SetUserDisabled disables or enables user, it fails if provider can not do that
+func SetUserDisabled(userProvider IUserProvider, id string, disabled bool) error {
+	if disableProvider, ok := userProvider.(IDisableUserProvider); ok {
+		return disableProvider.SetUserDisabled(id, disabled)
+	}
+	return fmt.Errorf("user provider does not support disabling users")
+}
+
 type IRoleProvider interface {
 	GetRoles(options map[string]struct{}) ([]core.CoreRole, error)
 	GetRole(id string, options map[string]struct{}) (*core.CoreRole, error)
@@ -42,12 +81,36 @@ type IGroupProvider interface {
 	AddUsersToGroup(groupId string, users []core.CoreUser) ([]core.CoreUser, []core.CoreUser, error)
 }
 
+// ITenantGroupProvider is implemented by providers keeping groups of several tenants, e.g. keystone domains
+// group name is only unique in its tenant, so search by name needs tenant
+type ITenantGroupProvider interface {
+	SearchTenantGroupByName(tenantId, name string, options map[string]struct{}) (*core.CoreGroup, error)
+}
+
+// SearchTenantGroupByName searches group in tenant, provider without tenants only has default tenant
+func SearchTenantGroupByName(groupProvider IGroupProvider, tenantId, name string, options map[string]struct{}) (*core.CoreGroup, error) {
+	if tenantProvider, ok := groupProvider.(ITenantGroupProvider); ok && tenantId != "" {
+		return tenantProvider.SearchTenantGroupByName(tenantId, name, options)
+	}
+	return groupProvider.SearchGroupByName(name, options)
+}
+
 type AuthProviderType string
 
 const (
 	KeystoneAuthProvider AuthProviderType = "keystone"
+	LdapAuthProvider     AuthProviderType = "ldap"
+	LocalAuthProvider    AuthProviderType = "local"
 )
 
+// GetAuthProviderType returns provider type selected in auth config, default to keystone
+func GetAuthProviderType(config *config.Config) AuthProviderType {
+	if config == nil || config.AuthConfig == nil || config.AuthConfig.Provider == "" {
+		return KeystoneAuthProvider
+	}
+	return AuthProviderType(config.AuthConfig.Provider)
+}
+
 func CreateUserProvider(config *config.Config, authProviderType AuthProviderType) (IUserProvider, error) {
 
 	switch authProviderType {
@@ -55,6 +118,19 @@ func CreateUserProvider(config *config.Config, authProviderType AuthProviderType
 		return &keystone.UserProvider{
 			Config: config,
 		}, nil
+	case LdapAuthProvider:
+		// avoid returning a typed nil wrapped in interface
+		userProvider, err := ldap.NewUserProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return userProvider, nil
+	case LocalAuthProvider:
+		userProvider, err := local.NewUserProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return userProvider, nil
 	}
 	return nil, fmt.Errorf("%s is not a valid user provider type", authProviderType)
 }
@@ -65,6 +141,18 @@ func CreateRoleProvider(config *config.Config, authProviderType AuthProviderType
 		return &keystone.RoleProvider{
 			Config: config,
 		}, nil
+	case LdapAuthProvider:
+		roleProvider, err := ldap.NewRoleProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return roleProvider, nil
+	case LocalAuthProvider:
+		roleProvider, err := local.NewRoleProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return roleProvider, nil
 	}
 	return nil, fmt.Errorf("%s is not a valid role provider type", authProviderType)
 }
@@ -75,6 +163,18 @@ func CreateGroupProvider(config *config.Config, authProviderType AuthProviderTyp
 		return &keystone.GroupProvider{
 			Config: config,
 		}, nil
+	case LdapAuthProvider:
+		groupProvider, err := ldap.NewGroupProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return groupProvider, nil
+	case LocalAuthProvider:
+		groupProvider, err := local.NewGroupProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		return groupProvider, nil
 	}
 	return nil, fmt.Errorf("%s is not a valid group provider type", authProviderType)
 }
@@ -0,0 +1,121 @@
+package accesstoken
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"strings"
+)
+
+// TokenPrefix tells a personal access token apart from session token and legacy basic token
+// neither base64 std encoding nor a signed token contains '_'
+const TokenPrefix = "ohpat_"
+
+// IAccessTokenStore keeps personal access tokens, only a hash of secret is kept
+type IAccessTokenStore interface {
+	// Create issues a token for user, scope has to be granted to user, secret is returned in field token
+	Create(user *core.CoreUser, request *core.CorePersonalAccessToken) (*core.CorePersonalAccessToken, error)
+	// List returns tokens of user that have not expired, secret is never returned
+	List(userId string) ([]core.CorePersonalAccessToken, error)
+	// Revoke deletes token of user, a not found error is returned if user does not own it
+	Revoke(userId, tokenId string) error
+	// RevokeUser deletes all tokens of user and returns the number of them
+	RevokeUser(userId string) (int, error)
+	// Verify returns the token carried by request, an unauthorized error is returned if it is invalid or expired
+	Verify(token string) (*core.CorePersonalAccessToken, error)
+}
+
+// Invalid is returned when a token can not be created as requested
+type Invalid struct {
+	Message string
+}
+
+func (i *Invalid) Error() string {
+	return i.Message
+}
+
+func IsInvalid(err error) bool {
+	_, ok := err.(*Invalid)
+	return ok
+}
+
+type AccessTokenStoreType string
+
+const (
+	MemoryAccessTokenStore AccessTokenStoreType = "memory"
+	BoltAccessTokenStore   AccessTokenStoreType = "bolt"
+)
+
+var defaultAccessTokenStore IAccessTokenStore
+
+// InitOrGetAccessTokenStore returns store shared by token handlers and auth middleware
+// tokens are kept in file if path is set, otherwise in memory
+func InitOrGetAccessTokenStore(serverConfig *config.Config) (IAccessTokenStore, error) {
+	if defaultAccessTokenStore == nil {
+		tokenConfig, err := tokenConfigOf(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		storeType := BoltAccessTokenStore
+		if tokenConfig.Path == "" {
+			storeType = MemoryAccessTokenStore
+			if !tokenConfig.Disabled {
+				coreApiLog.Logger.Warn("personal access token path is not set, tokens are kept in memory and lost after restart")
+			}
+		}
+
+		store, err := CreateAccessTokenStore(serverConfig, storeType)
+		if err != nil {
+			return nil, err
+		}
+		defaultAccessTokenStore = store
+	}
+	return defaultAccessTokenStore, nil
+}
+
+func CreateAccessTokenStore(serverConfig *config.Config, storeType AccessTokenStoreType) (IAccessTokenStore, error) {
+	tokenConfig, err := tokenConfigOf(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	switch storeType {
+	case MemoryAccessTokenStore:
+		return newAccessTokenStore(tokenConfig, nil), nil
+	case BoltAccessTokenStore:
+		db, err := openDB(tokenConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newAccessTokenStore(tokenConfig, db), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid access token store type", storeType)
+}
+
+// IsAccessToken tells whether token carried in header is a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, TokenPrefix)
+}
+
+// ScopedPermission limits permission of user to scope of token
+// so a token never grants more than its user currently has
+func ScopedPermission(userPermission, scope map[string]uint64) map[string]uint64 {
+	result := make(map[string]uint64, len(userPermission))
+	for moduleName, permission := range userPermission {
+		result[moduleName] = permission & scope[moduleName]
+	}
+	return result
+}
+
+func tokenConfigOf(serverConfig *config.Config) (*config.PersonalAccessTokenConfig, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil, fmt.Errorf("auth config is nil")
+	}
+
+	if serverConfig.AuthConfig.PersonalAccessToken == nil {
+		return config.DefaultConfig().AuthConfig.PersonalAccessToken, nil
+	}
+	return serverConfig.AuthConfig.PersonalAccessToken, nil
+}
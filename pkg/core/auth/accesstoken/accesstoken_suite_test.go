@@ -0,0 +1,13 @@
+package accesstoken_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAccessToken(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "AccessToken Suite")
+}
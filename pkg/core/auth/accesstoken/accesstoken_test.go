@@ -0,0 +1,181 @@
+package accesstoken
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("personal access token store test", func() {
+	var store *accessTokenStore
+	var now time.Time
+	var user *core.CoreUser
+	var request *core.CorePersonalAccessToken
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		now = time.Unix(10000, 0)
+		store = newAccessTokenStore(&config.PersonalAccessTokenConfig{MaxExpireDays: 30, MaxTokensPerUser: 2}, nil)
+		store.now = func() time.Time { return now }
+
+		permission := privileges.ModulesNoPermission()
+		permission["course"] = privileges.PermissionCourseList | privileges.PermissionCourseCreate
+		user = &core.CoreUser{Id: "u1", Name: "teacher", Permission: permission}
+		request = &core.CorePersonalAccessToken{
+			Name:          "notebook",
+			Scope:         map[string]uint64{"course": privileges.PermissionCourseList},
+			ExpiresInDays: 7,
+		}
+	})
+
+	Describe("Create test", func() {
+		It("should return secret once", func() {
+			created, err := store.Create(user, request)
+			Expect(err).To(BeNil())
+			Expect(IsAccessToken(created.Token)).To(BeTrue())
+			Expect(created.UserId).To(Equal("u1"))
+			Expect(created.ExpiresAt - created.CreatedAt).To(Equal(int64(7 * 24 * 3600)))
+
+			tokens, err := store.List("u1")
+			Expect(err).To(BeNil())
+			Expect(tokens).To(HaveLen(1))
+			Expect(tokens[0].Token).To(BeEmpty())
+		})
+
+		It("should reject scope that is not granted to user", func() {
+			request.Scope = map[string]uint64{"course": privileges.PermissionCourseDelete}
+			_, err := store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should reject unknown module and permission", func() {
+			request.Scope = map[string]uint64{"unknown": 1}
+			_, err := store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+
+			user.Permission["course"] = 1 << 40
+			request.Scope = map[string]uint64{"course": 1 << 40}
+			_, err = store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should reject lifetime out of range", func() {
+			request.ExpiresInDays = 0
+			_, err := store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+
+			request.ExpiresInDays = 31
+			_, err = store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should reject duplicated name and too many tokens", func() {
+			_, err := store.Create(user, request)
+			Expect(err).To(BeNil())
+			_, err = store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+
+			request.Name = "second"
+			_, err = store.Create(user, request)
+			Expect(err).To(BeNil())
+			request.Name = "third"
+			_, err = store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+		})
+
+		It("should be rejected if disabled", func() {
+			store.disabled = true
+			_, err := store.Create(user, request)
+			Expect(IsInvalid(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Verify test", func() {
+		It("should accept valid token and record use", func() {
+			created, _ := store.Create(user, request)
+			now = now.Add(time.Hour)
+			verified, err := store.Verify(created.Token)
+			Expect(err).To(BeNil())
+			Expect(verified.Id).To(Equal(created.Id))
+			Expect(verified.LastUsedAt).To(Equal(now.Unix()))
+		})
+
+		It("should reject wrong secret and malformed token", func() {
+			created, _ := store.Create(user, request)
+			_, err := store.Verify(created.Token + "0")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+			_, err = store.Verify(TokenPrefix + created.Id)
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+			_, err = store.Verify("dGVzdDp0ZXN0")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject expired token and forget it", func() {
+			created, _ := store.Create(user, request)
+			now = now.Add(8 * 24 * time.Hour)
+			_, err := store.Verify(created.Token)
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+			Expect(store.records).To(BeEmpty())
+		})
+
+		It("should reject revoked token", func() {
+			created, _ := store.Create(user, request)
+			Expect(customErr.IsNotFound(store.Revoke("u2", created.Id))).To(BeTrue())
+			Expect(store.Revoke("u1", created.Id)).To(BeNil())
+			_, err := store.Verify(created.Token)
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should revoke all tokens of user", func() {
+			store.Create(user, request)
+			request.Name = "second"
+			store.Create(user, request)
+			count, err := store.RevokeUser("u1")
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(2))
+			tokens, _ := store.List("u1")
+			Expect(tokens).To(BeEmpty())
+		})
+	})
+
+	Describe("bolt store test", func() {
+		It("should keep tokens after reopen", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "tokens.db")
+			db, err := openDB(path)
+			Expect(err).To(BeNil())
+			s := newAccessTokenStore(&config.PersonalAccessTokenConfig{}, db)
+			created, err := s.Create(user, request)
+			Expect(err).To(BeNil())
+			Expect(db.Close()).To(BeNil())
+
+			db, err = openDB(path)
+			Expect(err).To(BeNil())
+			defer db.Close()
+			s = newAccessTokenStore(&config.PersonalAccessTokenConfig{}, db)
+			verified, err := s.Verify(created.Token)
+			Expect(err).To(BeNil())
+			Expect(verified.Scope).To(Equal(request.Scope))
+		})
+	})
+
+	Describe("ScopedPermission test", func() {
+		It("should never exceed permission of user", func() {
+			scoped := ScopedPermission(map[string]uint64{"course": 3, "user": 31}, map[string]uint64{"course": 6})
+			Expect(scoped).To(Equal(map[string]uint64{"course": 2, "user": 0}))
+		})
+	})
+
+	Describe("CreateAccessTokenStore test", func() {
+		It("should be error with unknown type", func() {
+			_, err := CreateAccessTokenStore(config.DefaultConfig(), "unknown")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
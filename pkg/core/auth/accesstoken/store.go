@@ -0,0 +1,337 @@
+package accesstoken
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var tokensBucket = []byte("personalAccessTokens")
+
+// last used time is written to file at most once in this interval
+const lastUsedPersistInterval = time.Minute
+
+type record struct {
+	Token core.CorePersonalAccessToken `json:"token"`
+	// secret is random and long, a plain sha256 is enough
+	SecretHash string `json:"secretHash"`
+
+	persistedUse int64
+}
+
+// accessTokenStore keeps all tokens in memory, they are written through to file if db is set
+type accessTokenStore struct {
+	lock    sync.Mutex
+	records map[string]*record
+	db      *bbolt.DB
+	now     func() time.Time
+
+	disabled  bool
+	maxExpire time.Duration
+	maxTokens int
+}
+
+func openDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open personal access token store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tokensBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newAccessTokenStore(tokenConfig *config.PersonalAccessTokenConfig, db *bbolt.DB) *accessTokenStore {
+	defaults := config.DefaultConfig().AuthConfig.PersonalAccessToken
+	maxExpireDays := tokenConfig.MaxExpireDays
+	if maxExpireDays <= 0 {
+		maxExpireDays = defaults.MaxExpireDays
+	}
+	maxTokens := tokenConfig.MaxTokensPerUser
+	if maxTokens <= 0 {
+		maxTokens = defaults.MaxTokensPerUser
+	}
+
+	s := &accessTokenStore{
+		records:   map[string]*record{},
+		db:        db,
+		now:       time.Now,
+		disabled:  tokenConfig.Disabled,
+		maxExpire: time.Duration(maxExpireDays) * 24 * time.Hour,
+		maxTokens: maxTokens,
+	}
+
+	if db != nil {
+		err := db.View(func(tx *bbolt.Tx) error {
+			return tx.Bucket(tokensBucket).ForEach(func(k, v []byte) error {
+				r := &record{}
+				if err := json.Unmarshal(v, r); err != nil {
+					return err
+				}
+				r.persistedUse = r.Token.LastUsedAt
+				s.records[string(k)] = r
+				return nil
+			})
+		})
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to load personal access tokens", "error", err)
+		}
+	}
+	return s
+}
+
+func (s *accessTokenStore) Create(user *core.CoreUser, request *core.CorePersonalAccessToken) (*core.CorePersonalAccessToken, error) {
+	if s.disabled {
+		return nil, &Invalid{Message: "personal access token is disabled"}
+	}
+
+	if strings.TrimSpace(request.Name) == "" {
+		return nil, &Invalid{Message: "token name is required"}
+	}
+
+	expire := time.Duration(request.ExpiresInDays) * 24 * time.Hour
+	if expire <= 0 || expire > s.maxExpire {
+		return nil, &Invalid{Message: fmt.Sprintf("token should expire in 1 to %d days", int(s.maxExpire/(24*time.Hour)))}
+	}
+
+	if err := validateScope(request.Scope, user.Permission); err != nil {
+		return nil, err
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	owned := 0
+	for _, r := range s.active(now) {
+		if r.Token.UserId != user.Id {
+			continue
+		}
+		if r.Token.Name == request.Name {
+			return nil, &Invalid{Message: fmt.Sprintf("token %s already exists", request.Name)}
+		}
+		owned++
+	}
+	if owned >= s.maxTokens {
+		return nil, &Invalid{Message: fmt.Sprintf("a user can hold at most %d tokens", s.maxTokens)}
+	}
+
+	r := &record{
+		Token: core.CorePersonalAccessToken{
+			Id:        id,
+			UserId:    user.Id,
+			Name:      request.Name,
+			Scope:     request.Scope,
+			CreatedAt: now.Unix(),
+			ExpiresAt: now.Add(expire).Unix(),
+		},
+		SecretHash: hashSecret(secret),
+	}
+	if err = s.persist(r); err != nil {
+		return nil, err
+	}
+	s.records[id] = r
+
+	result := r.Token
+	result.Token = TokenPrefix + id + "_" + secret
+	return &result, nil
+}
+
+func (s *accessTokenStore) List(userId string) ([]core.CorePersonalAccessToken, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := []core.CorePersonalAccessToken{}
+	for _, r := range s.active(s.now()) {
+		if r.Token.UserId == userId {
+			result = append(result, r.Token)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+	return result, nil
+}
+
+func (s *accessTokenStore) Revoke(userId, tokenId string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r, found := s.records[tokenId]
+	if !found || r.Token.UserId != userId {
+		return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Token %s not found", tokenId))
+	}
+	return s.remove(tokenId)
+}
+
+func (s *accessTokenStore) RevokeUser(userId string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for id, r := range s.records {
+		if r.Token.UserId != userId {
+			continue
+		}
+		if err := s.remove(id); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *accessTokenStore) Verify(token string) (*core.CorePersonalAccessToken, error) {
+	unauthorized := customErr.NewUnauthorized(http.StatusUnauthorized, "invalid or expired personal access token")
+	if s.disabled || !IsAccessToken(token) {
+		return nil, unauthorized
+	}
+
+	id, secret, found := strings.Cut(strings.TrimPrefix(token, TokenPrefix), "_")
+	if !found {
+		return nil, unauthorized
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r, found := s.records[id]
+	if !found || subtle.ConstantTimeCompare([]byte(r.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, unauthorized
+	}
+
+	now := s.now()
+	if now.Unix() >= r.Token.ExpiresAt {
+		if err := s.remove(id); err != nil {
+			coreApiLog.Logger.Error("Failed to remove expired personal access token", "token", id, "error", err)
+		}
+		return nil, unauthorized
+	}
+
+	r.Token.LastUsedAt = now.Unix()
+	if time.Duration(r.Token.LastUsedAt-r.persistedUse)*time.Second >= lastUsedPersistInterval {
+		if err := s.persist(r); err != nil {
+			coreApiLog.Logger.Error("Failed to record use of personal access token", "token", id, "error", err)
+		} else {
+			r.persistedUse = r.Token.LastUsedAt
+		}
+	}
+
+	result := r.Token
+	return &result, nil
+}
+
+// active drops expired tokens and returns the rest, should be called with lock held
+func (s *accessTokenStore) active(now time.Time) []*record {
+	var result []*record
+	for id, r := range s.records {
+		if now.Unix() >= r.Token.ExpiresAt {
+			if err := s.remove(id); err != nil {
+				coreApiLog.Logger.Error("Failed to remove expired personal access token", "token", id, "error", err)
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// persist should be called with lock held
+func (s *accessTokenStore) persist(r *record) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(tokensBucket).Put([]byte(r.Token.Id), data)
+	})
+}
+
+// remove should be called with lock held
+func (s *accessTokenStore) remove(id string) error {
+	if s.db != nil {
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(tokensBucket).Delete([]byte(id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// validateScope accepts known modules only, every permission in scope has to be granted to user
+func validateScope(scope, userPermission map[string]uint64) error {
+	if len(scope) == 0 {
+		return &Invalid{Message: "token scope is required"}
+	}
+
+	for moduleName, permission := range scope {
+		var modulePermission uint64
+		known, found := privileges.Modules[moduleName]
+		if !found {
+			return &Invalid{Message: fmt.Sprintf("module %s not found", moduleName)}
+		}
+		for _, per := range known {
+			modulePermission |= per
+		}
+
+		if permission&^modulePermission != 0 {
+			return &Invalid{Message: fmt.Sprintf("unknown permission %d of module %s", permission&^modulePermission, moduleName)}
+		}
+
+		if permission&^userPermission[moduleName] != 0 {
+			return &Invalid{Message: fmt.Sprintf("permission %d of module %s is not granted to user", permission, moduleName)}
+		}
+	}
+	return nil
+}
+
+func randomHex(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
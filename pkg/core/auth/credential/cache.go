@@ -0,0 +1,113 @@
+package credential
+
+import (
+	core "core-api/pkg/north/api/user/core/v1"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultExpire is how long a verified credential is trusted before provider is asked again
+	DefaultExpire = 5 * time.Minute
+)
+
+// Cache remembers credentials that have been verified by user provider
+// only a salted bcrypt hash of password computed at login is kept, never the password itself
+// every entry expires on its own so a password changed out of core-api is picked up in time
+type Cache struct {
+	lock    sync.Mutex
+	entries map[string]entry
+	expire  time.Duration
+	now     func() time.Time
+}
+
+type entry struct {
+	userId    string
+	hash      []byte
+	expiresAt time.Time
+}
+
+var defaultCache *Cache
+var defaultCacheLock sync.Mutex
+
+// InitOrGetCache returns cache shared by auth middleware and handlers that change credentials
+func InitOrGetCache() *Cache {
+	defaultCacheLock.Lock()
+	defer defaultCacheLock.Unlock()
+
+	if defaultCache == nil {
+		defaultCache = NewCache(DefaultExpire)
+	}
+	return defaultCache
+}
+
+func NewCache(expire time.Duration) *Cache {
+	return &Cache{
+		entries: map[string]entry{},
+		expire:  expire,
+		now:     time.Now,
+	}
+}
+
+// Put remembers that password of user has been verified
+func (c *Cache) Put(user *core.CoreUser, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[user.Name] = entry{
+		userId:    user.Id,
+		hash:      hash,
+		expiresAt: c.now().Add(c.expire),
+	}
+	return nil
+}
+
+// Verify returns id of user if the credential has been verified before and has not expired
+func (c *Cache) Verify(userName, password string) (string, bool) {
+	c.lock.Lock()
+	e, found := c.entries[userName]
+	if found && !c.now().Before(e.expiresAt) {
+		delete(c.entries, userName)
+		found = false
+	}
+	c.lock.Unlock()
+
+	if !found {
+		return "", false
+	}
+
+	// compare outside of lock, bcrypt is slow on purpose
+	if bcrypt.CompareHashAndPassword(e.hash, []byte(password)) != nil {
+		return "", false
+	}
+	return e.userId, true
+}
+
+// RemoveUser forgets credential of user, it should be called when password is changed or user is deleted
+func (c *Cache) RemoveUser(userId string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for name, e := range c.entries {
+		if e.userId == userId {
+			delete(c.entries, name)
+		}
+	}
+}
+
+// RemoveExpired drops all expired entries
+func (c *Cache) RemoveExpired() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := c.now()
+	for name, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, name)
+		}
+	}
+}
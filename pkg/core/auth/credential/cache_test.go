@@ -0,0 +1,68 @@
+package credential
+
+import (
+	core "core-api/pkg/north/api/user/core/v1"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("credential cache test", func() {
+	var cache *Cache
+	var now time.Time
+	alice := &core.CoreUser{Id: "alice-id", Name: "alice"}
+
+	BeforeEach(func() {
+		now = time.Unix(10000, 0)
+		cache = NewCache(time.Minute)
+		cache.now = func() time.Time { return now }
+		Expect(cache.Put(alice, "alice-password")).To(BeNil())
+	})
+
+	It("should keep hash instead of password", func() {
+		e := cache.entries["alice"]
+		Expect(string(e.hash)).NotTo(ContainSubstring("alice-password"))
+		Expect(strings.HasPrefix(string(e.hash), "$2a$")).To(BeTrue())
+	})
+
+	It("should verify correct password only", func() {
+		userId, ok := cache.Verify("alice", "alice-password")
+		Expect(ok).To(BeTrue())
+		Expect(userId).To(Equal("alice-id"))
+
+		_, ok = cache.Verify("alice", "wrong")
+		Expect(ok).To(BeFalse())
+
+		_, ok = cache.Verify("bob", "alice-password")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should expire entry individually", func() {
+		now = now.Add(30 * time.Second)
+		Expect(cache.Put(&core.CoreUser{Id: "bob-id", Name: "bob"}, "bob-password")).To(BeNil())
+
+		now = now.Add(30 * time.Second)
+		_, ok := cache.Verify("alice", "alice-password")
+		Expect(ok).To(BeFalse())
+		Expect(cache.entries).NotTo(HaveKey("alice"))
+
+		_, ok = cache.Verify("bob", "bob-password")
+		Expect(ok).To(BeTrue())
+
+		now = now.Add(30 * time.Second)
+		cache.RemoveExpired()
+		Expect(cache.entries).To(BeEmpty())
+	})
+
+	It("should forget credential of user", func() {
+		cache.RemoveUser("alice-id")
+		_, ok := cache.Verify("alice", "alice-password")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should share default cache", func() {
+		Expect(InitOrGetCache()).To(BeIdenticalTo(InitOrGetCache()))
+	})
+})
@@ -0,0 +1,68 @@
+package event
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Kind is kind of identity object that is changed
+type Kind string
+
+const (
+	UserKind  Kind = "user"
+	RoleKind  Kind = "role"
+	GroupKind Kind = "group"
+)
+
+type Action string
+
+const (
+	Created Action = "created"
+	Updated Action = "updated"
+	Deleted Action = "deleted"
+)
+
+// Event tells an identity object is changed through core-api, name is set if it is known
+type Event struct {
+	Kind   Kind
+	Action Action
+	Id     string
+	Name   string
+}
+
+// IEventBus delivers change events to subscribers in the same process
+// other replicas pick up the change with periodic resync of their cache
+type IEventBus interface {
+	// Publish calls every subscriber before it returns, so a change is applied before response is written
+	Publish(event Event)
+	// Subscribe registers handler and returns a function to unsubscribe it
+	Subscribe(handler func(event Event)) func()
+}
+
+type EventBusType string
+
+const (
+	MemoryEventBus EventBusType = "memory"
+)
+
+var defaultEventBus IEventBus
+var defaultEventBusLock sync.Mutex
+
+// InitOrGetEventBus returns bus shared by handlers publishing changes and auth middleware
+func InitOrGetEventBus() IEventBus {
+	defaultEventBusLock.Lock()
+	defer defaultEventBusLock.Unlock()
+
+	if defaultEventBus == nil {
+		defaultEventBus, _ = CreateEventBus(MemoryEventBus)
+	}
+	return defaultEventBus
+}
+
+func CreateEventBus(busType EventBusType) (IEventBus, error) {
+	switch busType {
+	case MemoryEventBus:
+		return newMemoryBus(), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid event bus type", busType)
+}
@@ -0,0 +1,56 @@
+package event
+
+import (
+	coreApiLog "core-api/pkg/logger"
+	"sync"
+)
+
+type memoryBus struct {
+	lock        sync.Mutex
+	nextId      int
+	subscribers map[int]func(event Event)
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{subscribers: map[int]func(event Event){}}
+}
+
+func (b *memoryBus) Publish(event Event) {
+	b.lock.Lock()
+	handlers := make([]func(event Event), 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.lock.Unlock()
+
+	coreApiLog.Logger.Debug("publishing change", "kind", event.Kind, "action", event.Action, "id", event.Id)
+	// handlers are called without lock so a handler may publish or subscribe itself
+	for _, handler := range handlers {
+		notify(handler, event)
+	}
+}
+
+// notify keeps a failing subscriber from breaking the request that publishes the change
+func notify(handler func(event Event), event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			coreApiLog.Logger.Error("subscriber failed to handle change", "kind", event.Kind, "id", event.Id, "panic", r)
+		}
+	}()
+	handler(event)
+}
+
+func (b *memoryBus) Subscribe(handler func(event Event)) func() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := b.nextId
+	b.nextId++
+	b.subscribers[id] = handler
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.subscribers, id)
+	}
+}
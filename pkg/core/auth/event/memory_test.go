@@ -0,0 +1,62 @@
+package event
+
+import (
+	coreApiLog "core-api/pkg/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("memory event bus test", func() {
+	var bus IEventBus
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		bus, _ = CreateEventBus(MemoryEventBus)
+	})
+
+	It("should deliver event to every subscriber before publish returns", func() {
+		var first, second []Event
+		bus.Subscribe(func(event Event) { first = append(first, event) })
+		bus.Subscribe(func(event Event) { second = append(second, event) })
+
+		changed := Event{Kind: RoleKind, Action: Updated, Id: "teacher-id", Name: "teacher"}
+		bus.Publish(changed)
+		Expect(first).To(Equal([]Event{changed}))
+		Expect(second).To(Equal([]Event{changed}))
+	})
+
+	It("should stop delivering after unsubscribe", func() {
+		count := 0
+		unsubscribe := bus.Subscribe(func(event Event) { count++ })
+		bus.Publish(Event{Kind: UserKind, Action: Created, Id: "alice-id"})
+		unsubscribe()
+		bus.Publish(Event{Kind: UserKind, Action: Deleted, Id: "alice-id"})
+		Expect(count).To(Equal(1))
+	})
+
+	It("should keep delivering if a subscriber panics", func() {
+		count := 0
+		bus.Subscribe(func(event Event) { panic("broken") })
+		bus.Subscribe(func(event Event) { count++ })
+		Expect(func() { bus.Publish(Event{Kind: GroupKind, Action: Deleted, Id: "class-id"}) }).NotTo(Panic())
+		Expect(count).To(Equal(1))
+	})
+
+	It("should allow subscriber to publish", func() {
+		var received []Kind
+		bus.Subscribe(func(event Event) {
+			received = append(received, event.Kind)
+			if event.Kind == RoleKind {
+				bus.Publish(Event{Kind: UserKind, Action: Updated, Id: "alice-id"})
+			}
+		})
+		bus.Publish(Event{Kind: RoleKind, Action: Updated, Id: "teacher-id"})
+		Expect(received).To(Equal([]Kind{RoleKind, UserKind}))
+	})
+
+	It("should be a valid type", func() {
+		_, err := CreateEventBus("kafka")
+		Expect(err).NotTo(BeNil())
+	})
+})
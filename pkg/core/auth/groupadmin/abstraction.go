@@ -0,0 +1,174 @@
+package groupadmin
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/event"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// IGroupAdminStore keeps users designated administrator of groups
+type IGroupAdminStore interface {
+	// Add designates user administrator of group, designating an administrator again changes nothing
+	Add(admin *core.CoreGroupAdmin) (*core.CoreGroupAdmin, error)
+	// Remove takes user off administrators of group, a not found error is returned if user is not one
+	Remove(groupId, userId string) error
+	// ListAdmins returns administrators of group ordered by designation
+	ListAdmins(groupId string) ([]core.CoreGroupAdmin, error)
+	// GroupsOf returns ids of groups user administers
+	GroupsOf(userId string) []string
+	// DeleteUser takes user off administrators of every group and returns the number of groups
+	DeleteUser(userId string) (int, error)
+	// DeleteGroup removes administrators of group and returns the number of them
+	DeleteGroup(groupId string) (int, error)
+}
+
+// Invalid is returned when an administrator can not be added as requested
+type Invalid struct {
+	Message string
+}
+
+func (i *Invalid) Error() string {
+	return i.Message
+}
+
+func IsInvalid(err error) bool {
+	_, ok := err.(*Invalid)
+	return ok
+}
+
+type GroupAdminStoreType string
+
+const (
+	MemoryGroupAdminStore GroupAdminStoreType = "memory"
+	BoltGroupAdminStore   GroupAdminStoreType = "bolt"
+)
+
+var defaultGroupAdminStore IGroupAdminStore
+var defaultGroupAdminStoreLock sync.Mutex
+
+// InitOrGetGroupAdminStore returns store shared by auth middleware, core-api handlers and south handlers
+// administrators are kept in file if path is set, otherwise in memory
+// a deleted user or group is removed from administrators with it
+func InitOrGetGroupAdminStore(serverConfig *config.Config) (IGroupAdminStore, error) {
+	defaultGroupAdminStoreLock.Lock()
+	defer defaultGroupAdminStoreLock.Unlock()
+	if defaultGroupAdminStore == nil {
+		groupAdminConfig, err := groupAdminConfigOf(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		storeType := BoltGroupAdminStore
+		if groupAdminConfig.Path == "" {
+			storeType = MemoryGroupAdminStore
+			coreApiLog.Logger.Warn("group admin path is not set, group administrators are kept in memory and lost after restart")
+		}
+
+		store, err := CreateGroupAdminStore(serverConfig, storeType)
+		if err != nil {
+			return nil, err
+		}
+		event.InitOrGetEventBus().Subscribe(func(changed event.Event) {
+			removeAdminsOf(store, changed)
+		})
+		defaultGroupAdminStore = store
+	}
+	return defaultGroupAdminStore, nil
+}
+
+func CreateGroupAdminStore(serverConfig *config.Config, storeType GroupAdminStoreType) (IGroupAdminStore, error) {
+	groupAdminConfig, err := groupAdminConfigOf(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	switch storeType {
+	case MemoryGroupAdminStore:
+		return newGroupAdminStore(nil), nil
+	case BoltGroupAdminStore:
+		db, err := openDB(groupAdminConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newGroupAdminStore(db), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid group admin store type", storeType)
+}
+
+// removeAdminsOf drops administrators a deleted user or group is part of
+func removeAdminsOf(store IGroupAdminStore, changed event.Event) {
+	if changed.Action != event.Deleted {
+		return
+	}
+	var count int
+	var err error
+	switch changed.Kind {
+	case event.UserKind:
+		count, err = store.DeleteUser(changed.Id)
+	case event.GroupKind:
+		count, err = store.DeleteGroup(changed.Id)
+	default:
+		return
+	}
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to remove group administrators", "kind", changed.Kind, "id", changed.Id, "error", err)
+		return
+	}
+	if count > 0 {
+		coreApiLog.Logger.Info("group administrators removed", "kind", changed.Kind, "id", changed.Id, "total", count)
+	}
+}
+
+// CanAdminister tells why actor may not act on member as administrator of a group member belongs to, it is nil if actor may
+// member has to be loaded with permission, a built-in or uneditable member and a member holding permission actor lacks are refused
+// so a group administrator can take over neither the administrator nor another teacher of the group
+func CanAdminister(store IGroupAdminStore, actor, member *core.CoreUser) error {
+	if actor == nil || member == nil {
+		return fmt.Errorf("user to act on is unknown")
+	}
+	if actor.Id == member.Id {
+		return fmt.Errorf("user '%s' can not administer itself", actor.Name)
+	}
+	if member.UnEditable {
+		return fmt.Errorf("user '%s' can not administer built-in user '%s'", actor.Name, member.Name)
+	}
+
+	administers := false
+	for _, groupId := range store.GroupsOf(actor.Id) {
+		for _, group := range member.Groups {
+			if group.Id == groupId {
+				administers = true
+			}
+		}
+	}
+	if !administers {
+		return fmt.Errorf("user '%s' administers no group of user '%s'", actor.Name, member.Name)
+	}
+
+	var exceeded []string
+	for moduleName, permission := range member.Permission {
+		if actor.Permission[moduleName]&permission != permission {
+			exceeded = append(exceeded, moduleName)
+		}
+	}
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		return fmt.Errorf("user '%s' can not administer user '%s' who holds more permission on modules %v", actor.Name, member.Name, exceeded)
+	}
+	return nil
+}
+
+func groupAdminConfigOf(serverConfig *config.Config) (*config.GroupAdminConfig, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil, fmt.Errorf("auth config is nil")
+	}
+
+	if serverConfig.AuthConfig.GroupAdmin == nil {
+		return &config.GroupAdminConfig{}, nil
+	}
+	return serverConfig.AuthConfig.GroupAdmin, nil
+}
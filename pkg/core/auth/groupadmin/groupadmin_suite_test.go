@@ -0,0 +1,13 @@
+package groupadmin_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGroupAdmin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GroupAdmin Suite")
+}
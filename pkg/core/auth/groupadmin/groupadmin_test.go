@@ -0,0 +1,103 @@
+package groupadmin
+
+import (
+	"core-api/pkg/core/auth/event"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("group admin store test", func() {
+	var store *groupAdminStore
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		store = newGroupAdminStore(nil)
+	})
+
+	It("should designate an administrator once", func() {
+		created, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher", CreatedBy: "admin"})
+		Expect(err).To(BeNil())
+		Expect(created.CreatedAt).NotTo(BeZero())
+
+		again, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher", CreatedBy: "other"})
+		Expect(err).To(BeNil())
+		Expect(again).To(Equal(created))
+
+		_, err = store.Add(&core.CoreGroupAdmin{GroupId: "class2", UserId: "teacher"})
+		Expect(err).To(BeNil())
+		Expect(store.GroupsOf("teacher")).To(Equal([]string{"class1", "class2"}))
+
+		admins, err := store.ListAdmins("class1")
+		Expect(err).To(BeNil())
+		Expect(admins).To(HaveLen(1))
+		Expect(admins[0].CreatedBy).To(Equal("admin"))
+
+		_, err = store.Add(&core.CoreGroupAdmin{GroupId: "", UserId: "teacher"})
+		Expect(IsInvalid(err)).To(BeTrue())
+	})
+
+	It("should tell whether user administers a group of member", func() {
+		_, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher"})
+		Expect(err).To(BeNil())
+
+		teacher := &core.CoreUser{Id: "teacher", Permission: map[string]uint64{"user": 1, "device": 3}}
+		student := &core.CoreUser{Id: "student", Groups: []core.CoreGroup{{Id: "class2"}, {Id: "class1"}}, Permission: map[string]uint64{"device": 1}}
+		other := &core.CoreUser{Id: "other", Groups: []core.CoreGroup{{Id: "class2"}}}
+		Expect(CanAdminister(store, teacher, student)).To(Succeed())
+		Expect(CanAdminister(store, teacher, other)).NotTo(Succeed())
+		Expect(CanAdminister(store, student, student)).NotTo(Succeed())
+		Expect(CanAdminister(store, teacher, nil)).NotTo(Succeed())
+	})
+
+	It("should refuse member holding more permission or built-in member", func() {
+		_, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher"})
+		Expect(err).To(BeNil())
+
+		teacher := &core.CoreUser{Id: "teacher", Permission: map[string]uint64{"user": 1, "device": 3}}
+		anotherTeacher := &core.CoreUser{Id: "another-teacher", Groups: []core.CoreGroup{{Id: "class1"}}, Permission: map[string]uint64{"user": 3}}
+		admin := &core.CoreUser{Id: "admin", Name: "admin", Groups: []core.CoreGroup{{Id: "class1"}}, UnEditable: true}
+		Expect(CanAdminister(store, teacher, anotherTeacher)).NotTo(Succeed())
+		Expect(CanAdminister(store, teacher, admin)).NotTo(Succeed())
+	})
+
+	It("should remove administrators of deleted user and group", func() {
+		_, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher"})
+		Expect(err).To(BeNil())
+		_, err = store.Add(&core.CoreGroupAdmin{GroupId: "class2", UserId: "teacher"})
+		Expect(err).To(BeNil())
+		_, err = store.Add(&core.CoreGroupAdmin{GroupId: "class2", UserId: "assistant"})
+		Expect(err).To(BeNil())
+
+		removeAdminsOf(store, event.Event{Kind: event.GroupKind, Action: event.Updated, Id: "class2"})
+		Expect(store.admins).To(HaveLen(3))
+		removeAdminsOf(store, event.Event{Kind: event.GroupKind, Action: event.Deleted, Id: "class2"})
+		Expect(store.admins).To(HaveLen(1))
+		removeAdminsOf(store, event.Event{Kind: event.UserKind, Action: event.Deleted, Id: "teacher"})
+		Expect(store.admins).To(BeEmpty())
+
+		Expect(customErr.IsNotFound(store.Remove("class1", "teacher"))).To(BeTrue())
+	})
+
+	It("should keep administrators in file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "group-admins.db")
+		db, err := openDB(path)
+		Expect(err).To(BeNil())
+		store = newGroupAdminStore(db)
+		created, err := store.Add(&core.CoreGroupAdmin{GroupId: "class1", UserId: "teacher"})
+		Expect(err).To(BeNil())
+		Expect(db.Close()).To(Succeed())
+
+		db, err = openDB(path)
+		Expect(err).To(BeNil())
+		defer db.Close()
+		store = newGroupAdminStore(db)
+		admins, err := store.ListAdmins("class1")
+		Expect(err).To(BeNil())
+		Expect(admins).To(Equal([]core.CoreGroupAdmin{*created}))
+	})
+})
@@ -0,0 +1,196 @@
+package groupadmin
+
+import (
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var groupAdminsBucket = []byte("group_admins")
+
+// groupAdminStore keeps all administrators in memory, they are written through to file if db is set
+type groupAdminStore struct {
+	lock sync.RWMutex
+	// keyed by group id and user id
+	admins map[string]*core.CoreGroupAdmin
+	db     *bbolt.DB
+	now    func() time.Time
+}
+
+func openDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open group admin store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(groupAdminsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newGroupAdminStore(db *bbolt.DB) *groupAdminStore {
+	s := &groupAdminStore{
+		admins: map[string]*core.CoreGroupAdmin{},
+		db:     db,
+		now:    time.Now,
+	}
+
+	if db != nil {
+		err := db.View(func(tx *bbolt.Tx) error {
+			return tx.Bucket(groupAdminsBucket).ForEach(func(k, v []byte) error {
+				admin := &core.CoreGroupAdmin{}
+				if err := json.Unmarshal(v, admin); err != nil {
+					return err
+				}
+				s.admins[string(k)] = admin
+				return nil
+			})
+		})
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to load group administrators", "error", err)
+		}
+	}
+	return s
+}
+
+func keyOf(groupId, userId string) string {
+	return groupId + "/" + userId
+}
+
+func (s *groupAdminStore) Add(admin *core.CoreGroupAdmin) (*core.CoreGroupAdmin, error) {
+	if strings.TrimSpace(admin.GroupId) == "" {
+		return nil, &Invalid{Message: "group id is required"}
+	}
+	if strings.TrimSpace(admin.UserId) == "" {
+		return nil, &Invalid{Message: "user id is required"}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := keyOf(admin.GroupId, admin.UserId)
+	if existing, found := s.admins[key]; found {
+		result := *existing
+		return &result, nil
+	}
+
+	created := *admin
+	created.CreatedAt = s.now().Unix()
+	if s.db != nil {
+		data, err := json.Marshal(&created)
+		if err != nil {
+			return nil, err
+		}
+		err = s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(groupAdminsBucket).Put([]byte(key), data)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.admins[key] = &created
+	result := created
+	return &result, nil
+}
+
+func (s *groupAdminStore) Remove(groupId, userId string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := keyOf(groupId, userId)
+	if _, found := s.admins[key]; !found {
+		return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s is not administrator of group %s", userId, groupId))
+	}
+	return s.remove(key)
+}
+
+func (s *groupAdminStore) ListAdmins(groupId string) ([]core.CoreGroupAdmin, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := []core.CoreGroupAdmin{}
+	for _, admin := range s.admins {
+		if admin.GroupId == groupId {
+			result = append(result, *admin)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt < result[j].CreatedAt
+		}
+		return result[i].UserId < result[j].UserId
+	})
+	return result, nil
+}
+
+func (s *groupAdminStore) GroupsOf(userId string) []string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var groupIds []string
+	for _, admin := range s.admins {
+		if admin.UserId == userId {
+			groupIds = append(groupIds, admin.GroupId)
+		}
+	}
+	sort.Strings(groupIds)
+	return groupIds
+}
+
+func (s *groupAdminStore) DeleteUser(userId string) (int, error) {
+	return s.removeAll(func(admin *core.CoreGroupAdmin) bool {
+		return admin.UserId == userId
+	})
+}
+
+func (s *groupAdminStore) DeleteGroup(groupId string) (int, error) {
+	return s.removeAll(func(admin *core.CoreGroupAdmin) bool {
+		return admin.GroupId == groupId
+	})
+}
+
+func (s *groupAdminStore) removeAll(match func(admin *core.CoreGroupAdmin) bool) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for key, admin := range s.admins {
+		if !match(admin) {
+			continue
+		}
+		if err := s.remove(key); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// remove should be called with lock held
+func (s *groupAdminStore) remove(key string) error {
+	if s.db != nil {
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(groupAdminsBucket).Delete([]byte(key))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	delete(s.admins, key)
+	return nil
+}
@@ -0,0 +1,65 @@
+package impersonation
+
+import (
+	"context"
+	"core-api/pkg/core/privileges"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// HeaderName is header a request sets to act as another user, value is name of that user
+const HeaderName = "Impersonate-User"
+
+// ResponseHeaderName is set on every response to an impersonated request, value is name of impersonator
+const ResponseHeaderName = "Impersonated-By"
+
+// CanImpersonate returns error if actor is not allowed to act as target
+// a user can not gain any permission by impersonation, so target must not hold a permission actor lacks
+// target must be able to use api itself, so disabled user or user who must change password can not be impersonated
+func CanImpersonate(actor, target *core.CoreUser) error {
+	if actor.Id == target.Id {
+		return fmt.Errorf("user '%s' can not impersonate itself", actor.Name)
+	}
+
+	if target.Disabled {
+		return fmt.Errorf("user '%s' is disabled and can not be impersonated", target.Name)
+	}
+
+	if target.MustChangePassword {
+		return fmt.Errorf("user '%s' must change password and can not be impersonated", target.Name)
+	}
+
+	if actor.Permission["user"]&privileges.PermissionUserImpersonate != privileges.PermissionUserImpersonate {
+		return fmt.Errorf("user '%s' has no permission to impersonate", actor.Name)
+	}
+
+	var exceeded []string
+	for moduleName, permission := range target.Permission {
+		if actor.Permission[moduleName]&permission != permission {
+			exceeded = append(exceeded, moduleName)
+		}
+	}
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		return fmt.Errorf("user '%s' can not impersonate user '%s' who holds more permission on modules %v", actor.Name, target.Name, exceeded)
+	}
+	return nil
+}
+
+// IsReadOnlyMethod tells whether method does not change anything
+func IsReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// WithImpersonator returns context carrying user who acts as user of request
+func WithImpersonator(ctx context.Context, impersonator *core.CoreUser) context.Context {
+	return context.WithValue(ctx, "core-impersonator", impersonator)
+}
+
+// ImpersonatorFromRequest returns user who acts as user of request, it is nil if request is not impersonated
+func ImpersonatorFromRequest(r *http.Request) *core.CoreUser {
+	impersonator, _ := r.Context().Value("core-impersonator").(*core.CoreUser)
+	return impersonator
+}
@@ -0,0 +1,90 @@
+package impersonation
+
+import (
+	"context"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("impersonation test", func() {
+	var support, student, teacher *core.CoreUser
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		support = &core.CoreUser{
+			Id:   "support-id",
+			Name: "support",
+			Permission: map[string]uint64{
+				"user":   privileges.PermissionUserList | privileges.PermissionUserImpersonate,
+				"course": privileges.PermissionCourseList,
+				"rag":    privileges.PermissionRagList | privileges.PermissionRagQuery,
+			},
+		}
+		student = &core.CoreUser{
+			Id:   "student-id",
+			Name: "student",
+			Permission: map[string]uint64{
+				"course": privileges.PermissionCourseList,
+				"rag":    privileges.PermissionRagQuery,
+			},
+		}
+		teacher = &core.CoreUser{
+			Id:   "teacher-id",
+			Name: "teacher",
+			Permission: map[string]uint64{
+				"course": privileges.PermissionCourseList | privileges.PermissionCourseCreate,
+			},
+		}
+	})
+
+	It("should allow user with permission to impersonate user holding less permission", func() {
+		Expect(CanImpersonate(support, student)).To(Succeed())
+	})
+
+	It("should refuse user without permission", func() {
+		support.Permission["user"] = privileges.PermissionUserList
+		Expect(CanImpersonate(support, student)).NotTo(Succeed())
+	})
+
+	It("should refuse to impersonate user holding more permission", func() {
+		err := CanImpersonate(support, teacher)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("course"))
+	})
+
+	It("should refuse to impersonate disabled user", func() {
+		student.Disabled = true
+		err := CanImpersonate(support, student)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("disabled"))
+	})
+
+	It("should refuse to impersonate user who must change password", func() {
+		student.MustChangePassword = true
+		Expect(CanImpersonate(support, student)).NotTo(Succeed())
+	})
+
+	It("should refuse to impersonate itself", func() {
+		Expect(CanImpersonate(support, support)).NotTo(Succeed())
+	})
+
+	It("should only treat safe methods as read only", func() {
+		Expect(IsReadOnlyMethod(http.MethodGet)).To(BeTrue())
+		Expect(IsReadOnlyMethod(http.MethodHead)).To(BeTrue())
+		Expect(IsReadOnlyMethod(http.MethodOptions)).To(BeTrue())
+		Expect(IsReadOnlyMethod(http.MethodPost)).To(BeFalse())
+		Expect(IsReadOnlyMethod(http.MethodDelete)).To(BeFalse())
+	})
+
+	It("should carry impersonator in request context", func() {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		Expect(ImpersonatorFromRequest(r)).To(BeNil())
+		r = r.WithContext(WithImpersonator(context.Background(), support))
+		Expect(ImpersonatorFromRequest(r)).To(Equal(support))
+	})
+})
@@ -0,0 +1,109 @@
+package lockout
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"time"
+)
+
+// ILoginLimiter tracks failed password logins per user name and per client ip
+// so that password guessing is slowed down by backoff and stopped by temporary lockout
+type ILoginLimiter interface {
+	// Check returns a *Blocked error if a login of user from client ip should not be attempted now
+	Check(userName, clientIp string) error
+	// RecordFailure counts a failed login, backoff is extended and lockout is started once max failures is reached
+	RecordFailure(userName, clientIp string)
+	// RecordSuccess clears failures of user name, failures of client ip are kept until they expire
+	RecordSuccess(userName, clientIp string)
+	// GetUserLockout returns failed login state of user name
+	GetUserLockout(userName string) *core.CoreLockout
+	// Unlock clears failures and lockout of user name and returns state before it is cleared
+	Unlock(userName string) *core.CoreLockout
+}
+
+// Blocked is returned by Check when caller has to wait
+type Blocked struct {
+	RetryAfter time.Duration
+	// locked is true if max failures is reached, otherwise caller is only backing off
+	Locked bool
+}
+
+func (b *Blocked) Error() string {
+	if b.Locked {
+		return fmt.Sprintf("too many failed logins, locked for %d seconds", retryAfterSeconds(b.RetryAfter))
+	}
+	return fmt.Sprintf("too many failed logins, retry after %d seconds", retryAfterSeconds(b.RetryAfter))
+}
+
+// RetryAfterSeconds is value of Retry-After header, rounded up so client never retries too early
+func (b *Blocked) RetryAfterSeconds() int {
+	return retryAfterSeconds(b.RetryAfter)
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+type LoginLimiterType string
+
+const (
+	MemoryLoginLimiter LoginLimiterType = "memory"
+	BoltLoginLimiter   LoginLimiterType = "bolt"
+)
+
+var defaultLoginLimiter ILoginLimiter
+
+// InitOrGetLoginLimiter returns limiter shared by login handler and auth middleware
+// failed logins are kept in file if path is set, otherwise in memory
+// failed logins are not shared between servers, so core-api has to run as a single replica
+func InitOrGetLoginLimiter(serverConfig *config.Config) (ILoginLimiter, error) {
+	if defaultLoginLimiter == nil {
+		limiterType := BoltLoginLimiter
+		if lockoutConfig := lockoutConfigOf(serverConfig); lockoutConfig == nil || lockoutConfig.Path == "" {
+			limiterType = MemoryLoginLimiter
+			if lockoutConfig != nil && !lockoutConfig.Disabled {
+				coreApiLog.Logger.Warn("lockout path is not set, failed logins are kept in memory and forgotten after restart")
+			}
+		}
+
+		limiter, err := CreateLoginLimiter(serverConfig, limiterType)
+		if err != nil {
+			return nil, err
+		}
+		defaultLoginLimiter = limiter
+	}
+	return defaultLoginLimiter, nil
+}
+
+func CreateLoginLimiter(serverConfig *config.Config, limiterType LoginLimiterType) (ILoginLimiter, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil, fmt.Errorf("auth config is nil")
+	}
+
+	lockoutConfig := lockoutConfigOf(serverConfig)
+
+	switch limiterType {
+	case MemoryLoginLimiter:
+		return newMemoryLoginLimiter(lockoutConfig), nil
+	case BoltLoginLimiter:
+		db, err := openDB(lockoutConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newBoltLoginLimiter(lockoutConfig, db), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid login limiter type", limiterType)
+}
+
+func lockoutConfigOf(serverConfig *config.Config) *config.LockoutConfig {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil
+	}
+
+	if serverConfig.AuthConfig.Lockout == nil {
+		return config.DefaultConfig().AuthConfig.Lockout
+	}
+	return serverConfig.AuthConfig.Lockout
+}
@@ -0,0 +1,201 @@
+package lockout
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	userBucket = []byte("user_attempts")
+	ipBucket   = []byte("ip_attempts")
+)
+
+// attemptsRecord is attempts as written to file
+type attemptsRecord struct {
+	Failures     int   `json:"failures"`
+	LastFailure  int64 `json:"lastFailure"`
+	BlockedUntil int64 `json:"blockedUntil,omitempty"`
+	LockedUntil  int64 `json:"lockedUntil,omitempty"`
+}
+
+func recordOf(a *attempts) *attemptsRecord {
+	unixNano := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.UnixNano()
+	}
+	return &attemptsRecord{
+		Failures:     a.failures,
+		LastFailure:  unixNano(a.lastFailure),
+		BlockedUntil: unixNano(a.blockedUntil),
+		LockedUntil:  unixNano(a.lockedUntil),
+	}
+}
+
+func (r *attemptsRecord) attempts() *attempts {
+	fromUnixNano := func(value int64) time.Time {
+		if value == 0 {
+			return time.Time{}
+		}
+		return time.Unix(0, value)
+	}
+	return &attempts{
+		failures:     r.Failures,
+		lastFailure:  fromUnixNano(r.LastFailure),
+		blockedUntil: fromUnixNano(r.BlockedUntil),
+		lockedUntil:  fromUnixNano(r.LockedUntil),
+	}
+}
+
+// boltLoginLimiter works like memoryLoginLimiter, failed logins are written through to file so lockout survives restart
+// the file is locked by the server holding it open, a second server using it fails on start up
+type boltLoginLimiter struct {
+	*memoryLoginLimiter
+	db *bbolt.DB
+}
+
+func openDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lockout store %s, it may be held by another server: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{userBucket, ipBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newBoltLoginLimiter(lockoutConfig *config.LockoutConfig, db *bbolt.DB) *boltLoginLimiter {
+	b := &boltLoginLimiter{
+		memoryLoginLimiter: newMemoryLoginLimiter(lockoutConfig),
+		db:                 db,
+	}
+
+	now := b.now()
+	err := db.View(func(tx *bbolt.Tx) error {
+		for _, stored := range []struct {
+			bucket []byte
+			to     map[string]*attempts
+		}{{userBucket, b.users}, {ipBucket, b.ips}} {
+			to := stored.to
+			err := tx.Bucket(stored.bucket).ForEach(func(k, v []byte) error {
+				record := &attemptsRecord{}
+				if err := json.Unmarshal(v, record); err != nil {
+					return err
+				}
+				if a := record.attempts(); !b.isExpired(a, now) {
+					to[string(k)] = a
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to load failed logins", "error", err)
+	}
+	return b
+}
+
+func (b *boltLoginLimiter) RecordFailure(userName, clientIp string) {
+	if b.disabled {
+		return
+	}
+	b.memoryLoginLimiter.RecordFailure(userName, clientIp)
+	b.persist(userName, clientIp)
+}
+
+func (b *boltLoginLimiter) RecordSuccess(userName, clientIp string) {
+	b.memoryLoginLimiter.RecordSuccess(userName, clientIp)
+	b.persist(userName, "")
+}
+
+func (b *boltLoginLimiter) Unlock(userName string) *core.CoreLockout {
+	status := b.memoryLoginLimiter.Unlock(userName)
+	b.persist(userName, "")
+	return status
+}
+
+// persist writes current attempts of user name and client ip to file and drops expired ones
+// a failure to write is only logged, lockout still works in memory until restart
+func (b *boltLoginLimiter) persist(userName, clientIp string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := b.now()
+	err := b.db.Update(func(tx *bbolt.Tx) error {
+		for _, changed := range []struct {
+			bucket []byte
+			from   map[string]*attempts
+			key    string
+		}{{userBucket, b.users, userName}, {ipBucket, b.ips, clientIp}} {
+			bucket := tx.Bucket(changed.bucket)
+			if err := b.removeExpiredRecords(bucket, now); err != nil {
+				return err
+			}
+			if changed.key == "" {
+				continue
+			}
+
+			a := b.get(changed.from, changed.key, now)
+			if a == nil {
+				if err := bucket.Delete([]byte(changed.key)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			data, err := json.Marshal(recordOf(a))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(changed.key), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to persist failed logins", "user", userName, "clientIp", clientIp, "error", err)
+	}
+}
+
+func (b *boltLoginLimiter) removeExpiredRecords(bucket *bbolt.Bucket, now time.Time) error {
+	var expired [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		record := &attemptsRecord{}
+		if err := json.Unmarshal(v, record); err != nil || b.isExpired(record.attempts(), now) {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package lockout
+
+import (
+	coreApiLog "core-api/pkg/logger"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIp returns ip of caller, forwarded headers of trusted proxies are already applied to remote address by RealIp
+func ClientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RealIp replaces remote address with ip of client forwarded by trusted proxies
+// headers of any other caller are ignored, so a client can not pick an ip to escape lockout
+// X-Forwarded-For is read from right to left, the first ip that is not a trusted proxy is the client
+func RealIp(trustedProxies []string) func(http.Handler) http.Handler {
+	var trusted []*net.IPNet
+	for _, proxy := range trustedProxies {
+		network, err := parseNetwork(proxy)
+		if err != nil {
+			coreApiLog.Logger.Error("Ignoring invalid trusted proxy", "proxy", proxy, "error", err)
+			continue
+		}
+		trusted = append(trusted, network)
+	}
+
+	isTrusted := func(ip string) bool {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return false
+		}
+		for _, network := range trusted {
+			if network.Contains(parsed) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trusted) > 0 && isTrusted(ClientIp(r)) {
+				if ip := forwardedIp(r, isTrusted); ip != "" {
+					r.RemoteAddr = ip
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIp returns empty string if headers carry no valid ip
+func forwardedIp(r *http.Request, isTrusted func(ip string) bool) string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	client := ""
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			// anything left of an invalid hop can not be trusted
+			break
+		}
+		client = hops[i]
+		if !isTrusted(hops[i]) {
+			break
+		}
+	}
+	if client != "" {
+		return client
+	}
+
+	if realIp := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIp) != nil {
+		return realIp
+	}
+	return ""
+}
+
+// parseNetwork accepts a cidr or a single ip
+func parseNetwork(proxy string) (*net.IPNet, error) {
+	if strings.Contains(proxy, "/") {
+		_, network, err := net.ParseCIDR(proxy)
+		return network, err
+	}
+
+	ip := net.ParseIP(proxy)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: proxy}
+	}
+	bits := 128
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
@@ -0,0 +1,59 @@
+package lockout
+
+import (
+	coreApiLog "core-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("client ip test", func() {
+	var resolved string
+
+	resolve := func(trustedProxies []string, remoteAddr string, headers map[string]string) string {
+		handler := RealIp(trustedProxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resolved = ClientIp(r)
+		}))
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = remoteAddr
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return resolved
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		resolved = ""
+	})
+
+	It("should ignore forwarded headers without trusted proxies", func() {
+		Expect(resolve(nil, "203.0.113.7:4321", map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"})).To(Equal("203.0.113.7"))
+	})
+
+	It("should ignore forwarded headers of an untrusted caller", func() {
+		Expect(resolve([]string{"10.0.0.0/8"}, "203.0.113.7:4321", map[string]string{"X-Forwarded-For": "198.51.100.1"})).To(Equal("203.0.113.7"))
+	})
+
+	It("should take client forwarded by trusted proxy", func() {
+		Expect(resolve([]string{"10.0.0.0/8"}, "10.0.0.5:4321", map[string]string{"X-Forwarded-For": "198.51.100.1"})).To(Equal("198.51.100.1"))
+		Expect(resolve([]string{"10.0.0.5"}, "10.0.0.5:4321", map[string]string{"X-Real-IP": "198.51.100.2"})).To(Equal("198.51.100.2"))
+	})
+
+	It("should not take ip a client prepended to forwarded header", func() {
+		// client sent 'X-Forwarded-For: 192.0.2.1', proxies appended the address they saw
+		headers := map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.1, 10.0.0.9"}
+		Expect(resolve([]string{"10.0.0.0/8"}, "10.0.0.5:4321", headers)).To(Equal("198.51.100.1"))
+	})
+
+	It("should keep remote address if forwarded header is invalid", func() {
+		Expect(resolve([]string{"10.0.0.0/8"}, "10.0.0.5:4321", map[string]string{"X-Forwarded-For": "unknown"})).To(Equal("10.0.0.5"))
+	})
+
+	It("should skip invalid trusted proxy", func() {
+		Expect(resolve([]string{"not-an-ip", "10.0.0.5"}, "10.0.0.5:4321", map[string]string{"X-Forwarded-For": "198.51.100.1"})).To(Equal("198.51.100.1"))
+	})
+})
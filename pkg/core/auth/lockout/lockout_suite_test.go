@@ -0,0 +1,13 @@
+package lockout_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestLockout(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lockout Suite")
+}
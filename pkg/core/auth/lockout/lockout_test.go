@@ -0,0 +1,205 @@
+package lockout
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"errors"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("login limiter test", func() {
+	var limiter *memoryLoginLimiter
+	var now time.Time
+
+	blockedOf := func(err error) *Blocked {
+		blocked := &Blocked{}
+		Expect(errors.As(err, &blocked)).To(BeTrue())
+		return blocked
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		now = time.Unix(10000, 0)
+		limiter = newMemoryLoginLimiter(&config.LockoutConfig{
+			MaxUserFailures:      3,
+			MaxIpFailures:        5,
+			BackoffBaseSeconds:   1,
+			BackoffMaxSeconds:    3,
+			LockoutMinutes:       10,
+			FailureWindowMinutes: 5,
+		})
+		limiter.now = func() time.Time { return now }
+	})
+
+	Describe("CreateLoginLimiter test", func() {
+		It("should be error with unknown type", func() {
+			_, err := CreateLoginLimiter(config.DefaultConfig(), "unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fall back to default settings", func() {
+			serverConfig := config.DefaultConfig()
+			serverConfig.AuthConfig.Lockout = nil
+			created, err := CreateLoginLimiter(serverConfig, MemoryLoginLimiter)
+			Expect(err).To(BeNil())
+			Expect(created.(*memoryLoginLimiter).maxUserFailures).To(Equal(5))
+			Expect(created.(*memoryLoginLimiter).lockout).To(Equal(15 * time.Minute))
+		})
+	})
+
+	It("should allow login without failures", func() {
+		Expect(limiter.Check("alice", "10.0.0.1")).To(BeNil())
+	})
+
+	It("should back off exponentially", func() {
+		limiter.RecordFailure("alice", "10.0.0.1")
+		blocked := blockedOf(limiter.Check("alice", "10.0.0.2"))
+		Expect(blocked.Locked).To(BeFalse())
+		Expect(blocked.RetryAfter).To(Equal(time.Second))
+
+		now = now.Add(time.Second)
+		Expect(limiter.Check("alice", "10.0.0.2")).To(BeNil())
+
+		limiter.RecordFailure("alice", "10.0.0.2")
+		Expect(blockedOf(limiter.Check("alice", "")).RetryAfter).To(Equal(2 * time.Second))
+		// other users from another ip are not affected
+		Expect(limiter.Check("bob", "10.0.0.3")).To(BeNil())
+	})
+
+	It("should cap backoff", func() {
+		limiter.maxUserFailures = 100
+		for i := 0; i < 10; i++ {
+			limiter.RecordFailure("alice", "")
+		}
+		Expect(blockedOf(limiter.Check("alice", "")).RetryAfter).To(Equal(3 * time.Second))
+	})
+
+	It("should lock account after max failures until lockout ends", func() {
+		for i := 0; i < 3; i++ {
+			now = now.Add(5 * time.Second)
+			limiter.RecordFailure("alice", "")
+		}
+
+		blocked := blockedOf(limiter.Check("alice", "10.0.0.9"))
+		Expect(blocked.Locked).To(BeTrue())
+		Expect(blocked.RetryAfter).To(Equal(10 * time.Minute))
+		Expect(blocked.RetryAfterSeconds()).To(Equal(600))
+
+		status := limiter.GetUserLockout("alice")
+		Expect(status.Locked).To(BeTrue())
+		Expect(status.Failures).To(Equal(3))
+		Expect(status.LockedUntil).To(Equal(now.Add(10 * time.Minute).Unix()))
+
+		// success is never reached while locked, lockout ends by itself
+		now = now.Add(10 * time.Minute)
+		Expect(limiter.Check("alice", "")).To(BeNil())
+		Expect(limiter.GetUserLockout("alice").Failures).To(Equal(0))
+	})
+
+	It("should lock client ip regardless of user name", func() {
+		for i := 0; i < 5; i++ {
+			now = now.Add(5 * time.Second)
+			limiter.RecordFailure("user"+string(rune('a'+i)), "10.0.0.1")
+		}
+
+		Expect(blockedOf(limiter.Check("someone-else", "10.0.0.1")).Locked).To(BeTrue())
+		Expect(limiter.Check("someone-else", "10.0.0.2")).To(BeNil())
+	})
+
+	It("should forget failures after window", func() {
+		limiter.RecordFailure("alice", "")
+		limiter.RecordFailure("alice", "")
+		now = now.Add(5 * time.Minute)
+		limiter.RecordFailure("alice", "")
+		Expect(limiter.GetUserLockout("alice").Failures).To(Equal(1))
+	})
+
+	It("should clear user failures on success", func() {
+		limiter.RecordFailure("alice", "10.0.0.1")
+		limiter.RecordSuccess("alice", "10.0.0.1")
+		Expect(limiter.GetUserLockout("alice").Failures).To(Equal(0))
+		// failures of ip are kept
+		Expect(limiter.Check("bob", "10.0.0.1")).To(HaveOccurred())
+	})
+
+	It("should unlock account", func() {
+		for i := 0; i < 3; i++ {
+			limiter.RecordFailure("alice", "")
+		}
+
+		status := limiter.Unlock("alice")
+		Expect(status.Locked).To(BeTrue())
+		Expect(limiter.Check("alice", "")).To(BeNil())
+		Expect(limiter.GetUserLockout("alice").Locked).To(BeFalse())
+	})
+
+	It("should do nothing if disabled", func() {
+		limiter.disabled = true
+		for i := 0; i < 10; i++ {
+			limiter.RecordFailure("alice", "10.0.0.1")
+		}
+		Expect(limiter.Check("alice", "10.0.0.1")).To(BeNil())
+	})
+
+	Describe("bolt login limiter test", func() {
+		var serverConfig *config.Config
+
+		BeforeEach(func() {
+			serverConfig = config.DefaultConfig()
+			serverConfig.AuthConfig.Lockout.MaxUserFailures = 3
+			serverConfig.AuthConfig.Lockout.Path = filepath.Join(GinkgoT().TempDir(), "lockout.db")
+			// failures are loaded against wall clock
+			now = time.Now()
+		})
+
+		openLimiter := func() *boltLoginLimiter {
+			created, err := CreateLoginLimiter(serverConfig, BoltLoginLimiter)
+			Expect(err).To(BeNil())
+			boltLimiter := created.(*boltLoginLimiter)
+			boltLimiter.now = func() time.Time { return now }
+			return boltLimiter
+		}
+
+		It("should keep lockout after restart", func() {
+			boltLimiter := openLimiter()
+			for i := 0; i < 3; i++ {
+				boltLimiter.RecordFailure("alice", "10.0.0.1")
+			}
+			boltLimiter.RecordFailure("bob", "10.0.0.2")
+			boltLimiter.RecordSuccess("bob", "10.0.0.2")
+			Expect(boltLimiter.db.Close()).To(BeNil())
+
+			boltLimiter = openLimiter()
+			defer boltLimiter.db.Close()
+			Expect(blockedOf(boltLimiter.Check("alice", "")).Locked).To(BeTrue())
+			Expect(boltLimiter.GetUserLockout("alice").Failures).To(Equal(3))
+			Expect(boltLimiter.GetUserLockout("bob").Failures).To(Equal(0))
+			// failures of ip are kept as well
+			Expect(boltLimiter.Check("carol", "10.0.0.2")).To(HaveOccurred())
+		})
+
+		It("should keep unlock after restart", func() {
+			boltLimiter := openLimiter()
+			for i := 0; i < 3; i++ {
+				boltLimiter.RecordFailure("alice", "")
+			}
+			boltLimiter.Unlock("alice")
+			Expect(boltLimiter.db.Close()).To(BeNil())
+
+			boltLimiter = openLimiter()
+			defer boltLimiter.db.Close()
+			Expect(boltLimiter.Check("alice", "")).To(BeNil())
+		})
+
+		It("should fail when file is held by another server", func() {
+			boltLimiter := openLimiter()
+			defer boltLimiter.db.Close()
+			_, err := CreateLoginLimiter(serverConfig, BoltLoginLimiter)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,224 @@
+package lockout
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"sync"
+	"time"
+)
+
+// attempts is failed login state of a user name or a client ip
+type attempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	lockedUntil  time.Time
+}
+
+// memoryLoginLimiter keeps attempts in memory, they are lost after restart
+type memoryLoginLimiter struct {
+	lock  sync.Mutex
+	users map[string]*attempts
+	ips   map[string]*attempts
+	now   func() time.Time
+
+	disabled        bool
+	maxUserFailures int
+	maxIpFailures   int
+	backoffBase     time.Duration
+	backoffMax      time.Duration
+	lockout         time.Duration
+	failureWindow   time.Duration
+}
+
+func newMemoryLoginLimiter(lockoutConfig *config.LockoutConfig) *memoryLoginLimiter {
+	defaults := config.DefaultConfig().AuthConfig.Lockout
+	orDefault := func(value, defaultValue int) int {
+		if value <= 0 {
+			return defaultValue
+		}
+		return value
+	}
+
+	return &memoryLoginLimiter{
+		users:           map[string]*attempts{},
+		ips:             map[string]*attempts{},
+		now:             time.Now,
+		disabled:        lockoutConfig.Disabled,
+		maxUserFailures: orDefault(lockoutConfig.MaxUserFailures, defaults.MaxUserFailures),
+		maxIpFailures:   orDefault(lockoutConfig.MaxIpFailures, defaults.MaxIpFailures),
+		backoffBase:     time.Duration(orDefault(lockoutConfig.BackoffBaseSeconds, defaults.BackoffBaseSeconds)) * time.Second,
+		backoffMax:      time.Duration(orDefault(lockoutConfig.BackoffMaxSeconds, defaults.BackoffMaxSeconds)) * time.Second,
+		lockout:         time.Duration(orDefault(lockoutConfig.LockoutMinutes, defaults.LockoutMinutes)) * time.Minute,
+		failureWindow:   time.Duration(orDefault(lockoutConfig.FailureWindowMinutes, defaults.FailureWindowMinutes)) * time.Minute,
+	}
+}
+
+func (m *memoryLoginLimiter) Check(userName, clientIp string) error {
+	if m.disabled {
+		return nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	var result *Blocked
+	for _, a := range []*attempts{m.get(m.users, userName, now), m.get(m.ips, clientIp, now)} {
+		if a == nil {
+			continue
+		}
+
+		blocked := &Blocked{}
+		if now.Before(a.lockedUntil) {
+			blocked.Locked = true
+			blocked.RetryAfter = a.lockedUntil.Sub(now)
+		} else if now.Before(a.blockedUntil) {
+			blocked.RetryAfter = a.blockedUntil.Sub(now)
+		} else {
+			continue
+		}
+
+		// report the longest wait
+		if result == nil || blocked.RetryAfter > result.RetryAfter {
+			result = blocked
+		}
+	}
+
+	if result != nil {
+		return result
+	}
+	return nil
+}
+
+func (m *memoryLoginLimiter) RecordFailure(userName, clientIp string) {
+	if m.disabled {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	m.removeExpired(now)
+
+	if userName != "" {
+		a := m.recordFailure(m.users, userName, m.maxUserFailures, now)
+		if a.lockedUntil.After(now) && a.failures == m.maxUserFailures {
+			coreApiLog.Logger.Warn("audit: account locked due to too many failed logins", "user", userName, "clientIp", clientIp, "failures", a.failures, "lockedUntil", a.lockedUntil.Unix())
+		}
+	}
+
+	if clientIp != "" {
+		a := m.recordFailure(m.ips, clientIp, m.maxIpFailures, now)
+		if a.lockedUntil.After(now) && a.failures == m.maxIpFailures {
+			coreApiLog.Logger.Warn("audit: client ip locked due to too many failed logins", "clientIp", clientIp, "user", userName, "failures", a.failures, "lockedUntil", a.lockedUntil.Unix())
+		}
+	}
+}
+
+func (m *memoryLoginLimiter) RecordSuccess(userName, clientIp string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.users, userName)
+}
+
+func (m *memoryLoginLimiter) GetUserLockout(userName string) *core.CoreLockout {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.status(userName, m.now())
+}
+
+func (m *memoryLoginLimiter) Unlock(userName string) *core.CoreLockout {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	status := m.status(userName, m.now())
+	delete(m.users, userName)
+	if status.Failures > 0 {
+		coreApiLog.Logger.Warn("audit: account unlocked", "user", userName, "failures", status.Failures, "locked", status.Locked)
+	}
+	return status
+}
+
+// status should be called with lock held
+func (m *memoryLoginLimiter) status(userName string, now time.Time) *core.CoreLockout {
+	result := &core.CoreLockout{UserName: userName}
+	a := m.get(m.users, userName, now)
+	if a == nil {
+		return result
+	}
+
+	result.Failures = a.failures
+	if now.Before(a.lockedUntil) {
+		result.Locked = true
+		result.LockedUntil = a.lockedUntil.Unix()
+	}
+	return result
+}
+
+// get returns attempts of key, attempts that have expired are dropped
+// should be called with lock held
+func (m *memoryLoginLimiter) get(from map[string]*attempts, key string, now time.Time) *attempts {
+	if key == "" {
+		return nil
+	}
+
+	a, found := from[key]
+	if !found {
+		return nil
+	}
+
+	if m.isExpired(a, now) {
+		delete(from, key)
+		return nil
+	}
+	return a
+}
+
+// recordFailure should be called with lock held
+func (m *memoryLoginLimiter) recordFailure(to map[string]*attempts, key string, maxFailures int, now time.Time) *attempts {
+	a := m.get(to, key, now)
+	if a == nil {
+		a = &attempts{}
+		to[key] = a
+	}
+
+	a.failures++
+	a.lastFailure = now
+
+	// wait base, 2*base, 4*base ... up to max
+	backoff := m.backoffMax
+	if shift := a.failures - 1; shift < 30 {
+		if d := m.backoffBase << shift; d < backoff {
+			backoff = d
+		}
+	}
+	a.blockedUntil = now.Add(backoff)
+
+	if a.failures >= maxFailures && !now.Before(a.lockedUntil) {
+		a.lockedUntil = now.Add(m.lockout)
+	}
+	return a
+}
+
+// isExpired tells whether attempts can be forgotten, a lockout that has ended starts over
+func (m *memoryLoginLimiter) isExpired(a *attempts, now time.Time) bool {
+	if !a.lockedUntil.IsZero() {
+		return !now.Before(a.lockedUntil)
+	}
+	return now.Sub(a.lastFailure) >= m.failureWindow
+}
+
+// removeExpired keeps memory bounded when many user names or client ips are tried
+// should be called with lock held
+func (m *memoryLoginLimiter) removeExpired(now time.Time) {
+	for _, from := range []map[string]*attempts{m.users, m.ips} {
+		for key, a := range from {
+			if m.isExpired(a, now) {
+				delete(from, key)
+			}
+		}
+	}
+}
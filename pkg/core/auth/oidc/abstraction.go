@@ -0,0 +1,110 @@
+package oidc
+
+import (
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// IOIDCClient drives authorization code flow with PKCE against an openid connect identity provider
+type IOIDCClient interface {
+	// AuthCodeURL starts a login and returns url of identity provider that browser should be redirected to
+	// and sealed login that browser keeps in login cookie until callback
+	AuthCodeURL(ctx context.Context) (string, string, error)
+	// Exchange finishes a login started by the browser sealed login comes from,
+	// it trades authorization code for id token and validates the token
+	Exchange(ctx context.Context, code, state, sealedLogin string) (*IdentityClaims, error)
+}
+
+// IdentityClaims is what core-api reads from a validated id token
+type IdentityClaims struct {
+	// issuer and subject identify user at identity provider, other claims can be changed by user
+	Issuer   string
+	Subject  string
+	Username string
+	Email    string
+	Name     string
+	Groups   []string
+}
+
+const (
+	DefaultUsernameClaim = "preferred_username"
+	DefaultGroupsClaim   = "groups"
+	// cookie carrying sealed login from redirect to callback
+	LoginCookieName = "core-api-oidc-login"
+	// a login must be finished within this duration
+	pendingLoginExpire = 10 * time.Minute
+	// tolerated clock difference between core-api and identity provider
+	clockSkew = time.Minute
+)
+
+var DefaultScopes = []string{"openid", "profile", "email", "groups"}
+
+var defaultOIDCClient IOIDCClient
+
+// InitOrGetOIDCClient returns client shared by login redirect and callback handler
+func InitOrGetOIDCClient(serverConfig *config.Config) (IOIDCClient, error) {
+	if defaultOIDCClient == nil {
+		client, err := CreateOIDCClient(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+		defaultOIDCClient = client
+	}
+	return defaultOIDCClient, nil
+}
+
+func CreateOIDCClient(serverConfig *config.Config) (IOIDCClient, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil || serverConfig.AuthConfig.OIDC == nil {
+		return nil, fmt.Errorf("oidc is not configured")
+	}
+
+	oidcConfig := serverConfig.AuthConfig.OIDC
+	if oidcConfig.IssuerUrl == "" || oidcConfig.ClientId == "" || oidcConfig.RedirectUrl == "" {
+		return nil, fmt.Errorf("oidc issuer url, client id and redirect url are required")
+	}
+
+	var sealKey []byte
+	if serverConfig.CoreApiConfig != nil {
+		sealKey = []byte(serverConfig.CoreApiConfig.TokenSigningKey)
+	}
+	if len(sealKey) == 0 {
+		// session token is not valid on other replicas either without a signing key
+		coreApiLog.Logger.Warn("token signing key is not set, single sign-on login has to be finished on the replica it is started on")
+		sealKey = make([]byte, 32)
+		if _, err := rand.Read(sealKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return newCodeFlowClient(oidcConfig, sealKey, &http.Client{Timeout: 10 * time.Second})
+}
+
+// LoginCookie returns cookie carrying sealed login to callback, it is sent to callback path only and can not be read by script
+// lax same site lets it ride along redirect of identity provider, which is a top level navigation
+func LoginCookie(oidcConfig *config.OIDCConfig, sealedLogin string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     LoginCookieName,
+		Value:    sealedLogin,
+		Path:     "/",
+		MaxAge:   int(pendingLoginExpire / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if redirectUrl, err := url.Parse(oidcConfig.RedirectUrl); err == nil {
+		if redirectUrl.Path != "" {
+			cookie.Path = redirectUrl.Path
+		}
+		cookie.Secure = redirectUrl.Scheme == "https"
+	}
+	if sealedLogin == "" {
+		// login is single use, cookie is dropped once callback is reached
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
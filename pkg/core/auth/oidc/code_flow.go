@@ -0,0 +1,436 @@
+package oidc
+
+import (
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"core-api/pkg/util/common"
+	customError "core-api/pkg/util/error"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IdToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// pendingLogin is carried between redirect and callback by a sealed cookie of browser that starts the login
+// so callback of another browser is refused and any replica can finish the login
+type pendingLogin struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"codeVerifier"`
+	Nonce        string `json:"nonce"`
+	ExpiresAt    int64  `json:"expiresAt"`
+}
+
+type codeFlowClient struct {
+	config     *config.OIDCConfig
+	httpClient *http.Client
+	now        func() time.Time
+
+	// seals pending login, same key on every replica lets any of them finish a login
+	sealer cipher.AEAD
+
+	lock      sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]crypto.PublicKey
+}
+
+func newCodeFlowClient(oidcConfig *config.OIDCConfig, sealKey []byte, httpClient *http.Client) (*codeFlowClient, error) {
+	// key of any length is stretched to aes-256 key
+	key := sha256.Sum256(append([]byte("core-api oidc login\n"), sealKey...))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	sealer, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &codeFlowClient{
+		config:     oidcConfig,
+		httpClient: httpClient,
+		now:        time.Now,
+		sealer:     sealer,
+	}, nil
+}
+
+func (c *codeFlowClient) AuthCodeURL(ctx context.Context) (string, string, error) {
+	discovery, err := c.getDiscovery(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	codeVerifier, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+
+	login, err := c.seal(&pendingLogin{
+		State:        state,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    c.now().Add(pendingLoginExpire).Unix(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	scopes := c.config.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.config.ClientId},
+		"redirect_uri":          {c.config.RedirectUrl},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), login, nil
+}
+
+func (c *codeFlowClient) Exchange(ctx context.Context, code, state, sealedLogin string) (*IdentityClaims, error) {
+	// state has to be the one of login started by this browser, authorization code is single use at identity provider
+	login, err := c.open(sealedLogin)
+	if err != nil || subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 || c.now().Unix() > login.ExpiresAt {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "unknown or expired login state")
+	}
+
+	if code == "" {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "missing authorization code")
+	}
+
+	discovery, err := c.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := c.requestToken(ctx, discovery, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := c.verifyIdToken(ctx, discovery, tokens.IdToken, login.Nonce)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to verify id token", "error", err)
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "invalid id token")
+	}
+
+	return c.identityFromClaims(claims)
+}
+
+func (c *codeFlowClient) requestToken(ctx context.Context, discovery *discoveryDocument, code, codeVerifier string) (*tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectUrl},
+		"client_id":     {c.config.ClientId},
+		"code_verifier": {codeVerifier},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if c.config.ClientSecret != "" {
+		// client_secret_basic is the default client authentication method
+		request.SetBasicAuth(url.QueryEscape(c.config.ClientId), url.QueryEscape(c.config.ClientSecret))
+	}
+
+	body, responseCode, err := c.do(request)
+	if err != nil {
+		return nil, err
+	}
+	if responseCode != http.StatusOK {
+		coreApiLog.Logger.Error("Failed to exchange authorization code", "code", responseCode, "body", string(body))
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "failed to exchange authorization code")
+	}
+
+	tokens := &tokenResponse{}
+	err = json.Unmarshal(body, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if tokens.IdToken == "" {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "no id token in token response")
+	}
+	return tokens, nil
+}
+
+func (c *codeFlowClient) verifyIdToken(ctx context.Context, discovery *discoveryDocument, rawToken, nonce string) (map[string]interface{}, error) {
+	header, payload, signature, signingInput, err := splitToken(rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := c.getKey(ctx, discovery, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(header.Alg, key, signingInput, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.UseNumber()
+	err = decoder.Decode(&claims)
+	if err != nil {
+		return nil, fmt.Errorf("malformed id token payload")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %s", iss)
+	}
+
+	if !audienceContains(claims["aud"], c.config.ClientId) {
+		return nil, fmt.Errorf("id token is not issued for client %s", c.config.ClientId)
+	}
+
+	exp, err := numericClaim(claims, "exp")
+	if err != nil {
+		return nil, err
+	}
+	if c.now().Add(-clockSkew).Unix() >= exp {
+		return nil, fmt.Errorf("id token expired")
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("nonce mismatch")
+	}
+
+	return claims, nil
+}
+
+func (c *codeFlowClient) identityFromClaims(claims map[string]interface{}) (*IdentityClaims, error) {
+	identity := &IdentityClaims{}
+	identity.Subject, _ = claims["sub"].(string)
+	issuer, _ := claims["iss"].(string)
+	identity.Issuer = NormalizeIssuer(issuer)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims[common.GetStringValueOrDefault(c.config.UsernameClaim, DefaultUsernameClaim)].(string)
+
+	if identity.Subject == "" {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "no subject in id token")
+	}
+	if identity.Username == "" {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "no user name in id token")
+	}
+
+	switch groups := claims[common.GetStringValueOrDefault(c.config.GroupsClaim, DefaultGroupsClaim)].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok && name != "" {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		if groups != "" {
+			identity.Groups = []string{groups}
+		}
+	}
+
+	return identity, nil
+}
+
+// getKey returns key used to verify token, jwks is fetched again once if key id is unknown
+// so that key rotation at identity provider is picked up
+func (c *codeFlowClient) getKey(ctx context.Context, discovery *discoveryDocument, kid string) (crypto.PublicKey, error) {
+	c.lock.Lock()
+	keys := c.keys
+	c.lock.Unlock()
+
+	if key, found := keys[kid]; found {
+		return key, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JwksUri, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, code, err := c.do(request)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch jwks, response code: %d", code)
+	}
+
+	keys, err = parseKeySet(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	c.keys = keys
+	c.lock.Unlock()
+
+	if key, found := keys[kid]; found {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key %s not found in jwks", kid)
+}
+
+func (c *codeFlowClient) getDiscovery(ctx context.Context) (*discoveryDocument, error) {
+	c.lock.Lock()
+	discovery := c.discovery
+	c.lock.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.config.IssuerUrl, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	body, code, err := c.do(request)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch oidc discovery document, response code: %d", code)
+	}
+
+	discovery = &discoveryDocument{}
+	err = json.Unmarshal(body, discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(c.config.IssuerUrl, "/") {
+		return nil, fmt.Errorf("issuer %s in discovery document does not match configured issuer %s", discovery.Issuer, c.config.IssuerUrl)
+	}
+
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksUri == "" {
+		return nil, fmt.Errorf("incomplete oidc discovery document")
+	}
+
+	c.lock.Lock()
+	c.discovery = discovery
+	c.lock.Unlock()
+	return discovery, nil
+}
+
+func (c *codeFlowClient) do(request *http.Request) ([]byte, int, error) {
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, -1, err
+	}
+	return body, response.StatusCode, nil
+}
+
+// seal encrypts and authenticates pending login, browser can neither read nor change it
+func (c *codeFlowClient) seal(login *pendingLogin) (string, error) {
+	plain, err := json.Marshal(login)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, c.sealer.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(c.sealer.Seal(nonce, nonce, plain, nil)), nil
+}
+
+func (c *codeFlowClient) open(sealedLogin string) (*pendingLogin, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(sealedLogin)
+	if err != nil || len(sealed) < c.sealer.NonceSize() {
+		return nil, fmt.Errorf("malformed login state")
+	}
+	plain, err := c.sealer.Open(nil, sealed[:c.sealer.NonceSize()], sealed[c.sealer.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	login := &pendingLogin{}
+	if err := json.Unmarshal(plain, login); err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+func audienceContains(aud interface{}, clientId string) bool {
+	switch value := aud.(type) {
+	case string:
+		return value == clientId
+	case []interface{}:
+		for _, item := range value {
+			if s, ok := item.(string); ok && s == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func numericClaim(claims map[string]interface{}, name string) (int64, error) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("missing claim %s", name)
+	}
+	value, err := number.Float64()
+	if err != nil {
+		return 0, fmt.Errorf("malformed claim %s", name)
+	}
+	return int64(value), nil
+}
+
+// randomString returns 32 random bytes in base64url, long enough for state, nonce and pkce verifier
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,278 @@
+package oidc
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/event"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// ILinkStore keeps issuer and subject each user is linked to
+type ILinkStore interface {
+	// UserOf returns id of user linked to subject of issuer
+	UserOf(issuer, subject string) (string, bool)
+	// Link binds subject of issuer to user, a subject linked to another user is invalid
+	Link(link *core.CoreOIDCLink) (*core.CoreOIDCLink, error)
+	// LinksOf returns links of user
+	LinksOf(userId string) []core.CoreOIDCLink
+	// Unlink removes link of subject of issuer from user, a not found error is returned if there is none
+	Unlink(userId, issuer, subject string) error
+	// DeleteUser removes links of user and returns the number of them
+	DeleteUser(userId string) (int, error)
+}
+
+// Invalid is returned when a link can not be made as requested
+type Invalid struct {
+	Message string
+}
+
+func (i *Invalid) Error() string {
+	return i.Message
+}
+
+func IsInvalid(err error) bool {
+	_, ok := err.(*Invalid)
+	return ok
+}
+
+type LinkStoreType string
+
+const (
+	MemoryLinkStore LinkStoreType = "memory"
+	BoltLinkStore   LinkStoreType = "bolt"
+)
+
+var defaultLinkStore ILinkStore
+var defaultLinkStoreLock sync.Mutex
+
+// InitOrGetLinkStore returns store shared by single sign-on callback and link handlers
+// links are kept in file if link path is set, otherwise in memory, links of a deleted user are removed with it
+func InitOrGetLinkStore(serverConfig *config.Config) (ILinkStore, error) {
+	defaultLinkStoreLock.Lock()
+	defer defaultLinkStoreLock.Unlock()
+	if defaultLinkStore == nil {
+		storeType := BoltLinkStore
+		if linkPathOf(serverConfig) == "" {
+			storeType = MemoryLinkStore
+			coreApiLog.Logger.Warn("oidc link path is not set, links are lost after restart and users have to be linked again by an administrator")
+		}
+
+		store, err := CreateLinkStore(serverConfig, storeType)
+		if err != nil {
+			return nil, err
+		}
+		event.InitOrGetEventBus().Subscribe(func(changed event.Event) {
+			if changed.Kind != event.UserKind || changed.Action != event.Deleted {
+				return
+			}
+			if _, err := store.DeleteUser(changed.Id); err != nil {
+				coreApiLog.Logger.Error("Failed to remove oidc links of deleted user", "user", changed.Id, "error", err)
+			}
+		})
+		defaultLinkStore = store
+	}
+	return defaultLinkStore, nil
+}
+
+func CreateLinkStore(serverConfig *config.Config, storeType LinkStoreType) (ILinkStore, error) {
+	switch storeType {
+	case MemoryLinkStore:
+		return newLinkStore(nil), nil
+	case BoltLinkStore:
+		db, err := openLinkDB(linkPathOf(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		return newLinkStore(db), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid oidc link store type", storeType)
+}
+
+func linkPathOf(serverConfig *config.Config) string {
+	if serverConfig == nil || serverConfig.AuthConfig == nil || serverConfig.AuthConfig.OIDC == nil {
+		return ""
+	}
+	return serverConfig.AuthConfig.OIDC.LinkPath
+}
+
+// NormalizeIssuer drops trailing slash so issuer of config and of id token compare equal
+func NormalizeIssuer(issuer string) string {
+	return strings.TrimSuffix(issuer, "/")
+}
+
+var linksBucket = []byte("oidc_links")
+
+// linkStore keeps all links in memory, they are written through to file if db is set
+type linkStore struct {
+	lock sync.RWMutex
+	// keyed by issuer and subject
+	links map[string]*core.CoreOIDCLink
+	db    *bbolt.DB
+	now   func() time.Time
+}
+
+func openLinkDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open oidc link store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(linksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newLinkStore(db *bbolt.DB) *linkStore {
+	s := &linkStore{
+		links: map[string]*core.CoreOIDCLink{},
+		db:    db,
+		now:   time.Now,
+	}
+
+	if db != nil {
+		err := db.View(func(tx *bbolt.Tx) error {
+			return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+				link := &core.CoreOIDCLink{}
+				if err := json.Unmarshal(v, link); err != nil {
+					return err
+				}
+				s.links[string(k)] = link
+				return nil
+			})
+		})
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to load oidc links", "error", err)
+		}
+	}
+	return s
+}
+
+// linkKeyOf joins issuer and subject with a new line, which a url can not hold
+func linkKeyOf(issuer, subject string) string {
+	return NormalizeIssuer(issuer) + "\n" + subject
+}
+
+func (s *linkStore) UserOf(issuer, subject string) (string, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	link, found := s.links[linkKeyOf(issuer, subject)]
+	if !found {
+		return "", false
+	}
+	return link.UserId, true
+}
+
+func (s *linkStore) Link(link *core.CoreOIDCLink) (*core.CoreOIDCLink, error) {
+	if strings.TrimSpace(link.Issuer) == "" || strings.TrimSpace(link.Subject) == "" {
+		return nil, &Invalid{Message: "issuer and subject are required"}
+	}
+	if strings.TrimSpace(link.UserId) == "" {
+		return nil, &Invalid{Message: "user id is required"}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := linkKeyOf(link.Issuer, link.Subject)
+	if existing, found := s.links[key]; found {
+		if existing.UserId != link.UserId {
+			return nil, &Invalid{Message: fmt.Sprintf("subject %s is linked to another user", link.Subject)}
+		}
+		result := *existing
+		return &result, nil
+	}
+
+	created := *link
+	created.Issuer = NormalizeIssuer(link.Issuer)
+	created.CreatedAt = s.now().Unix()
+	if s.db != nil {
+		data, err := json.Marshal(&created)
+		if err != nil {
+			return nil, err
+		}
+		err = s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(linksBucket).Put([]byte(key), data)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.links[key] = &created
+	result := created
+	return &result, nil
+}
+
+func (s *linkStore) LinksOf(userId string) []core.CoreOIDCLink {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := []core.CoreOIDCLink{}
+	for _, link := range s.links {
+		if link.UserId == userId {
+			result = append(result, *link)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return linkKeyOf(result[i].Issuer, result[i].Subject) < linkKeyOf(result[j].Issuer, result[j].Subject)
+	})
+	return result
+}
+
+func (s *linkStore) Unlink(userId, issuer, subject string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := linkKeyOf(issuer, subject)
+	if link, found := s.links[key]; !found || link.UserId != userId {
+		return customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("subject %s is not linked to user %s", subject, userId))
+	}
+	return s.remove(key)
+}
+
+func (s *linkStore) DeleteUser(userId string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for key, link := range s.links {
+		if link.UserId != userId {
+			continue
+		}
+		if err := s.remove(key); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// remove should be called with lock held
+func (s *linkStore) remove(key string) error {
+	if s.db != nil {
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(linksBucket).Delete([]byte(key))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	delete(s.links, key)
+	return nil
+}
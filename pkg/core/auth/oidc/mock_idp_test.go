@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// mockIdP is a local openid connect identity provider used by tests
+type mockIdP struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	kid        string
+	clientId   string
+	secret     string
+	lock       sync.Mutex
+	codes      map[string]mockAuthorization
+	claims     map[string]interface{}
+	jwksserved int
+}
+
+// mockAuthorization is what idp remembers about an issued authorization code
+type mockAuthorization struct {
+	nonce         string
+	codeChallenge string
+	redirectUri   string
+}
+
+var mockKey *rsa.PrivateKey
+
+func newMockIdP(clientId, secret string) *mockIdP {
+	if mockKey == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		mockKey = key
+	}
+
+	idp := &mockIdP{
+		key:      mockKey,
+		kid:      "key-1",
+		clientId: clientId,
+		secret:   secret,
+		codes:    map[string]mockAuthorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.lock.Lock()
+		idp.jwksserved++
+		idp.lock.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": idp.kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", idp.handleToken)
+	idp.server = httptest.NewServer(mux)
+	return idp
+}
+
+// authorize plays the part of user logging in at idp, it returns code for the authorization request
+func (idp *mockIdP) authorize(code, nonce, codeChallenge, redirectUri string) {
+	idp.lock.Lock()
+	defer idp.lock.Unlock()
+	idp.codes[code] = mockAuthorization{nonce: nonce, codeChallenge: codeChallenge, redirectUri: redirectUri}
+}
+
+func (idp *mockIdP) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientId, secret, ok := r.BasicAuth()
+	if !ok || clientId != idp.clientId || secret != idp.secret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	idp.lock.Lock()
+	authorization, found := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.lock.Unlock()
+
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != authorization.codeChallenge || r.PostForm.Get("redirect_uri") != authorization.redirectUri {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	claims := map[string]interface{}{}
+	for key, value := range idp.claims {
+		claims[key] = value
+	}
+	if _, set := claims["nonce"]; !set {
+		claims["nonce"] = authorization.nonce
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"id_token":     idp.sign(claims),
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+	})
+}
+
+func (idp *mockIdP) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": idp.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *mockIdP) close() {
+	idp.server.Close()
+}
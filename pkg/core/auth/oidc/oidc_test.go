@@ -0,0 +1,453 @@
+package oidc
+
+import (
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDirectory implements user, role and group provider in memory
+type fakeDirectory struct {
+	users  []core.CoreUser
+	roles  []core.CoreRole
+	groups []core.CoreGroup
+}
+
+func (f *fakeDirectory) SearchUserByName(name string, options map[string]struct{}) (*core.CoreUser, error) {
+	for _, user := range f.users {
+		if user.Name == name {
+			return &user, nil
+		}
+	}
+	return nil, customError.NewNotFound(http.StatusNotFound, "user not found")
+}
+func (f *fakeDirectory) GetUsers(options map[string]struct{}) ([]core.CoreUser, error) {
+	return f.users, nil
+}
+func (f *fakeDirectory) GetUser(id string, options map[string]struct{}) (*core.CoreUser, error) {
+	for _, user := range f.users {
+		if user.Id == id {
+			return &user, nil
+		}
+	}
+	return nil, customError.NewNotFound(http.StatusNotFound, "user not found")
+}
+func (f *fakeDirectory) UpdateUser(user *core.CoreUser, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) DeleteUser(id string, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) CreateUser(user *core.CoreUser, options map[string]struct{}) (*core.CoreUser, error) {
+	user.Id = user.Name + "-id"
+	f.users = append(f.users, *user)
+	return user, nil
+}
+func (f *fakeDirectory) LoginUser(username, password string) (*core.CoreUser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) GetRoles(options map[string]struct{}) ([]core.CoreRole, error) {
+	return f.roles, nil
+}
+func (f *fakeDirectory) GetRole(id string, options map[string]struct{}) (*core.CoreRole, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) UpdateRole(role *core.CoreRole, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) DeleteRole(id string, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) CreateRole(role *core.CoreRole, options map[string]struct{}) (*core.CoreRole, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) SearchRoleByName(name string, options map[string]struct{}) (*core.CoreRole, error) {
+	for _, role := range f.roles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	return nil, customError.NewNotFound(http.StatusNotFound, "role not found")
+}
+func (f *fakeDirectory) GetGroups(options map[string]struct{}) ([]core.CoreGroup, error) {
+	return f.groups, nil
+}
+func (f *fakeDirectory) GetGroup(id string, options map[string]struct{}) (*core.CoreGroup, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) UpdateGroup(group *core.CoreGroup, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) DeleteGroup(id string, options map[string]struct{}) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) CreateGroup(group *core.CoreGroup, options map[string]struct{}) (*core.CoreGroup, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) SearchGroupByName(name string, options map[string]struct{}) (*core.CoreGroup, error) {
+	for _, group := range f.groups {
+		if group.Name == name {
+			return &group, nil
+		}
+	}
+	return nil, customError.NewNotFound(http.StatusNotFound, "group not found")
+}
+func (f *fakeDirectory) AddUserToGroup(userId, groupId string) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) RemoveUserFromGroup(userId, groupId string) error {
+	return fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) GetGroupUsers(groupId string, options map[string]struct{}) ([]core.CoreUser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) GetGroupSummary(options map[string]struct{}) (*core.CoreGroupSummary, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+func (f *fakeDirectory) AddUsersToGroup(groupId string, users []core.CoreUser) ([]core.CoreUser, []core.CoreUser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+var _ = Describe("oidc test", func() {
+	var idp *mockIdP
+	var serverConfig *config.Config
+	var client *codeFlowClient
+	ctx := context.Background()
+
+	// startLogin runs redirect step and lets idp issue a code for it, returns state and sealed login of login cookie
+	startLogin := func(code string) (string, string) {
+		redirectUrl, login, err := client.AuthCodeURL(ctx)
+		Expect(err).To(BeNil())
+		parsed, err := url.Parse(redirectUrl)
+		Expect(err).To(BeNil())
+		query := parsed.Query()
+		Expect(query.Get("code_challenge_method")).To(Equal("S256"))
+		Expect(query.Get("client_id")).To(Equal("core-api"))
+		idp.authorize(code, query.Get("nonce"), query.Get("code_challenge"), query.Get("redirect_uri"))
+		return query.Get("state"), login
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		idp = newMockIdP("core-api", "core-api-secret")
+		idp.claims = map[string]interface{}{
+			"iss":                idp.server.URL,
+			"aud":                "core-api",
+			"sub":                "subject-1",
+			"preferred_username": "student1",
+			"email":              "student1@example.edu",
+			"name":               "Student One",
+			"groups":             []string{"class-1", "unknown-group"},
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iat":                time.Now().Unix(),
+		}
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.OIDC = &config.OIDCConfig{
+			IssuerUrl:    idp.server.URL,
+			ClientId:     "core-api",
+			ClientSecret: "core-api-secret",
+			RedirectUrl:  "http://localhost/callback",
+			DefaultRole:  "student",
+		}
+		serverConfig.CoreApiConfig.TokenSigningKey = "core-api-signing-key"
+		created, err := CreateOIDCClient(serverConfig)
+		Expect(err).To(BeNil())
+		client = created.(*codeFlowClient)
+	})
+
+	AfterEach(func() {
+		idp.close()
+	})
+
+	Describe("CreateOIDCClient test", func() {
+		It("should be error without oidc config", func() {
+			_, err := CreateOIDCClient(config.DefaultConfig())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be error without client id", func() {
+			serverConfig.AuthConfig.OIDC.ClientId = ""
+			_, err := CreateOIDCClient(serverConfig)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("code flow test", func() {
+		It("should map claims of id token", func() {
+			state, login := startLogin("code-1")
+			identity, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			Expect(*identity).To(Equal(IdentityClaims{
+				Issuer:   idp.server.URL,
+				Subject:  "subject-1",
+				Username: "student1",
+				Email:    "student1@example.edu",
+				Name:     "Student One",
+				Groups:   []string{"class-1", "unknown-group"},
+			}))
+		})
+
+		It("should reject reused state", func() {
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			_, err = client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject unknown state", func() {
+			_, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", "forged-state", login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject state of login started by another browser", func() {
+			state, _ := startLogin("code-1")
+			_, anotherLogin := startLogin("code-2")
+			_, err := client.Exchange(ctx, "code-1", state, anotherLogin)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+			_, err = client.Exchange(ctx, "code-1", state, "")
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject tampered login cookie", func() {
+			state, login := startLogin("code-1")
+			sealed, err := base64.RawURLEncoding.DecodeString(login)
+			Expect(err).To(BeNil())
+			sealed[len(sealed)-1] ^= 1
+			_, err = client.Exchange(ctx, "code-1", state, base64.RawURLEncoding.EncodeToString(sealed))
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should finish login on another replica with same signing key", func() {
+			state, login := startLogin("code-1")
+			replica, err := CreateOIDCClient(serverConfig)
+			Expect(err).To(BeNil())
+			identity, err := replica.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			Expect(identity.Subject).To(Equal("subject-1"))
+
+			serverConfig.CoreApiConfig.TokenSigningKey = "another-signing-key"
+			state, login = startLogin("code-2")
+			another, err := CreateOIDCClient(serverConfig)
+			Expect(err).To(BeNil())
+			_, err = another.Exchange(ctx, "code-2", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject expired state", func() {
+			state, login := startLogin("code-1")
+			client.now = func() time.Time { return time.Now().Add(pendingLoginExpire + time.Minute) }
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject token of another client", func() {
+			idp.claims["aud"] = []string{"another-client"}
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject token of another issuer", func() {
+			idp.claims["iss"] = "https://evil.example.com"
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject expired token", func() {
+			idp.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject replayed nonce", func() {
+			idp.claims["nonce"] = "nonce-of-another-login"
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should reject wrong client secret", func() {
+			serverConfig.AuthConfig.OIDC.ClientSecret = "wrong"
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should fetch jwks again after key rotation", func() {
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			idp.kid = "key-2"
+			state, login = startLogin("code-2")
+			_, err = client.Exchange(ctx, "code-2", state, login)
+			Expect(err).To(BeNil())
+			Expect(idp.jwksserved).To(Equal(2))
+		})
+
+		It("should reject tampered token", func() {
+			discovery, err := client.getDiscovery(ctx)
+			Expect(err).To(BeNil())
+			idp.claims["nonce"] = "nonce-1"
+			token := idp.sign(idp.claims)
+			_, err = client.verifyIdToken(ctx, discovery, token, "nonce-1")
+			Expect(err).To(BeNil())
+
+			idp.claims["preferred_username"] = "admin"
+			tampered := idp.sign(idp.claims)
+			segments := strings.Split(token, ".")
+			_, err = client.verifyIdToken(ctx, discovery, segments[0]+"."+strings.Split(tampered, ".")[1]+"."+segments[2], "nonce-1")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should cache jwks", func() {
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			state, login = startLogin("code-2")
+			_, err = client.Exchange(ctx, "code-2", state, login)
+			Expect(err).To(BeNil())
+			Expect(idp.jwksserved).To(Equal(1))
+		})
+
+		It("should reject token without user name", func() {
+			delete(idp.claims, "preferred_username")
+			state, login := startLogin("code-1")
+			_, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should read user name from configured claim", func() {
+			serverConfig.AuthConfig.OIDC.UsernameClaim = "email"
+			state, login := startLogin("code-1")
+			identity, err := client.Exchange(ctx, "code-1", state, login)
+			Expect(err).To(BeNil())
+			Expect(identity.Username).To(Equal("student1@example.edu"))
+		})
+	})
+
+	Describe("verifySignature test", func() {
+		It("should reject none algorithm", func() {
+			err := verifySignature("none", &idp.key.PublicKey, "a.b", nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject hmac algorithm", func() {
+			err := verifySignature("HS256", &idp.key.PublicKey, "a.b", []byte("signature"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Provision test", func() {
+		var directory *fakeDirectory
+		var links ILinkStore
+		identity := &IdentityClaims{Issuer: "https://idp.example.edu", Subject: "subject-1", Username: "student1", Email: "student1@example.edu", Groups: []string{"class-1", "unknown-group"}}
+
+		BeforeEach(func() {
+			directory = &fakeDirectory{
+				users:  []core.CoreUser{{Id: "teacher1-id", Name: "teacher1"}},
+				roles:  []core.CoreRole{{Id: "student-role-id", Name: "student"}},
+				groups: []core.CoreGroup{{Id: "class-1-id", Name: "class-1"}},
+			}
+			var err error
+			links, err = CreateLinkStore(serverConfig, MemoryLinkStore)
+			Expect(err).To(BeNil())
+		})
+
+		It("should create user with default role and known groups and link it", func() {
+			user, err := Provision(serverConfig.AuthConfig.OIDC, identity, links, directory, directory, directory)
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("student1-id"))
+			Expect(user.Email).To(Equal("student1@example.edu"))
+			Expect(user.Roles).To(Equal([]core.CoreRole{{Id: "student-role-id", Name: "student"}}))
+			Expect(user.Groups).To(Equal([]core.CoreGroup{{Id: "class-1-id", Name: "class-1"}}))
+			Expect(user.Password).To(BeEmpty())
+
+			userId, found := links.UserOf("https://idp.example.edu/", "subject-1")
+			Expect(found).To(BeTrue())
+			Expect(userId).To(Equal("student1-id"))
+		})
+
+		It("should return linked user by subject whatever user name identity provider sends", func() {
+			_, err := links.Link(&core.CoreOIDCLink{Issuer: identity.Issuer, Subject: "s", UserId: "teacher1-id"})
+			Expect(err).To(BeNil())
+			user, err := Provision(serverConfig.AuthConfig.OIDC, &IdentityClaims{Issuer: identity.Issuer, Subject: "s", Username: "renamed"}, links, directory, directory, directory)
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("teacher1-id"))
+			Expect(len(directory.users)).To(Equal(1))
+		})
+
+		It("should refuse existing user not linked to identity", func() {
+			_, err := Provision(serverConfig.AuthConfig.OIDC, &IdentityClaims{Issuer: identity.Issuer, Subject: "attacker", Username: "teacher1"}, links, directory, directory, directory)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+			Expect(len(directory.users)).To(Equal(1))
+
+			// subject of another issuer is another identity
+			_, err = links.Link(&core.CoreOIDCLink{Issuer: "https://other.example.edu", Subject: "s", UserId: "teacher1-id"})
+			Expect(err).To(BeNil())
+			_, err = Provision(serverConfig.AuthConfig.OIDC, &IdentityClaims{Issuer: identity.Issuer, Subject: "s", Username: "teacher1"}, links, directory, directory, directory)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be unauthorized if auto provision is disabled", func() {
+			serverConfig.AuthConfig.OIDC.DisableAutoProvision = true
+			_, err := Provision(serverConfig.AuthConfig.OIDC, identity, links, directory, directory, directory)
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be error if default role does not exist", func() {
+			serverConfig.AuthConfig.OIDC.DefaultRole = "unknown"
+			_, err := Provision(serverConfig.AuthConfig.OIDC, identity, links, directory, directory, directory)
+			Expect(err).To(HaveOccurred())
+			Expect(len(directory.users)).To(Equal(1))
+		})
+	})
+
+	Describe("link store test", func() {
+		It("should not link a subject to two users", func() {
+			links, err := CreateLinkStore(serverConfig, MemoryLinkStore)
+			Expect(err).To(BeNil())
+			_, err = links.Link(&core.CoreOIDCLink{Issuer: "https://idp.example.edu", Subject: "s", UserId: "a"})
+			Expect(err).To(BeNil())
+			_, err = links.Link(&core.CoreOIDCLink{Issuer: "https://idp.example.edu", Subject: "s", UserId: "a"})
+			Expect(err).To(BeNil())
+			_, err = links.Link(&core.CoreOIDCLink{Issuer: "https://idp.example.edu", Subject: "s", UserId: "b"})
+			Expect(IsInvalid(err)).To(BeTrue())
+
+			Expect(links.Unlink("b", "https://idp.example.edu", "s")).NotTo(Succeed())
+			Expect(links.Unlink("a", "https://idp.example.edu", "s")).To(Succeed())
+			Expect(links.LinksOf("a")).To(BeEmpty())
+		})
+
+		It("should keep links in file", func() {
+			serverConfig.AuthConfig.OIDC.LinkPath = filepath.Join(GinkgoT().TempDir(), "oidc-links.db")
+			links, err := CreateLinkStore(serverConfig, BoltLinkStore)
+			Expect(err).To(BeNil())
+			_, err = links.Link(&core.CoreOIDCLink{Issuer: "https://idp.example.edu", Subject: "s", UserId: "a"})
+			Expect(err).To(BeNil())
+			links.(*linkStore).db.Close()
+
+			reopened, err := CreateLinkStore(serverConfig, BoltLinkStore)
+			Expect(err).To(BeNil())
+			defer reopened.(*linkStore).db.Close()
+			userId, found := reopened.UserOf("https://idp.example.edu", "s")
+			Expect(found).To(BeTrue())
+			Expect(userId).To(Equal("a"))
+		})
+	})
+})
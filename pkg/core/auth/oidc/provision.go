@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+)
+
+// Provision returns user linked to issuer and subject of identity with permission loaded
+// user name in id token is chosen by identity provider, so it never links an existing user, an administrator has to link such user
+// a user that does not exist yet is created with default role and groups in id token that also exist in group provider
+// and linked to identity, a disabled user is refused
+func Provision(oidcConfig *config.OIDCConfig, identity *IdentityClaims, links ILinkStore, userProvider auth.IUserProvider, roleProvider auth.IRoleProvider, groupProvider auth.IGroupProvider) (*core.CoreUser, error) {
+	if userId, found := links.UserOf(identity.Issuer, identity.Subject); found {
+		user, err := userProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+		if err == nil {
+			if user.Disabled {
+				return nil, customError.NewUnauthorized(http.StatusUnauthorized, fmt.Sprintf("user %s is disabled", user.Name))
+			}
+			return user, nil
+		}
+		if !customError.IsNotFound(err) {
+			coreApiLog.Logger.Error("Failed to get linked user", "user", userId, "error", err)
+			return nil, err
+		}
+		// linked user is gone, subject is treated as a new identity
+		if err := links.Unlink(userId, identity.Issuer, identity.Subject); err != nil {
+			coreApiLog.Logger.Warn("Failed to remove link of deleted user", "user", userId, "error", err)
+		}
+	}
+
+	// disabled user is searched as well, its name is taken all the same
+	_, err := userProvider.SearchUserByName(identity.Username, map[string]struct{}{keystone.IncludeDisabledUsers: {}})
+	if err == nil {
+		coreApiLog.Logger.Warn("audit: single sign-on refused for user not linked to identity", "user", identity.Username, "issuer", identity.Issuer, "subject", identity.Subject)
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, fmt.Sprintf("user %s is not linked to identity, an administrator has to link it", identity.Username))
+	}
+
+	if !customError.IsNotFound(err) {
+		coreApiLog.Logger.Error("Failed to search user", "user", identity.Username, "error", err)
+		return nil, err
+	}
+
+	if oidcConfig.DisableAutoProvision {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, fmt.Sprintf("user %s is not provisioned", identity.Username))
+	}
+
+	toCreate := &core.CoreUser{
+		Name:        identity.Username,
+		Email:       identity.Email,
+		Description: identity.Name,
+	}
+
+	if oidcConfig.DefaultRole != "" {
+		role, err := roleProvider.SearchRoleByName(oidcConfig.DefaultRole, nil)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to get default role", "role", oidcConfig.DefaultRole, "error", err)
+			return nil, err
+		}
+		toCreate.Roles = []core.CoreRole{{Id: role.Id, Name: role.Name}}
+	}
+
+	for _, groupName := range identity.Groups {
+		// group of same name in another tenant is not the one meant by identity provider
+		group, err := auth.SearchTenantGroupByName(groupProvider, toCreate.TenantId, groupName, nil)
+		if err != nil {
+			coreApiLog.Logger.Debug("Skip group in id token", "group", groupName, "error", err)
+			continue
+		}
+		toCreate.Groups = append(toCreate.Groups, core.CoreGroup{Id: group.Id, Name: group.Name})
+	}
+
+	_, err = userProvider.CreateUser(toCreate, nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to provision user", "user", identity.Username, "error", err)
+		return nil, err
+	}
+
+	user, err := userProvider.SearchUserByName(identity.Username, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = links.Link(&core.CoreOIDCLink{Issuer: identity.Issuer, Subject: identity.Subject, UserId: user.Id, UserName: user.Name, CreatedBy: user.Id})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to link provisioned user", "user", identity.Username, "error", err)
+		return nil, err
+	}
+	coreApiLog.Logger.Info("user provisioned from oidc", "user", identity.Username, "issuer", identity.Issuer, "subject", identity.Subject)
+
+	return user, nil
+}
@@ -0,0 +1,127 @@
+package password
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"fmt"
+	"strings"
+)
+
+// IPasswordPolicy tells whether a new password is strong enough
+type IPasswordPolicy interface {
+	// Validate returns a *Violation error listing every rule that password breaks
+	Validate(userName, password string) error
+}
+
+// IPasswordHistory remembers previous passwords of users so they are not reused
+// only salted hashes are kept
+type IPasswordHistory interface {
+	// Reused tells whether password is one of previous passwords of user
+	Reused(userId, password string) (bool, error)
+	// Remember adds password as the latest one of user, oldest one is dropped once history is full
+	Remember(userId, password string) error
+	// Forget drops history of user, it should be called when user is deleted
+	Forget(userId string) error
+}
+
+// Violation is returned when a password breaks the policy
+type Violation struct {
+	Reasons []string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("password does not meet policy: %s", strings.Join(v.Reasons, "; "))
+}
+
+func IsViolation(err error) bool {
+	_, ok := err.(*Violation)
+	return ok
+}
+
+type PasswordHistoryType string
+
+const (
+	MemoryPasswordHistory PasswordHistoryType = "memory"
+	BoltPasswordHistory   PasswordHistoryType = "bolt"
+)
+
+var defaultPasswordPolicy IPasswordPolicy
+var defaultPasswordHistory IPasswordHistory
+
+// InitOrGetPasswordPolicy returns policy shared by handlers that set password
+func InitOrGetPasswordPolicy(serverConfig *config.Config) (IPasswordPolicy, error) {
+	if defaultPasswordPolicy == nil {
+		policy, err := CreatePasswordPolicy(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+		defaultPasswordPolicy = policy
+	}
+	return defaultPasswordPolicy, nil
+}
+
+func CreatePasswordPolicy(serverConfig *config.Config) (IPasswordPolicy, error) {
+	policyConfig, err := policyConfigOf(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newDefaultPolicy(policyConfig)
+}
+
+// InitOrGetPasswordHistory returns history shared by handlers that set password
+// history is kept in file if history path is set, otherwise in memory
+func InitOrGetPasswordHistory(serverConfig *config.Config) (IPasswordHistory, error) {
+	if defaultPasswordHistory == nil {
+		policyConfig, err := policyConfigOf(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		historyType := BoltPasswordHistory
+		if policyConfig.HistoryPath == "" {
+			historyType = MemoryPasswordHistory
+			if !policyConfig.Disabled && policyConfig.HistorySize > 0 {
+				coreApiLog.Logger.Warn("password history path is not set, previous passwords are kept in memory and forgotten after restart")
+			}
+		}
+
+		history, err := CreatePasswordHistory(serverConfig, historyType)
+		if err != nil {
+			return nil, err
+		}
+		defaultPasswordHistory = history
+	}
+	return defaultPasswordHistory, nil
+}
+
+func CreatePasswordHistory(serverConfig *config.Config, historyType PasswordHistoryType) (IPasswordHistory, error) {
+	policyConfig, err := policyConfigOf(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// history of size 0 never reports reuse
+	size := policyConfig.HistorySize
+	if policyConfig.Disabled || size < 0 {
+		size = 0
+	}
+
+	switch historyType {
+	case MemoryPasswordHistory:
+		return newMemoryHistory(size), nil
+	case BoltPasswordHistory:
+		return newBoltHistory(policyConfig.HistoryPath, size)
+	}
+	return nil, fmt.Errorf("%s is not a valid password history type", historyType)
+}
+
+func policyConfigOf(serverConfig *config.Config) (*config.PasswordPolicyConfig, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil, fmt.Errorf("auth config is nil")
+	}
+
+	if serverConfig.AuthConfig.PasswordPolicy == nil {
+		return config.DefaultConfig().AuthConfig.PasswordPolicy, nil
+	}
+	return serverConfig.AuthConfig.PasswordPolicy, nil
+}
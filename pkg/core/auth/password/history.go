@@ -0,0 +1,171 @@
+package password
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var historyBucket = []byte("passwordHistory")
+
+// reused compares password with every hash, newest first
+func reused(hashes []string, password string) bool {
+	for i := len(hashes) - 1; i >= 0; i-- {
+		if bcrypt.CompareHashAndPassword([]byte(hashes[i]), []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// appendHash keeps newest size hashes
+func appendHash(hashes []string, hash string, size int) []string {
+	hashes = append(hashes, hash)
+	if len(hashes) > size {
+		hashes = hashes[len(hashes)-size:]
+	}
+	return hashes
+}
+
+func hashOf(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// memoryHistory keeps history in memory, it is lost after restart
+type memoryHistory struct {
+	lock   sync.Mutex
+	size   int
+	hashes map[string][]string
+}
+
+func newMemoryHistory(size int) *memoryHistory {
+	return &memoryHistory{size: size, hashes: map[string][]string{}}
+}
+
+func (m *memoryHistory) Reused(userId, password string) (bool, error) {
+	m.lock.Lock()
+	hashes := m.hashes[userId]
+	m.lock.Unlock()
+
+	// compare outside of lock, bcrypt is slow on purpose
+	return reused(hashes, password), nil
+}
+
+func (m *memoryHistory) Remember(userId, password string) error {
+	if m.size == 0 {
+		return nil
+	}
+
+	hash, err := hashOf(password)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	// copy so a slice handed to Reused is never changed
+	m.hashes[userId] = appendHash(append([]string{}, m.hashes[userId]...), hash, m.size)
+	return nil
+}
+
+func (m *memoryHistory) Forget(userId string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.hashes, userId)
+	return nil
+}
+
+// boltHistory keeps history in a bbolt file so it survives restart
+type boltHistory struct {
+	db   *bbolt.DB
+	size int
+}
+
+func newBoltHistory(path string, size int) (*boltHistory, error) {
+	if path == "" {
+		return nil, fmt.Errorf("password history path is empty")
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open password history %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(historyBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltHistory{db: db, size: size}, nil
+}
+
+func (b *boltHistory) Reused(userId, password string) (bool, error) {
+	var hashes []string
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		hashes, err = getHashes(tx, userId)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	return reused(hashes, password), nil
+}
+
+func (b *boltHistory) Remember(userId, password string) error {
+	if b.size == 0 {
+		return nil
+	}
+
+	hash, err := hashOf(password)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		hashes, err := getHashes(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(appendHash(hashes, hash, b.size))
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(historyBucket).Put([]byte(userId), data)
+	})
+}
+
+func (b *boltHistory) Forget(userId string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).Delete([]byte(userId))
+	})
+}
+
+func (b *boltHistory) close() error {
+	return b.db.Close()
+}
+
+func getHashes(tx *bbolt.Tx, userId string) ([]string, error) {
+	data := tx.Bucket(historyBucket).Get([]byte(userId))
+	if data == nil {
+		return nil, nil
+	}
+
+	var hashes []string
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
@@ -0,0 +1,184 @@
+package password
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("password policy test", func() {
+	var policy *defaultPolicy
+
+	reasonsOf := func(err error) []string {
+		Expect(IsViolation(err)).To(BeTrue())
+		return err.(*Violation).Reasons
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		var err error
+		policy, err = newDefaultPolicy(config.DefaultConfig().AuthConfig.PasswordPolicy)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("Validate test", func() {
+		It("should accept a strong password", func() {
+			Expect(policy.Validate("student1", "Blue-Harbor-42")).To(BeNil())
+		})
+
+		It("should reject empty password even if policy is disabled", func() {
+			policy.disabled = true
+			Expect(reasonsOf(policy.Validate("student1", ""))).To(HaveLen(1))
+		})
+
+		It("should accept anything but empty password if policy is disabled", func() {
+			policy.disabled = true
+			Expect(policy.Validate("student1", "1")).To(BeNil())
+		})
+
+		It("should report every broken rule", func() {
+			reasons := reasonsOf(policy.Validate("student1", "abc"))
+			Expect(reasons).To(HaveLen(2))
+		})
+
+		It("should reject password that is too long", func() {
+			reasons := reasonsOf(policy.Validate("student1", "a1"+string(make([]byte, 80))))
+			Expect(reasons).To(ContainElement("at most 72 bytes are allowed"))
+		})
+
+		It("should reject common password regardless of case", func() {
+			Expect(reasonsOf(policy.Validate("student1", "PassWord123"))).To(Equal([]string{"password is too common"}))
+		})
+
+		It("should reject password containing user name", func() {
+			Expect(reasonsOf(policy.Validate("Alice", "alice-2024x"))).To(Equal([]string{"password should not contain user name"}))
+		})
+
+		It("should check required character classes", func() {
+			policy.requireUpper = true
+			policy.requireSymbol = true
+			reasons := reasonsOf(policy.Validate("student1", "harbor2024"))
+			Expect(reasons).To(Equal([]string{"an upper case letter is required", "a symbol is required"}))
+		})
+	})
+
+	Describe("deny list test", func() {
+		It("should load configured and file deny list", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "deny.txt")
+			Expect(os.WriteFile(path, []byte("Campus2024\n\n  school!2024  \n"), 0600)).To(BeNil())
+
+			policyConfig := config.DefaultConfig().AuthConfig.PasswordPolicy
+			policyConfig.DenyList = []string{"Blue-Harbor-42"}
+			policyConfig.DenyListPath = path
+			p, err := newDefaultPolicy(policyConfig)
+			Expect(err).To(BeNil())
+
+			Expect(IsViolation(p.Validate("student1", "blue-harbor-42"))).To(BeTrue())
+			Expect(IsViolation(p.Validate("student1", "campus2024"))).To(BeTrue())
+			Expect(IsViolation(p.Validate("student1", "School!2024"))).To(BeTrue())
+			Expect(p.Validate("student1", "Green-Harbor-42")).To(BeNil())
+		})
+
+		It("should be error if deny list file is missing", func() {
+			policyConfig := config.DefaultConfig().AuthConfig.PasswordPolicy
+			policyConfig.DenyListPath = filepath.Join(GinkgoT().TempDir(), "missing.txt")
+			_, err := newDefaultPolicy(policyConfig)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("password history test", func() {
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+	})
+
+	testHistory := func(create func(size int) IPasswordHistory) {
+		It("should report reuse of remembered passwords only", func() {
+			history := create(2)
+			Expect(history.Remember("u1", "first-pass-1")).To(BeNil())
+			Expect(history.Remember("u1", "second-pass-2")).To(BeNil())
+
+			reused, err := history.Reused("u1", "first-pass-1")
+			Expect(err).To(BeNil())
+			Expect(reused).To(BeTrue())
+
+			reused, err = history.Reused("u2", "first-pass-1")
+			Expect(err).To(BeNil())
+			Expect(reused).To(BeFalse())
+		})
+
+		It("should drop oldest password once history is full", func() {
+			history := create(2)
+			Expect(history.Remember("u1", "first-pass-1")).To(BeNil())
+			Expect(history.Remember("u1", "second-pass-2")).To(BeNil())
+			Expect(history.Remember("u1", "third-pass-3")).To(BeNil())
+
+			reused, _ := history.Reused("u1", "first-pass-1")
+			Expect(reused).To(BeFalse())
+			reused, _ = history.Reused("u1", "third-pass-3")
+			Expect(reused).To(BeTrue())
+		})
+
+		It("should forget user", func() {
+			history := create(2)
+			Expect(history.Remember("u1", "first-pass-1")).To(BeNil())
+			Expect(history.Forget("u1")).To(BeNil())
+			reused, _ := history.Reused("u1", "first-pass-1")
+			Expect(reused).To(BeFalse())
+		})
+
+		It("should never report reuse with size 0", func() {
+			history := create(0)
+			Expect(history.Remember("u1", "first-pass-1")).To(BeNil())
+			reused, _ := history.Reused("u1", "first-pass-1")
+			Expect(reused).To(BeFalse())
+		})
+	}
+
+	Describe("memory history", func() {
+		testHistory(func(size int) IPasswordHistory {
+			return newMemoryHistory(size)
+		})
+	})
+
+	Describe("bolt history", func() {
+		testHistory(func(size int) IPasswordHistory {
+			history, err := newBoltHistory(filepath.Join(GinkgoT().TempDir(), "history.db"), size)
+			Expect(err).To(BeNil())
+			DeferCleanup(history.close)
+			return history
+		})
+
+		It("should keep history after reopen", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "history.db")
+			history, err := newBoltHistory(path, 3)
+			Expect(err).To(BeNil())
+			Expect(history.Remember("u1", "first-pass-1")).To(BeNil())
+			Expect(history.close()).To(BeNil())
+
+			history, err = newBoltHistory(path, 3)
+			Expect(err).To(BeNil())
+			defer history.close()
+			reused, err := history.Reused("u1", "first-pass-1")
+			Expect(err).To(BeNil())
+			Expect(reused).To(BeTrue())
+		})
+	})
+
+	Describe("CreatePasswordHistory test", func() {
+		It("should be error with unknown type", func() {
+			_, err := CreatePasswordHistory(config.DefaultConfig(), "unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be error with bolt type but no path", func() {
+			_, err := CreatePasswordHistory(config.DefaultConfig(), BoltPasswordHistory)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,143 @@
+package password
+
+import (
+	"bufio"
+	"core-api/cmd/core-api-server/app/config"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// commonPasswords are refused even if deny list is not configured
+var commonPasswords = []string{
+	"password", "password1", "password123", "passw0rd", "p@ssw0rd",
+	"12345678", "123456789", "1234567890", "87654321", "11111111", "88888888",
+	"qwerty123", "qwertyuiop", "1q2w3e4r", "1qaz2wsx", "abc12345", "abcd1234",
+	"a1234567", "iloveyou", "admin123", "admin1234", "welcome1", "changeme",
+	"openhydra", "student1", "teacher1",
+}
+
+type defaultPolicy struct {
+	disabled            bool
+	minLength           int
+	maxLength           int
+	minCharacterClasses int
+	requireUpper        bool
+	requireLower        bool
+	requireDigit        bool
+	requireSymbol       bool
+	denied              map[string]struct{}
+}
+
+func newDefaultPolicy(policyConfig *config.PasswordPolicyConfig) (*defaultPolicy, error) {
+	policy := &defaultPolicy{
+		disabled:            policyConfig.Disabled,
+		minLength:           policyConfig.MinLength,
+		maxLength:           policyConfig.MaxLength,
+		minCharacterClasses: policyConfig.MinCharacterClasses,
+		requireUpper:        policyConfig.RequireUpper,
+		requireLower:        policyConfig.RequireLower,
+		requireDigit:        policyConfig.RequireDigit,
+		requireSymbol:       policyConfig.RequireSymbol,
+		denied:              map[string]struct{}{},
+	}
+
+	for _, denied := range append(commonPasswords, policyConfig.DenyList...) {
+		policy.deny(denied)
+	}
+
+	if policyConfig.DenyListPath != "" {
+		file, err := os.Open(policyConfig.DenyListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open password deny list: %w", err)
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			policy.deny(scanner.Text())
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read password deny list: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+func (p *defaultPolicy) deny(password string) {
+	password = strings.TrimSpace(password)
+	if password != "" {
+		p.denied[strings.ToLower(password)] = struct{}{}
+	}
+}
+
+func (p *defaultPolicy) Validate(userName, password string) error {
+	if password == "" {
+		return &Violation{Reasons: []string{"password is empty"}}
+	}
+
+	if p.disabled {
+		return nil
+	}
+
+	var reasons []string
+	if length := utf8.RuneCountInString(password); length < p.minLength {
+		reasons = append(reasons, fmt.Sprintf("at least %d characters are required", p.minLength))
+	}
+
+	if p.maxLength > 0 && len(password) > p.maxLength {
+		reasons = append(reasons, fmt.Sprintf("at most %d bytes are allowed", p.maxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.requireUpper && !upper {
+		reasons = append(reasons, "an upper case letter is required")
+	}
+	if p.requireLower && !lower {
+		reasons = append(reasons, "a lower case letter is required")
+	}
+	if p.requireDigit && !digit {
+		reasons = append(reasons, "a digit is required")
+	}
+	if p.requireSymbol && !symbol {
+		reasons = append(reasons, "a symbol is required")
+	}
+
+	classes := 0
+	for _, found := range []bool{upper, lower, digit, symbol} {
+		if found {
+			classes++
+		}
+	}
+	if classes < p.minCharacterClasses {
+		reasons = append(reasons, fmt.Sprintf("at least %d kinds of upper case, lower case, digit and symbol are required", p.minCharacterClasses))
+	}
+
+	lowered := strings.ToLower(password)
+	if _, found := p.denied[lowered]; found {
+		reasons = append(reasons, "password is too common")
+	}
+	if userName != "" && strings.Contains(lowered, strings.ToLower(userName)) {
+		reasons = append(reasons, "password should not contain user name")
+	}
+
+	if len(reasons) > 0 {
+		return &Violation{Reasons: reasons}
+	}
+	return nil
+}
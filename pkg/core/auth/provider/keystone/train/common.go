@@ -41,6 +41,11 @@ func ConvertKeystoneUserToCoreUser(user *User, hidePassword bool, permission map
 		result.UnEditable = true
 	}
 
+	// tenant is keystone domain, value kept in core user is never trusted
+	result.TenantId = user.DomainID
+	// so is disabled, it is keystone enabled flag
+	result.Disabled = !user.Enabled
+
 	if permission == nil {
 		permission = privileges.ModulesNoPermission()
 	}
@@ -67,6 +72,7 @@ func ConvertKeystoneRoleToCoreRole(role *Role) *core.CoreRole {
 func ConvertKeystoneGroupToCoreGroup(group *Group) *core.CoreGroup {
 	if group.CoreGroup != nil {
 		group.CoreGroup.Id = group.ID
+		group.CoreGroup.TenantId = group.DomainID
 		return group.CoreGroup
 	}
 
@@ -74,6 +80,7 @@ func ConvertKeystoneGroupToCoreGroup(group *Group) *core.CoreGroup {
 		Id:          group.ID,
 		Name:        group.Name,
 		Description: group.Description,
+		TenantId:    group.DomainID,
 	}
 }
 
@@ -109,7 +116,8 @@ func RequestToken(name, password, DomainId, keystoneEndpoint, tokenInResponse st
 		return "", nil, err
 	}
 
-	coreApiLog.Logger.Debug("Requesting token from keystone", "body", string(postBody))
+	// request body carries password so it is never logged
+	coreApiLog.Logger.Debug("Requesting token from keystone", "user", name, "domain", DomainId)
 
 	resp, header, respCode, err := common.CommonRequest(common.BuildPath(keystoneEndpoint, "/v3/auth/tokens"), http.MethodPost, "", postBody, nil, true, false, 3*time.Second)
 	if err != nil {
@@ -140,9 +148,12 @@ func RequestToken(name, password, DomainId, keystoneEndpoint, tokenInResponse st
 	return token, tokenResp, nil
 }
 
-func commentRequestAutoRenewToken(path, method string, keystoneConfig *config.KeystoneConfig, getToken func() (string, error), setToken func(token string), body json.RawMessage) ([]byte, http.Header, int, error) {
+// commentRequestAutoRenewToken requests keystone with admin token shared by all providers
+// token rejected by keystone is renewed once and request is retried
+func commentRequestAutoRenewToken(path, method string, keystoneConfig *config.KeystoneConfig, body json.RawMessage) ([]byte, http.Header, int, error) {
 	tokenHeaderKey := common.GetStringValueOrDefault(keystoneConfig.TokenKeyInRequest, "X-Auth-Token")
-	token, err := getToken()
+	tokenManager := InitOrGetTokenManager(keystoneConfig)
+	token, err := tokenManager.Token()
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get token", "error", err)
 		return nil, nil, -1, err
@@ -151,14 +162,14 @@ func commentRequestAutoRenewToken(path, method string, keystoneConfig *config.Ke
 	result, header, code, err := common.CommonRequest(reqURL, method, "", body, map[string][]string{tokenHeaderKey: {token}}, true, false, 3*time.Second)
 
 	if code == http.StatusUnauthorized {
-		coreApiLog.Logger.Warn("Token may expired, attempt to renew the token and retry for one shot")
-		// token may expired, request a new one
-		newToken, _, err := RequestToken(keystoneConfig.Username, keystoneConfig.Password, keystoneConfig.DomainId, keystoneConfig.Endpoint, keystoneConfig.TokenKeyInResponse, true)
+		coreApiLog.Logger.Warn("Token may be revoked, attempt to renew the token and retry for one shot")
+		// token may be revoked before it expires, request a new one
+		tokenManager.Invalidate(token)
+		newToken, err := tokenManager.Token()
 		if err != nil {
 			coreApiLog.Logger.Error("Failed to renew token", "error", err)
 			return nil, nil, -1, err
 		}
-		setToken(newToken)
 		return common.CommonRequest(reqURL, method, "", body, map[string][]string{tokenHeaderKey: {newToken}}, true, false, 3*time.Second)
 	}
 
@@ -196,12 +207,11 @@ func sumPermission(userRelatedRoles []core.CoreRole, allRoles map[string]core.Co
 	return initPermission
 }
 
-func getRoleMap(serverConfig *config.Config, token string, options map[string]struct{}) (map[string]core.CoreRole, error) {
+func getRoleMap(serverConfig *config.Config, options map[string]struct{}) (map[string]core.CoreRole, error) {
 
 	mapRoles := make(map[string]core.CoreRole)
 	// load roles
 	roleProvider := &RoleProvider{Config: serverConfig}
-	roleProvider.setToken(token)
 	roles, err := roleProvider.GetRoles(options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get roles", "error", err)
@@ -215,12 +225,40 @@ func getRoleMap(serverConfig *config.Config, token string, options map[string]st
 	return mapRoles, nil
 }
 
-func getGroupMap(serverConfig *config.Config, token string, options map[string]struct{}) (map[string]core.CoreGroup, error) {
+// getUserRoleMap loads roles of a user only, roles are taken from index while they are fresh
+// so loading permission does not list all roles on every login
+func getUserRoleMap(serverConfig *config.Config, userRoles []core.CoreRole, options map[string]struct{}) (map[string]core.CoreRole, error) {
+	mapRoles := make(map[string]core.CoreRole)
+	roleProvider := &RoleProvider{Config: serverConfig}
+	for _, userRole := range userRoles {
+		if _, found := mapRoles[userRole.Id]; found {
+			continue
+		}
+
+		role, err := roleProvider.getCachedRawKeystoneRole(userRole.Id)
+		if customError.IsNotFound(err) {
+			// role deleted is reported by sumPermission
+			continue
+		}
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to get role", "role", userRole.Id, "error", err)
+			return nil, err
+		}
+
+		// filter out that origin keystone role if LoadUnRelatedKeystoneObjects is not enabled
+		if _, found := options[LoadUnRelatedKeystoneObjects]; !found && role.CoreRole == nil {
+			continue
+		}
+		mapRoles[userRole.Id] = *ConvertKeystoneRoleToCoreRole(role)
+	}
+	return mapRoles, nil
+}
+
+func getGroupMap(serverConfig *config.Config, options map[string]struct{}) (map[string]core.CoreGroup, error) {
 
 	mapGroups := make(map[string]core.CoreGroup)
 	// load groups
 	groupProvider := &GroupProvider{Config: serverConfig}
-	groupProvider.setToken(token)
 	groups, err := groupProvider.GetGroups(options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get groups", "error", err)
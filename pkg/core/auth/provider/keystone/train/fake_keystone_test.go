@@ -0,0 +1,255 @@
+package train
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	core "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+const fakeKeystoneToken = "fake-token"
+
+// fakeKeystone serves the part of keystone api used by providers and counts requests it receives
+// users, groups and roles are filtered by name like keystone does
+type fakeKeystone struct {
+	server *httptest.Server
+
+	lock     sync.Mutex
+	users    map[string]User
+	groups   map[string]Group
+	roles    map[string]Role
+	requests map[string]int
+}
+
+func newFakeKeystone(userCount, roleCount int) *fakeKeystone {
+	k := &fakeKeystone{
+		users:    map[string]User{},
+		groups:   map[string]Group{},
+		roles:    map[string]Role{},
+		requests: map[string]int{},
+	}
+
+	for i := 0; i < roleCount; i++ {
+		id := fmt.Sprintf("role%did", i)
+		name := fmt.Sprintf("role%d", i)
+		k.roles[id] = Role{ID: id, Name: name, CoreRole: &core.CoreRole{Id: id, Name: name, Permission: map[string]uint64{"course": 1 << (i % 4)}}}
+	}
+
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("group%did", i)
+		name := fmt.Sprintf("group%d", i)
+		k.groups[id] = Group{ID: id, Name: name, CoreGroup: &core.CoreGroup{Id: id, Name: name}}
+	}
+
+	for i := 0; i < userCount; i++ {
+		id := fmt.Sprintf("student%did", i)
+		name := fmt.Sprintf("student%d", i)
+		var roles []core.CoreRole
+		if roleCount > 0 {
+			roles = []core.CoreRole{{Id: fmt.Sprintf("role%did", i%roleCount)}}
+		}
+		k.users[id] = User{ID: id, Name: name, Enabled: true, CoreUser: &core.CoreUser{Id: id, Name: name, Roles: roles}}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v3/auth/tokens", k.issueToken)
+	mux.HandleFunc("GET /v3/users", k.authorized("list users", func(w http.ResponseWriter, r *http.Request) {
+		var users []User
+		k.lock.Lock()
+		for _, user := range k.users {
+			if matchName(r, user.Name) {
+				users = append(users, user)
+			}
+		}
+		k.lock.Unlock()
+		writeJson(w, UserContainer{Users: users})
+	}))
+	mux.HandleFunc("GET /v3/users/{id}", k.authorized("get user", func(w http.ResponseWriter, r *http.Request) {
+		k.lock.Lock()
+		user, found := k.users[r.PathValue("id")]
+		k.lock.Unlock()
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJson(w, map[string]User{"user": user})
+	}))
+	mux.HandleFunc("PATCH /v3/users/{id}", k.authorized("update user", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			User map[string]interface{} `json:"user"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		k.lock.Lock()
+		defer k.lock.Unlock()
+		user, found := k.users[r.PathValue("id")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if enabled, ok := body.User["enabled"].(bool); ok {
+			user.Enabled = enabled
+		}
+		if coreUser, ok := body.User["core_user"]; ok {
+			data, _ := json.Marshal(coreUser)
+			user.CoreUser = &core.CoreUser{}
+			if err := json.Unmarshal(data, user.CoreUser); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		k.users[user.ID] = user
+		writeJson(w, map[string]User{"user": user})
+	}))
+	mux.HandleFunc("DELETE /v3/users/{id}", k.authorized("delete user", func(w http.ResponseWriter, r *http.Request) {
+		k.lock.Lock()
+		delete(k.users, r.PathValue("id"))
+		k.lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("GET /v3/roles", k.authorized("list roles", func(w http.ResponseWriter, r *http.Request) {
+		var roles []Role
+		k.lock.Lock()
+		for _, role := range k.roles {
+			if matchName(r, role.Name) {
+				roles = append(roles, role)
+			}
+		}
+		k.lock.Unlock()
+		writeJson(w, RoleContainer{Roles: roles})
+	}))
+	mux.HandleFunc("GET /v3/roles/{id}", k.authorized("get role", func(w http.ResponseWriter, r *http.Request) {
+		k.lock.Lock()
+		role, found := k.roles[r.PathValue("id")]
+		k.lock.Unlock()
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJson(w, map[string]Role{"role": role})
+	}))
+	mux.HandleFunc("GET /v3/groups", k.authorized("list groups", func(w http.ResponseWriter, r *http.Request) {
+		var groups []Group
+		k.lock.Lock()
+		for _, group := range k.groups {
+			if matchName(r, group.Name) && matchDomain(r, group.DomainID) {
+				groups = append(groups, group)
+			}
+		}
+		k.lock.Unlock()
+		writeJson(w, GroupContainer{Groups: groups})
+	}))
+	mux.HandleFunc("GET /v3/groups/{id}", k.authorized("get group", func(w http.ResponseWriter, r *http.Request) {
+		k.lock.Lock()
+		group, found := k.groups[r.PathValue("id")]
+		k.lock.Unlock()
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJson(w, map[string]Group{"group": group})
+	}))
+
+	k.server = httptest.NewServer(mux)
+	return k
+}
+
+func (k *fakeKeystone) config() *config.Config {
+	return &config.Config{
+		AuthConfig: &config.AuthConfig{
+			Keystone: &config.KeystoneConfig{
+				Endpoint: k.server.URL,
+				Username: "admin",
+				Password: "admin",
+				DomainId: "default",
+			},
+		},
+	}
+}
+
+func (k *fakeKeystone) close() {
+	k.server.Close()
+}
+
+// count returns number of requests of kind, e.g. 'list users', a request filtered by name is counted as 'list users by name'
+func (k *fakeKeystone) count(kind string) int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.requests[kind]
+}
+
+func (k *fakeKeystone) total() int {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	total := 0
+	for _, count := range k.requests {
+		total += count
+	}
+	return total
+}
+
+func (k *fakeKeystone) authorized(kind string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != fakeKeystoneToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		counted := kind
+		if r.URL.Query().Has("name") {
+			counted = kind + " by name"
+		}
+		k.lock.Lock()
+		k.requests[counted]++
+		k.lock.Unlock()
+		handler(w, r)
+	}
+}
+
+// issueToken accepts any user whose password equals its name
+func (k *fakeKeystone) issueToken(w http.ResponseWriter, r *http.Request) {
+	var auth AuthRequest
+	if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	k.lock.Lock()
+	k.requests["issue token"]++
+	k.lock.Unlock()
+
+	user := auth.Auth.Identity.Password.User
+	if user.Name == "" || user.Name != user.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("X-Subject-Token", fakeKeystoneToken)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&TokenResponse{Token: Token{
+		User:      User{Name: user.Name},
+		IssuedAt:  "2021-08-10T13:00:00.000000Z",
+		ExpiresAt: "2021-08-10T14:00:00.000000Z",
+	}})
+}
+
+func matchName(r *http.Request, name string) bool {
+	query := r.URL.Query()
+	return !query.Has("name") || query.Get("name") == name
+}
+
+// matchDomain treats object without domain as one of default domain
+func matchDomain(r *http.Request, domainId string) bool {
+	query := r.URL.Query()
+	return !query.Has("domain_id") || query.Get("domain_id") == getDomainOrDefault(domainId)
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -9,11 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 type GroupProvider struct {
 	Config *config.Config
-	token  string
 }
 
 func (gp *GroupProvider) GetGroups(options map[string]struct{}) ([]core.CoreGroup, error) {
@@ -40,7 +40,17 @@ func (gp *GroupProvider) GetGroups(options map[string]struct{}) ([]core.CoreGrou
 }
 
 func (gp *GroupProvider) GetRawKeystoneGroupList() (*GroupContainer, error) {
-	body, _, _, err := commentRequestAutoRenewToken("/v3/groups", http.MethodGet, gp.Config.AuthConfig.Keystone, gp.getToken, gp.setToken, nil)
+	return gp.listRawKeystoneGroups(nil)
+}
+
+// listRawKeystoneGroups lists groups matching query, e.g. name or domain_id
+func (gp *GroupProvider) listRawKeystoneGroups(query url.Values) (*GroupContainer, error) {
+	path := "/v3/groups"
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	body, _, _, err := commentRequestAutoRenewToken(path, http.MethodGet, gp.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to list groups", "error", err)
 		return nil, err
@@ -55,23 +65,8 @@ func (gp *GroupProvider) GetRawKeystoneGroupList() (*GroupContainer, error) {
 	return groupCollection, nil
 }
 
-func (gp *GroupProvider) getToken() (string, error) {
-	if gp.token == "" {
-		token, _, err := RequestToken(gp.Config.AuthConfig.Keystone.Username, gp.Config.AuthConfig.Keystone.Password, common.GetStringValueOrDefault(gp.Config.AuthConfig.Keystone.DomainId, KeystoneDefaultDomainId), gp.Config.AuthConfig.Keystone.Endpoint, gp.Config.AuthConfig.Keystone.TokenKeyInResponse, true)
-		if err != nil {
-			return "", err
-		}
-		gp.token = token
-	}
-	return gp.token, nil
-}
-
-func (gp *GroupProvider) setToken(token string) {
-	gp.token = token
-}
-
 func (gp *GroupProvider) GetGroup(id string, options map[string]struct{}) (*core.CoreGroup, error) {
-	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", id), http.MethodGet, gp.Config.AuthConfig.Keystone, gp.getToken, gp.setToken, nil)
+	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", url.PathEscape(id)), http.MethodGet, gp.Config.AuthConfig.Keystone, nil)
 	if code == http.StatusNotFound {
 		return nil, customErr.NewNotFound(code, fmt.Sprintf("Group %s not found", id))
 	}
@@ -88,6 +83,9 @@ func (gp *GroupProvider) GetGroup(id string, options map[string]struct{}) (*core
 		coreApiLog.Logger.Error("Failed to unmarshal gropu", "error", err)
 		return nil, err
 	}
+	if groupContainer.Group == nil {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Group %s not found", id))
+	}
 
 	// filter out that origin keystone group if LoadUnRelatedKeystoneGroups is not enabled
 	if _, found := options[LoadUnRelatedKeystoneObjects]; !found && groupContainer.Group.CoreGroup == nil {
@@ -118,7 +116,7 @@ func (gp *GroupProvider) UpdateGroup(group *core.CoreGroup, options map[string]s
 		return err
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", group.Id), http.MethodPatch, gp.Config.AuthConfig.Keystone, gp.getToken, gp.setToken, postBody)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", group.Id), http.MethodPatch, gp.Config.AuthConfig.Keystone, postBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to update group", "error", err)
 		return err
@@ -143,7 +141,6 @@ func (gp *GroupProvider) DeleteGroup(id string, options map[string]struct{}) err
 	coreApiLog.Logger.Debug("Attempting to remove group from users", "group", id)
 	// ge all users
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	users, err := userProvider.GetUsers(nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get users, failed to remove delete group for users", "error", err)
@@ -156,11 +153,12 @@ func (gp *GroupProvider) DeleteGroup(id string, options map[string]struct{}) err
 		}
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", id), http.MethodDelete, gp.Config.AuthConfig.Keystone, gp.getToken, gp.setToken, nil)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/groups/%s", id), http.MethodDelete, gp.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to delete group", "error", err)
 		return err
 	}
+	indexOf(gp.Config.AuthConfig.Keystone).groupIds.remove(domainIndexKey(getDomainOrDefault(group.TenantId), group.Name))
 
 	return nil
 }
@@ -170,7 +168,7 @@ func (gp *GroupProvider) CreateGroup(group *core.CoreGroup, options map[string]s
 		Name:        group.Name,
 		Description: group.Description,
 		ID:          group.Id,
-		DomainID:    gp.Config.AuthConfig.Keystone.DomainId,
+		DomainID:    common.GetStringValueOrDefault(group.TenantId, gp.Config.AuthConfig.Keystone.DomainId),
 		CoreGroup:   group,
 	}
 
@@ -182,7 +180,7 @@ func (gp *GroupProvider) CreateGroup(group *core.CoreGroup, options map[string]s
 		return nil, err
 	}
 
-	body, _, status, err := commentRequestAutoRenewToken("/v3/groups", http.MethodPost, gp.Config.AuthConfig.Keystone, gp.getToken, gp.setToken, groupBody)
+	body, _, status, err := commentRequestAutoRenewToken("/v3/groups", http.MethodPost, gp.Config.AuthConfig.Keystone, groupBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to create group", "error", err)
 		return nil, err
@@ -206,15 +204,45 @@ func (gp *GroupProvider) CreateGroup(group *core.CoreGroup, options map[string]s
 }
 
 func (gp *GroupProvider) SearchGroupByName(name string, options map[string]struct{}) (*core.CoreGroup, error) {
-	groups, err := gp.GetGroups(options)
+	return gp.SearchTenantGroupByName(gp.Config.AuthConfig.Keystone.DomainId, name, options)
+}
+
+// SearchTenantGroupByName searches group in keystone domain of tenant, group name is only unique in its domain
+func (gp *GroupProvider) SearchTenantGroupByName(tenantId, name string, options map[string]struct{}) (*core.CoreGroup, error) {
+	domainId := getDomainOrDefault(tenantId)
+	key := domainIndexKey(domainId, name)
+	index := indexOf(gp.Config.AuthConfig.Keystone)
+	if id, found := index.groupIds.get(key); found {
+		group, err := gp.GetGroup(id, options)
+		if err == nil && group.Name == name && getDomainOrDefault(group.TenantId) == domainId {
+			return group, nil
+		}
+		if err != nil && !customErr.IsNotFound(err) {
+			return nil, err
+		}
+		// group is renamed or deleted after it is indexed
+		index.groupIds.remove(key)
+	}
+
+	groups, err := gp.listRawKeystoneGroups(url.Values{
+		"name":      {name},
+		"domain_id": {domainId},
+	})
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get groups", "error", err)
 		return nil, err
 	}
 
-	for _, group := range groups {
-		if group.Name == name {
-			return &group, nil
+	for _, group := range groups.Groups {
+		// filter out that origin keystone group if LoadUnRelatedKeystoneGroups is not enabled
+		if _, found := options[LoadUnRelatedKeystoneObjects]; !found && group.CoreGroup == nil {
+			continue
+		}
+
+		coreGroup := ConvertKeystoneGroupToCoreGroup(&group)
+		if coreGroup.Name == name {
+			index.groupIds.put(key, group.ID)
+			return coreGroup, nil
 		}
 	}
 
@@ -224,7 +252,6 @@ func (gp *GroupProvider) SearchGroupByName(name string, options map[string]struc
 func (gp *GroupProvider) AddUserToGroup(userId, groupId string) error {
 	// get user
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	user, err := userProvider.GetUser(userId, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get user", "error", err)
@@ -263,7 +290,6 @@ func (gp *GroupProvider) AddUserToGroup(userId, groupId string) error {
 func (gp *GroupProvider) RemoveUserFromGroup(userId, groupId string) error {
 	// get user
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	user, err := userProvider.GetUser(userId, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get user", "error", err)
@@ -313,7 +339,6 @@ func (gp *GroupProvider) GetGroupUsers(groupId string, options map[string]struct
 
 	// get all users
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	users, err := userProvider.GetUsers(nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get users", "error", err)
@@ -370,7 +395,6 @@ func (gp *GroupProvider) GetGroupSummary(options map[string]struct{}) (*core.Cor
 
 	// get all users
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	users, err := userProvider.GetUsers(options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get users", "error", err)
@@ -407,7 +431,6 @@ func (gp *GroupProvider) AddUsersToGroup(groupId string, users []core.CoreUser)
 	}
 
 	userProvider := &UserProvider{Config: gp.Config}
-	userProvider.setToken(gp.token)
 	for index, user := range users {
 		userFound, err := userProvider.GetUser(user.Id, nil)
 		if err != nil {
@@ -0,0 +1,151 @@
+package train
+
+import (
+	"container/list"
+	"core-api/cmd/core-api-server/app/config"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIndexSize = 4096
+	defaultIndexTTL  = 60 * time.Second
+	// a role changed through a replica is dropped from index of that replica only, other replicas load it again
+	// once it expires, so it bounds how long they keep granting old permission whatever index ttl is configured
+	maxRoleIndexTTL = 60 * time.Second
+)
+
+// lookupIndex is a bounded cache for single object lookups
+// least recently used entry is evicted when it is full, entries older than ttl are never returned
+type lookupIndex[V any] struct {
+	lock     sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+type indexEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newLookupIndex[V any](capacity int, ttl time.Duration) *lookupIndex[V] {
+	return &lookupIndex[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (i *lookupIndex[V]) get(key string) (V, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var empty V
+	element, found := i.entries[key]
+	if !found {
+		return empty, false
+	}
+
+	entry := element.Value.(*indexEntry[V])
+	if !i.now().Before(entry.expiresAt) {
+		i.order.Remove(element)
+		delete(i.entries, key)
+		return empty, false
+	}
+
+	i.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (i *lookupIndex[V]) put(key string, value V) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	expiresAt := i.now().Add(i.ttl)
+	if element, found := i.entries[key]; found {
+		entry := element.Value.(*indexEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		i.order.MoveToFront(element)
+		return
+	}
+
+	i.entries[key] = i.order.PushFront(&indexEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	for i.order.Len() > i.capacity {
+		oldest := i.order.Back()
+		i.order.Remove(oldest)
+		delete(i.entries, oldest.Value.(*indexEntry[V]).key)
+	}
+}
+
+func (i *lookupIndex[V]) remove(key string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if element, found := i.entries[key]; found {
+		i.order.Remove(element)
+		delete(i.entries, key)
+	}
+}
+
+func (i *lookupIndex[V]) len() int {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.order.Len()
+}
+
+// keystoneIndex is shared by every provider talking to the same keystone
+type keystoneIndex struct {
+	// name to id, user and group names are only unique in their domain so users and groups are keyed with domainIndexKey
+	userIds  *lookupIndex[string]
+	groupIds *lookupIndex[string]
+	roleIds  *lookupIndex[string]
+	// id to role, so loading permission of a user does not list all roles, entries live maxRoleIndexTTL at most
+	roles *lookupIndex[Role]
+}
+
+func domainIndexKey(domainId, name string) string {
+	return domainId + "/" + name
+}
+
+var keystoneIndexes = map[string]*keystoneIndex{}
+var keystoneIndexesLock sync.Mutex
+
+func indexOf(keystoneConfig *config.KeystoneConfig) *keystoneIndex {
+	keystoneIndexesLock.Lock()
+	defer keystoneIndexesLock.Unlock()
+
+	key := keystoneKey(keystoneConfig)
+	if index, found := keystoneIndexes[key]; found {
+		return index
+	}
+
+	size := keystoneConfig.IndexSize
+	if size <= 0 {
+		size = defaultIndexSize
+	}
+	ttl := time.Duration(keystoneConfig.IndexTTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultIndexTTL
+	}
+
+	roleTTL := ttl
+	if roleTTL > maxRoleIndexTTL {
+		roleTTL = maxRoleIndexTTL
+	}
+
+	index := &keystoneIndex{
+		userIds:  newLookupIndex[string](size, ttl),
+		groupIds: newLookupIndex[string](size, ttl),
+		roleIds:  newLookupIndex[string](size, ttl),
+		roles:    newLookupIndex[Role](size, roleTTL),
+	}
+	keystoneIndexes[key] = index
+	return index
+}
@@ -0,0 +1,287 @@
+package train
+
+import (
+	"time"
+
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("keystone lookup index tests", func() {
+	var index *lookupIndex[string]
+	var now time.Time
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		now = time.Date(2021, 8, 10, 13, 0, 0, 0, time.UTC)
+		index = newLookupIndex[string](2, time.Minute)
+		index.now = func() time.Time { return now }
+	})
+
+	It("should evict least recently used entry when it is full", func() {
+		index.put("a", "1")
+		index.put("b", "2")
+		_, found := index.get("a")
+		Expect(found).To(BeTrue())
+
+		index.put("c", "3")
+		Expect(index.len()).To(Equal(2))
+		_, found = index.get("b")
+		Expect(found).To(BeFalse())
+		value, found := index.get("a")
+		Expect(found).To(BeTrue())
+		Expect(value).To(Equal("1"))
+	})
+
+	It("should not return expired entry", func() {
+		index.put("a", "1")
+		now = now.Add(time.Minute)
+		_, found := index.get("a")
+		Expect(found).To(BeFalse())
+		Expect(index.len()).To(Equal(0))
+	})
+
+	It("should replace and remove entry", func() {
+		index.put("a", "1")
+		index.put("a", "2")
+		value, _ := index.get("a")
+		Expect(value).To(Equal("2"))
+		Expect(index.len()).To(Equal(1))
+
+		index.remove("a")
+		_, found := index.get("a")
+		Expect(found).To(BeFalse())
+	})
+
+	It("should not keep roles longer than bound of other replicas", func() {
+		keystoneConfig := &config.KeystoneConfig{Endpoint: "http://keystone-with-long-ttl:5000", IndexTTLSeconds: 3600}
+		keystoneIndex := indexOf(keystoneConfig)
+		Expect(keystoneIndex.roles.ttl).To(Equal(maxRoleIndexTTL))
+		Expect(keystoneIndex.roleIds.ttl).To(Equal(time.Hour))
+	})
+})
+
+var _ = Describe("keystone server side filtering tests", func() {
+	var keystone *fakeKeystone
+	var userProvider *UserProvider
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		keystone = newFakeKeystone(5000, 20)
+		userProvider = &UserProvider{Config: keystone.config()}
+	})
+
+	AfterEach(func() {
+		keystone.close()
+	})
+
+	Describe("SearchUserByName test", func() {
+		It("should ask keystone to filter users by name", func() {
+			user, err := userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("student42id"))
+			Expect(keystone.count("list users")).To(Equal(0))
+			Expect(keystone.count("list users by name")).To(Equal(1))
+
+			// id is taken from index next time
+			user, err = userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("student42id"))
+			Expect(keystone.count("list users by name")).To(Equal(1))
+			Expect(keystone.count("get user")).To(Equal(1))
+		})
+
+		It("should be not found", func() {
+			_, err := userProvider.SearchUserByName("nobody", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+			Expect(keystone.count("list users")).To(Equal(0))
+		})
+
+		It("should search again if indexed user is deleted", func() {
+			_, err := userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+
+			keystone.lock.Lock()
+			delete(keystone.users, "student42id")
+			keystone.lock.Unlock()
+
+			_, err = userProvider.SearchUserByName("student42", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+			Expect(keystone.count("list users by name")).To(Equal(2))
+		})
+
+		It("should search again if indexed user is renamed", func() {
+			_, err := userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+
+			keystone.lock.Lock()
+			renamed := keystone.users["student42id"]
+			renamed.Name = "renamed"
+			keystone.users["student42id"] = renamed
+			keystone.lock.Unlock()
+
+			_, err = userProvider.SearchUserByName("student42", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should not find disabled user unless it is asked for", func() {
+			err := userProvider.SetUserDisabled("student42id", true)
+			Expect(err).To(BeNil())
+			Expect(keystone.users["student42id"].Enabled).To(BeFalse())
+
+			_, err = userProvider.SearchUserByName("student42", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+			user, err := userProvider.SearchUserByName("student42", map[string]struct{}{IncludeDisabledUsers: {}})
+			Expect(err).To(BeNil())
+			Expect(user.Disabled).To(BeTrue())
+
+			err = userProvider.SetUserDisabled("student42id", false)
+			Expect(err).To(BeNil())
+			user, err = userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+			Expect(user.Disabled).To(BeFalse())
+		})
+
+		It("should forget deleted user", func() {
+			_, err := userProvider.SearchUserByName("student42", nil)
+			Expect(err).To(BeNil())
+
+			err = userProvider.DeleteUser("student42id", nil)
+			Expect(err).To(BeNil())
+			_, found := indexOf(userProvider.Config.AuthConfig.Keystone).userIds.get(domainIndexKey("default", "student42"))
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("LoginUser test", func() {
+		It("should load permission with roles of user only", func() {
+			user, err := userProvider.LoginUser("student5", "student5")
+			Expect(err).To(BeNil())
+			Expect(user.Permission["course"]).To(Equal(uint64(1 << 1)))
+			Expect(keystone.count("list users")).To(Equal(0))
+			Expect(keystone.count("list roles")).To(Equal(0))
+			Expect(keystone.count("get role")).To(Equal(1))
+
+			// role is cached
+			_, err = userProvider.LoginUser("student25", "student25")
+			Expect(err).To(BeNil())
+			Expect(keystone.count("get role")).To(Equal(1))
+		})
+
+		It("should reload role after it is updated", func() {
+			_, err := userProvider.LoginUser("student5", "student5")
+			Expect(err).To(BeNil())
+
+			indexOf(userProvider.Config.AuthConfig.Keystone).roles.remove("role5id")
+			_, err = userProvider.LoginUser("student5", "student5")
+			Expect(err).To(BeNil())
+			Expect(keystone.count("get role")).To(Equal(2))
+		})
+	})
+
+	Describe("GetUserIdFromName test", func() {
+		It("should ask keystone to filter users by name", func() {
+			id, err := userProvider.GetUserIdFromName("student7")
+			Expect(err).To(BeNil())
+			Expect(id).To(Equal("student7id"))
+			Expect(keystone.count("list users")).To(Equal(0))
+		})
+	})
+
+	Describe("legacy password test", func() {
+		It("should hand out legacy password only when it is asked for and scrub it", func() {
+			keystone.lock.Lock()
+			legacy := keystone.users["student3id"]
+			legacy.CoreUser.Password = "legacy-password"
+			legacy.Enabled = false
+			keystone.users["student3id"] = legacy
+			keystone.lock.Unlock()
+
+			findUser := func(users []core.CoreUser, id string) *core.CoreUser {
+				for i := range users {
+					if users[i].Id == id {
+						return &users[i]
+					}
+				}
+				return nil
+			}
+
+			users, err := userProvider.GetUsers(map[string]struct{}{IncludeDisabledUsers: {}})
+			Expect(err).To(BeNil())
+			Expect(findUser(users, "student3id").Password).To(BeEmpty())
+
+			users, err = userProvider.GetUsers(map[string]struct{}{IncludeDisabledUsers: {}, LoadLegacyPassword: {}})
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(5000))
+			Expect(findUser(users, "student3id").Password).To(Equal("legacy-password"))
+			Expect(findUser(users, "student3id").Disabled).To(BeTrue())
+
+			scrubbed, err := userProvider.ScrubLegacyPasswords()
+			Expect(err).To(BeNil())
+			Expect(scrubbed).To(Equal([]string{"student3"}))
+			Expect(keystone.count("update user")).To(Equal(1))
+			Expect(keystone.users["student3id"].CoreUser.Password).To(BeEmpty())
+			Expect(keystone.users["student3id"].CoreUser.Name).To(Equal("student3"))
+
+			users, err = userProvider.GetUsers(map[string]struct{}{IncludeDisabledUsers: {}, LoadLegacyPassword: {}})
+			Expect(err).To(BeNil())
+			Expect(findUser(users, "student3id").Password).To(BeEmpty())
+		})
+	})
+
+	Describe("SearchGroupByName test", func() {
+		It("should ask keystone to filter groups by name", func() {
+			groupProvider := &GroupProvider{Config: keystone.config()}
+			group, err := groupProvider.SearchGroupByName("group3", nil)
+			Expect(err).To(BeNil())
+			Expect(group.Id).To(Equal("group3id"))
+			Expect(keystone.count("list groups")).To(Equal(0))
+			Expect(keystone.count("list groups by name")).To(Equal(1))
+
+			_, err = groupProvider.SearchGroupByName("group3", nil)
+			Expect(err).To(BeNil())
+			Expect(keystone.count("list groups by name")).To(Equal(1))
+			Expect(keystone.count("get group")).To(Equal(1))
+		})
+
+		It("should tell apart groups of same name in different domains", func() {
+			keystone.lock.Lock()
+			keystone.groups["school-b-group3id"] = Group{ID: "school-b-group3id", Name: "group3", DomainID: "school-b", CoreGroup: &core.CoreGroup{Id: "school-b-group3id", Name: "group3"}}
+			keystone.lock.Unlock()
+
+			groupProvider := &GroupProvider{Config: keystone.config()}
+			for i := 0; i < 2; i++ {
+				group, err := groupProvider.SearchGroupByName("group3", nil)
+				Expect(err).To(BeNil())
+				Expect(group.Id).To(Equal("group3id"))
+				group, err = groupProvider.SearchTenantGroupByName("school-b", "group3", nil)
+				Expect(err).To(BeNil())
+				Expect(group.Id).To(Equal("school-b-group3id"))
+			}
+			// second round is served by index
+			Expect(keystone.count("list groups by name")).To(Equal(2))
+
+			_, err := groupProvider.SearchTenantGroupByName("school-c", "group3", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("SearchRoleByName test", func() {
+		It("should ask keystone to filter roles by name", func() {
+			roleProvider := &RoleProvider{Config: keystone.config()}
+			role, err := roleProvider.SearchRoleByName("role3", nil)
+			Expect(err).To(BeNil())
+			Expect(role.Id).To(Equal("role3id"))
+			Expect(keystone.count("list roles")).To(Equal(0))
+			Expect(keystone.count("list roles by name")).To(Equal(1))
+
+			_, err = roleProvider.SearchRoleByName("missing", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
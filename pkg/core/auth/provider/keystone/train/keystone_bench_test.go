@@ -0,0 +1,76 @@
+package train
+
+import (
+	"fmt"
+	"testing"
+
+	coreApiLog "core-api/pkg/logger"
+)
+
+// benchmarks run against a fake keystone holding as many students as a large tenant
+// requests/op tells how many keystone requests a single call costs
+const benchmarkUserCount = 5000
+
+func benchmarkKeystone(b *testing.B) (*fakeKeystone, *UserProvider) {
+	coreApiLog.InitLogger("ERROR")
+	keystone := newFakeKeystone(benchmarkUserCount, 20)
+	b.Cleanup(keystone.close)
+	return keystone, &UserProvider{Config: keystone.config()}
+}
+
+func reportRequests(b *testing.B, keystone *fakeKeystone, before int) {
+	b.ReportMetric(float64(keystone.total()-before)/float64(b.N), "requests/op")
+}
+
+func BenchmarkLoginUser(b *testing.B) {
+	keystone, userProvider := benchmarkKeystone(b)
+	before := keystone.total()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		name := fmt.Sprintf("student%d", i%benchmarkUserCount)
+		if _, err := userProvider.LoginUser(name, name); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRequests(b, keystone, before)
+}
+
+func BenchmarkSearchUserByName(b *testing.B) {
+	keystone, userProvider := benchmarkKeystone(b)
+	before := keystone.total()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := userProvider.SearchUserByName(fmt.Sprintf("student%d", i%benchmarkUserCount), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRequests(b, keystone, before)
+}
+
+func BenchmarkSearchUserByNameIndexed(b *testing.B) {
+	keystone, userProvider := benchmarkKeystone(b)
+	if _, err := userProvider.SearchUserByName("student42", nil); err != nil {
+		b.Fatal(err)
+	}
+	before := keystone.total()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := userProvider.SearchUserByName("student42", nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRequests(b, keystone, before)
+}
+
+// BenchmarkGetUsers is the cost of a full listing, which name lookups used to pay
+func BenchmarkGetUsers(b *testing.B) {
+	keystone, userProvider := benchmarkKeystone(b)
+	before := keystone.total()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := userProvider.GetUsers(map[string]struct{}{LoadPermission: {}}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	reportRequests(b, keystone, before)
+}
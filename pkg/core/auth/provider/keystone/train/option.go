@@ -4,9 +4,6 @@ const (
 	// LoadPermission is a constant of type string
 	// indicate function handle user should also load LoadPermission details
 	LoadPermission = "loadPermission"
-	// loadPasswd is a constant of type string
-	// indicate function handle user should also load password
-	LoadPasswd = "loadPasswd"
 	// LoadUnRelatedKeystoneRoles is a constant of type string
 	// indicate whether role handler should skip role that do not have corerole object
 	LoadUnRelatedKeystoneObjects = "loadUnRelatedKeystoneObjects"
@@ -16,4 +13,11 @@ const (
 	// ReverseGetGroupUsers is a constant of type string
 	// ReverseGetGroupUsers function will force GetGroupUsers to reverse the search result
 	ReverseGetGroupUsers = "reverseGetGroupUsers"
+	// IncludeDisabledUsers is a constant of type string
+	// IncludeDisabledUsers function handle user list or search should not skip disabled users
+	IncludeDisabledUsers = "includeDisabledUsers"
+	// LoadLegacyPassword is a constant of type string
+	// LoadLegacyPassword function handle user list should hand out password former versions kept in core user
+	// it is only used by keystone migration, which scrubs it afterwards with ScrubLegacyPasswords
+	LoadLegacyPassword = "loadLegacyPassword"
 )
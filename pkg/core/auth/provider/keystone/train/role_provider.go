@@ -4,16 +4,15 @@ import (
 	"core-api/cmd/core-api-server/app/config"
 	coreApiLog "core-api/pkg/logger"
 	core "core-api/pkg/north/api/user/core/v1"
-	"core-api/pkg/util/common"
 	customErr "core-api/pkg/util/error"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 type RoleProvider struct {
 	Config *config.Config
-	token  string
 }
 
 // implement IRoleProvider
@@ -42,12 +41,30 @@ func (rp *RoleProvider) GetRoles(options map[string]struct{}) ([]core.CoreRole,
 }
 
 func (rp *RoleProvider) GetRole(id string, options map[string]struct{}) (*core.CoreRole, error) {
-	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", id), http.MethodGet, rp.Config.AuthConfig.Keystone, rp.getToken, rp.setToken, nil)
+	role, err := rp.getRawKeystoneRole(id)
+	if err != nil {
+		if !customErr.IsNotFound(err) {
+			coreApiLog.Logger.Error("Failed to get role", "error", err)
+		}
+		return nil, err
+	}
+	// refresh cached role so permission loaded next is up to date
+	indexOf(rp.Config.AuthConfig.Keystone).roles.put(id, *role)
+
+	// filter out that origin keystone role if LoadUnRelatedKeystoneRoles is not enabled
+	if _, found := options[LoadUnRelatedKeystoneObjects]; !found && role.CoreRole == nil {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", id))
+	}
+
+	return ConvertKeystoneRoleToCoreRole(role), nil
+}
+
+func (rp *RoleProvider) getRawKeystoneRole(id string) (*Role, error) {
+	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", url.PathEscape(id)), http.MethodGet, rp.Config.AuthConfig.Keystone, nil)
 	if code == http.StatusNotFound {
 		return nil, customErr.NewNotFound(code, fmt.Sprintf("Role %s not found", id))
 	}
 	if err != nil {
-		coreApiLog.Logger.Error("Failed to get role", "error", err)
 		return nil, err
 	}
 
@@ -59,13 +76,25 @@ func (rp *RoleProvider) GetRole(id string, options map[string]struct{}) (*core.C
 		coreApiLog.Logger.Error("Failed to unmarshal role", "error", err)
 		return nil, err
 	}
-
-	// filter out that origin keystone role if LoadUnRelatedKeystoneRoles is not enabled
-	if _, found := options[LoadUnRelatedKeystoneObjects]; !found && roleContainer.Role.CoreRole == nil {
+	if roleContainer.Role == nil {
 		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", id))
 	}
+	return roleContainer.Role, nil
+}
 
-	return ConvertKeystoneRoleToCoreRole(roleContainer.Role), nil
+// getCachedRawKeystoneRole is used to load permission, role is taken from index while it is fresh
+func (rp *RoleProvider) getCachedRawKeystoneRole(id string) (*Role, error) {
+	index := indexOf(rp.Config.AuthConfig.Keystone)
+	if role, found := index.roles.get(id); found {
+		return &role, nil
+	}
+
+	role, err := rp.getRawKeystoneRole(id)
+	if err != nil {
+		return nil, err
+	}
+	index.roles.put(id, *role)
+	return role, nil
 }
 
 func (rp *RoleProvider) UpdateRole(role *core.CoreRole, options map[string]struct{}) error {
@@ -88,11 +117,12 @@ func (rp *RoleProvider) UpdateRole(role *core.CoreRole, options map[string]struc
 		return err
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", role.Id), http.MethodPatch, rp.Config.AuthConfig.Keystone, rp.getToken, rp.setToken, postBody)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", role.Id), http.MethodPatch, rp.Config.AuthConfig.Keystone, postBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to create user", "error", err)
 		return err
 	}
+	indexOf(rp.Config.AuthConfig.Keystone).roles.remove(role.Id)
 
 	return nil
 }
@@ -110,7 +140,6 @@ func (rp *RoleProvider) DeleteRole(id string, options map[string]struct{}) error
 
 	// get all users
 	userProvider := &UserProvider{Config: rp.Config}
-	userProvider.setToken(rp.token)
 	users, err := userProvider.GetUsers(nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get users", "error", err)
@@ -136,11 +165,14 @@ func (rp *RoleProvider) DeleteRole(id string, options map[string]struct{}) error
 		}
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", id), http.MethodDelete, rp.Config.AuthConfig.Keystone, rp.getToken, rp.setToken, nil)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/roles/%s", id), http.MethodDelete, rp.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to delete role", "error", err)
 		return err
 	}
+	index := indexOf(rp.Config.AuthConfig.Keystone)
+	index.roles.remove(id)
+	index.roleIds.remove(role.Name)
 	return nil
 }
 
@@ -160,7 +192,7 @@ func (rp *RoleProvider) CreateRole(role *core.CoreRole, options map[string]struc
 		return nil, err
 	}
 
-	result, _, _, err := commentRequestAutoRenewToken("/v3/roles", http.MethodPost, rp.Config.AuthConfig.Keystone, rp.getToken, rp.setToken, postBody)
+	result, _, _, err := commentRequestAutoRenewToken("/v3/roles", http.MethodPost, rp.Config.AuthConfig.Keystone, postBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to create role", "error", err)
 		return nil, err
@@ -181,7 +213,17 @@ func (rp *RoleProvider) CreateRole(role *core.CoreRole, options map[string]struc
 }
 
 func (rp *RoleProvider) GetRawKeystoneRoleList() (*RoleContainer, error) {
-	body, _, _, err := commentRequestAutoRenewToken("/v3/roles", http.MethodGet, rp.Config.AuthConfig.Keystone, rp.getToken, rp.setToken, nil)
+	return rp.listRawKeystoneRoles(nil)
+}
+
+// listRawKeystoneRoles lists roles matching query, e.g. name or domain_id
+func (rp *RoleProvider) listRawKeystoneRoles(query url.Values) (*RoleContainer, error) {
+	path := "/v3/roles"
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	body, _, _, err := commentRequestAutoRenewToken(path, http.MethodGet, rp.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to list roles", "error", err)
 		return nil, err
@@ -196,31 +238,37 @@ func (rp *RoleProvider) GetRawKeystoneRoleList() (*RoleContainer, error) {
 	return roleCollection, nil
 }
 
-func (rp *RoleProvider) getToken() (string, error) {
-	if rp.token == "" {
-		token, _, err := RequestToken(rp.Config.AuthConfig.Keystone.Username, rp.Config.AuthConfig.Keystone.Password, common.GetStringValueOrDefault(rp.Config.AuthConfig.Keystone.DomainId, KeystoneDefaultDomainId), rp.Config.AuthConfig.Keystone.Endpoint, rp.Config.AuthConfig.Keystone.TokenKeyInResponse, true)
-		if err != nil {
-			return "", err
+func (rp *RoleProvider) SearchRoleByName(name string, options map[string]struct{}) (*core.CoreRole, error) {
+	index := indexOf(rp.Config.AuthConfig.Keystone)
+	if id, found := index.roleIds.get(name); found {
+		role, err := rp.GetRole(id, options)
+		if err == nil && role.Name == name {
+			return role, nil
 		}
-		rp.token = token
+		if err != nil && !customErr.IsNotFound(err) {
+			return nil, err
+		}
+		// role is renamed or deleted after it is indexed
+		index.roleIds.remove(name)
 	}
-	return rp.token, nil
-}
 
-func (rp *RoleProvider) setToken(token string) {
-	rp.token = token
-}
-
-func (rp *RoleProvider) SearchRoleByName(name string, options map[string]struct{}) (*core.CoreRole, error) {
-	roles, err := rp.GetRoles(options)
+	// roles are global in keystone, they are not filtered by domain
+	roles, err := rp.listRawKeystoneRoles(url.Values{"name": {name}})
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get roles", "error", err)
 		return nil, err
 	}
 
-	for _, role := range roles {
-		if role.Name == name {
-			return &role, nil
+	for _, role := range roles.Roles {
+		// filter out that origin keystone role if LoadUnRelatedKeystoneRoles is not enabled
+		if _, found := options[LoadUnRelatedKeystoneObjects]; !found && role.CoreRole == nil {
+			continue
+		}
+
+		coreRole := ConvertKeystoneRoleToCoreRole(&role)
+		if coreRole.Name == name {
+			index.roleIds.put(name, role.ID)
+			return coreRole, nil
 		}
 	}
 
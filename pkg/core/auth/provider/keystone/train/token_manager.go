@@ -0,0 +1,151 @@
+package train
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// lifetime assumed if keystone does not tell when token expires
+	defaultAdminTokenLifetime = 30 * time.Minute
+	// token is renewed this long before it expires, at most a fifth of its lifetime
+	adminTokenRenewBefore = 2 * time.Minute
+)
+
+// TokenManager keeps the admin token shared by every provider talking to the same keystone
+// token is renewed before it expires and concurrent renewals are collapsed into one request
+type TokenManager struct {
+	lock    sync.Mutex
+	token   string
+	renewAt time.Time
+	// closed when renewal in flight is done, nil if there is none
+	renewing chan struct{}
+	renewErr error
+
+	now          func() time.Time
+	requestToken func() (string, *TokenResponse, error)
+}
+
+var tokenManagers = map[string]*TokenManager{}
+var tokenManagersLock sync.Mutex
+
+// InitOrGetTokenManager returns token manager of keystone admin described by config
+func InitOrGetTokenManager(keystoneConfig *config.KeystoneConfig) *TokenManager {
+	tokenManagersLock.Lock()
+	defer tokenManagersLock.Unlock()
+
+	key := keystoneKey(keystoneConfig)
+	if manager, found := tokenManagers[key]; found {
+		return manager
+	}
+
+	manager := NewTokenManager(func() (string, *TokenResponse, error) {
+		return RequestToken(keystoneConfig.Username, keystoneConfig.Password, getDomainOrDefault(keystoneConfig.DomainId), keystoneConfig.Endpoint, keystoneConfig.TokenKeyInResponse, true)
+	})
+	tokenManagers[key] = manager
+	return manager
+}
+
+// NewTokenManager builds a manager requesting token with requestToken, mostly for tests
+func NewTokenManager(requestToken func() (string, *TokenResponse, error)) *TokenManager {
+	return &TokenManager{
+		now:          time.Now,
+		requestToken: requestToken,
+	}
+}
+
+// Token returns a valid admin token, a new one is requested if current one is about to expire
+func (m *TokenManager) Token() (string, error) {
+	m.lock.Lock()
+	for m.renewing != nil {
+		// someone else is renewing, wait for its result
+		renewing := m.renewing
+		m.lock.Unlock()
+		<-renewing
+		m.lock.Lock()
+
+		if m.valid() {
+			token := m.token
+			m.lock.Unlock()
+			return token, nil
+		}
+		if m.renewErr != nil {
+			err := m.renewErr
+			m.lock.Unlock()
+			return "", err
+		}
+	}
+
+	if m.valid() {
+		token := m.token
+		m.lock.Unlock()
+		return token, nil
+	}
+
+	renewing := make(chan struct{})
+	m.renewing = renewing
+	m.lock.Unlock()
+
+	coreApiLog.Logger.Debug("Renewing keystone admin token")
+	token, tokenResp, err := m.requestToken()
+	requestedAt := m.now()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.renewing = nil
+	m.renewErr = err
+	close(renewing)
+
+	if err != nil {
+		return "", err
+	}
+
+	lifetime := tokenLifetime(tokenResp)
+	renewBefore := adminTokenRenewBefore
+	if renewBefore > lifetime/5 {
+		renewBefore = lifetime / 5
+	}
+	m.token = token
+	m.renewAt = requestedAt.Add(lifetime - renewBefore)
+	return token, nil
+}
+
+// Invalidate drops token if keystone rejected it, next call of Token requests a new one
+// a token that has already been replaced is left alone so racing callers renew only once
+func (m *TokenManager) Invalidate(token string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.token == token {
+		m.token = ""
+	}
+}
+
+// valid should be called with lock held
+func (m *TokenManager) valid() bool {
+	return m.token != "" && m.now().Before(m.renewAt)
+}
+
+// tokenLifetime is measured with clock of keystone so skew between servers does not matter
+func tokenLifetime(tokenResp *TokenResponse) time.Duration {
+	if tokenResp == nil {
+		return defaultAdminTokenLifetime
+	}
+
+	issuedAt, err := time.Parse(time.RFC3339Nano, tokenResp.Token.IssuedAt)
+	if err != nil {
+		return defaultAdminTokenLifetime
+	}
+	expiresAt, err := time.Parse(time.RFC3339Nano, tokenResp.Token.ExpiresAt)
+	if err != nil || !expiresAt.After(issuedAt) {
+		return defaultAdminTokenLifetime
+	}
+	return expiresAt.Sub(issuedAt)
+}
+
+// keystoneKey tells keystone and admin user apart, state shared by providers is kept per key
+func keystoneKey(keystoneConfig *config.KeystoneConfig) string {
+	return fmt.Sprintf("%s|%s|%s", keystoneConfig.Endpoint, getDomainOrDefault(keystoneConfig.DomainId), keystoneConfig.Username)
+}
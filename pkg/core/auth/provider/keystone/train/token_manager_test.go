@@ -0,0 +1,137 @@
+package train
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coreApiLog "core-api/pkg/logger"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("keystone token manager tests", func() {
+	var requested int32
+	var now time.Time
+	var manager *TokenManager
+	var release chan struct{}
+
+	hourToken := &TokenResponse{Token: Token{IssuedAt: "2021-08-10T13:00:00.000000Z", ExpiresAt: "2021-08-10T14:00:00.000000Z"}}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		requested = 0
+		now = time.Date(2021, 8, 10, 13, 0, 0, 0, time.UTC)
+		release = nil
+		manager = NewTokenManager(func() (string, *TokenResponse, error) {
+			count := atomic.AddInt32(&requested, 1)
+			if release != nil {
+				<-release
+			}
+			return fmt.Sprintf("token-%d", count), hourToken, nil
+		})
+		manager.now = func() time.Time { return now }
+	})
+
+	Describe("Token test", func() {
+		It("should reuse token before it is about to expire", func() {
+			token, err := manager.Token()
+			Expect(err).To(BeNil())
+			Expect(token).To(Equal("token-1"))
+
+			now = now.Add(57 * time.Minute)
+			token, err = manager.Token()
+			Expect(err).To(BeNil())
+			Expect(token).To(Equal("token-1"))
+			Expect(requested).To(Equal(int32(1)))
+		})
+
+		It("should renew token before it expires", func() {
+			_, err := manager.Token()
+			Expect(err).To(BeNil())
+
+			now = now.Add(58 * time.Minute)
+			token, err := manager.Token()
+			Expect(err).To(BeNil())
+			Expect(token).To(Equal("token-2"))
+			Expect(requested).To(Equal(int32(2)))
+		})
+
+		It("should collapse concurrent renewals into one request", func() {
+			release = make(chan struct{})
+			var wg sync.WaitGroup
+			tokens := make([]string, 10)
+			for i := range tokens {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					defer GinkgoRecover()
+					token, err := manager.Token()
+					Expect(err).To(BeNil())
+					tokens[i] = token
+				}(i)
+			}
+
+			Eventually(func() int32 { return atomic.LoadInt32(&requested) }).Should(Equal(int32(1)))
+			close(release)
+			wg.Wait()
+
+			Expect(requested).To(Equal(int32(1)))
+			for _, token := range tokens {
+				Expect(token).To(Equal("token-1"))
+			}
+		})
+
+		It("should return error and retry on next call", func() {
+			failed := true
+			manager.requestToken = func() (string, *TokenResponse, error) {
+				atomic.AddInt32(&requested, 1)
+				if failed {
+					return "", nil, fmt.Errorf("keystone is down")
+				}
+				return "token", hourToken, nil
+			}
+
+			_, err := manager.Token()
+			Expect(err).NotTo(BeNil())
+
+			failed = false
+			token, err := manager.Token()
+			Expect(err).To(BeNil())
+			Expect(token).To(Equal("token"))
+			Expect(requested).To(Equal(int32(2)))
+		})
+	})
+
+	Describe("Invalidate test", func() {
+		It("should renew invalidated token once", func() {
+			token, _ := manager.Token()
+			manager.Invalidate(token)
+
+			renewed, err := manager.Token()
+			Expect(err).To(BeNil())
+			Expect(renewed).To(Equal("token-2"))
+
+			// a caller still holding the old token should not trigger another renewal
+			manager.Invalidate(token)
+			renewed, err = manager.Token()
+			Expect(err).To(BeNil())
+			Expect(renewed).To(Equal("token-2"))
+			Expect(requested).To(Equal(int32(2)))
+		})
+	})
+
+	Describe("tokenLifetime test", func() {
+		It("should be measured from response", func() {
+			Expect(tokenLifetime(hourToken)).To(Equal(time.Hour))
+		})
+
+		It("should fall back to default", func() {
+			Expect(tokenLifetime(nil)).To(Equal(defaultAdminTokenLifetime))
+			Expect(tokenLifetime(&TokenResponse{})).To(Equal(defaultAdminTokenLifetime))
+			Expect(tokenLifetime(&TokenResponse{Token: Token{IssuedAt: "2021-08-10T14:00:00Z", ExpiresAt: "2021-08-10T13:00:00Z"}})).To(Equal(defaultAdminTokenLifetime))
+		})
+	})
+})
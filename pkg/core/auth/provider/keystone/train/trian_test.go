@@ -469,7 +469,10 @@ var testRouter = func(ws *restful.WebService) {
 
 		userCreated = userContainer.User
 
-		response.WriteHeader(http.StatusCreated)
+		// keystone responds created user with generated id
+		response.WriteHeaderAndJson(http.StatusCreated, &struct {
+			User *User `json:"user"`
+		}{User: &User{ID: userContainer.User.Name + "id", Name: userContainer.User.Name, Email: userContainer.User.Email, Enabled: true}}, restful.MIME_JSON)
 	}))
 	ws.Route(ws.POST("/v3/roles").To(func(request *restful.Request, response *restful.Response) {
 		token := request.HeaderParameter("X-Auth-Token")
@@ -759,7 +762,7 @@ var _ = Describe("getRoleMap tests", func() {
 			go common.StartMockServer(20001, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			roles, err := getRoleMap(serverConfig, "test-token", nil)
+			roles, err := getRoleMap(serverConfig, nil)
 			Expect(err).To(BeNil())
 			Expect(len(roles)).To(Equal(2))
 		})
@@ -769,7 +772,7 @@ var _ = Describe("getRoleMap tests", func() {
 			go common.StartMockServer(20001, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			roles, err := getRoleMap(serverConfig, "test-token", map[string]struct{}{LoadUnRelatedKeystoneObjects: {}})
+			roles, err := getRoleMap(serverConfig, map[string]struct{}{LoadUnRelatedKeystoneObjects: {}})
 			Expect(err).To(BeNil())
 			Expect(len(roles)).To(Equal(len(testRoleList.Roles)))
 		})
@@ -782,7 +785,7 @@ var _ = Describe("getRoleMap tests", func() {
 			go common.StartMockServer(20001, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			groups, err := getGroupMap(serverConfig, "test-token", nil)
+			groups, err := getGroupMap(serverConfig, nil)
 			Expect(err).To(BeNil())
 			Expect(len(groups)).To(Equal(3))
 		})
@@ -792,7 +795,7 @@ var _ = Describe("getRoleMap tests", func() {
 			go common.StartMockServer(20001, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			groups, err := getGroupMap(serverConfig, "test-token", map[string]struct{}{LoadUnRelatedKeystoneObjects: {}})
+			groups, err := getGroupMap(serverConfig, map[string]struct{}{LoadUnRelatedKeystoneObjects: {}})
 			Expect(err).To(BeNil())
 			Expect(len(groups)).To(Equal(len(testGroupList.Groups)))
 		})
@@ -1533,10 +1536,11 @@ var _ = Describe("keystone user tests", func() {
 			go common.StartMockServer(20081, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			token, err := keystone.getToken()
+			tokenManager := InitOrGetTokenManager(serverConfig.AuthConfig.Keystone)
+			token, err := tokenManager.Token()
 			Expect(err).To(BeNil())
 			Expect(token).To(Equal("test-token"))
-			token, err = keystone.getToken()
+			token, err = tokenManager.Token()
 			Expect(err).To(BeNil())
 			Expect(token).To(Equal("test-token"))
 		})
@@ -1552,14 +1556,17 @@ var _ = Describe("keystone user tests", func() {
 	Describe("commentRequestAutoRenewToken test", func() {
 		It("should be expected", func() {
 			serverConfig.AuthConfig.Keystone.Endpoint = "http://localhost:20082"
-			keystone.token = "wrong token"
+			tokenManager := InitOrGetTokenManager(serverConfig.AuthConfig.Keystone)
+			tokenManager.token = "wrong token"
+			tokenManager.renewAt = time.Now().Add(time.Hour)
 			stopChan := make(chan struct{}, 1)
 			go common.StartMockServer(20082, testRouter, stopChan)
 			time.Sleep(1 * time.Second)
 			defer close(stopChan)
-			_, _, httpCode, err := commentRequestAutoRenewToken("/v3/users", http.MethodGet, serverConfig.AuthConfig.Keystone, keystone.getToken, keystone.setToken, nil)
+			_, _, httpCode, err := commentRequestAutoRenewToken("/v3/users", http.MethodGet, serverConfig.AuthConfig.Keystone, nil)
 			Expect(err).To(BeNil())
 			Expect(httpCode).To(Equal(http.StatusOK))
+			Expect(tokenManager.token).To(Equal("test-token"))
 
 		})
 	})
@@ -9,11 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 )
 
 type UserProvider struct {
 	Config *config.Config
-	token  string
 }
 
 // implement IUserProvider
@@ -27,7 +27,7 @@ func (up *UserProvider) GetUsers(options map[string]struct{}) ([]core.CoreUser,
 
 	var mapRoles map[string]core.CoreRole
 	if _, found := options[LoadPermission]; found {
-		mapRoles, err = getRoleMap(up.Config, up.token, options)
+		mapRoles, err = getRoleMap(up.Config, options)
 		if err != nil {
 			coreApiLog.Logger.Error("Failed to get role map", "error", err)
 			return []core.CoreUser{}, err
@@ -36,8 +36,10 @@ func (up *UserProvider) GetUsers(options map[string]struct{}) ([]core.CoreUser,
 
 	var userList []core.CoreUser
 
+	_, includeDisabled := options[IncludeDisabledUsers]
+	_, loadLegacyPassword := options[LoadLegacyPassword]
 	for _, user := range userCollection.Users {
-		if !user.Enabled {
+		if !user.Enabled && !includeDisabled {
 			// skip disabled user
 			continue
 		}
@@ -52,36 +54,105 @@ func (up *UserProvider) GetUsers(options map[string]struct{}) ([]core.CoreUser,
 			initPermission = sumPermission(user.CoreUser.Roles, mapRoles)
 		}
 
-		_, found := options[LoadPasswd]
-		userList = append(userList, *ConvertKeystoneUserToCoreUser(&user, !found, initPermission))
+		userList = append(userList, *ConvertKeystoneUserToCoreUser(&user, !loadLegacyPassword, initPermission))
 	}
 
 	return userList, nil
 }
 
-func (up *UserProvider) SearchUserByName(name string, options map[string]struct{}) (*core.CoreUser, error) {
-	users, err := up.GetUsers(options)
+// ScrubLegacyPasswords removes password former versions kept in plain text in core user of keystone users
+// password kept by keystone itself is untouched, names of scrubbed users are returned
+func (up *UserProvider) ScrubLegacyPasswords() ([]string, error) {
+	userCollection, err := up.GetRawKeystoneUserList()
 	if err != nil {
-		coreApiLog.Logger.Error("Failed to get users", "error", err)
 		return nil, err
 	}
 
-	for _, user := range users {
-		if user.Name == name {
-			return &user, nil
+	var scrubbed []string
+	for _, user := range userCollection.Users {
+		if user.CoreUser == nil || user.CoreUser.Password == "" {
+			continue
+		}
+
+		coreUser := *user.CoreUser
+		coreUser.Password = ""
+		postBody, err := json.Marshal(&struct {
+			User *User `json:"user"`
+		}{User: &User{CoreUser: &coreUser}})
+		if err != nil {
+			return scrubbed, err
+		}
+
+		_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", user.ID), http.MethodPatch, up.Config.AuthConfig.Keystone, postBody)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to scrub legacy password", "user", user.Name, "error", err)
+			return scrubbed, err
+		}
+		scrubbed = append(scrubbed, user.Name)
+	}
+	return scrubbed, nil
+}
+
+func (up *UserProvider) SearchUserByName(name string, options map[string]struct{}) (*core.CoreUser, error) {
+	return up.SearchTenantUserByName(up.Config.AuthConfig.Keystone.DomainId, name, options)
+}
+
+// SearchTenantUserByName searches user in keystone domain of tenant, user name is only unique in its domain
+func (up *UserProvider) SearchTenantUserByName(tenantId, name string, options map[string]struct{}) (*core.CoreUser, error) {
+	user, err := up.getRawKeystoneUserByName(getDomainOrDefault(tenantId), name)
+	if err != nil {
+		if !customErr.IsNotFound(err) {
+			coreApiLog.Logger.Error("Failed to search user", "error", err)
 		}
+		return nil, err
 	}
 
-	return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
+	if _, found := options[IncludeDisabledUsers]; !found && !user.Enabled {
+		// disabled user is skipped by user list as well
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
+	}
+
+	return up.toCoreUser(user, name, options)
 }
 
 func (up *UserProvider) GetUser(id string, options map[string]struct{}) (*core.CoreUser, error) {
-	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", id), http.MethodGet, up.Config.AuthConfig.Keystone, up.getToken, up.setToken, nil)
+	user, err := up.getRawKeystoneUser(id)
+	if err != nil {
+		if !customErr.IsNotFound(err) {
+			coreApiLog.Logger.Error("Failed to get user", "error", err)
+		}
+		return nil, err
+	}
+
+	return up.toCoreUser(user, id, options)
+}
+
+// toCoreUser filters out origin keystone user and loads permission with roles of user only
+func (up *UserProvider) toCoreUser(user *User, nameOrId string, options map[string]struct{}) (*core.CoreUser, error) {
+	// filter out that origin keystone user if LoadUnRelatedKeystoneObjects is not enabled
+	if _, found := options[LoadUnRelatedKeystoneObjects]; !found && user.CoreUser == nil {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", nameOrId))
+	}
+
+	initPermission := privileges.ModulesNoPermission()
+	if _, found := options[LoadPermission]; found && user.CoreUser != nil {
+		mapRoles, err := getUserRoleMap(up.Config, user.CoreUser.Roles, options)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to get role map", "error", err)
+			return nil, err
+		}
+		initPermission = sumPermission(user.CoreUser.Roles, mapRoles)
+	}
+
+	return ConvertKeystoneUserToCoreUser(user, true, initPermission), nil
+}
+
+func (up *UserProvider) getRawKeystoneUser(id string) (*User, error) {
+	body, _, code, err := commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", url.PathEscape(id)), http.MethodGet, up.Config.AuthConfig.Keystone, nil)
 	if code == http.StatusNotFound {
 		return nil, customErr.NewNotFound(code, fmt.Sprintf("User %s not found", id))
 	}
 	if err != nil {
-		coreApiLog.Logger.Error("Failed to get user", "error", err)
 		return nil, err
 	}
 
@@ -93,37 +164,50 @@ func (up *UserProvider) GetUser(id string, options map[string]struct{}) (*core.C
 		coreApiLog.Logger.Error("Failed to unmarshal user", "error", err)
 		return nil, err
 	}
-
-	// filter out that origin keystone user if LoadUnRelatedKeystoneObjects is not enabled
-	if _, found := options[LoadUnRelatedKeystoneObjects]; !found && userContainer.User.CoreUser == nil {
+	if userContainer.User == nil {
 		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", id))
 	}
+	return userContainer.User, nil
+}
 
-	var mapRoles map[string]core.CoreRole
-	if _, found := options[LoadPermission]; found {
-		mapRoles, err = getRoleMap(up.Config, up.token, options)
-		if err != nil {
-			coreApiLog.Logger.Error("Failed to get role map", "error", err)
+// getRawKeystoneUserByName asks keystone to filter users by name instead of listing all of them
+// id of user found is kept in index, so next lookup is a get by id
+func (up *UserProvider) getRawKeystoneUserByName(domainId, name string) (*User, error) {
+	index := indexOf(up.Config.AuthConfig.Keystone)
+	key := domainIndexKey(domainId, name)
+	if id, found := index.userIds.get(key); found {
+		user, err := up.getRawKeystoneUser(id)
+		if err == nil && user.Name == name {
+			return user, nil
+		}
+		if err != nil && !customErr.IsNotFound(err) {
 			return nil, err
 		}
+		// user is renamed or deleted after it is indexed
+		index.userIds.remove(key)
 	}
 
-	initPermission := privileges.ModulesNoPermission()
-	if len(mapRoles) > 0 && userContainer.User.CoreUser != nil {
-		initPermission = sumPermission(userContainer.User.CoreUser.Roles, mapRoles)
+	users, err := up.listRawKeystoneUsers(url.Values{
+		"name":      {name},
+		"domain_id": {domainId},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	_, found := options[LoadPasswd]
-	return ConvertKeystoneUserToCoreUser(userContainer.User, !found, initPermission), nil
+	// keystone may match name case insensitively depending on its database, so compare again
+	for _, user := range users.Users {
+		if user.Name == name {
+			index.userIds.put(key, user.ID)
+			return &user, nil
+		}
+	}
+	return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
 }
 
 func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struct{}) error {
 
 	// get old user
-	if options == nil {
-		options = map[string]struct{}{LoadPasswd: {}}
-	}
-
 	oldUser, err := up.GetUser(user.Id, options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get user", "error", err)
@@ -133,10 +217,14 @@ func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struc
 	changed := false
 	userPost := &User{}
 	if user.Password != "" {
-		// update password
+		// update password, it is only kept by keystone itself and never written into core user
 		changed = true
-		oldUser.Password = user.Password
 		userPost.Password = user.Password
+		// a new password set by user clears the flag, a reset by admin may set it again
+		oldUser.MustChangePassword = user.MustChangePassword
+	} else if user.MustChangePassword && !oldUser.MustChangePassword {
+		changed = true
+		oldUser.MustChangePassword = true
 	}
 
 	if user.Email != "" {
@@ -167,6 +255,8 @@ func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struc
 	}
 
 	// update user in database or wherever
+	// password left in core user by former versions is dropped here as well
+	oldUser.Password = ""
 	userPost.CoreUser = oldUser
 
 	postBody, err := json.Marshal(&struct {
@@ -177,7 +267,7 @@ func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struc
 		return err
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", user.Id), http.MethodPatch, up.Config.AuthConfig.Keystone, up.getToken, up.setToken, postBody)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", user.Id), http.MethodPatch, up.Config.AuthConfig.Keystone, postBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to create user", "error", err)
 		return err
@@ -198,11 +288,43 @@ func (up *UserProvider) DeleteUser(id string, options map[string]struct{}) error
 		return fmt.Errorf("build in user can not be deleted")
 	}
 
-	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", id), http.MethodDelete, up.Config.AuthConfig.Keystone, up.getToken, up.setToken, nil)
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", id), http.MethodDelete, up.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to delete user", "error", err)
 		return err
 	}
+	indexOf(up.Config.AuthConfig.Keystone).userIds.remove(domainIndexKey(getDomainOrDefault(user.TenantId), user.Name))
+	return nil
+}
+
+// SetUserDisabled sets keystone enabled flag of user, keystone refuses to issue token to a disabled user
+func (up *UserProvider) SetUserDisabled(id string, disabled bool) error {
+	user, err := up.GetUser(id, nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get user", "error", err)
+		return err
+	}
+
+	if disabled && (user.Name == "admin" || user.Name == "service") {
+		return fmt.Errorf("build in user can not be disabled")
+	}
+
+	// enabled of User is omitted if it is false, so patch is written by hand
+	postBody, err := json.Marshal(map[string]interface{}{
+		"user": map[string]interface{}{
+			"enabled": !disabled,
+		},
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to marshal user", "error", err)
+		return err
+	}
+
+	_, _, _, err = commentRequestAutoRenewToken(fmt.Sprintf("/v3/users/%s", url.PathEscape(id)), http.MethodPatch, up.Config.AuthConfig.Keystone, postBody)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to set user disabled", "user", id, "disabled", disabled, "error", err)
+		return err
+	}
 	return nil
 }
 
@@ -210,7 +332,7 @@ func (up *UserProvider) DeleteUser(id string, options map[string]struct{}) error
 func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struct{}) (*core.CoreUser, error) {
 
 	// get role map
-	mapRoles, err := getRoleMap(up.Config, up.token, options)
+	mapRoles, err := getRoleMap(up.Config, options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get role map", "error", err)
 		return nil, err
@@ -225,7 +347,7 @@ func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struc
 	}
 
 	// get group map
-	mapGroups, err := getGroupMap(up.Config, up.token, options)
+	mapGroups, err := getGroupMap(up.Config, options)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to get group map", "error", err)
 		return nil, err
@@ -239,12 +361,20 @@ func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struc
 		}
 	}
 
+	// password is only kept by keystone itself and never written into core user
+	coreUser := *user
+	coreUser.Password = ""
+
 	userPost := &User{
 		Name:     user.Name,
 		Email:    user.Email,
 		Password: user.Password,
 		Enabled:  true,
-		CoreUser: user,
+		// user is created in domain of admin token if tenant is not set
+		DomainID: user.TenantId,
+		CoreUser: &coreUser,
+		// forced password change is enforced by core-api with core user flag mustChangePassword
+		// keystone would refuse to issue token instead which leaves user no way to change password
 		Options: &Options{
 			IgnorePasswordExpiry:             true,
 			IgnoreChangePasswordUponFirstUse: true,
@@ -260,7 +390,7 @@ func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struc
 		return nil, err
 	}
 
-	retBodyBytes, _, retCode, err := commentRequestAutoRenewToken("/v3/users", http.MethodPost, up.Config.AuthConfig.Keystone, up.getToken, up.setToken, postBody)
+	retBodyBytes, _, retCode, err := commentRequestAutoRenewToken("/v3/users", http.MethodPost, up.Config.AuthConfig.Keystone, postBody)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to create user", "error", err)
 		return nil, err
@@ -281,35 +411,27 @@ func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struc
 		return nil, err
 	}
 
-	fmt.Println(userContainer)
-
 	user.Id = userContainer.User.ID
 
 	return user, nil
 }
 
-func (up *UserProvider) getToken() (string, error) {
-	if up.token == "" {
-		token, _, err := RequestToken(up.Config.AuthConfig.Keystone.Username, up.Config.AuthConfig.Keystone.Password, getDomainOrDefault(up.Config.AuthConfig.Keystone.DomainId), up.Config.AuthConfig.Keystone.Endpoint, up.Config.AuthConfig.Keystone.TokenKeyInResponse, true)
-		if err != nil {
-			return "", err
-		}
-		up.token = token
-	}
-	return up.token, nil
+func (up *UserProvider) GetRawKeystoneUserList() (UserContainer, error) {
+	return up.listRawKeystoneUsers(nil)
 }
 
-func (up *UserProvider) setToken(token string) {
-	up.token = token
-}
+// listRawKeystoneUsers lists users matching query, e.g. name, domain_id or enabled
+func (up *UserProvider) listRawKeystoneUsers(query url.Values) (UserContainer, error) {
+	path := "/v3/users"
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
 
-func (up *UserProvider) GetRawKeystoneUserList() (UserContainer, error) {
-	body, _, _, err := commentRequestAutoRenewToken("/v3/users", http.MethodGet, up.Config.AuthConfig.Keystone, up.getToken, up.setToken, nil)
+	body, _, _, err := commentRequestAutoRenewToken(path, http.MethodGet, up.Config.AuthConfig.Keystone, nil)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to list users", "error", err)
 		return UserContainer{}, err
 	}
-	//fmt.Println(string(body))
 
 	var userCollection UserContainer
 	err = json.Unmarshal(body, &userCollection)
@@ -320,33 +442,32 @@ func (up *UserProvider) GetRawKeystoneUserList() (UserContainer, error) {
 	return userCollection, nil
 }
 
-// due to keystone do not have api to query user with name
-// we have to list all users and find the user id by name
 func (up *UserProvider) GetUserIdFromName(name string) (string, error) {
-	userCollection, err := up.GetRawKeystoneUserList()
+	user, err := up.getRawKeystoneUserByName(getDomainOrDefault(up.Config.AuthConfig.Keystone.DomainId), name)
 	if err != nil {
-		coreApiLog.Logger.Error("Failed to get raw keystone user list", "error", err)
-		return "", err
-	}
-
-	for _, user := range userCollection.Users {
-		if user.Name == name {
-			return user.ID, nil
+		if customErr.IsNotFound(err) {
+			return "", fmt.Errorf("user %s not found", name)
 		}
+		coreApiLog.Logger.Error("Failed to search user", "error", err)
+		return "", err
 	}
-
-	return "", fmt.Errorf(fmt.Sprintf("user %s not found", name))
+	return user.ID, nil
 }
 
 // Login a user
 func (up *UserProvider) LoginUser(name, password string) (*core.CoreUser, error) {
+	return up.LoginTenantUser(up.Config.AuthConfig.Keystone.DomainId, name, password)
+}
+
+// LoginTenantUser logs in a user of keystone domain of tenant
+func (up *UserProvider) LoginTenantUser(tenantId, name, password string) (*core.CoreUser, error) {
 
-	_, _, err := RequestToken(name, password, getDomainOrDefault(up.Config.AuthConfig.Keystone.DomainId), up.Config.AuthConfig.Keystone.Endpoint, up.Config.AuthConfig.Keystone.TokenKeyInResponse, false)
+	_, _, err := RequestToken(name, password, getDomainOrDefault(tenantId), up.Config.AuthConfig.Keystone.Endpoint, up.Config.AuthConfig.Keystone.TokenKeyInResponse, false)
 	if err != nil {
 		coreApiLog.Logger.Error("Failed to login user", "error", err)
 		return nil, err
 	}
 
 	// now user detail by name
-	return up.SearchUserByName(name, map[string]struct{}{LoadPermission: {}})
+	return up.SearchTenantUserByName(tenantId, name, map[string]struct{}{LoadPermission: {}})
 }
@@ -0,0 +1,224 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"core-api/pkg/util/common"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	defaultUserFilter                = "(objectClass=person)"
+	defaultUserIdAttribute           = "uid"
+	defaultUserNameAttribute         = "uid"
+	defaultUserEmailAttribute        = "mail"
+	defaultUserDescriptionAttribute  = "description"
+	defaultGroupFilter               = "(objectClass=groupOfNames)"
+	defaultGroupIdAttribute          = "cn"
+	defaultGroupNameAttribute        = "cn"
+	defaultGroupDescriptionAttribute = "description"
+	defaultGroupMemberAttribute      = "member"
+	// active directory returns at most 1000 entries without paging
+	searchPageSize = 500
+)
+
+// directoryConn is the subset of *goldap.Conn used by providers
+// so that tests can run against an in-process directory
+type directoryConn interface {
+	Bind(username, password string) error
+	SearchWithPaging(searchRequest *goldap.SearchRequest, pagingSize uint32) (*goldap.SearchResult, error)
+	Close() error
+}
+
+// dialDirectory opens a connection to directory without binding
+// it is a variable so tests can replace it
+var dialDirectory = func(ldapConfig *config.LdapConfig) (directoryConn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: ldapConfig.InsecureSkipVerify}
+	conn, err := goldap.DialURL(ldapConfig.Url, goldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	if ldapConfig.StartTLS {
+		err = conn.StartTLS(tlsConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// directory wraps search of users and groups with attribute mapping in ldap config
+type directory struct {
+	config *config.LdapConfig
+}
+
+func newDirectory(serverConfig *config.Config) (*directory, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil || serverConfig.AuthConfig.Ldap == nil {
+		return nil, fmt.Errorf("ldap config is nil")
+	}
+
+	if serverConfig.AuthConfig.Ldap.Url == "" {
+		return nil, fmt.Errorf("ldap url is not set")
+	}
+
+	return &directory{config: serverConfig.AuthConfig.Ldap}, nil
+}
+
+// serviceConn returns a connection bound with service account
+func (d *directory) serviceConn() (directoryConn, error) {
+	conn, err := dialDirectory(d.config)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to dial ldap", "url", d.config.Url, "error", err)
+		return nil, err
+	}
+
+	if d.config.BindDN != "" {
+		err = conn.Bind(d.config.BindDN, d.config.BindPassword)
+		if err != nil {
+			conn.Close()
+			coreApiLog.Logger.Error("Failed to bind ldap with service account", "bindDN", d.config.BindDN, "error", err)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// authenticate binds a new connection with user dn and password
+func (d *directory) authenticate(userDN, password string) error {
+	// empty password leads to an unauthenticated bind which always succeeds
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+
+	conn, err := dialDirectory(d.config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Bind(userDN, password)
+}
+
+func (d *directory) search(baseDN, filter string, attributes []string) ([]*goldap.Entry, error) {
+	conn, err := d.serviceConn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	request := goldap.NewSearchRequest(baseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, 0, false, filter, attributes, nil)
+	result, err := conn.SearchWithPaging(request, searchPageSize)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search ldap", "baseDN", baseDN, "filter", filter, "error", err)
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// searchUsers returns users match user filter and optional attribute equality
+func (d *directory) searchUsers(attribute, value string) ([]*goldap.Entry, error) {
+	return d.search(d.config.UserBaseDN, andFilter(d.userFilter(), attribute, value), d.userAttributes())
+}
+
+// searchGroups returns groups match group filter and optional attribute equality
+func (d *directory) searchGroups(attribute, value string) ([]*goldap.Entry, error) {
+	return d.search(d.config.GroupBaseDN, andFilter(d.groupFilter(), attribute, value), d.groupAttributes())
+}
+
+func andFilter(baseFilter, attribute, value string) string {
+	if attribute == "" {
+		return baseFilter
+	}
+	return fmt.Sprintf("(&%s(%s=%s))", baseFilter, attribute, goldap.EscapeFilter(value))
+}
+
+func (d *directory) userFilter() string {
+	return common.GetStringValueOrDefault(d.config.UserFilter, defaultUserFilter)
+}
+
+func (d *directory) userIdAttribute() string {
+	return common.GetStringValueOrDefault(d.config.UserIdAttribute, defaultUserIdAttribute)
+}
+
+func (d *directory) userNameAttribute() string {
+	return common.GetStringValueOrDefault(d.config.UserNameAttribute, defaultUserNameAttribute)
+}
+
+func (d *directory) userAttributes() []string {
+	return []string{
+		d.userIdAttribute(),
+		d.userNameAttribute(),
+		common.GetStringValueOrDefault(d.config.UserEmailAttribute, defaultUserEmailAttribute),
+		common.GetStringValueOrDefault(d.config.UserDescriptionAttribute, defaultUserDescriptionAttribute),
+	}
+}
+
+func (d *directory) groupFilter() string {
+	return common.GetStringValueOrDefault(d.config.GroupFilter, defaultGroupFilter)
+}
+
+func (d *directory) groupIdAttribute() string {
+	return common.GetStringValueOrDefault(d.config.GroupIdAttribute, defaultGroupIdAttribute)
+}
+
+func (d *directory) groupNameAttribute() string {
+	return common.GetStringValueOrDefault(d.config.GroupNameAttribute, defaultGroupNameAttribute)
+}
+
+func (d *directory) groupMemberAttribute() string {
+	return common.GetStringValueOrDefault(d.config.GroupMemberAttribute, defaultGroupMemberAttribute)
+}
+
+func (d *directory) groupAttributes() []string {
+	return []string{
+		d.groupIdAttribute(),
+		d.groupNameAttribute(),
+		common.GetStringValueOrDefault(d.config.GroupDescriptionAttribute, defaultGroupDescriptionAttribute),
+		d.groupMemberAttribute(),
+	}
+}
+
+func (d *directory) entryToCoreUser(entry *goldap.Entry) *core.CoreUser {
+	return &core.CoreUser{
+		Id:          entry.GetAttributeValue(d.userIdAttribute()),
+		Name:        entry.GetAttributeValue(d.userNameAttribute()),
+		Email:       entry.GetAttributeValue(common.GetStringValueOrDefault(d.config.UserEmailAttribute, defaultUserEmailAttribute)),
+		Description: entry.GetAttributeValue(common.GetStringValueOrDefault(d.config.UserDescriptionAttribute, defaultUserDescriptionAttribute)),
+	}
+}
+
+func (d *directory) entryToCoreGroup(entry *goldap.Entry) *core.CoreGroup {
+	return &core.CoreGroup{
+		Id:          entry.GetAttributeValue(d.groupIdAttribute()),
+		Name:        entry.GetAttributeValue(d.groupNameAttribute()),
+		Description: entry.GetAttributeValue(common.GetStringValueOrDefault(d.config.GroupDescriptionAttribute, defaultGroupDescriptionAttribute)),
+	}
+}
+
+// isMember tells whether user entry is listed in member attribute of group entry
+// member value can be either dn of user or user name
+func (d *directory) isMember(groupEntry, userEntry *goldap.Entry) bool {
+	userName := userEntry.GetAttributeValue(d.userNameAttribute())
+	for _, member := range groupEntry.GetAttributeValues(d.groupMemberAttribute()) {
+		if strings.EqualFold(member, userEntry.DN) || member == userName {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *directory) isAdminUser(name string) bool {
+	for _, adminUser := range d.config.AdminUsers {
+		if adminUser == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// standInDirectory is an in-process ldap directory used by tests
+// it evaluates search filter the same way a real server does for and, or, not, equality and present
+type standInDirectory struct {
+	entries   []*goldap.Entry
+	passwords map[string]string
+	// number of connections opened
+	dialCount int
+}
+
+type standInConn struct {
+	directory *standInDirectory
+	bound     bool
+}
+
+func newStandInDirectory() *standInDirectory {
+	d := &standInDirectory{passwords: map[string]string{}}
+	d.passwords["cn=admin,dc=example,dc=org"] = "service-password"
+	d.addUser("alice", "alice-password", "alice@example.org")
+	d.addUser("bob", "bob-password", "bob@example.org")
+	d.addUser("carol", "carol-password", "carol@example.org")
+	d.addGroup("teachers", "uid=alice,ou=people,dc=example,dc=org")
+	d.addGroup("students", "uid=bob,ou=people,dc=example,dc=org", "UID=CAROL,OU=PEOPLE,DC=EXAMPLE,DC=ORG")
+	return d
+}
+
+func (d *standInDirectory) addUser(name, password, mail string) {
+	dn := fmt.Sprintf("uid=%s,ou=people,dc=example,dc=org", name)
+	d.passwords[dn] = password
+	d.entries = append(d.entries, goldap.NewEntry(dn, map[string][]string{
+		"objectClass": {"person", "inetOrgPerson"},
+		"uid":         {name},
+		"mail":        {mail},
+	}))
+}
+
+func (d *standInDirectory) addGroup(name string, members ...string) {
+	d.entries = append(d.entries, goldap.NewEntry(fmt.Sprintf("cn=%s,ou=groups,dc=example,dc=org", name), map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"cn":          {name},
+		"description": {name + " group"},
+		"member":      members,
+	}))
+}
+
+func (d *standInDirectory) dial(ldapConfig *config.LdapConfig) (directoryConn, error) {
+	d.dialCount++
+	return &standInConn{directory: d}, nil
+}
+
+func (c *standInConn) Bind(username, password string) error {
+	if password == "" {
+		// unauthenticated bind
+		return nil
+	}
+	if expected, found := c.directory.passwords[username]; !found || expected != password {
+		return goldap.NewError(goldap.LDAPResultInvalidCredentials, fmt.Errorf("invalid credentials"))
+	}
+	c.bound = true
+	return nil
+}
+
+func (c *standInConn) SearchWithPaging(searchRequest *goldap.SearchRequest, pagingSize uint32) (*goldap.SearchResult, error) {
+	if !c.bound {
+		return nil, goldap.NewError(goldap.LDAPResultInsufficientAccessRights, fmt.Errorf("anonymous search is not allowed"))
+	}
+
+	filter, err := goldap.CompileFilter(searchRequest.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &goldap.SearchResult{}
+	for _, entry := range c.directory.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(searchRequest.BaseDN)) {
+			continue
+		}
+		if matchFilter(filter, entry) {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+func (c *standInConn) Close() error {
+	return nil
+}
+
+func matchFilter(filter *ber.Packet, entry *goldap.Entry) bool {
+	switch filter.Tag {
+	case goldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case goldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterNot:
+		return !matchFilter(filter.Children[0], entry)
+	case goldap.FilterEqualityMatch:
+		attribute := filter.Children[0].Value.(string)
+		value := filter.Children[1].Value.(string)
+		for _, v := range entry.GetEqualFoldAttributeValues(attribute) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case goldap.FilterPresent:
+		return len(entry.GetEqualFoldAttributeValues(filter.Data.String())) > 0
+	}
+	return false
+}
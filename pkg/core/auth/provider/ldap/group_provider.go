@@ -0,0 +1,189 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+)
+
+// GroupProvider reads groups and membership from directory
+// membership is managed by directory, only display attributes such as tag color are kept in local overlay
+type GroupProvider struct {
+	Config       *config.Config
+	directory    *directory
+	overlay      *overlay
+	userProvider *UserProvider
+}
+
+func NewGroupProvider(serverConfig *config.Config) (*GroupProvider, error) {
+	userProvider, err := NewUserProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupProvider{
+		Config:       serverConfig,
+		directory:    userProvider.directory,
+		overlay:      userProvider.overlay,
+		userProvider: userProvider,
+	}, nil
+}
+
+// implement IGroupProvider
+
+func (gp *GroupProvider) GetGroups(options map[string]struct{}) ([]core.CoreGroup, error) {
+	entries, err := gp.directory.searchGroups("", "")
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search groups", "error", err)
+		return nil, err
+	}
+
+	result := make([]core.CoreGroup, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, *gp.mergeExtra(gp.directory.entryToCoreGroup(entry)))
+	}
+	return result, nil
+}
+
+func (gp *GroupProvider) GetGroup(id string, options map[string]struct{}) (*core.CoreGroup, error) {
+	return gp.searchOne(gp.directory.groupIdAttribute(), id)
+}
+
+func (gp *GroupProvider) SearchGroupByName(name string, options map[string]struct{}) (*core.CoreGroup, error) {
+	return gp.searchOne(gp.directory.groupNameAttribute(), name)
+}
+
+// UpdateGroup only keeps description and tag color in overlay, directory is left untouched
+func (gp *GroupProvider) UpdateGroup(group *core.CoreGroup, options map[string]struct{}) error {
+	found, err := gp.GetGroup(group.Id, nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get group", "error", err)
+		return err
+	}
+
+	extra := core.CoreGroup{
+		Id:          found.Id,
+		Description: group.Description,
+		TagColor:    group.TagColor,
+	}
+	err = gp.overlay.putGroupExtra(extra)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update group", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (gp *GroupProvider) DeleteGroup(id string, options map[string]struct{}) error {
+	return fmt.Errorf("group %s is managed by ldap directory and can not be deleted", id)
+}
+
+func (gp *GroupProvider) CreateGroup(group *core.CoreGroup, options map[string]struct{}) (*core.CoreGroup, error) {
+	return nil, fmt.Errorf("group %s can not be created, groups are managed by ldap directory", group.Name)
+}
+
+func (gp *GroupProvider) AddUserToGroup(userId, groupId string) error {
+	return fmt.Errorf("membership of group %s is managed by ldap directory", groupId)
+}
+
+func (gp *GroupProvider) RemoveUserFromGroup(userId, groupId string) error {
+	return fmt.Errorf("membership of group %s is managed by ldap directory", groupId)
+}
+
+func (gp *GroupProvider) GetGroupUsers(groupId string, options map[string]struct{}) ([]core.CoreUser, error) {
+	_, err := gp.GetGroup(groupId, nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get group", "error", err)
+		return nil, err
+	}
+
+	users, err := gp.userProvider.GetUsers(nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get users", "error", err)
+		return nil, err
+	}
+
+	_, reverse := options[keystone.ReverseGetGroupUsers]
+
+	var groupUsers []core.CoreUser
+	for _, user := range users {
+		inGroup := false
+		for _, group := range user.Groups {
+			if group.Id == groupId {
+				inGroup = true
+				break
+			}
+		}
+		if inGroup != reverse {
+			groupUsers = append(groupUsers, user)
+		}
+	}
+	return groupUsers, nil
+}
+
+func (gp *GroupProvider) GetGroupSummary(options map[string]struct{}) (*core.CoreGroupSummary, error) {
+	groups, err := gp.GetGroups(options)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get groups", "error", err)
+		return nil, err
+	}
+
+	users, err := gp.userProvider.GetUsers(nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get users", "error", err)
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, user := range users {
+		for _, group := range user.Groups {
+			counts[group.Id]++
+		}
+	}
+
+	result := &core.CoreGroupSummary{}
+	for _, group := range groups {
+		result.Counts = append(result.Counts, core.CoreGroupSummaryDetail{
+			Id:    group.Id,
+			Name:  group.Name,
+			Count: counts[group.Id],
+		})
+	}
+	return result, nil
+}
+
+func (gp *GroupProvider) AddUsersToGroup(groupId string, users []core.CoreUser) ([]core.CoreUser, []core.CoreUser, error) {
+	return nil, users, fmt.Errorf("membership of group %s is managed by ldap directory", groupId)
+}
+
+func (gp *GroupProvider) searchOne(attribute, value string) (*core.CoreGroup, error) {
+	entries, err := gp.directory.searchGroups(attribute, value)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search group", "error", err)
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Group %s not found", value))
+	}
+
+	return gp.mergeExtra(gp.directory.entryToCoreGroup(entries[0])), nil
+}
+
+// mergeExtra overrides directory attributes with what is kept in overlay
+func (gp *GroupProvider) mergeExtra(group *core.CoreGroup) *core.CoreGroup {
+	extra, found := gp.overlay.getGroupExtra(group.Id)
+	if !found {
+		return group
+	}
+
+	if extra.Description != "" {
+		group.Description = extra.Description
+	}
+	group.TagColor = extra.TagColor
+	return group
+}
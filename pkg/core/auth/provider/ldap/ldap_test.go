@@ -0,0 +1,244 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ldap provider test", func() {
+	var serverConfig *config.Config
+	var standIn *standInDirectory
+	var userProvider *UserProvider
+	var roleProvider *RoleProvider
+	var groupProvider *GroupProvider
+	var overlayPath string
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		standIn = newStandInDirectory()
+		dialDirectory = standIn.dial
+		overlayPath = filepath.Join(GinkgoT().TempDir(), "overlay.json")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "ldap"
+		serverConfig.AuthConfig.Ldap = &config.LdapConfig{
+			Url:          "ldap://stand-in:389",
+			BindDN:       "cn=admin,dc=example,dc=org",
+			BindPassword: "service-password",
+			UserBaseDN:   "ou=people,dc=example,dc=org",
+			GroupBaseDN:  "ou=groups,dc=example,dc=org",
+			OverlayPath:  overlayPath,
+			AdminUsers:   []string{"alice"},
+		}
+		var err error
+		userProvider, err = NewUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		roleProvider, err = NewRoleProvider(serverConfig)
+		Expect(err).To(BeNil())
+		groupProvider, err = NewGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+	})
+
+	Describe("NewUserProvider test", func() {
+		It("should be error without ldap config", func() {
+			_, err := NewUserProvider(config.DefaultConfig())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be error without ldap url", func() {
+			serverConfig.AuthConfig.Ldap.Url = ""
+			_, err := NewUserProvider(serverConfig)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("user provider test", func() {
+		It("should list all directory users with groups", func() {
+			users, err := userProvider.GetUsers(nil)
+			Expect(err).To(BeNil())
+			Expect(len(users)).To(Equal(3))
+			Expect(users[0].Name).To(Equal("alice"))
+			Expect(users[0].Id).To(Equal("alice"))
+			Expect(users[0].Email).To(Equal("alice@example.org"))
+			Expect(users[0].Groups).To(Equal([]core.CoreGroup{{Id: "teachers", Name: "teachers", Description: "teachers group"}}))
+			// member dn is compared case insensitively
+			Expect(users[2].Groups[0].Id).To(Equal("students"))
+		})
+
+		It("should grant build-in admin role to admin users", func() {
+			user, err := userProvider.GetUser("alice", map[string]struct{}{keystone.LoadPermission: {}})
+			Expect(err).To(BeNil())
+			Expect(user.UnEditable).To(BeTrue())
+			Expect(user.Roles[0].Id).To(Equal(BuildInAdminRoleId))
+			Expect(user.Permission).To(Equal(privileges.ModulesFullPermission()))
+		})
+
+		It("should be not found with unknown user", func() {
+			_, err := userProvider.GetUser("nobody", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+			_, err = userProvider.SearchUserByName("nobody", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should escape filter value", func() {
+			_, err := userProvider.SearchUserByName("*", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep role bindings in overlay", func() {
+			role, err := roleProvider.CreateRole(&core.CoreRole{Name: "teacher", Permission: map[string]uint64{"course": privileges.PermissionCourseList}}, nil)
+			Expect(err).To(BeNil())
+			err = userProvider.UpdateUser(&core.CoreUser{Id: "bob", Roles: []core.CoreRole{{Id: role.Id}}}, nil)
+			Expect(err).To(BeNil())
+			user, err := userProvider.GetUser("bob", map[string]struct{}{keystone.LoadPermission: {}})
+			Expect(err).To(BeNil())
+			Expect(user.Roles[0].Name).To(Equal("teacher"))
+			Expect(user.Permission["course"]).To(Equal(uint64(privileges.PermissionCourseList)))
+
+			// overlay survives reload
+			reloaded, err := loadOverlay(overlayPath)
+			Expect(err).To(BeNil())
+			Expect(reloaded.getUserRoles("bob", false)[0].Id).To(Equal(role.Id))
+		})
+
+		It("should be error with unknown role", func() {
+			err := userProvider.UpdateUser(&core.CoreUser{Id: "bob", Roles: []core.CoreRole{{Id: "unknown"}}}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should refuse to change identity managed by directory", func() {
+			Expect(userProvider.UpdateUser(&core.CoreUser{Id: "bob", Password: "new"}, nil)).To(HaveOccurred())
+			Expect(userProvider.DeleteUser("bob", nil)).To(HaveOccurred())
+			_, err := userProvider.CreateUser(&core.CoreUser{Name: "dave"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LoginUser test", func() {
+		It("should login with directory password", func() {
+			user, err := userProvider.LoginUser("bob", "bob-password")
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal("bob"))
+			Expect(user.Permission).To(Equal(privileges.ModulesNoPermission()))
+		})
+
+		It("should be unauthorized with wrong password", func() {
+			_, err := userProvider.LoginUser("bob", "alice-password")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be unauthorized with empty password", func() {
+			_, err := userProvider.LoginUser("bob", "")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be unauthorized with unknown user", func() {
+			_, err := userProvider.LoginUser("nobody", "bob-password")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be error with wrong service account", func() {
+			serverConfig.AuthConfig.Ldap.BindPassword = "wrong"
+			_, err := userProvider.LoginUser("bob", "bob-password")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("role provider test", func() {
+		It("should create update and delete role", func() {
+			role, err := roleProvider.CreateRole(&core.CoreRole{Name: "student"}, nil)
+			Expect(err).To(BeNil())
+			Expect(role.Id).NotTo(BeEmpty())
+
+			role.Description = "updated"
+			Expect(roleProvider.UpdateRole(role, nil)).To(BeNil())
+			found, err := roleProvider.SearchRoleByName("student", nil)
+			Expect(err).To(BeNil())
+			Expect(found.Description).To(Equal("updated"))
+
+			Expect(userProvider.UpdateUser(&core.CoreUser{Id: "carol", Roles: []core.CoreRole{{Id: role.Id}}}, nil)).To(BeNil())
+			Expect(roleProvider.DeleteRole(role.Id, nil)).To(BeNil())
+			_, err = roleProvider.GetRole(role.Id, nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+			user, err := userProvider.GetUser("carol", nil)
+			Expect(err).To(BeNil())
+			Expect(user.Roles).To(BeEmpty())
+		})
+
+		It("should protect build-in admin role", func() {
+			Expect(roleProvider.DeleteRole(BuildInAdminRoleId, nil)).To(HaveOccurred())
+			Expect(roleProvider.UpdateRole(&core.CoreRole{Id: BuildInAdminRoleId, Name: "admin"}, nil)).To(HaveOccurred())
+			_, err := roleProvider.CreateRole(&core.CoreRole{Name: "admin"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be not found when updating unknown role", func() {
+			err := roleProvider.UpdateRole(&core.CoreRole{Id: "unknown", Name: "unknown"}, nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	Describe("group provider test", func() {
+		It("should list directory groups", func() {
+			groups, err := groupProvider.GetGroups(nil)
+			Expect(err).To(BeNil())
+			Expect(len(groups)).To(Equal(2))
+			group, err := groupProvider.SearchGroupByName("students", nil)
+			Expect(err).To(BeNil())
+			Expect(group.Id).To(Equal("students"))
+			_, err = groupProvider.GetGroup("unknown", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep tag color in overlay", func() {
+			Expect(groupProvider.UpdateGroup(&core.CoreGroup{Id: "students", TagColor: "red"}, nil)).To(BeNil())
+			group, err := groupProvider.GetGroup("students", nil)
+			Expect(err).To(BeNil())
+			Expect(group.TagColor).To(Equal("red"))
+			Expect(group.Description).To(Equal("students group"))
+		})
+
+		It("should get group users and reversed", func() {
+			users, err := groupProvider.GetGroupUsers("students", nil)
+			Expect(err).To(BeNil())
+			Expect(len(users)).To(Equal(2))
+			users, err = groupProvider.GetGroupUsers("students", map[string]struct{}{keystone.ReverseGetGroupUsers: {}})
+			Expect(err).To(BeNil())
+			Expect(len(users)).To(Equal(1))
+			Expect(users[0].Name).To(Equal("alice"))
+		})
+
+		It("should summarize group members", func() {
+			summary, err := groupProvider.GetGroupSummary(nil)
+			Expect(err).To(BeNil())
+			Expect(summary.Counts).To(ConsistOf(
+				core.CoreGroupSummaryDetail{Id: "teachers", Name: "teachers", Count: 1},
+				core.CoreGroupSummaryDetail{Id: "students", Name: "students", Count: 2},
+			))
+		})
+
+		It("should refuse to change membership managed by directory", func() {
+			Expect(groupProvider.AddUserToGroup("alice", "students")).To(HaveOccurred())
+			Expect(groupProvider.RemoveUserFromGroup("bob", "students")).To(HaveOccurred())
+			_, failed, err := groupProvider.AddUsersToGroup("students", []core.CoreUser{{Id: "alice"}})
+			Expect(err).To(HaveOccurred())
+			Expect(len(failed)).To(Equal(1))
+		})
+	})
+
+	Describe("overlay test", func() {
+		It("should be error with broken overlay file", func() {
+			Expect(os.WriteFile(overlayPath, []byte("{broken"), 0600)).To(BeNil())
+			_, err := loadOverlay(overlayPath)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
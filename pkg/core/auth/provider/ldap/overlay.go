@@ -0,0 +1,258 @@
+package ldap
+
+import (
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	BuildInAdminRoleId = "admin"
+)
+
+// overlayData is what directory does not know about, it is persisted as json
+type overlayData struct {
+	Roles map[string]core.CoreRole `json:"roles"`
+	// user id -> role ids
+	UserRoles map[string][]string `json:"userRoles"`
+	// group id -> attributes that only core-api cares about e.g. tag color
+	Groups map[string]core.CoreGroup `json:"groups"`
+}
+
+// overlay keeps core-api roles and role bindings of directory users
+type overlay struct {
+	lock sync.RWMutex
+	path string
+	data *overlayData
+}
+
+var overlays = map[string]*overlay{}
+var overlaysLock sync.Mutex
+
+// initOrGetOverlay returns overlay shared by providers that use same overlay path
+func initOrGetOverlay(path string) (*overlay, error) {
+	overlaysLock.Lock()
+	defer overlaysLock.Unlock()
+
+	if o, found := overlays[path]; found {
+		return o, nil
+	}
+
+	o, err := loadOverlay(path)
+	if err != nil {
+		return nil, err
+	}
+	overlays[path] = o
+	return o, nil
+}
+
+func loadOverlay(path string) (*overlay, error) {
+	data := &overlayData{}
+	if path == "" {
+		coreApiLog.Logger.Warn("ldap overlay path is not set, roles and role bindings will be lost after restart")
+	} else {
+		content, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(content) > 0 {
+			err = json.Unmarshal(content, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ldap overlay %s: %w", path, err)
+			}
+		}
+	}
+
+	if data.Roles == nil {
+		data.Roles = map[string]core.CoreRole{}
+	}
+	if data.UserRoles == nil {
+		data.UserRoles = map[string][]string{}
+	}
+	if data.Groups == nil {
+		data.Groups = map[string]core.CoreGroup{}
+	}
+
+	// build-in admin role always has full permission
+	data.Roles[BuildInAdminRoleId] = core.CoreRole{
+		Id:          BuildInAdminRoleId,
+		Name:        "admin",
+		Description: "build-in administrator",
+		Permission:  privileges.ModulesFullPermission(),
+		UnEditable:  true,
+	}
+
+	return &overlay{path: path, data: data}, nil
+}
+
+// save should be called with write lock held
+func (o *overlay) save() error {
+	if o.path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(o.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to temp file then rename so a crash never leaves a half written overlay
+	tempFile, err := os.CreateTemp(filepath.Dir(o.path), filepath.Base(o.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(content)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), o.path)
+}
+
+func (o *overlay) getRoles() []core.CoreRole {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	result := make([]core.CoreRole, 0, len(o.data.Roles))
+	for _, role := range o.data.Roles {
+		result = append(result, role)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (o *overlay) getRole(id string) (core.CoreRole, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	role, found := o.data.Roles[id]
+	return role, found
+}
+
+// putRole creates or replaces role, id is generated for new role
+func (o *overlay) putRole(role core.CoreRole) (core.CoreRole, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if role.Id == "" {
+		id, err := newId()
+		if err != nil {
+			return role, err
+		}
+		role.Id = id
+	}
+	o.data.Roles[role.Id] = role
+	return role, o.save()
+}
+
+func (o *overlay) deleteRole(id string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	delete(o.data.Roles, id)
+	for userId, roleIds := range o.data.UserRoles {
+		o.data.UserRoles[userId] = removeString(roleIds, id)
+	}
+	return o.save()
+}
+
+// getUserRoles returns roles bound to user, build-in admin role is added for admin users
+func (o *overlay) getUserRoles(userId string, isAdmin bool) []core.CoreRole {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	var result []core.CoreRole
+	adminBound := false
+	for _, roleId := range o.data.UserRoles[userId] {
+		role, found := o.data.Roles[roleId]
+		if !found {
+			coreApiLog.Logger.Warn("Role not found", "role", roleId, "user", userId)
+			continue
+		}
+		if roleId == BuildInAdminRoleId {
+			adminBound = true
+		}
+		result = append(result, role)
+	}
+
+	if isAdmin && !adminBound {
+		result = append(result, o.data.Roles[BuildInAdminRoleId])
+	}
+	return result
+}
+
+func (o *overlay) setUserRoles(userId string, roles []core.CoreRole) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	var roleIds []string
+	for _, role := range roles {
+		if _, found := o.data.Roles[role.Id]; !found {
+			return fmt.Errorf("role %s not found", role.Id)
+		}
+		roleIds = append(roleIds, role.Id)
+	}
+
+	if len(roleIds) == 0 {
+		delete(o.data.UserRoles, userId)
+	} else {
+		o.data.UserRoles[userId] = roleIds
+	}
+	return o.save()
+}
+
+func (o *overlay) getGroupExtra(groupId string) (core.CoreGroup, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+	group, found := o.data.Groups[groupId]
+	return group, found
+}
+
+func (o *overlay) putGroupExtra(group core.CoreGroup) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.data.Groups[group.Id] = group
+	return o.save()
+}
+
+// sumPermission merges permission of all roles
+func sumPermission(roles []core.CoreRole) map[string]uint64 {
+	result := privileges.ModulesNoPermission()
+	for _, role := range roles {
+		for module, permission := range role.Permission {
+			result[module] |= permission
+		}
+	}
+	return result
+}
+
+func removeString(values []string, toRemove string) []string {
+	var result []string
+	for _, value := range values {
+		if value != toRemove {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func newId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
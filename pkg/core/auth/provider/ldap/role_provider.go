@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+)
+
+// RoleProvider keeps core-api roles in local overlay, directory has no notion of them
+type RoleProvider struct {
+	Config  *config.Config
+	overlay *overlay
+}
+
+func NewRoleProvider(serverConfig *config.Config) (*RoleProvider, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil || serverConfig.AuthConfig.Ldap == nil {
+		return nil, fmt.Errorf("ldap config is nil")
+	}
+
+	o, err := initOrGetOverlay(serverConfig.AuthConfig.Ldap.OverlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RoleProvider{Config: serverConfig, overlay: o}, nil
+}
+
+// implement IRoleProvider
+
+func (rp *RoleProvider) GetRoles(options map[string]struct{}) ([]core.CoreRole, error) {
+	return rp.overlay.getRoles(), nil
+}
+
+func (rp *RoleProvider) GetRole(id string, options map[string]struct{}) (*core.CoreRole, error) {
+	role, found := rp.overlay.getRole(id)
+	if !found {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", id))
+	}
+	return &role, nil
+}
+
+func (rp *RoleProvider) UpdateRole(role *core.CoreRole, options map[string]struct{}) error {
+	if role.Id == BuildInAdminRoleId || role.Name == "admin" {
+		return fmt.Errorf("Role %s is a reserved role cannot be modified", role.Name)
+	}
+
+	if _, found := rp.overlay.getRole(role.Id); !found {
+		return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", role.Id))
+	}
+
+	_, err := rp.overlay.putRole(*role)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update role", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (rp *RoleProvider) DeleteRole(id string, options map[string]struct{}) error {
+	role, err := rp.GetRole(id, options)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get role", "error", err)
+		return err
+	}
+
+	if role.Id == BuildInAdminRoleId {
+		return fmt.Errorf("build in role can not be deleted")
+	}
+
+	// role bindings of users are removed together with role
+	err = rp.overlay.deleteRole(id)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to delete role", "error", err)
+		return err
+	}
+	return nil
+}
+
+func (rp *RoleProvider) CreateRole(role *core.CoreRole, options map[string]struct{}) (*core.CoreRole, error) {
+	if role.Name == "admin" {
+		return nil, fmt.Errorf("Role %s is a reserved role", role.Name)
+	}
+
+	toCreate := *role
+	toCreate.Id = ""
+	created, err := rp.overlay.putRole(toCreate)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create role", "error", err)
+		return nil, err
+	}
+
+	role.Id = created.Id
+	return role, nil
+}
+
+func (rp *RoleProvider) SearchRoleByName(name string, options map[string]struct{}) (*core.CoreRole, error) {
+	for _, role := range rp.overlay.getRoles() {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+
+	return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", name))
+}
@@ -0,0 +1,169 @@
+package ldap
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+// UserProvider reads users from directory, roles of user are kept in local overlay
+// user identity such as name, email and password is managed by directory and can not be changed here
+type UserProvider struct {
+	Config    *config.Config
+	directory *directory
+	overlay   *overlay
+}
+
+func NewUserProvider(serverConfig *config.Config) (*UserProvider, error) {
+	d, err := newDirectory(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := initOrGetOverlay(serverConfig.AuthConfig.Ldap.OverlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserProvider{Config: serverConfig, directory: d, overlay: o}, nil
+}
+
+// implement IUserProvider
+
+func (up *UserProvider) GetUsers(options map[string]struct{}) ([]core.CoreUser, error) {
+	entries, err := up.directory.searchUsers("", "")
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search users", "error", err)
+		return []core.CoreUser{}, err
+	}
+
+	return up.convertEntries(entries, options)
+}
+
+func (up *UserProvider) SearchUserByName(name string, options map[string]struct{}) (*core.CoreUser, error) {
+	return up.searchOne(up.directory.userNameAttribute(), name, options)
+}
+
+func (up *UserProvider) GetUser(id string, options map[string]struct{}) (*core.CoreUser, error) {
+	return up.searchOne(up.directory.userIdAttribute(), id, options)
+}
+
+// UpdateUser only updates role bindings, identity attributes are owned by directory
+func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struct{}) error {
+	if user.Password != "" {
+		return fmt.Errorf("password of user %s is managed by ldap directory and can not be changed", user.Id)
+	}
+
+	_, err := up.GetUser(user.Id, nil)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get user", "error", err)
+		return err
+	}
+
+	if user.Roles == nil {
+		coreApiLog.Logger.Debug("No role change found skip update", "user", user.Id)
+		return nil
+	}
+
+	err = up.overlay.setUserRoles(user.Id, user.Roles)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update user roles", "user", user.Id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (up *UserProvider) DeleteUser(id string, options map[string]struct{}) error {
+	return fmt.Errorf("user %s is managed by ldap directory and can not be deleted", id)
+}
+
+func (up *UserProvider) SetUserDisabled(id string, disabled bool) error {
+	return fmt.Errorf("user %s is managed by ldap directory and can not be disabled or enabled", id)
+}
+
+func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struct{}) (*core.CoreUser, error) {
+	return nil, fmt.Errorf("user %s can not be created, users are managed by ldap directory", user.Name)
+}
+
+// LoginUser binds directory with dn of user and given password
+func (up *UserProvider) LoginUser(name, password string) (*core.CoreUser, error) {
+	entries, err := up.directory.searchUsers(up.directory.userNameAttribute(), name)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search user", "error", err)
+		return nil, err
+	}
+
+	if len(entries) != 1 {
+		coreApiLog.Logger.Error("Failed to login user", "user", name, "matched", len(entries))
+		return nil, customErr.NewUnauthorized(http.StatusUnauthorized, "Failed to login user")
+	}
+
+	err = up.directory.authenticate(entries[0].DN, password)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to login user", "user", name, "error", err)
+		return nil, customErr.NewUnauthorized(http.StatusUnauthorized, "Failed to login user")
+	}
+
+	users, err := up.convertEntries(entries, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+func (up *UserProvider) searchOne(attribute, value string, options map[string]struct{}) (*core.CoreUser, error) {
+	entries, err := up.directory.searchUsers(attribute, value)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search user", "error", err)
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", value))
+	}
+
+	users, err := up.convertEntries(entries[:1], options)
+	if err != nil {
+		return nil, err
+	}
+	return &users[0], nil
+}
+
+// convertEntries maps user entries to core users with groups and roles filled
+func (up *UserProvider) convertEntries(userEntries []*goldap.Entry, options map[string]struct{}) ([]core.CoreUser, error) {
+	groupEntries, err := up.directory.searchGroups("", "")
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to search groups", "error", err)
+		return nil, err
+	}
+
+	_, loadPermission := options[keystone.LoadPermission]
+
+	result := make([]core.CoreUser, 0, len(userEntries))
+	for _, entry := range userEntries {
+		user := up.directory.entryToCoreUser(entry)
+		for _, groupEntry := range groupEntries {
+			if up.directory.isMember(groupEntry, entry) {
+				user.Groups = append(user.Groups, *up.directory.entryToCoreGroup(groupEntry))
+			}
+		}
+
+		isAdmin := up.directory.isAdminUser(user.Name)
+		user.UnEditable = isAdmin
+		user.Roles = up.overlay.getUserRoles(user.Id, isAdmin)
+		if loadPermission {
+			user.Permission = sumPermission(user.Roles)
+		} else {
+			user.Permission = privileges.ModulesNoPermission()
+		}
+		result = append(result, *user)
+	}
+	return result, nil
+}
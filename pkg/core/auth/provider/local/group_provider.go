@@ -0,0 +1,354 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+
+	"go.etcd.io/bbolt"
+)
+
+// GroupProvider keeps groups in local identity store
+// same as keystone provider, membership is kept in groups of user
+type GroupProvider struct {
+	Config *config.Config
+	store  *store
+}
+
+func NewGroupProvider(serverConfig *config.Config) (*GroupProvider, error) {
+	s, err := storeFromConfig(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupProvider{Config: serverConfig, store: s}, nil
+}
+
+// implement IGroupProvider
+
+func (gp *GroupProvider) GetGroups(options map[string]struct{}) ([]core.CoreGroup, error) {
+	var result []core.CoreGroup
+	err := gp.store.view(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = listGroups(tx)
+		return err
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get groups", "error", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (gp *GroupProvider) GetGroup(id string, options map[string]struct{}) (*core.CoreGroup, error) {
+	var result *core.CoreGroup
+	err := gp.store.view(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = getGroup(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (gp *GroupProvider) UpdateGroup(group *core.CoreGroup, options map[string]struct{}) error {
+	if isReservedGroupName(group.Name) {
+		return fmt.Errorf("Group %s is a reserved group cannot be modified", group.Name)
+	}
+
+	err := gp.store.update(func(tx *bbolt.Tx) error {
+		found, err := getGroup(tx, group.Id)
+		if err != nil {
+			return err
+		}
+
+		if group.Name == "" {
+			group.Name = found.Name
+		}
+		if err = putGroup(tx, group); err != nil {
+			return err
+		}
+
+		// keep name of group cached in users in sync
+		if group.Name == found.Name {
+			return nil
+		}
+		return updateUsersInGroup(tx, group.Id, func(userGroup *core.CoreGroup) bool {
+			userGroup.Name = group.Name
+			return true
+		})
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update group", "group", group.Id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (gp *GroupProvider) DeleteGroup(id string, options map[string]struct{}) error {
+	err := gp.store.update(func(tx *bbolt.Tx) error {
+		group, err := getGroup(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if isReservedGroupName(group.Name) {
+			return fmt.Errorf("build in group can not be deleted")
+		}
+
+		// unlike keystone, membership is removed in same transaction so no user is left with a dangling group
+		err = updateUsersInGroup(tx, id, func(*core.CoreGroup) bool {
+			return false
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(groupsBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to delete group", "group", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (gp *GroupProvider) CreateGroup(group *core.CoreGroup, options map[string]struct{}) (*core.CoreGroup, error) {
+	if group.Name == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+
+	toCreate := *group
+	if toCreate.Id == "" {
+		id, err := newId()
+		if err != nil {
+			return nil, err
+		}
+		toCreate.Id = id
+	}
+
+	err := gp.store.update(func(tx *bbolt.Tx) error {
+		if _, err := getGroup(tx, toCreate.Id); err == nil {
+			return fmt.Errorf("group %s already exists", toCreate.Id)
+		}
+		return putGroup(tx, &toCreate)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create group", "group", group.Name, "error", err)
+		return nil, err
+	}
+	return &toCreate, nil
+}
+
+func (gp *GroupProvider) SearchGroupByName(name string, options map[string]struct{}) (*core.CoreGroup, error) {
+	groups, err := gp.GetGroups(options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if group.Name == name {
+			return &group, nil
+		}
+	}
+
+	return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Group %s not found", name))
+}
+
+func (gp *GroupProvider) AddUserToGroup(userId, groupId string) error {
+	err := gp.store.update(func(tx *bbolt.Tx) error {
+		return addUserToGroup(tx, userId, groupId)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to add user to group", "user", userId, "group", groupId, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (gp *GroupProvider) RemoveUserFromGroup(userId, groupId string) error {
+	err := gp.store.update(func(tx *bbolt.Tx) error {
+		record, err := getUser(tx, userId)
+		if err != nil {
+			return err
+		}
+
+		if _, err = getGroup(tx, groupId); err != nil {
+			return err
+		}
+
+		var groups []core.CoreGroup
+		for _, group := range record.User.Groups {
+			if group.Id != groupId {
+				groups = append(groups, group)
+			}
+		}
+
+		if len(groups) == len(record.User.Groups) {
+			coreApiLog.Logger.Warn("User not in group", "user", userId, "group", groupId)
+			return nil
+		}
+
+		record.User.Groups = groups
+		return putUser(tx, record)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to remove user from group", "user", userId, "group", groupId, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (gp *GroupProvider) GetGroupUsers(groupId string, options map[string]struct{}) ([]core.CoreUser, error) {
+	_, reverse := options[keystone.ReverseGetGroupUsers]
+
+	var groupUsers []core.CoreUser
+	err := gp.store.view(func(tx *bbolt.Tx) error {
+		if _, err := getGroup(tx, groupId); err != nil {
+			return err
+		}
+
+		records, err := listUsers(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if isInGroup(&record.User, groupId) != reverse {
+				groupUsers = append(groupUsers, *toCoreUser(&record, nil))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get group users", "group", groupId, "error", err)
+		return nil, err
+	}
+	return groupUsers, nil
+}
+
+func (gp *GroupProvider) GetGroupSummary(options map[string]struct{}) (*core.CoreGroupSummary, error) {
+	result := &core.CoreGroupSummary{}
+	err := gp.store.view(func(tx *bbolt.Tx) error {
+		groups, err := listGroups(tx)
+		if err != nil {
+			return err
+		}
+
+		records, err := listUsers(tx)
+		if err != nil {
+			return err
+		}
+
+		counts := map[string]int{}
+		for _, record := range records {
+			for _, group := range record.User.Groups {
+				counts[group.Id]++
+			}
+		}
+
+		for _, group := range groups {
+			result.Counts = append(result.Counts, core.CoreGroupSummaryDetail{
+				Id:    group.Id,
+				Name:  group.Name,
+				Count: counts[group.Id],
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get group summary", "error", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (gp *GroupProvider) AddUsersToGroup(groupId string, users []core.CoreUser) ([]core.CoreUser, []core.CoreUser, error) {
+	if _, err := gp.GetGroup(groupId, nil); err != nil {
+		coreApiLog.Logger.Error("Failed to get group", "error", err)
+		return nil, nil, err
+	}
+
+	var successes []core.CoreUser
+	var failures []core.CoreUser
+	var errSum error
+	for _, user := range users {
+		err := gp.AddUserToGroup(user.Id, groupId)
+		if err != nil {
+			failures = append(failures, user)
+			continue
+		}
+		successes = append(successes, user)
+	}
+
+	if len(failures) > 0 {
+		errSum = fmt.Errorf("failed to add %d users to group", len(failures))
+	}
+
+	return successes, failures, errSum
+}
+
+func addUserToGroup(tx *bbolt.Tx, userId, groupId string) error {
+	record, err := getUser(tx, userId)
+	if err != nil {
+		return err
+	}
+
+	group, err := getGroup(tx, groupId)
+	if err != nil {
+		return err
+	}
+
+	if isInGroup(&record.User, groupId) {
+		coreApiLog.Logger.Warn("User already in group", "user", userId, "group", groupId)
+		return nil
+	}
+
+	record.User.Groups = append(record.User.Groups, core.CoreGroup{Id: group.Id, Name: group.Name})
+	return putUser(tx, record)
+}
+
+// updateUsersInGroup calls fn on group entry of every member, entry is dropped if fn returns false
+func updateUsersInGroup(tx *bbolt.Tx, groupId string, fn func(userGroup *core.CoreGroup) bool) error {
+	records, err := listUsers(tx)
+	if err != nil {
+		return err
+	}
+
+	for index := range records {
+		if !isInGroup(&records[index].User, groupId) {
+			continue
+		}
+
+		var groups []core.CoreGroup
+		for _, userGroup := range records[index].User.Groups {
+			if userGroup.Id == groupId && !fn(&userGroup) {
+				continue
+			}
+			groups = append(groups, userGroup)
+		}
+		records[index].User.Groups = groups
+		if err = putUser(tx, &records[index]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isInGroup(user *core.CoreUser, groupId string) bool {
+	for _, group := range user.Groups {
+		if group.Id == groupId {
+			return true
+		}
+	}
+	return false
+}
+
+func isReservedGroupName(name string) bool {
+	return name == "admin" || name == "service"
+}
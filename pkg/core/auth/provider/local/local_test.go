@@ -0,0 +1,405 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.etcd.io/bbolt"
+)
+
+var _ = Describe("local provider test", func() {
+	var serverConfig *config.Config
+	var userProvider *UserProvider
+	var roleProvider *RoleProvider
+	var groupProvider *GroupProvider
+	var teacherRole *core.CoreRole
+	var studentGroup *core.CoreGroup
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+
+		var err error
+		userProvider, err = NewUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		roleProvider, err = NewRoleProvider(serverConfig)
+		Expect(err).To(BeNil())
+		groupProvider, err = NewGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+
+		teacherRole, err = roleProvider.CreateRole(&core.CoreRole{
+			Name:       "teacher",
+			Permission: map[string]uint64{"user": 1},
+		}, nil)
+		Expect(err).To(BeNil())
+
+		studentGroup, err = groupProvider.CreateGroup(&core.CoreGroup{Name: "students", TagColor: "red"}, nil)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		storesLock.Lock()
+		defer storesLock.Unlock()
+		path := serverConfig.AuthConfig.Local.Path
+		Expect(stores[path].db.Close()).To(BeNil())
+		delete(stores, path)
+	})
+
+	Describe("NewUserProvider test", func() {
+		It("should be error without local config", func() {
+			_, err := NewUserProvider(config.DefaultConfig())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should share store between providers", func() {
+			Expect(userProvider.store).To(BeIdenticalTo(roleProvider.store))
+			Expect(userProvider.store).To(BeIdenticalTo(groupProvider.store))
+		})
+	})
+
+	Describe("bootstrap test", func() {
+		It("should create build-in admin role and admin user", func() {
+			role, err := roleProvider.GetRole(BuildInAdminRoleId, nil)
+			Expect(err).To(BeNil())
+			Expect(role.UnEditable).To(BeTrue())
+			Expect(role.Permission).To(Equal(privileges.ModulesFullPermission()))
+
+			admin, err := userProvider.LoginUser("admin", "admin-password")
+			Expect(err).To(BeNil())
+			Expect(admin.UnEditable).To(BeTrue())
+			Expect(admin.Permission).To(Equal(privileges.ModulesFullPermission()))
+		})
+	})
+
+	Describe("user provider test", func() {
+		var created *core.CoreUser
+
+		BeforeEach(func() {
+			var err error
+			created, err = userProvider.CreateUser(&core.CoreUser{
+				Name:     "alice",
+				Email:    "alice@example.org",
+				Password: "alice-password",
+				Roles:    []core.CoreRole{{Id: teacherRole.Id, Name: teacherRole.Name}},
+				Groups:   []core.CoreGroup{{Id: studentGroup.Id, Name: studentGroup.Name}},
+			}, nil)
+			Expect(err).To(BeNil())
+			Expect(created.Id).NotTo(BeEmpty())
+		})
+
+		It("should store password as salted hash", func() {
+			var record *userRecord
+			err := userProvider.store.view(func(tx *bbolt.Tx) error {
+				var err error
+				record, err = getUser(tx, created.Id)
+				return err
+			})
+			Expect(err).To(BeNil())
+			Expect(record.User.Password).To(BeEmpty())
+			Expect(record.PasswordHash).To(HavePrefix("$2a$"))
+			Expect(record.PasswordHash).NotTo(ContainSubstring("alice-password"))
+
+			// same password hashes differently with another salt
+			other, err := hashPassword("alice-password")
+			Expect(err).To(BeNil())
+			Expect(other).NotTo(Equal(record.PasswordHash))
+		})
+
+		It("should never return password", func() {
+			user, err := userProvider.GetUser(created.Id, map[string]struct{}{keystone.LoadPermission: {}})
+			Expect(err).To(BeNil())
+			Expect(user.Password).To(BeEmpty())
+
+			users, err := userProvider.GetUsers(map[string]struct{}{keystone.LoadPermission: {}})
+			Expect(err).To(BeNil())
+			for _, u := range users {
+				Expect(u.Password).To(BeEmpty())
+			}
+		})
+
+		It("should login with correct password only", func() {
+			user, err := userProvider.LoginUser("alice", "alice-password")
+			Expect(err).To(BeNil())
+			Expect(user.Id).To(Equal(created.Id))
+			Expect(user.Permission["user"]).To(Equal(uint64(1)))
+
+			_, err = userProvider.LoginUser("alice", "wrong")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+
+			_, err = userProvider.LoginUser("alice", "")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+
+			_, err = userProvider.LoginUser("nobody", "alice-password")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should load permission only if asked", func() {
+			user, err := userProvider.GetUser(created.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(user.Permission["user"]).To(Equal(uint64(0)))
+
+			user, err = userProvider.SearchUserByName("alice", map[string]struct{}{keystone.LoadPermission: {}})
+			Expect(err).To(BeNil())
+			Expect(user.Permission["user"]).To(Equal(uint64(1)))
+		})
+
+		It("should reject duplicated user name", func() {
+			_, err := userProvider.CreateUser(&core.CoreUser{Name: "alice", Password: "x"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject unknown role and group", func() {
+			_, err := userProvider.CreateUser(&core.CoreUser{Name: "bob", Roles: []core.CoreRole{{Id: "unknown"}}}, nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+
+			err = userProvider.UpdateUser(&core.CoreUser{Id: created.Id, Groups: []core.CoreGroup{{Id: "unknown"}}}, nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should update password and keep other fields", func() {
+			err := userProvider.UpdateUser(&core.CoreUser{Id: created.Id, Password: "new-password"}, nil)
+			Expect(err).To(BeNil())
+
+			_, err = userProvider.LoginUser("alice", "alice-password")
+			Expect(err).To(HaveOccurred())
+			user, err := userProvider.LoginUser("alice", "new-password")
+			Expect(err).To(BeNil())
+			Expect(user.Email).To(Equal("alice@example.org"))
+			Expect(user.Groups).To(HaveLen(1))
+		})
+
+		It("should clear must change password only with a new password", func() {
+			err := userProvider.UpdateUser(&core.CoreUser{Id: created.Id, MustChangePassword: true}, nil)
+			Expect(err).To(BeNil())
+			user, err := userProvider.GetUser(created.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(user.MustChangePassword).To(BeTrue())
+
+			err = userProvider.UpdateUser(&core.CoreUser{Id: created.Id, Description: "changed"}, nil)
+			Expect(err).To(BeNil())
+			user, _ = userProvider.GetUser(created.Id, nil)
+			Expect(user.MustChangePassword).To(BeTrue())
+
+			err = userProvider.UpdateUser(&core.CoreUser{Id: created.Id, Password: "new-password"}, nil)
+			Expect(err).To(BeNil())
+			user, _ = userProvider.GetUser(created.Id, nil)
+			Expect(user.MustChangePassword).To(BeFalse())
+		})
+
+		It("should delete user but not build-in user", func() {
+			Expect(userProvider.DeleteUser(created.Id, nil)).To(BeNil())
+			_, err := userProvider.SearchUserByName("alice", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+
+			admin, err := userProvider.SearchUserByName("admin", nil)
+			Expect(err).To(BeNil())
+			Expect(userProvider.DeleteUser(admin.Id, nil)).To(HaveOccurred())
+		})
+
+		It("should disable and enable user and keep its bindings", func() {
+			Expect(userProvider.SetUserDisabled(created.Id, true)).To(BeNil())
+
+			_, err := userProvider.LoginUser("alice", "alice-password")
+			Expect(customErr.IsUnauthorized(err)).To(BeTrue())
+			_, err = userProvider.SearchUserByName("alice", nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+
+			users, err := userProvider.GetUsers(nil)
+			Expect(err).To(BeNil())
+			for _, u := range users {
+				Expect(u.Id).NotTo(Equal(created.Id))
+			}
+
+			user, err := userProvider.SearchUserByName("alice", map[string]struct{}{keystone.IncludeDisabledUsers: {}})
+			Expect(err).To(BeNil())
+			Expect(user.Disabled).To(BeTrue())
+			Expect(user.Groups).To(HaveLen(1))
+			Expect(user.Roles).To(HaveLen(1))
+
+			Expect(userProvider.SetUserDisabled(created.Id, false)).To(BeNil())
+			user, err = userProvider.LoginUser("alice", "alice-password")
+			Expect(err).To(BeNil())
+			Expect(user.Disabled).To(BeFalse())
+		})
+
+		It("should not disable build-in user", func() {
+			admin, err := userProvider.SearchUserByName("admin", nil)
+			Expect(err).To(BeNil())
+			Expect(userProvider.SetUserDisabled(admin.Id, true)).To(HaveOccurred())
+			Expect(customErr.IsNotFound(userProvider.SetUserDisabled("unknown", true))).To(BeTrue())
+		})
+
+		It("should keep data after store is reopened", func() {
+			storesLock.Lock()
+			path := serverConfig.AuthConfig.Local.Path
+			Expect(stores[path].db.Close()).To(BeNil())
+			delete(stores, path)
+			storesLock.Unlock()
+
+			reopened, err := NewUserProvider(serverConfig)
+			Expect(err).To(BeNil())
+			_, err = reopened.LoginUser("alice", "alice-password")
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Describe("role provider test", func() {
+		It("should not modify or delete build-in role", func() {
+			err := roleProvider.UpdateRole(&core.CoreRole{Id: BuildInAdminRoleId, Name: "admin"}, nil)
+			Expect(err).To(HaveOccurred())
+			Expect(roleProvider.DeleteRole(BuildInAdminRoleId, nil)).To(HaveOccurred())
+			_, err = roleProvider.CreateRole(&core.CoreRole{Name: "service"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should update role", func() {
+			teacherRole.Description = "teacher"
+			Expect(roleProvider.UpdateRole(teacherRole, nil)).To(BeNil())
+			role, err := roleProvider.SearchRoleByName("teacher", nil)
+			Expect(err).To(BeNil())
+			Expect(role.Description).To(Equal("teacher"))
+		})
+
+		It("should remove role from users when role is deleted", func() {
+			user, err := userProvider.CreateUser(&core.CoreUser{Name: "bob", Roles: []core.CoreRole{{Id: teacherRole.Id}}}, nil)
+			Expect(err).To(BeNil())
+
+			Expect(roleProvider.DeleteRole(teacherRole.Id, nil)).To(BeNil())
+			_, err = roleProvider.GetRole(teacherRole.Id, nil)
+			Expect(customErr.IsNotFound(err)).To(BeTrue())
+
+			found, err := userProvider.GetUser(user.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(found.Roles).To(BeEmpty())
+		})
+	})
+
+	Describe("group provider test", func() {
+		var bob *core.CoreUser
+
+		BeforeEach(func() {
+			var err error
+			bob, err = userProvider.CreateUser(&core.CoreUser{Name: "bob"}, nil)
+			Expect(err).To(BeNil())
+		})
+
+		It("should add and remove user", func() {
+			Expect(groupProvider.AddUserToGroup(bob.Id, studentGroup.Id)).To(BeNil())
+			// adding twice is fine
+			Expect(groupProvider.AddUserToGroup(bob.Id, studentGroup.Id)).To(BeNil())
+
+			users, err := groupProvider.GetGroupUsers(studentGroup.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(users).To(HaveLen(1))
+			Expect(users[0].Name).To(Equal("bob"))
+
+			others, err := groupProvider.GetGroupUsers(studentGroup.Id, map[string]struct{}{keystone.ReverseGetGroupUsers: {}})
+			Expect(err).To(BeNil())
+			Expect(others).To(HaveLen(1))
+			Expect(others[0].Name).To(Equal("admin"))
+
+			summary, err := groupProvider.GetGroupSummary(nil)
+			Expect(err).To(BeNil())
+			Expect(summary.Counts).To(Equal([]core.CoreGroupSummaryDetail{{Id: studentGroup.Id, Name: "students", Count: 1}}))
+
+			Expect(groupProvider.RemoveUserFromGroup(bob.Id, studentGroup.Id)).To(BeNil())
+			users, err = groupProvider.GetGroupUsers(studentGroup.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(users).To(BeEmpty())
+		})
+
+		It("should add users in batch and report failures", func() {
+			successes, failures, err := groupProvider.AddUsersToGroup(studentGroup.Id, []core.CoreUser{{Id: bob.Id}, {Id: "unknown"}})
+			Expect(err).To(HaveOccurred())
+			Expect(successes).To(HaveLen(1))
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0].Id).To(Equal("unknown"))
+		})
+
+		It("should rename group in users and remove membership when group is deleted", func() {
+			Expect(groupProvider.AddUserToGroup(bob.Id, studentGroup.Id)).To(BeNil())
+
+			Expect(groupProvider.UpdateGroup(&core.CoreGroup{Id: studentGroup.Id, Name: "pupils"}, nil)).To(BeNil())
+			user, err := userProvider.GetUser(bob.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(user.Groups[0].Name).To(Equal("pupils"))
+
+			Expect(groupProvider.DeleteGroup(studentGroup.Id, nil)).To(BeNil())
+			user, err = userProvider.GetUser(bob.Id, nil)
+			Expect(err).To(BeNil())
+			Expect(user.Groups).To(BeEmpty())
+		})
+
+		It("should reject duplicated group name", func() {
+			_, err := groupProvider.CreateGroup(&core.CoreGroup{Name: "students"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Import test", func() {
+		It("should import keystone objects and hash passwords", func() {
+			roles := []core.CoreRole{
+				{Id: "k-admin", Name: "admin"},
+				{Id: "k-student", Name: "student", Permission: map[string]uint64{"user": 2}},
+			}
+			groups := []core.CoreGroup{{Id: "k-class", Name: "class-1"}}
+			users := []core.CoreUser{
+				{Id: "k-carol", Name: "carol", Password: "carol-password", Roles: []core.CoreRole{{Id: "k-student"}, {Id: "k-admin"}}, Groups: []core.CoreGroup{{Id: "k-class"}}},
+				{Id: "k-dave", Name: "dave"},
+				{Id: "k-admin-user", Name: "admin", Password: "keystone-admin"},
+			}
+
+			report, err := Import(serverConfig.AuthConfig.Local, roles, groups, users, false)
+			Expect(err).To(BeNil())
+			Expect(report.Roles).To(Equal([]string{"student"}))
+			Expect(report.Groups).To(Equal([]string{"class-1"}))
+			Expect(report.Users).To(Equal([]string{"carol", "dave"}))
+			Expect(report.UsersWithoutPassword).To(Equal([]string{"dave"}))
+			Expect(report.Skipped).To(ConsistOf("role/admin", "user/admin"))
+
+			carol, err := userProvider.LoginUser("carol", "carol-password")
+			Expect(err).To(BeNil())
+			Expect(carol.Id).To(Equal("k-carol"))
+			// admin role of keystone is mapped to build-in admin role
+			Expect(carol.Permission).To(Equal(privileges.ModulesFullPermission()))
+			Expect(carol.Groups).To(Equal([]core.CoreGroup{{Id: "k-class"}}))
+
+			// existing admin is untouched without overwrite
+			_, err = userProvider.LoginUser("admin", "admin-password")
+			Expect(err).To(BeNil())
+
+			report, err = Import(serverConfig.AuthConfig.Local, roles, groups, users, true)
+			Expect(err).To(BeNil())
+			Expect(report.Users).To(ContainElement("admin"))
+			_, err = userProvider.LoginUser("admin", "keystone-admin")
+			Expect(err).To(BeNil())
+			Expect(strings.Join(report.Skipped, ",")).To(ContainSubstring("role/student"))
+		})
+
+		It("should import disabled keystone user as disabled", func() {
+			users := []core.CoreUser{{Id: "k-erin", Name: "erin", Password: "erin-password", Disabled: true}}
+			report, err := Import(serverConfig.AuthConfig.Local, nil, nil, users, false)
+			Expect(err).To(BeNil())
+			Expect(report.Users).To(Equal([]string{"erin"}))
+
+			erin, err := userProvider.GetUser("k-erin", nil)
+			Expect(err).To(BeNil())
+			Expect(erin.Disabled).To(BeTrue())
+			_, err = userProvider.LoginUser("erin", "erin-password")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,192 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+
+	"go.etcd.io/bbolt"
+)
+
+// ImportReport tells what Import has done, objects are referred by name
+type ImportReport struct {
+	Roles   []string `json:"roles"`
+	Groups  []string `json:"groups"`
+	Users   []string `json:"users"`
+	Skipped []string `json:"skipped"`
+	// users imported without password, they can not login until password is set by an administrator
+	UsersWithoutPassword []string `json:"usersWithoutPassword"`
+}
+
+// Import copies roles, groups and users of another provider into local identity store
+// ids are kept so references between objects stay valid, an object whose name already exists in store
+// is skipped and references to it are pointed to the existing one, existing users are replaced if overwrite is set
+// password of user is hashed before saving if it is given, users without password are listed in report
+func Import(localConfig *config.LocalConfig, roles []core.CoreRole, groups []core.CoreGroup, users []core.CoreUser, overwrite bool) (*ImportReport, error) {
+	s, err := initOrGetStore(localConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// hash outside of transaction, bcrypt is slow on purpose
+	passwordHashes := map[string]string{}
+	for _, user := range users {
+		if user.Password == "" {
+			continue
+		}
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to hash password", "user", user.Name, "error", err)
+			return nil, err
+		}
+		passwordHashes[user.Name] = hash
+	}
+
+	report := &ImportReport{}
+	err = s.update(func(tx *bbolt.Tx) error {
+		roleIds, err := importRoles(tx, roles, report)
+		if err != nil {
+			return err
+		}
+
+		groupIds, err := importGroups(tx, groups, report)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			record := &userRecord{User: user, PasswordHash: passwordHashes[user.Name]}
+			record.User.Roles = remapRoles(user.Roles, roleIds)
+			record.User.Groups = remapGroups(user.Groups, groupIds)
+
+			if existingId, found := getUserIdByName(tx, user.Name); found {
+				if !overwrite {
+					report.Skipped = append(report.Skipped, "user/"+user.Name)
+					continue
+				}
+				record.User.Id = existingId
+			} else if _, err := getUser(tx, user.Id); err == nil || user.Id == "" {
+				// id is taken by a user with another name, give imported one a new id
+				if record.User.Id, err = newId(); err != nil {
+					return err
+				}
+			}
+
+			if err = putUser(tx, record); err != nil {
+				return err
+			}
+
+			report.Users = append(report.Users, user.Name)
+			if record.PasswordHash == "" {
+				report.UsersWithoutPassword = append(report.UsersWithoutPassword, user.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to import into local identity store", "error", err)
+		return nil, err
+	}
+	return report, nil
+}
+
+// importRoles returns id mapping from source roles to roles in store
+func importRoles(tx *bbolt.Tx, roles []core.CoreRole, report *ImportReport) (map[string]string, error) {
+	existing, err := listRoles(tx)
+	if err != nil {
+		return nil, err
+	}
+	existingByName := map[string]string{}
+	for _, role := range existing {
+		existingByName[role.Name] = role.Id
+	}
+
+	result := map[string]string{}
+	for _, role := range roles {
+		if id, found := existingByName[role.Name]; found {
+			result[role.Id] = id
+			report.Skipped = append(report.Skipped, "role/"+role.Name)
+			continue
+		}
+
+		toImport := role
+		if _, err := getRole(tx, toImport.Id); err == nil || toImport.Id == "" {
+			if toImport.Id, err = newId(); err != nil {
+				return nil, err
+			}
+		}
+		toImport.UnEditable = false
+		if err = putRole(tx, &toImport); err != nil {
+			return nil, err
+		}
+
+		result[role.Id] = toImport.Id
+		existingByName[role.Name] = toImport.Id
+		report.Roles = append(report.Roles, role.Name)
+	}
+	return result, nil
+}
+
+// importGroups returns id mapping from source groups to groups in store
+func importGroups(tx *bbolt.Tx, groups []core.CoreGroup, report *ImportReport) (map[string]string, error) {
+	existing, err := listGroups(tx)
+	if err != nil {
+		return nil, err
+	}
+	existingByName := map[string]string{}
+	for _, group := range existing {
+		existingByName[group.Name] = group.Id
+	}
+
+	result := map[string]string{}
+	for _, group := range groups {
+		if id, found := existingByName[group.Name]; found {
+			result[group.Id] = id
+			report.Skipped = append(report.Skipped, "group/"+group.Name)
+			continue
+		}
+
+		toImport := group
+		if _, err := getGroup(tx, toImport.Id); err == nil || toImport.Id == "" {
+			if toImport.Id, err = newId(); err != nil {
+				return nil, err
+			}
+		}
+		if err = putGroup(tx, &toImport); err != nil {
+			return nil, err
+		}
+
+		result[group.Id] = toImport.Id
+		existingByName[group.Name] = toImport.Id
+		report.Groups = append(report.Groups, group.Name)
+	}
+	return result, nil
+}
+
+// remapRoles points role references to ids in store, references to unknown roles are dropped
+func remapRoles(roles []core.CoreRole, ids map[string]string) []core.CoreRole {
+	var result []core.CoreRole
+	for _, role := range roles {
+		id, found := ids[role.Id]
+		if !found {
+			coreApiLog.Logger.Warn("Role not found, drop it from user", "role", role.Id)
+			continue
+		}
+		result = append(result, core.CoreRole{Id: id, Name: role.Name})
+	}
+	return result
+}
+
+// remapGroups points group references to ids in store, references to unknown groups are dropped
+func remapGroups(groups []core.CoreGroup, ids map[string]string) []core.CoreGroup {
+	var result []core.CoreGroup
+	for _, group := range groups {
+		id, found := ids[group.Id]
+		if !found {
+			coreApiLog.Logger.Warn("Group not found, drop it from user", "group", group.Id)
+			continue
+		}
+		result = append(result, core.CoreGroup{Id: id, Name: group.Name})
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package local
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared against when user does not exist
+// so response time does not tell whether a user name is valid
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("core-api-dummy-password"), bcrypt.DefaultCost)
+
+// hashPassword returns bcrypt hash of password, bcrypt generates and embeds a random salt
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// checkPassword reports whether password matches hash, empty hash never matches
+func checkPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,158 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+
+	"go.etcd.io/bbolt"
+)
+
+// RoleProvider keeps roles in local identity store
+type RoleProvider struct {
+	Config *config.Config
+	store  *store
+}
+
+func NewRoleProvider(serverConfig *config.Config) (*RoleProvider, error) {
+	s, err := storeFromConfig(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &RoleProvider{Config: serverConfig, store: s}, nil
+}
+
+// implement IRoleProvider
+
+func (rp *RoleProvider) GetRoles(options map[string]struct{}) ([]core.CoreRole, error) {
+	var result []core.CoreRole
+	err := rp.store.view(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = listRoles(tx)
+		return err
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get roles", "error", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (rp *RoleProvider) GetRole(id string, options map[string]struct{}) (*core.CoreRole, error) {
+	var result *core.CoreRole
+	err := rp.store.view(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = getRole(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (rp *RoleProvider) UpdateRole(role *core.CoreRole, options map[string]struct{}) error {
+	if role.Id == BuildInAdminRoleId || isReservedRoleName(role.Name) {
+		return fmt.Errorf("Role %s is a reserved role cannot be modified", role.Name)
+	}
+
+	err := rp.store.update(func(tx *bbolt.Tx) error {
+		if _, err := getRole(tx, role.Id); err != nil {
+			return err
+		}
+		return putRole(tx, role)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update role", "role", role.Id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (rp *RoleProvider) DeleteRole(id string, options map[string]struct{}) error {
+	err := rp.store.update(func(tx *bbolt.Tx) error {
+		role, err := getRole(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if role.Id == BuildInAdminRoleId || isReservedRoleName(role.Name) || role.Name == "aes-admin" {
+			return fmt.Errorf("build in role can not be deleted")
+		}
+
+		// role bindings of users are removed in same transaction
+		records, err := listUsers(tx)
+		if err != nil {
+			return err
+		}
+		for index := range records {
+			var roles []core.CoreRole
+			for _, userRole := range records[index].User.Roles {
+				if userRole.Id != id {
+					roles = append(roles, userRole)
+				}
+			}
+			if len(roles) == len(records[index].User.Roles) {
+				continue
+			}
+			records[index].User.Roles = roles
+			if err = putUser(tx, &records[index]); err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(rolesBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to delete role", "role", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (rp *RoleProvider) CreateRole(role *core.CoreRole, options map[string]struct{}) (*core.CoreRole, error) {
+	if isReservedRoleName(role.Name) {
+		return nil, fmt.Errorf("Role %s is a reserved role", role.Name)
+	}
+
+	id, err := newId()
+	if err != nil {
+		return nil, err
+	}
+
+	toCreate := *role
+	toCreate.Id = id
+	toCreate.UnEditable = false
+	err = rp.store.update(func(tx *bbolt.Tx) error {
+		return putRole(tx, &toCreate)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create role", "role", role.Name, "error", err)
+		return nil, err
+	}
+
+	role.Id = id
+	return role, nil
+}
+
+func (rp *RoleProvider) SearchRoleByName(name string, options map[string]struct{}) (*core.CoreRole, error) {
+	roles, err := rp.GetRoles(options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+
+	return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", name))
+}
+
+func isReservedRoleName(name string) bool {
+	return name == "admin" || name == "service"
+}
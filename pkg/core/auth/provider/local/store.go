@@ -0,0 +1,351 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	BuildInAdminRoleId   = "admin"
+	BuildInAdminUserName = "admin"
+)
+
+var (
+	usersBucket     = []byte("users")
+	userNamesBucket = []byte("userNames")
+	rolesBucket     = []byte("roles")
+	groupsBucket    = []byte("groups")
+)
+
+// userRecord is what is persisted for a user, password is only kept as salted bcrypt hash
+type userRecord struct {
+	User         core.CoreUser `json:"user"`
+	PasswordHash string        `json:"passwordHash,omitempty"`
+}
+
+// store keeps users, roles and groups in a bbolt database file
+// users are indexed by name so login does not need to scan all users
+type store struct {
+	db *bbolt.DB
+}
+
+var stores = map[string]*store{}
+var storesLock sync.Mutex
+
+// initOrGetStore returns store shared by providers that use same database file
+// bbolt holds an exclusive file lock so a file can only be opened once per process
+func initOrGetStore(localConfig *config.LocalConfig) (*store, error) {
+	if localConfig == nil || localConfig.Path == "" {
+		return nil, fmt.Errorf("local identity store path is not set")
+	}
+
+	storesLock.Lock()
+	defer storesLock.Unlock()
+
+	if s, found := stores[localConfig.Path]; found {
+		return s, nil
+	}
+
+	s, err := openStore(localConfig.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.bootstrap(localConfig.BootstrapAdminPassword)
+	if err != nil {
+		_ = s.db.Close()
+		return nil, err
+	}
+
+	stores[localConfig.Path] = s
+	return s, nil
+}
+
+func openStore(path string) (*store, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local identity store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{usersBucket, userNamesBucket, rolesBucket, groupsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &store{db: db}, nil
+}
+
+// bootstrap makes sure build-in admin role exists, admin user is created only when password is given
+func (s *store) bootstrap(adminPassword string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		// build-in admin role always has full permission
+		err := putRole(tx, &core.CoreRole{
+			Id:          BuildInAdminRoleId,
+			Name:        "admin",
+			Description: "build-in administrator",
+			Permission:  privileges.ModulesFullPermission(),
+			UnEditable:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		if adminPassword == "" {
+			return nil
+		}
+
+		if _, found := getUserIdByName(tx, BuildInAdminUserName); found {
+			return nil
+		}
+
+		hash, err := hashPassword(adminPassword)
+		if err != nil {
+			return err
+		}
+
+		id, err := newId()
+		if err != nil {
+			return err
+		}
+
+		coreApiLog.Logger.Info("Creating build-in admin user in local identity store")
+		return putUser(tx, &userRecord{
+			User: core.CoreUser{
+				Id:    id,
+				Name:  BuildInAdminUserName,
+				Roles: []core.CoreRole{{Id: BuildInAdminRoleId, Name: "admin"}},
+			},
+			PasswordHash: hash,
+		})
+	})
+}
+
+func (s *store) view(fn func(tx *bbolt.Tx) error) error {
+	return s.db.View(fn)
+}
+
+func (s *store) update(fn func(tx *bbolt.Tx) error) error {
+	return s.db.Update(fn)
+}
+
+func getUser(tx *bbolt.Tx, id string) (*userRecord, error) {
+	record := &userRecord{}
+	found, err := getObject(tx, usersBucket, id, record)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", id))
+	}
+	return record, nil
+}
+
+func getUserIdByName(tx *bbolt.Tx, name string) (string, bool) {
+	id := tx.Bucket(userNamesBucket).Get([]byte(name))
+	if id == nil {
+		return "", false
+	}
+	return string(id), true
+}
+
+func listUsers(tx *bbolt.Tx) ([]userRecord, error) {
+	var result []userRecord
+	err := tx.Bucket(usersBucket).ForEach(func(_, value []byte) error {
+		record := userRecord{}
+		if err := json.Unmarshal(value, &record); err != nil {
+			return err
+		}
+		result = append(result, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].User.Name < result[j].User.Name
+	})
+	return result, nil
+}
+
+// putUser creates or replaces user and keeps name index in sync, user name must be unique
+func putUser(tx *bbolt.Tx, record *userRecord) error {
+	if record.User.Id == "" || record.User.Name == "" {
+		return fmt.Errorf("user id and name are required")
+	}
+
+	if id, found := getUserIdByName(tx, record.User.Name); found && id != record.User.Id {
+		return fmt.Errorf("user %s already exists", record.User.Name)
+	}
+
+	old := &userRecord{}
+	found, err := getObject(tx, usersBucket, record.User.Id, old)
+	if err != nil {
+		return err
+	}
+	if found && old.User.Name != record.User.Name {
+		if err = tx.Bucket(userNamesBucket).Delete([]byte(old.User.Name)); err != nil {
+			return err
+		}
+	}
+
+	// password and permission are never persisted as part of core user
+	record.User.Password = ""
+	record.User.Permission = nil
+	record.User.UnEditable = false
+
+	if err = putObject(tx, usersBucket, record.User.Id, record); err != nil {
+		return err
+	}
+	return tx.Bucket(userNamesBucket).Put([]byte(record.User.Name), []byte(record.User.Id))
+}
+
+func deleteUser(tx *bbolt.Tx, id string) error {
+	record, err := getUser(tx, id)
+	if err != nil {
+		return err
+	}
+	if err = tx.Bucket(userNamesBucket).Delete([]byte(record.User.Name)); err != nil {
+		return err
+	}
+	return tx.Bucket(usersBucket).Delete([]byte(id))
+}
+
+func getRole(tx *bbolt.Tx, id string) (*core.CoreRole, error) {
+	role := &core.CoreRole{}
+	found, err := getObject(tx, rolesBucket, id, role)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Role %s not found", id))
+	}
+	return role, nil
+}
+
+func listRoles(tx *bbolt.Tx) ([]core.CoreRole, error) {
+	var result []core.CoreRole
+	err := tx.Bucket(rolesBucket).ForEach(func(_, value []byte) error {
+		role := core.CoreRole{}
+		if err := json.Unmarshal(value, &role); err != nil {
+			return err
+		}
+		result = append(result, role)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// putRole creates or replaces role, role name must be unique
+func putRole(tx *bbolt.Tx, role *core.CoreRole) error {
+	roles, err := listRoles(tx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range roles {
+		if existing.Name == role.Name && existing.Id != role.Id {
+			return fmt.Errorf("role %s already exists", role.Name)
+		}
+	}
+	return putObject(tx, rolesBucket, role.Id, role)
+}
+
+func getGroup(tx *bbolt.Tx, id string) (*core.CoreGroup, error) {
+	group := &core.CoreGroup{}
+	found, err := getObject(tx, groupsBucket, id, group)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Group %s not found", id))
+	}
+	return group, nil
+}
+
+func listGroups(tx *bbolt.Tx) ([]core.CoreGroup, error) {
+	var result []core.CoreGroup
+	err := tx.Bucket(groupsBucket).ForEach(func(_, value []byte) error {
+		group := core.CoreGroup{}
+		if err := json.Unmarshal(value, &group); err != nil {
+			return err
+		}
+		result = append(result, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// putGroup creates or replaces group, group name must be unique
+func putGroup(tx *bbolt.Tx, group *core.CoreGroup) error {
+	groups, err := listGroups(tx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range groups {
+		if existing.Name == group.Name && existing.Id != group.Id {
+			return fmt.Errorf("group %s already exists", group.Name)
+		}
+	}
+	return putObject(tx, groupsBucket, group.Id, group)
+}
+
+func getObject(tx *bbolt.Tx, bucket []byte, key string, value interface{}) (bool, error) {
+	content := tx.Bucket(bucket).Get([]byte(key))
+	if content == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(content, value)
+}
+
+func putObject(tx *bbolt.Tx, bucket []byte, key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("empty key in bucket %s", bucket)
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(key), content)
+}
+
+func newId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
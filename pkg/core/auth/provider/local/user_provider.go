@@ -0,0 +1,374 @@
+package local
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+
+	"go.etcd.io/bbolt"
+)
+
+// UserProvider keeps users in local identity store, password is stored as salted hash and never returned
+type UserProvider struct {
+	Config *config.Config
+	store  *store
+}
+
+func NewUserProvider(serverConfig *config.Config) (*UserProvider, error) {
+	s, err := storeFromConfig(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &UserProvider{Config: serverConfig, store: s}, nil
+}
+
+func storeFromConfig(serverConfig *config.Config) (*store, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil || serverConfig.AuthConfig.Local == nil {
+		return nil, fmt.Errorf("local identity store config is nil")
+	}
+	return initOrGetStore(serverConfig.AuthConfig.Local)
+}
+
+// implement IUserProvider
+
+func (up *UserProvider) GetUsers(options map[string]struct{}) ([]core.CoreUser, error) {
+	var result []core.CoreUser
+	err := up.store.view(func(tx *bbolt.Tx) error {
+		records, err := listUsers(tx)
+		if err != nil {
+			return err
+		}
+
+		roles, err := roleMap(tx, options)
+		if err != nil {
+			return err
+		}
+
+		_, includeDisabled := options[keystone.IncludeDisabledUsers]
+		for _, record := range records {
+			if record.User.Disabled && !includeDisabled {
+				// follows keystone provider which skips disabled user
+				continue
+			}
+			result = append(result, *toCoreUser(&record, roles))
+		}
+		return nil
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get users", "error", err)
+		return []core.CoreUser{}, err
+	}
+	return result, nil
+}
+
+func (up *UserProvider) SearchUserByName(name string, options map[string]struct{}) (*core.CoreUser, error) {
+	var result *core.CoreUser
+	err := up.store.view(func(tx *bbolt.Tx) error {
+		id, found := getUserIdByName(tx, name)
+		if !found {
+			return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
+		}
+
+		var err error
+		result, err = getCoreUser(tx, id, options)
+		if err != nil {
+			return err
+		}
+
+		if _, found := options[keystone.IncludeDisabledUsers]; !found && result.Disabled {
+			return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("User %s not found", name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (up *UserProvider) GetUser(id string, options map[string]struct{}) (*core.CoreUser, error) {
+	var result *core.CoreUser
+	err := up.store.view(func(tx *bbolt.Tx) error {
+		var err error
+		result, err = getCoreUser(tx, id, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdateUser follows keystone provider, only non empty fields are changed
+// mustChangePassword can only be cleared together with a password change
+func (up *UserProvider) UpdateUser(user *core.CoreUser, options map[string]struct{}) error {
+	passwordHash := ""
+	if user.Password != "" {
+		// hash outside of transaction, bcrypt is slow on purpose
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to hash password", "error", err)
+			return err
+		}
+		passwordHash = hash
+	}
+
+	err := up.store.update(func(tx *bbolt.Tx) error {
+		record, err := getUser(tx, user.Id)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		if passwordHash != "" {
+			changed = true
+			record.PasswordHash = passwordHash
+			// a new password set by user clears the flag, a reset by admin may set it again
+			record.User.MustChangePassword = user.MustChangePassword
+		} else if user.MustChangePassword && !record.User.MustChangePassword {
+			changed = true
+			record.User.MustChangePassword = true
+		}
+
+		if user.Email != "" {
+			changed = true
+			record.User.Email = user.Email
+		}
+
+		if user.Roles != nil {
+			if err = checkRolesExist(tx, user.Roles); err != nil {
+				return err
+			}
+			changed = true
+			record.User.Roles = user.Roles
+		}
+
+		if user.Groups != nil {
+			if err = checkGroupsExist(tx, user.Groups); err != nil {
+				return err
+			}
+			changed = true
+			record.User.Groups = user.Groups
+		}
+
+		if user.Description != "" {
+			changed = true
+			record.User.Description = user.Description
+		}
+
+		if !changed {
+			coreApiLog.Logger.Debug("No change found skip update", "user", user.Id)
+			return nil
+		}
+
+		return putUser(tx, record)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to update user", "user", user.Id, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (up *UserProvider) DeleteUser(id string, options map[string]struct{}) error {
+	err := up.store.update(func(tx *bbolt.Tx) error {
+		record, err := getUser(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if isBuildInUser(record.User.Name) {
+			return fmt.Errorf("build in user can not be deleted")
+		}
+
+		return deleteUser(tx, id)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to delete user", "user", id, "error", err)
+		return err
+	}
+	return nil
+}
+
+// SetUserDisabled keeps user and everything bound to it, login of a disabled user is refused
+func (up *UserProvider) SetUserDisabled(id string, disabled bool) error {
+	err := up.store.update(func(tx *bbolt.Tx) error {
+		record, err := getUser(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if disabled && isBuildInUser(record.User.Name) {
+			return fmt.Errorf("build in user can not be disabled")
+		}
+
+		if record.User.Disabled == disabled {
+			return nil
+		}
+		record.User.Disabled = disabled
+		return putUser(tx, record)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to set user disabled", "user", id, "disabled", disabled, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (up *UserProvider) CreateUser(user *core.CoreUser, options map[string]struct{}) (*core.CoreUser, error) {
+	if user.Name == "" {
+		return nil, fmt.Errorf("user name is required")
+	}
+
+	passwordHash := ""
+	if user.Password != "" {
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to hash password", "error", err)
+			return nil, err
+		}
+		passwordHash = hash
+	}
+
+	id, err := newId()
+	if err != nil {
+		return nil, err
+	}
+
+	err = up.store.update(func(tx *bbolt.Tx) error {
+		if _, found := getUserIdByName(tx, user.Name); found {
+			return fmt.Errorf("user %s already exists", user.Name)
+		}
+
+		if err := checkRolesExist(tx, user.Roles); err != nil {
+			return err
+		}
+
+		if err := checkGroupsExist(tx, user.Groups); err != nil {
+			return err
+		}
+
+		record := &userRecord{User: *user, PasswordHash: passwordHash}
+		record.User.Id = id
+		return putUser(tx, record)
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create user", "user", user.Name, "error", err)
+		return nil, err
+	}
+
+	user.Id = id
+	return user, nil
+}
+
+// LoginUser compares password with stored hash
+func (up *UserProvider) LoginUser(name, password string) (*core.CoreUser, error) {
+	var record *userRecord
+	err := up.store.view(func(tx *bbolt.Tx) error {
+		id, found := getUserIdByName(tx, name)
+		if !found {
+			return nil
+		}
+
+		var err error
+		record, err = getUser(tx, id)
+		return err
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to get user", "user", name, "error", err)
+		return nil, err
+	}
+
+	passwordHash := ""
+	if record != nil {
+		passwordHash = record.PasswordHash
+	}
+
+	if !checkPassword(passwordHash, password) {
+		coreApiLog.Logger.Error("Failed to login user", "user", name)
+		return nil, customErr.NewUnauthorized(http.StatusUnauthorized, "Failed to login user")
+	}
+
+	// checked after password so a disabled user is not told apart by wrong password
+	if record.User.Disabled {
+		coreApiLog.Logger.Error("Failed to login disabled user", "user", name)
+		return nil, customErr.NewUnauthorized(http.StatusUnauthorized, "Failed to login user")
+	}
+
+	return up.GetUser(record.User.Id, map[string]struct{}{keystone.LoadPermission: {}})
+}
+
+func getCoreUser(tx *bbolt.Tx, id string, options map[string]struct{}) (*core.CoreUser, error) {
+	record, err := getUser(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := roleMap(tx, options)
+	if err != nil {
+		return nil, err
+	}
+	return toCoreUser(record, roles), nil
+}
+
+// roleMap returns all roles indexed by id if permission should be loaded, otherwise nil
+func roleMap(tx *bbolt.Tx, options map[string]struct{}) (map[string]core.CoreRole, error) {
+	if _, found := options[keystone.LoadPermission]; !found {
+		return nil, nil
+	}
+
+	roles, err := listRoles(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]core.CoreRole, len(roles))
+	for _, role := range roles {
+		result[role.Id] = role
+	}
+	return result, nil
+}
+
+func toCoreUser(record *userRecord, roles map[string]core.CoreRole) *core.CoreUser {
+	user := record.User
+	user.Password = ""
+	user.UnEditable = isBuildInUser(user.Name)
+	user.Permission = privileges.ModulesNoPermission()
+	for _, userRole := range user.Roles {
+		role, found := roles[userRole.Id]
+		if !found {
+			continue
+		}
+		for module, permission := range role.Permission {
+			user.Permission[module] |= permission
+		}
+	}
+	return &user
+}
+
+func checkRolesExist(tx *bbolt.Tx, roles []core.CoreRole) error {
+	for _, role := range roles {
+		if _, err := getRole(tx, role.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkGroupsExist(tx *bbolt.Tx, groups []core.CoreGroup) error {
+	for _, group := range groups {
+		if _, err := getGroup(tx, group.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isBuildInUser(name string) bool {
+	return name == "admin" || name == "service"
+}
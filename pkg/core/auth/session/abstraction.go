@@ -0,0 +1,100 @@
+package session
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	authToken "core-api/pkg/core/auth/token"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"time"
+)
+
+// ISessionStore keeps track of the session token issued by login api
+// so that a session can be listed, refreshed and revoked before the token expires
+type ISessionStore interface {
+	// Add registers a newly issued session
+	Add(session *core.CoreSession) error
+	// Touch records activity of a session that passed token validation
+	// the session is registered again if it is unknown to the store, e.g. after server restart
+	// an unauthorized error is returned if the session has been revoked
+	Touch(session *core.CoreSession) error
+	// IsRevoked tells whether the session has been revoked, by its id or by revoking all sessions of its user after it is issued
+	IsRevoked(session *core.CoreSession) bool
+	// GetSession returns the session with given id, a not found error is returned if it does not exist or expired
+	GetSession(sessionId string) (*core.CoreSession, error)
+	// ListUserSessions returns all active sessions of the user
+	ListUserSessions(userId string) ([]core.CoreSession, error)
+	// Revoke revokes the session, token of the session will be rejected until it expires
+	Revoke(sessionId string) error
+	// RevokeIfActive revokes the session unless it has been revoked already, it works with a session unknown to the store
+	// an unauthorized error is returned if it has been revoked, so only one of requests racing on the same session wins
+	RevokeIfActive(session *core.CoreSession) error
+	// RevokeUserSessions revokes all sessions of the user and returns the number of revoked sessions known to the store
+	// sessions issued before are revoked as well even if the store never saw them, e.g. issued before restart
+	RevokeUserSessions(userId string) (int, error)
+}
+
+type SessionStoreType string
+
+const (
+	MemorySessionStore SessionStoreType = "memory"
+	BoltSessionStore   SessionStoreType = "bolt"
+)
+
+var defaultSessionStore ISessionStore
+
+// InitOrGetSessionStore returns the session store shared by login handler and auth middleware
+// revocations are kept in file if session path is set, otherwise in memory
+// revocations are not shared between servers, so core-api has to run as a single replica
+func InitOrGetSessionStore(serverConfig *config.Config) (ISessionStore, error) {
+	if defaultSessionStore == nil {
+		storeType := BoltSessionStore
+		if serverConfig != nil && serverConfig.CoreApiConfig != nil && serverConfig.CoreApiConfig.SessionPath == "" {
+			storeType = MemorySessionStore
+			coreApiLog.Logger.Warn("session path is not set, revoked session tokens are accepted again after restart")
+		}
+
+		store, err := CreateSessionStore(serverConfig, storeType)
+		if err != nil {
+			return nil, err
+		}
+		defaultSessionStore = store
+	}
+	return defaultSessionStore, nil
+}
+
+func CreateSessionStore(serverConfig *config.Config, storeType SessionStoreType) (ISessionStore, error) {
+	if serverConfig == nil || serverConfig.CoreApiConfig == nil {
+		return nil, fmt.Errorf("core api config is nil")
+	}
+
+	// revocation of all sessions of a user is kept as long as a token issued before it can be valid
+	tokenExpire := time.Duration(serverConfig.CoreApiConfig.TokenExpireMinutes) * time.Minute
+	if tokenExpire <= 0 {
+		tokenExpire = authToken.DefaultTokenExpireMinutes * time.Minute
+	}
+
+	switch storeType {
+	case MemorySessionStore:
+		return newMemorySessionStore(tokenExpire), nil
+	case BoltSessionStore:
+		db, err := openDB(serverConfig.CoreApiConfig.SessionPath)
+		if err != nil {
+			return nil, err
+		}
+		return newBoltSessionStore(db, tokenExpire), nil
+	}
+
+	return nil, fmt.Errorf("unknown session store type: '%s'", storeType)
+}
+
+// SessionFromClaims builds the session described by a validated token
+func SessionFromClaims(claims *authToken.Claims) *core.CoreSession {
+	return &core.CoreSession{
+		Id:        claims.SessionId,
+		UserId:    claims.UserId,
+		UserName:  claims.UserName,
+		IssuedAt:  claims.IssuedAt,
+		ExpiresAt: claims.ExpiresAt,
+	}
+}
@@ -0,0 +1,189 @@
+package session
+
+import (
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	revokedBucket = []byte("revoked_sessions")
+	// user id -> unix time all sessions of user are revoked
+	revokedUsersBucket = []byte("revoked_users")
+)
+
+// boltSessionStore works like memorySessionStore, revocations are written through to file so they survive restart
+// the file is locked by the server holding it open, a second server using it fails on start up
+type boltSessionStore struct {
+	*memorySessionStore
+	db *bbolt.DB
+}
+
+func openDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store %s, it may be held by another server: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{revokedBucket, revokedUsersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newBoltSessionStore(db *bbolt.DB, tokenExpire time.Duration) *boltSessionStore {
+	s := &boltSessionStore{
+		memorySessionStore: newMemorySessionStore(tokenExpire),
+		db:                 db,
+	}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(revokedBucket).ForEach(func(k, v []byte) error {
+			expiresAt, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			s.revoked[string(k)] = expiresAt
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(revokedUsersBucket).ForEach(func(k, v []byte) error {
+			before, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			s.revokedBefore[string(k)] = before
+			return nil
+		})
+	})
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to load revoked sessions", "error", err)
+	}
+	return s
+}
+
+func (b *boltSessionStore) Revoke(sessionId string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	session, found := b.sessions[sessionId]
+	if !found {
+		return customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("session %s not found", sessionId))
+	}
+
+	if err := b.persistRevoked([]*core.CoreSession{session}, "", 0); err != nil {
+		return err
+	}
+	b.revoke(session)
+	return nil
+}
+
+func (b *boltSessionStore) RevokeIfActive(session *core.CoreSession) error {
+	if session == nil || session.Id == "" {
+		return fmt.Errorf("session id is empty")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.isRevoked(session) {
+		return customError.NewUnauthorized(http.StatusUnauthorized, "session revoked")
+	}
+
+	if err := b.persistRevoked([]*core.CoreSession{session}, "", 0); err != nil {
+		return err
+	}
+	b.revoke(session)
+	return nil
+}
+
+func (b *boltSessionStore) RevokeUserSessions(userId string) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var toRevoke []*core.CoreSession
+	for _, session := range b.sessions {
+		if session.UserId == userId {
+			toRevoke = append(toRevoke, session)
+		}
+	}
+
+	before := b.now().Unix()
+	if err := b.persistRevoked(toRevoke, userId, before); err != nil {
+		return 0, err
+	}
+	b.revokedBefore[userId] = before
+	for _, session := range toRevoke {
+		b.revoke(session)
+	}
+	return len(toRevoke), nil
+}
+
+// persistRevoked writes revoked sessions and time sessions of user are revoked to file, user is skipped if it is empty
+// revocations whose token expired are dropped
+// should be called with lock held
+func (b *boltSessionStore) persistRevoked(sessions []*core.CoreSession, userId string, before int64) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(revokedBucket)
+		err := removeExpired(bucket, b.isExpired)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if err := bucket.Put([]byte(session.Id), []byte(strconv.FormatInt(session.ExpiresAt, 10))); err != nil {
+				return err
+			}
+		}
+
+		usersBucket := tx.Bucket(revokedUsersBucket)
+		err = removeExpired(usersBucket, func(before int64) bool {
+			return b.isExpired(b.revokedBeforeExpiresAt(before))
+		})
+		if err != nil {
+			return err
+		}
+		if userId == "" {
+			return nil
+		}
+		return usersBucket.Put([]byte(userId), []byte(strconv.FormatInt(before, 10)))
+	})
+}
+
+// removeExpired drops entries whose unix time value is expired or invalid
+func removeExpired(bucket *bbolt.Bucket, isExpired func(value int64) bool) error {
+	var expired [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		value, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil || isExpired(value) {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
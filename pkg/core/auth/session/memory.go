@@ -0,0 +1,205 @@
+package session
+
+import (
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memorySessionStore keeps sessions in memory
+// revoked session id is kept until the token of the session expires so a revoked token
+// is rejected during its whole lifetime, note revocation is lost after server restart, see boltSessionStore
+type memorySessionStore struct {
+	lock     sync.RWMutex
+	sessions map[string]*core.CoreSession
+	// session id -> unix time the token of the session expires
+	revoked map[string]int64
+	// user id -> unix time sessions of user are revoked, so sessions the store never saw are revoked as well
+	// kept until every token issued before it expired
+	revokedBefore map[string]int64
+	tokenExpire   time.Duration
+	now           func() time.Time
+}
+
+func newMemorySessionStore(tokenExpire time.Duration) *memorySessionStore {
+	return &memorySessionStore{
+		sessions:      map[string]*core.CoreSession{},
+		revoked:       map[string]int64{},
+		revokedBefore: map[string]int64{},
+		tokenExpire:   tokenExpire,
+		now:           time.Now,
+	}
+}
+
+func (m *memorySessionStore) Add(session *core.CoreSession) error {
+	if session == nil || session.Id == "" {
+		return fmt.Errorf("session id is empty")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.removeExpired()
+	if m.isRevoked(session) {
+		return customError.NewUnauthorized(http.StatusUnauthorized, "session revoked")
+	}
+
+	toAdd := *session
+	if toAdd.LastSeenAt == 0 {
+		toAdd.LastSeenAt = m.now().Unix()
+	}
+	m.sessions[session.Id] = &toAdd
+	return nil
+}
+
+func (m *memorySessionStore) Touch(session *core.CoreSession) error {
+	if session == nil || session.Id == "" {
+		return fmt.Errorf("session id is empty")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.isRevoked(session) {
+		return customError.NewUnauthorized(http.StatusUnauthorized, "session revoked")
+	}
+
+	existing, found := m.sessions[session.Id]
+	if !found {
+		toAdd := *session
+		existing = &toAdd
+		m.sessions[session.Id] = existing
+	}
+	existing.LastSeenAt = m.now().Unix()
+	return nil
+}
+
+func (m *memorySessionStore) IsRevoked(session *core.CoreSession) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.isRevoked(session)
+}
+
+// isRevoked should be called with lock held
+// a session issued in the second sessions of its user are revoked is kept, so token issued right after revocation works
+func (m *memorySessionStore) isRevoked(session *core.CoreSession) bool {
+	if _, found := m.revoked[session.Id]; found {
+		return true
+	}
+	before, found := m.revokedBefore[session.UserId]
+	return found && session.IssuedAt < before
+}
+
+func (m *memorySessionStore) GetSession(sessionId string) (*core.CoreSession, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	session, found := m.sessions[sessionId]
+	if !found || m.isExpired(session.ExpiresAt) {
+		return nil, customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("session %s not found", sessionId))
+	}
+
+	result := *session
+	return &result, nil
+}
+
+func (m *memorySessionStore) ListUserSessions(userId string) ([]core.CoreSession, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	result := []core.CoreSession{}
+	for _, session := range m.sessions {
+		if session.UserId == userId && !m.isExpired(session.ExpiresAt) {
+			result = append(result, *session)
+		}
+	}
+
+	// newest session first
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].IssuedAt > result[j].IssuedAt
+	})
+	return result, nil
+}
+
+func (m *memorySessionStore) Revoke(sessionId string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	session, found := m.sessions[sessionId]
+	if !found {
+		return customError.NewNotFound(http.StatusNotFound, fmt.Sprintf("session %s not found", sessionId))
+	}
+
+	m.revoke(session)
+	return nil
+}
+
+func (m *memorySessionStore) RevokeIfActive(session *core.CoreSession) error {
+	if session == nil || session.Id == "" {
+		return fmt.Errorf("session id is empty")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.isRevoked(session) {
+		return customError.NewUnauthorized(http.StatusUnauthorized, "session revoked")
+	}
+
+	m.revoke(session)
+	return nil
+}
+
+func (m *memorySessionStore) RevokeUserSessions(userId string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.revokedBefore[userId] = m.now().Unix()
+	count := 0
+	for _, session := range m.sessions {
+		if session.UserId == userId {
+			m.revoke(session)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// revoke should be called with lock held
+func (m *memorySessionStore) revoke(session *core.CoreSession) {
+	delete(m.sessions, session.Id)
+	m.revoked[session.Id] = session.ExpiresAt
+}
+
+// removeExpired drops expired sessions and revocations, token of them can not pass validation anyway
+// should be called with lock held
+func (m *memorySessionStore) removeExpired() {
+	for id, session := range m.sessions {
+		if m.isExpired(session.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+	for id, expiresAt := range m.revoked {
+		if m.isExpired(expiresAt) {
+			delete(m.revoked, id)
+		}
+	}
+	for userId, before := range m.revokedBefore {
+		if m.isExpired(m.revokedBeforeExpiresAt(before)) {
+			delete(m.revokedBefore, userId)
+		}
+	}
+}
+
+// revokedBeforeExpiresAt is when every token issued before revocation expired
+func (m *memorySessionStore) revokedBeforeExpiresAt(before int64) int64 {
+	return before + int64(m.tokenExpire/time.Second)
+}
+
+func (m *memorySessionStore) isExpired(expiresAt int64) bool {
+	return m.now().Unix() >= expiresAt
+}
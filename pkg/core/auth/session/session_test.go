@@ -0,0 +1,247 @@
+package session
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	authToken "core-api/pkg/core/auth/token"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("session store test", func() {
+	var store *memorySessionStore
+	var now time.Time
+
+	newSession := func(id, userId string, issuedAt int64) *core.CoreSession {
+		return &core.CoreSession{
+			Id:        id,
+			UserId:    userId,
+			UserName:  userId + "-name",
+			IssuedAt:  issuedAt,
+			ExpiresAt: issuedAt + 3600,
+		}
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(10000, 0)
+		store = newMemorySessionStore(time.Hour)
+		store.now = func() time.Time { return now }
+	})
+
+	Describe("CreateSessionStore test", func() {
+		It("should be error with unknown store type", func() {
+			_, err := CreateSessionStore(config.DefaultConfig(), "unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be error with nil config", func() {
+			_, err := CreateSessionStore(nil, MemorySessionStore)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Add and GetSession test", func() {
+		It("should be expected", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			result, err := store.GetSession("s1")
+			Expect(err).To(BeNil())
+			Expect(result.UserId).To(Equal("u1"))
+			Expect(result.LastSeenAt).To(Equal(int64(10000)))
+		})
+
+		It("should be not found with expired session", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			now = now.Add(2 * time.Hour)
+			_, err := store.GetSession("s1")
+			Expect(customError.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should be error with empty session id", func() {
+			Expect(store.Add(newSession("", "u1", 10000))).To(HaveOccurred())
+		})
+	})
+
+	Describe("ListUserSessions test", func() {
+		It("should list only active sessions of the user newest first", func() {
+			Expect(store.Add(newSession("s1", "u1", 9000))).To(BeNil())
+			Expect(store.Add(newSession("s2", "u1", 9500))).To(BeNil())
+			Expect(store.Add(newSession("s3", "u2", 9500))).To(BeNil())
+			// already expired
+			Expect(store.Add(newSession("s4", "u1", 1000))).To(BeNil())
+			result, err := store.ListUserSessions("u1")
+			Expect(err).To(BeNil())
+			Expect(len(result)).To(Equal(2))
+			Expect(result[0].Id).To(Equal("s2"))
+			Expect(result[1].Id).To(Equal("s1"))
+		})
+	})
+
+	Describe("Revoke test", func() {
+		It("should reject revoked session", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(store.Revoke("s1")).To(BeNil())
+			Expect(store.IsRevoked(newSession("s1", "u1", 10000))).To(BeTrue())
+			_, err := store.GetSession("s1")
+			Expect(customError.IsNotFound(err)).To(BeTrue())
+			err = store.Touch(newSession("s1", "u1", 10000))
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should be not found with unknown session", func() {
+			err := store.Revoke("unknown")
+			Expect(customError.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should revoke all sessions of the user", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(store.Add(newSession("s2", "u1", 10000))).To(BeNil())
+			Expect(store.Add(newSession("s3", "u2", 10000))).To(BeNil())
+			count, err := store.RevokeUserSessions("u1")
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(2))
+			Expect(store.IsRevoked(newSession("s1", "u1", 10000))).To(BeTrue())
+			Expect(store.IsRevoked(newSession("s2", "u1", 10000))).To(BeTrue())
+			Expect(store.IsRevoked(newSession("s3", "u2", 10000))).To(BeFalse())
+		})
+
+		It("should revoke sessions of the user the store never saw", func() {
+			// issued before restart or by another server
+			issuedBefore := newSession("s1", "u1", 9000)
+			_, err := store.RevokeUserSessions("u1")
+			Expect(err).To(BeNil())
+			Expect(store.IsRevoked(issuedBefore)).To(BeTrue())
+			Expect(customError.IsUnauthorized(store.Touch(issuedBefore))).To(BeTrue())
+			Expect(customError.IsUnauthorized(store.Add(issuedBefore))).To(BeTrue())
+
+			// login right after revocation is kept
+			Expect(store.IsRevoked(newSession("s2", "u1", 10000))).To(BeFalse())
+			Expect(store.IsRevoked(newSession("s3", "u2", 9000))).To(BeFalse())
+		})
+
+		It("should forget revocation of the user once token issued before it expired", func() {
+			_, err := store.RevokeUserSessions("u1")
+			Expect(err).To(BeNil())
+			now = now.Add(time.Hour)
+			Expect(store.Add(newSession("s2", "u1", now.Unix()))).To(BeNil())
+			Expect(store.revokedBefore).To(BeEmpty())
+		})
+
+		It("should forget revocation once token expired", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(store.Revoke("s1")).To(BeNil())
+			now = now.Add(2 * time.Hour)
+			Expect(store.Add(newSession("s2", "u1", now.Unix()))).To(BeNil())
+			Expect(store.IsRevoked(newSession("s1", "u1", 10000))).To(BeFalse())
+		})
+	})
+
+	Describe("RevokeIfActive test", func() {
+		It("should revoke session only once", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(store.RevokeIfActive(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(store.IsRevoked(newSession("s1", "u1", 10000))).To(BeTrue())
+			_, err := store.GetSession("s1")
+			Expect(customError.IsNotFound(err)).To(BeTrue())
+			err = store.RevokeIfActive(newSession("s1", "u1", 10000))
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should revoke session unknown to the store", func() {
+			Expect(store.RevokeIfActive(newSession("s1", "u1", 9000))).To(BeNil())
+			Expect(store.IsRevoked(newSession("s1", "u1", 9000))).To(BeTrue())
+		})
+
+		It("should refuse session of user whose sessions are revoked", func() {
+			_, err := store.RevokeUserSessions("u1")
+			Expect(err).To(BeNil())
+			err = store.RevokeIfActive(newSession("s1", "u1", 9000))
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+	})
+
+	Describe("Touch test", func() {
+		It("should register unknown session", func() {
+			Expect(store.Touch(newSession("s1", "u1", 9000))).To(BeNil())
+			result, err := store.GetSession("s1")
+			Expect(err).To(BeNil())
+			Expect(result.LastSeenAt).To(Equal(int64(10000)))
+		})
+
+		It("should update last seen time", func() {
+			Expect(store.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			now = now.Add(time.Minute)
+			Expect(store.Touch(newSession("s1", "u1", 10000))).To(BeNil())
+			result, err := store.GetSession("s1")
+			Expect(err).To(BeNil())
+			Expect(result.LastSeenAt).To(Equal(int64(10060)))
+		})
+	})
+
+	Describe("bolt session store test", func() {
+		var serverConfig *config.Config
+
+		BeforeEach(func() {
+			serverConfig = config.DefaultConfig()
+			serverConfig.CoreApiConfig.SessionPath = filepath.Join(GinkgoT().TempDir(), "sessions.db")
+		})
+
+		openStore := func() *boltSessionStore {
+			created, err := CreateSessionStore(serverConfig, BoltSessionStore)
+			Expect(err).To(BeNil())
+			boltStore := created.(*boltSessionStore)
+			boltStore.now = func() time.Time { return now }
+			return boltStore
+		}
+
+		It("should keep revocations after restart", func() {
+			boltStore := openStore()
+			Expect(boltStore.Add(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(boltStore.Add(newSession("s2", "u1", 10000))).To(BeNil())
+			Expect(boltStore.Add(newSession("s3", "u2", 10000))).To(BeNil())
+			Expect(boltStore.Revoke("s1")).To(BeNil())
+			count, err := boltStore.RevokeUserSessions("u2")
+			Expect(err).To(BeNil())
+			Expect(count).To(Equal(1))
+			Expect(boltStore.db.Close()).To(BeNil())
+
+			boltStore = openStore()
+			defer boltStore.db.Close()
+			Expect(boltStore.IsRevoked(newSession("s1", "u1", 10000))).To(BeTrue())
+			Expect(boltStore.IsRevoked(newSession("s3", "u2", 10000))).To(BeTrue())
+			Expect(boltStore.IsRevoked(newSession("s2", "u1", 10000))).To(BeFalse())
+			Expect(customError.IsUnauthorized(boltStore.Touch(newSession("s1", "u1", 10000)))).To(BeTrue())
+			Expect(boltStore.Touch(newSession("s2", "u1", 10000))).To(BeNil())
+			// session of u2 the store never saw is revoked as well
+			Expect(boltStore.IsRevoked(newSession("s4", "u2", 9000))).To(BeTrue())
+		})
+
+		It("should keep session revoked if active after restart", func() {
+			boltStore := openStore()
+			Expect(boltStore.RevokeIfActive(newSession("s1", "u1", 10000))).To(BeNil())
+			Expect(boltStore.db.Close()).To(BeNil())
+
+			boltStore = openStore()
+			defer boltStore.db.Close()
+			err := boltStore.RevokeIfActive(newSession("s1", "u1", 10000))
+			Expect(customError.IsUnauthorized(err)).To(BeTrue())
+		})
+
+		It("should fail when file is held by another server", func() {
+			boltStore := openStore()
+			defer boltStore.db.Close()
+			_, err := CreateSessionStore(serverConfig, BoltSessionStore)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("SessionFromClaims test", func() {
+		It("should be expected", func() {
+			result := SessionFromClaims(&authToken.Claims{SessionId: "s1", UserId: "u1", UserName: "n1", IssuedAt: 1, ExpiresAt: 2})
+			Expect(*result).To(Equal(core.CoreSession{Id: "s1", UserId: "u1", UserName: "n1", IssuedAt: 1, ExpiresAt: 2}))
+		})
+	})
+})
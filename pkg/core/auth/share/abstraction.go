@@ -0,0 +1,228 @@
+package share
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/event"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"sync"
+)
+
+// kind of resource a share is granted on
+const (
+	KindConversation  = "conversation"
+	KindKnowledgeBase = "knowledge_base"
+	// device is identified by name of user it belongs to
+	KindDevice = "device"
+)
+
+// kind of subject a share is granted to
+const (
+	SubjectUser  = "user"
+	SubjectGroup = "group"
+)
+
+// access granted by a share, each includes the ones before it
+const (
+	// see resource, e.g. messages of a conversation or files of a knowledge base
+	AccessRead = "read"
+	// act with resource, e.g. chat with a knowledge base or start a device
+	AccessUse = "use"
+	// change or delete resource and its shares
+	AccessManage = "manage"
+)
+
+var accessLevels = map[string]int{
+	AccessRead:   1,
+	AccessUse:    2,
+	AccessManage: 3,
+}
+
+// resourceModules tells module and permission that lets a user act on any resource of a kind without a share
+var resourceModules = map[string]struct {
+	module      string
+	manageOther uint64
+}{
+	KindConversation:  {module: "rag", manageOther: privileges.PermissionRagManageOtherUserResource},
+	KindKnowledgeBase: {module: "rag", manageOther: privileges.PermissionRagManageOtherUserResource},
+	KindDevice:        {module: "device", manageOther: privileges.PermissionDeviceManageOtherUserResource},
+}
+
+// IShareStore keeps shares of resources
+type IShareStore interface {
+	// Put grants subject access to resource, access of a subject the resource is already shared with is replaced
+	Put(share *core.CoreShare) (*core.CoreShare, error)
+	// Get returns a share, a not found error is returned if it does not exist
+	Get(id string) (*core.CoreShare, error)
+	// Delete removes a share, a not found error is returned if it does not exist
+	Delete(id string) error
+	// List returns shares of a resource
+	List(kind, resourceId string) ([]core.CoreShare, error)
+	// ListGranted returns shares granted to user directly or through one of groups
+	ListGranted(userId string, groupIds []string) ([]core.CoreShare, error)
+	// Access returns highest access granted to user directly or through one of groups, it is empty if resource is not shared with user
+	Access(kind, resourceId, userId string, groupIds []string) string
+	// DeleteResource removes shares of a resource and returns the number of them
+	DeleteResource(kind, resourceId string) (int, error)
+	// DeleteSubject removes shares granted to a user or group and returns the number of them
+	DeleteSubject(subjectKind, subjectId string) (int, error)
+	// DeleteOwner removes shares of resources owned by user and returns the number of them
+	DeleteOwner(ownerId string) (int, error)
+}
+
+// Invalid is returned when a share can not be put as requested
+type Invalid struct {
+	Message string
+}
+
+func (i *Invalid) Error() string {
+	return i.Message
+}
+
+func IsInvalid(err error) bool {
+	_, ok := err.(*Invalid)
+	return ok
+}
+
+type ShareStoreType string
+
+const (
+	MemoryShareStore ShareStoreType = "memory"
+	BoltShareStore   ShareStoreType = "bolt"
+)
+
+var defaultShareStore IShareStore
+var defaultShareStoreLock sync.Mutex
+
+// InitOrGetShareStore returns store shared by share handlers and south handlers
+// shares are kept in file if path is set, otherwise in memory
+// shares of a deleted user or group are removed with it
+func InitOrGetShareStore(serverConfig *config.Config) (IShareStore, error) {
+	defaultShareStoreLock.Lock()
+	defer defaultShareStoreLock.Unlock()
+	if defaultShareStore == nil {
+		shareConfig, err := shareConfigOf(serverConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		storeType := BoltShareStore
+		if shareConfig.Path == "" {
+			storeType = MemoryShareStore
+			coreApiLog.Logger.Warn("share path is not set, shares are kept in memory and lost after restart")
+		}
+
+		store, err := CreateShareStore(serverConfig, storeType)
+		if err != nil {
+			return nil, err
+		}
+		event.InitOrGetEventBus().Subscribe(func(changed event.Event) {
+			removeSharesOf(store, changed)
+		})
+		defaultShareStore = store
+	}
+	return defaultShareStore, nil
+}
+
+func CreateShareStore(serverConfig *config.Config, storeType ShareStoreType) (IShareStore, error) {
+	shareConfig, err := shareConfigOf(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	switch storeType {
+	case MemoryShareStore:
+		return newShareStore(shareConfig, nil), nil
+	case BoltShareStore:
+		db, err := openDB(shareConfig.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newShareStore(shareConfig, db), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid share store type", storeType)
+}
+
+// removeSharesOf drops shares a deleted user or group is part of
+func removeSharesOf(store IShareStore, changed event.Event) {
+	if changed.Action != event.Deleted {
+		return
+	}
+	var count int
+	var err error
+	switch changed.Kind {
+	case event.UserKind:
+		count, err = store.DeleteSubject(SubjectUser, changed.Id)
+		if err == nil {
+			var owned int
+			owned, err = store.DeleteOwner(changed.Id)
+			count += owned
+		}
+	case event.GroupKind:
+		count, err = store.DeleteSubject(SubjectGroup, changed.Id)
+	default:
+		return
+	}
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to remove shares", "kind", changed.Kind, "id", changed.Id, "error", err)
+		return
+	}
+	if count > 0 {
+		coreApiLog.Logger.Info("shares removed", "kind", changed.Kind, "id", changed.Id, "total", count)
+	}
+}
+
+// Covers tells whether granted access includes required access
+func Covers(granted, required string) bool {
+	return accessLevels[required] > 0 && accessLevels[granted] >= accessLevels[required]
+}
+
+// ManageOtherPermission returns module and permission that let a user act on any resource of kind without a share
+func ManageOtherPermission(kind string) (string, uint64, error) {
+	resourceModule, found := resourceModules[kind]
+	if !found {
+		return "", 0, fmt.Errorf("unknown resource kind %s", kind)
+	}
+	return resourceModule.module, resourceModule.manageOther, nil
+}
+
+// GroupIds returns ids of groups of user, shares granted to them apply to user
+func GroupIds(user *core.CoreUser) []string {
+	ids := make([]string, 0, len(user.Groups))
+	for _, group := range user.Groups {
+		ids = append(ids, group.Id)
+	}
+	return ids
+}
+
+// OwnerResolver returns id of user owning resource, a not found error is returned if resource does not exist
+type OwnerResolver func(resourceId string) (string, error)
+
+var ownerResolvers sync.Map
+
+// RegisterOwnerResolver lets share api look up owner of resources of kind, it is done by handlers serving the resource
+func RegisterOwnerResolver(kind string, resolver OwnerResolver) {
+	ownerResolvers.Store(kind, resolver)
+}
+
+// ResourceOwner returns id of user owning resource
+func ResourceOwner(kind, resourceId string) (string, error) {
+	resolver, found := ownerResolvers.Load(kind)
+	if !found {
+		return "", &Invalid{Message: fmt.Sprintf("resource kind %s can not be shared", kind)}
+	}
+	return resolver.(OwnerResolver)(resourceId)
+}
+
+func shareConfigOf(serverConfig *config.Config) (*config.ShareConfig, error) {
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return nil, fmt.Errorf("auth config is nil")
+	}
+
+	if serverConfig.AuthConfig.Share == nil {
+		return config.DefaultConfig().AuthConfig.Share, nil
+	}
+	return serverConfig.AuthConfig.Share, nil
+}
@@ -0,0 +1,123 @@
+package share
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/event"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("share store test", func() {
+	var store *shareStore
+	var share *core.CoreShare
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		store = newShareStore(&config.ShareConfig{MaxSharesPerResource: 2}, nil)
+		share = &core.CoreShare{ResourceKind: KindKnowledgeBase, ResourceId: "kb1", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "assistant", Access: AccessRead}
+	})
+
+	It("should replace access of a subject already shared with", func() {
+		created, err := store.Put(share)
+		Expect(err).To(BeNil())
+		Expect(created.Id).NotTo(BeEmpty())
+
+		share.Access = AccessManage
+		updated, err := store.Put(share)
+		Expect(err).To(BeNil())
+		Expect(updated.Id).To(Equal(created.Id))
+
+		shares, err := store.List(KindKnowledgeBase, "kb1")
+		Expect(err).To(BeNil())
+		Expect(shares).To(HaveLen(1))
+		Expect(shares[0].Access).To(Equal(AccessManage))
+	})
+
+	It("should grant highest access of user and groups", func() {
+		_, err := store.Put(share)
+		Expect(err).To(BeNil())
+		_, err = store.Put(&core.CoreShare{ResourceKind: KindKnowledgeBase, ResourceId: "kb1", OwnerId: "teacher", SubjectKind: SubjectGroup, SubjectId: "class1", Access: AccessUse})
+		Expect(err).To(BeNil())
+
+		Expect(store.Access(KindKnowledgeBase, "kb1", "assistant", nil)).To(Equal(AccessRead))
+		Expect(store.Access(KindKnowledgeBase, "kb1", "assistant", []string{"class1"})).To(Equal(AccessUse))
+		Expect(store.Access(KindKnowledgeBase, "kb1", "student", []string{"class2"})).To(BeEmpty())
+		Expect(store.Access(KindConversation, "kb1", "assistant", []string{"class1"})).To(BeEmpty())
+
+		granted, err := store.ListGranted("student", []string{"class1"})
+		Expect(err).To(BeNil())
+		Expect(granted).To(HaveLen(1))
+		Expect(granted[0].SubjectId).To(Equal("class1"))
+	})
+
+	It("should refuse invalid share", func() {
+		invalid := []core.CoreShare{
+			{ResourceKind: "course", ResourceId: "c1", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "u", Access: AccessRead},
+			{ResourceKind: KindDevice, ResourceId: "", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "u", Access: AccessRead},
+			{ResourceKind: KindDevice, ResourceId: "teacher", OwnerId: "teacher", SubjectKind: "role", SubjectId: "u", Access: AccessRead},
+			{ResourceKind: KindDevice, ResourceId: "teacher", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "teacher", Access: AccessRead},
+			{ResourceKind: KindDevice, ResourceId: "teacher", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "u", Access: "write"},
+		}
+		for index := range invalid {
+			_, err := store.Put(&invalid[index])
+			Expect(IsInvalid(err)).To(BeTrue(), "share %v", invalid[index])
+		}
+
+		_, err := store.Put(share)
+		Expect(err).To(BeNil())
+		_, err = store.Put(&core.CoreShare{ResourceKind: KindKnowledgeBase, ResourceId: "kb1", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "u2", Access: AccessRead})
+		Expect(err).To(BeNil())
+		_, err = store.Put(&core.CoreShare{ResourceKind: KindKnowledgeBase, ResourceId: "kb1", OwnerId: "teacher", SubjectKind: SubjectUser, SubjectId: "u3", Access: AccessRead})
+		Expect(IsInvalid(err)).To(BeTrue())
+	})
+
+	It("should remove shares of deleted user, group and resource", func() {
+		_, err := store.Put(share)
+		Expect(err).To(BeNil())
+		_, err = store.Put(&core.CoreShare{ResourceKind: KindDevice, ResourceId: "teacher", OwnerId: "teacher", SubjectKind: SubjectGroup, SubjectId: "class1", Access: AccessUse})
+		Expect(err).To(BeNil())
+		_, err = store.Put(&core.CoreShare{ResourceKind: KindConversation, ResourceId: "c1", OwnerId: "other", SubjectKind: SubjectGroup, SubjectId: "class1", Access: AccessRead})
+		Expect(err).To(BeNil())
+
+		removeSharesOf(store, event.Event{Kind: event.UserKind, Action: event.Updated, Id: "teacher"})
+		Expect(store.shares).To(HaveLen(3))
+		removeSharesOf(store, event.Event{Kind: event.UserKind, Action: event.Deleted, Id: "teacher"})
+		Expect(store.shares).To(HaveLen(1))
+		count, err := store.DeleteResource(KindConversation, "c1")
+		Expect(err).To(BeNil())
+		Expect(count).To(Equal(1))
+
+		Expect(customErr.IsNotFound(store.Delete("missing"))).To(BeTrue())
+	})
+
+	It("should keep shares in file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "shares.db")
+		db, err := openDB(path)
+		Expect(err).To(BeNil())
+		store = newShareStore(&config.ShareConfig{}, db)
+		created, err := store.Put(share)
+		Expect(err).To(BeNil())
+		Expect(db.Close()).To(Succeed())
+
+		db, err = openDB(path)
+		Expect(err).To(BeNil())
+		defer db.Close()
+		store = newShareStore(&config.ShareConfig{}, db)
+		found, err := store.Get(created.Id)
+		Expect(err).To(BeNil())
+		Expect(found).To(Equal(created))
+	})
+
+	It("should tell whether access covers another", func() {
+		Expect(Covers(AccessManage, AccessRead)).To(BeTrue())
+		Expect(Covers(AccessUse, AccessUse)).To(BeTrue())
+		Expect(Covers(AccessRead, AccessUse)).To(BeFalse())
+		Expect(Covers("", AccessRead)).To(BeFalse())
+		Expect(Covers(AccessManage, "")).To(BeFalse())
+	})
+})
@@ -0,0 +1,303 @@
+package share
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var sharesBucket = []byte("shares")
+
+// shareStore keeps all shares in memory, they are written through to file if db is set
+type shareStore struct {
+	lock   sync.RWMutex
+	shares map[string]*core.CoreShare
+	db     *bbolt.DB
+	now    func() time.Time
+
+	maxSharesPerResource int
+}
+
+func openDB(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open share store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sharesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func newShareStore(shareConfig *config.ShareConfig, db *bbolt.DB) *shareStore {
+	maxShares := shareConfig.MaxSharesPerResource
+	if maxShares <= 0 {
+		maxShares = config.DefaultConfig().AuthConfig.Share.MaxSharesPerResource
+	}
+
+	s := &shareStore{
+		shares:               map[string]*core.CoreShare{},
+		db:                   db,
+		now:                  time.Now,
+		maxSharesPerResource: maxShares,
+	}
+
+	if db != nil {
+		err := db.View(func(tx *bbolt.Tx) error {
+			return tx.Bucket(sharesBucket).ForEach(func(k, v []byte) error {
+				share := &core.CoreShare{}
+				if err := json.Unmarshal(v, share); err != nil {
+					return err
+				}
+				s.shares[string(k)] = share
+				return nil
+			})
+		})
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to load shares", "error", err)
+		}
+	}
+	return s
+}
+
+func (s *shareStore) Put(share *core.CoreShare) (*core.CoreShare, error) {
+	if err := validate(share); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for _, existing := range s.shares {
+		if existing.ResourceKind != share.ResourceKind || existing.ResourceId != share.ResourceId {
+			continue
+		}
+		if existing.SubjectKind == share.SubjectKind && existing.SubjectId == share.SubjectId {
+			updated := *existing
+			updated.Access = share.Access
+			updated.OwnerId = share.OwnerId
+			if err := s.persist(&updated); err != nil {
+				return nil, err
+			}
+			s.shares[updated.Id] = &updated
+			return &updated, nil
+		}
+		count++
+	}
+	if count >= s.maxSharesPerResource {
+		return nil, &Invalid{Message: fmt.Sprintf("a resource can be shared with at most %d users and groups", s.maxSharesPerResource)}
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	created := *share
+	created.Id = id
+	created.CreatedAt = s.now().Unix()
+	if err := s.persist(&created); err != nil {
+		return nil, err
+	}
+	s.shares[id] = &created
+	return &created, nil
+}
+
+func (s *shareStore) Get(id string) (*core.CoreShare, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	share, found := s.shares[id]
+	if !found {
+		return nil, customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Share %s not found", id))
+	}
+	result := *share
+	return &result, nil
+}
+
+func (s *shareStore) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.shares[id]; !found {
+		return customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Share %s not found", id))
+	}
+	return s.remove(id)
+}
+
+func (s *shareStore) List(kind, resourceId string) ([]core.CoreShare, error) {
+	return s.filter(func(share *core.CoreShare) bool {
+		return share.ResourceKind == kind && share.ResourceId == resourceId
+	}), nil
+}
+
+func (s *shareStore) ListGranted(userId string, groupIds []string) ([]core.CoreShare, error) {
+	return s.filter(func(share *core.CoreShare) bool {
+		return grantedTo(share, userId, groupIds)
+	}), nil
+}
+
+func (s *shareStore) Access(kind, resourceId, userId string, groupIds []string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	access := ""
+	for _, share := range s.shares {
+		if share.ResourceKind != kind || share.ResourceId != resourceId || !grantedTo(share, userId, groupIds) {
+			continue
+		}
+		if accessLevels[share.Access] > accessLevels[access] {
+			access = share.Access
+		}
+	}
+	return access
+}
+
+func (s *shareStore) DeleteResource(kind, resourceId string) (int, error) {
+	return s.removeAll(func(share *core.CoreShare) bool {
+		return share.ResourceKind == kind && share.ResourceId == resourceId
+	})
+}
+
+func (s *shareStore) DeleteSubject(subjectKind, subjectId string) (int, error) {
+	return s.removeAll(func(share *core.CoreShare) bool {
+		return share.SubjectKind == subjectKind && share.SubjectId == subjectId
+	})
+}
+
+func (s *shareStore) DeleteOwner(ownerId string) (int, error) {
+	return s.removeAll(func(share *core.CoreShare) bool {
+		return share.OwnerId == ownerId
+	})
+}
+
+// filter returns copies of matched shares ordered by creation
+func (s *shareStore) filter(match func(share *core.CoreShare) bool) []core.CoreShare {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	result := []core.CoreShare{}
+	for _, share := range s.shares {
+		if match(share) {
+			result = append(result, *share)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt < result[j].CreatedAt
+		}
+		return result[i].Id < result[j].Id
+	})
+	return result
+}
+
+func (s *shareStore) removeAll(match func(share *core.CoreShare) bool) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for id, share := range s.shares {
+		if !match(share) {
+			continue
+		}
+		if err := s.remove(id); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// persist should be called with lock held
+func (s *shareStore) persist(share *core.CoreShare) error {
+	if s.db == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(share)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sharesBucket).Put([]byte(share.Id), data)
+	})
+}
+
+// remove should be called with lock held
+func (s *shareStore) remove(id string) error {
+	if s.db != nil {
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			return tx.Bucket(sharesBucket).Delete([]byte(id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	delete(s.shares, id)
+	return nil
+}
+
+func grantedTo(share *core.CoreShare, userId string, groupIds []string) bool {
+	switch share.SubjectKind {
+	case SubjectUser:
+		return share.SubjectId == userId
+	case SubjectGroup:
+		for _, groupId := range groupIds {
+			if share.SubjectId == groupId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validate(share *core.CoreShare) error {
+	if _, found := resourceModules[share.ResourceKind]; !found {
+		return &Invalid{Message: fmt.Sprintf("invalid resource kind %s expected to be one of conversation, knowledge_base or device", share.ResourceKind)}
+	}
+	if strings.TrimSpace(share.ResourceId) == "" {
+		return &Invalid{Message: "resource id is required"}
+	}
+	if share.OwnerId == "" {
+		return &Invalid{Message: "owner of resource is required"}
+	}
+	if share.SubjectKind != SubjectUser && share.SubjectKind != SubjectGroup {
+		return &Invalid{Message: fmt.Sprintf("invalid subject kind %s expected to be one of user or group", share.SubjectKind)}
+	}
+	if strings.TrimSpace(share.SubjectId) == "" {
+		return &Invalid{Message: "subject id is required"}
+	}
+	if share.SubjectKind == SubjectUser && share.SubjectId == share.OwnerId {
+		return &Invalid{Message: "resource can not be shared with its owner"}
+	}
+	if _, found := accessLevels[share.Access]; !found {
+		return &Invalid{Message: fmt.Sprintf("invalid access %s expected to be one of read, use or manage", share.Access)}
+	}
+	return nil
+}
+
+func randomHex(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// HeaderName is header a super admin sets to act on another tenant
+const HeaderName = "X-Tenant-Id"
+
+// ITenantResolver decides which tenant a request acts on and whether an object is visible to it
+type ITenantResolver interface {
+	// Enabled is false for a single tenant install, nothing is partitioned then
+	Enabled() bool
+	// DefaultTenant holds objects created before tenant is enabled and super admins
+	DefaultTenant() string
+	// TenantOf returns tenant of an object, object without tenant belongs to default tenant
+	TenantOf(tenantId string) string
+	// Tenants returns all tenants served, default tenant comes first
+	Tenants() []string
+	// Serves tells whether tenant is default tenant or a tenant listed in config
+	Serves(tenantId string) bool
+	IsSuperAdmin(user *core.CoreUser) bool
+	// Resolve returns tenant request of user acts on, requested is value of header 'X-Tenant-Id'
+	// only a super admin can request a tenant other than its own
+	Resolve(user *core.CoreUser, requested string) (string, error)
+}
+
+type TenantResolverType string
+
+const (
+	StaticTenantResolver TenantResolverType = "static"
+)
+
+var defaultTenantResolver ITenantResolver
+var defaultTenantResolverLock sync.Mutex
+
+// InitOrGetTenantResolver returns resolver shared by auth middleware and handlers
+func InitOrGetTenantResolver(serverConfig *config.Config) ITenantResolver {
+	defaultTenantResolverLock.Lock()
+	defer defaultTenantResolverLock.Unlock()
+
+	if defaultTenantResolver == nil {
+		defaultTenantResolver, _ = CreateTenantResolver(serverConfig, StaticTenantResolver)
+	}
+	return defaultTenantResolver
+}
+
+func CreateTenantResolver(serverConfig *config.Config, resolverType TenantResolverType) (ITenantResolver, error) {
+	switch resolverType {
+	case StaticTenantResolver:
+		return newStaticResolver(serverConfig), nil
+	}
+	return nil, fmt.Errorf("%s is not a valid tenant resolver type", resolverType)
+}
+
+// WithTenant returns context carrying tenant resolved by auth middleware
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, "core-tenant", tenantId)
+}
+
+// FromRequest returns tenant request acts on, it is empty if tenant is disabled or auth is disabled
+// nothing is filtered by tenant if it is empty
+func FromRequest(r *http.Request) string {
+	tenantId, _ := r.Context().Value("core-tenant").(string)
+	return tenantId
+}
+
+// Visible tells whether object of objectTenant can be seen by request acting on requestTenant
+func Visible(resolver ITenantResolver, requestTenant, objectTenant string) bool {
+	return requestTenant == "" || resolver.TenantOf(objectTenant) == requestTenant
+}
+
+// Filter keeps items visible to request acting on requestTenant
+func Filter[T any](resolver ITenantResolver, requestTenant string, items []T, tenantOf func(T) string) []T {
+	if requestTenant == "" {
+		return items
+	}
+
+	var result []T
+	for _, item := range items {
+		if Visible(resolver, requestTenant, tenantOf(item)) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package tenant
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	core "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"sort"
+)
+
+const defaultTenantId = "default"
+
+// staticResolver serves tenants listed in config
+type staticResolver struct {
+	enabled       bool
+	defaultTenant string
+	tenants       map[string]struct{}
+	superAdmins   map[string]struct{}
+}
+
+func newStaticResolver(serverConfig *config.Config) *staticResolver {
+	resolver := &staticResolver{
+		defaultTenant: defaultTenantId,
+		tenants:       map[string]struct{}{},
+		superAdmins:   map[string]struct{}{},
+	}
+
+	if serverConfig == nil || serverConfig.AuthConfig == nil {
+		return resolver
+	}
+
+	// same fallback keystone provider uses for its domain
+	if serverConfig.AuthConfig.Keystone != nil && serverConfig.AuthConfig.Keystone.DomainId != "" {
+		resolver.defaultTenant = serverConfig.AuthConfig.Keystone.DomainId
+	}
+
+	tenantConfig := serverConfig.AuthConfig.Tenant
+	if tenantConfig == nil || !tenantConfig.Enabled {
+		return resolver
+	}
+
+	resolver.enabled = true
+	for _, tenantId := range tenantConfig.Tenants {
+		resolver.tenants[tenantId] = struct{}{}
+	}
+	for _, name := range tenantConfig.SuperAdmins {
+		resolver.superAdmins[name] = struct{}{}
+	}
+	return resolver
+}
+
+func (s *staticResolver) Enabled() bool {
+	return s.enabled
+}
+
+func (s *staticResolver) DefaultTenant() string {
+	return s.defaultTenant
+}
+
+func (s *staticResolver) TenantOf(tenantId string) string {
+	if tenantId == "" {
+		return s.defaultTenant
+	}
+	return tenantId
+}
+
+func (s *staticResolver) Tenants() []string {
+	tenants := []string{s.defaultTenant}
+	var others []string
+	for tenantId := range s.tenants {
+		if tenantId != s.defaultTenant {
+			others = append(others, tenantId)
+		}
+	}
+	sort.Strings(others)
+	return append(tenants, others...)
+}
+
+func (s *staticResolver) IsSuperAdmin(user *core.CoreUser) bool {
+	if !s.enabled || user == nil {
+		return false
+	}
+	// a user of another tenant may have the same name
+	if s.TenantOf(user.TenantId) != s.defaultTenant {
+		return false
+	}
+	_, found := s.superAdmins[user.Name]
+	return found
+}
+
+func (s *staticResolver) Resolve(user *core.CoreUser, requested string) (string, error) {
+	if !s.enabled {
+		return "", nil
+	}
+
+	own := s.TenantOf(user.TenantId)
+	if !s.Serves(own) {
+		return "", fmt.Errorf("tenant '%s' of user '%s' is not served", own, user.Name)
+	}
+
+	if requested == "" || requested == own {
+		return own, nil
+	}
+
+	if !s.IsSuperAdmin(user) {
+		return "", fmt.Errorf("user '%s' of tenant '%s' can not act on tenant '%s'", user.Name, own, requested)
+	}
+	if !s.Serves(requested) {
+		return "", fmt.Errorf("tenant '%s' is not served", requested)
+	}
+	return requested, nil
+}
+
+func (s *staticResolver) Serves(tenantId string) bool {
+	if tenantId == s.defaultTenant {
+		return true
+	}
+	if !s.enabled {
+		return false
+	}
+	_, found := s.tenants[tenantId]
+	return found
+}
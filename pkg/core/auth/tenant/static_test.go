@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("static tenant resolver test", func() {
+	var serverConfig *config.Config
+	var resolver ITenantResolver
+	var admin, teacher, otherTeacher *core.CoreUser
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Tenant = &config.TenantConfig{
+			Enabled:     true,
+			Tenants:     []string{"school-b", "school-a"},
+			SuperAdmins: []string{"admin"},
+		}
+		var err error
+		resolver, err = CreateTenantResolver(serverConfig, StaticTenantResolver)
+		Expect(err).NotTo(HaveOccurred())
+
+		admin = &core.CoreUser{Id: "admin-id", Name: "admin"}
+		teacher = &core.CoreUser{Id: "teacher-id", Name: "teacher", TenantId: "school-a"}
+		// same name as super admin but in another tenant
+		otherTeacher = &core.CoreUser{Id: "other-admin-id", Name: "admin", TenantId: "school-b"}
+	})
+
+	It("should be error with unknown resolver type", func() {
+		_, err := CreateTenantResolver(serverConfig, "unknown")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should use keystone domain as default tenant", func() {
+		Expect(resolver.DefaultTenant()).To(Equal("default"))
+		serverConfig.AuthConfig.Keystone.DomainId = "domain-x"
+		resolver, _ = CreateTenantResolver(serverConfig, StaticTenantResolver)
+		Expect(resolver.DefaultTenant()).To(Equal("domain-x"))
+		Expect(resolver.TenantOf("")).To(Equal("domain-x"))
+	})
+
+	It("should list default tenant first then sorted tenants", func() {
+		Expect(resolver.Tenants()).To(Equal([]string{"default", "school-a", "school-b"}))
+	})
+
+	It("should serve default and listed tenants only", func() {
+		Expect(resolver.Serves("default")).To(BeTrue())
+		Expect(resolver.Serves("school-a")).To(BeTrue())
+		Expect(resolver.Serves("school-c")).To(BeFalse())
+	})
+
+	It("should only treat listed user of default tenant as super admin", func() {
+		Expect(resolver.IsSuperAdmin(admin)).To(BeTrue())
+		Expect(resolver.IsSuperAdmin(teacher)).To(BeFalse())
+		Expect(resolver.IsSuperAdmin(otherTeacher)).To(BeFalse())
+	})
+
+	It("should resolve own tenant of user", func() {
+		tenantId, err := resolver.Resolve(teacher, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenantId).To(Equal("school-a"))
+
+		tenantId, err = resolver.Resolve(admin, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenantId).To(Equal("default"))
+	})
+
+	It("should let super admin act on another served tenant", func() {
+		tenantId, err := resolver.Resolve(admin, "school-b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenantId).To(Equal("school-b"))
+
+		_, err = resolver.Resolve(admin, "school-c")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should not let other user act on another tenant", func() {
+		_, err := resolver.Resolve(teacher, "school-b")
+		Expect(err).To(HaveOccurred())
+		_, err = resolver.Resolve(otherTeacher, "school-a")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject user of tenant not served", func() {
+		_, err := resolver.Resolve(&core.CoreUser{Name: "ghost", TenantId: "school-c"}, "")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should resolve nothing when tenant is disabled", func() {
+		serverConfig.AuthConfig.Tenant.Enabled = false
+		resolver, _ = CreateTenantResolver(serverConfig, StaticTenantResolver)
+		Expect(resolver.Enabled()).To(BeFalse())
+		Expect(resolver.Tenants()).To(Equal([]string{"default"}))
+		tenantId, err := resolver.Resolve(teacher, "school-b")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tenantId).To(BeEmpty())
+	})
+
+	It("should filter objects by tenant of request", func() {
+		users := []core.CoreUser{*admin, *teacher, *otherTeacher}
+		tenantOf := func(user core.CoreUser) string { return user.TenantId }
+
+		Expect(Filter(resolver, "", users, tenantOf)).To(HaveLen(3))
+		Expect(Filter(resolver, "default", users, tenantOf)).To(Equal([]core.CoreUser{*admin}))
+		Expect(Filter(resolver, "school-a", users, tenantOf)).To(Equal([]core.CoreUser{*teacher}))
+		Expect(Filter(resolver, "school-c", users, tenantOf)).To(BeEmpty())
+	})
+
+	It("should carry tenant in request context", func() {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		Expect(FromRequest(r)).To(BeEmpty())
+		r = r.WithContext(WithTenant(context.Background(), "school-a"))
+		Expect(FromRequest(r)).To(Equal("school-a"))
+	})
+})
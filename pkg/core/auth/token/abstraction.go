@@ -0,0 +1,97 @@
+package token
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ITokenIssuer issues the session token returned by login api
+// and validates the token that client carries in header 'Authorization: Bearer <token>'
+type ITokenIssuer interface {
+	Issue(user *core.CoreUser) (string, *Claims, error)
+	Validate(token string) (*Claims, error)
+}
+
+type TokenIssuerType string
+
+const (
+	HMACTokenIssuer TokenIssuerType = "hmac"
+)
+
+const (
+	DefaultTokenExpireMinutes = 480
+)
+
+// Claims is the payload carried by a session token
+type Claims struct {
+	SessionId  string            `json:"jti"`
+	UserId     string            `json:"sub"`
+	UserName   string            `json:"name"`
+	Roles      []string          `json:"roles,omitempty"`
+	TenantId   string            `json:"tid,omitempty"`
+	Permission map[string]uint64 `json:"perm,omitempty"`
+	IssuedAt   int64             `json:"iat"`
+	ExpiresAt  int64             `json:"exp"`
+	// session can only be used to change password
+	PasswordChangeRequired bool `json:"pcr,omitempty"`
+}
+
+var defaultTokenIssuer ITokenIssuer
+
+// InitOrGetTokenIssuer returns the token issuer shared by login handler and auth middleware
+func InitOrGetTokenIssuer(serverConfig *config.Config) (ITokenIssuer, error) {
+	if defaultTokenIssuer == nil {
+		issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+		if err != nil {
+			return nil, err
+		}
+		defaultTokenIssuer = issuer
+	}
+	return defaultTokenIssuer, nil
+}
+
+func CreateTokenIssuer(serverConfig *config.Config, issuerType TokenIssuerType) (ITokenIssuer, error) {
+	if serverConfig == nil || serverConfig.CoreApiConfig == nil {
+		return nil, fmt.Errorf("core api config is nil")
+	}
+
+	expire := time.Duration(serverConfig.CoreApiConfig.TokenExpireMinutes) * time.Minute
+	if expire <= 0 {
+		expire = DefaultTokenExpireMinutes * time.Minute
+	}
+
+	switch issuerType {
+	case HMACTokenIssuer:
+		key := []byte(serverConfig.CoreApiConfig.TokenSigningKey)
+		if len(key) == 0 {
+			// all issued token will be invalid after restart
+			coreApiLog.Logger.Warn("token signing key is not set, a random key is generated, session token will not survive restart")
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+		}
+		return newHMACTokenIssuer(key, expire), nil
+	}
+
+	return nil, fmt.Errorf("unknown token issuer type: '%s'", issuerType)
+}
+
+// IsSignedToken tells a signed session token apart from the legacy base64 'username:password' token
+// base64 std encoding never contains '.' so a token with exactly 3 segments is a signed one
+func IsSignedToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func newSessionId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", b), nil
+}
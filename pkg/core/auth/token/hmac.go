@@ -0,0 +1,111 @@
+package token
+
+import (
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// header of every token issued by hmacTokenIssuer, token is a standard HS256 jwt
+var hmacTokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type hmacTokenIssuer struct {
+	key    []byte
+	expire time.Duration
+	now    func() time.Time
+}
+
+func newHMACTokenIssuer(key []byte, expire time.Duration) *hmacTokenIssuer {
+	return &hmacTokenIssuer{
+		key:    key,
+		expire: expire,
+		now:    time.Now,
+	}
+}
+
+func (h *hmacTokenIssuer) Issue(user *core.CoreUser) (string, *Claims, error) {
+	sessionId, err := newSessionId()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := h.now()
+	claims := &Claims{
+		SessionId:  sessionId,
+		UserId:     user.Id,
+		UserName:   user.Name,
+		TenantId:   user.TenantId,
+		Permission: user.Permission,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(h.expire).Unix(),
+
+		PasswordChangeRequired: user.MustChangePassword,
+	}
+	for _, role := range user.Roles {
+		claims.Roles = append(claims.Roles, role.Id)
+	}
+
+	token, err := h.sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+func (h *hmacTokenIssuer) Validate(token string) (*Claims, error) {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "malformed token")
+	}
+
+	if segments[0] != hmacTokenHeader {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "unsupported token header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "malformed token signature")
+	}
+
+	if !hmac.Equal(signature, h.signature(segments[0]+"."+segments[1])) {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "invalid token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(segments[1])
+	if err != nil {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "malformed token payload")
+	}
+
+	claims := &Claims{}
+	err = json.Unmarshal(payload, claims)
+	if err != nil {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "malformed token payload")
+	}
+
+	if h.now().Unix() >= claims.ExpiresAt {
+		return nil, customError.NewUnauthorized(http.StatusUnauthorized, "token expired")
+	}
+
+	return claims, nil
+}
+
+func (h *hmacTokenIssuer) sign(claims *Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := hmacTokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(h.signature(signingInput)), nil
+}
+
+func (h *hmacTokenIssuer) signature(signingInput string) []byte {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,148 @@
+package token
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	coreApiLog "core-api/pkg/logger"
+	core "core-api/pkg/north/api/user/core/v1"
+	customError "core-api/pkg/util/error"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("token test", func() {
+	var serverConfig *config.Config
+	var testUser *core.CoreUser
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.CoreApiConfig.TokenSigningKey = "test-signing-key"
+		testUser = &core.CoreUser{
+			Id:         "test1id",
+			Name:       "test1",
+			Password:   "test1-password",
+			Roles:      []core.CoreRole{{Id: "role1id"}, {Id: "role2id"}},
+			Permission: map[string]uint64{"course": 3},
+		}
+	})
+
+	Describe("CreateTokenIssuer test", func() {
+		It("should be error with unknown issuer type", func() {
+			_, err := CreateTokenIssuer(serverConfig, "unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should generate random key if signing key is not set", func() {
+			serverConfig.CoreApiConfig.TokenSigningKey = ""
+			issuer1, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			issuer2, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			token, _, err := issuer1.Issue(testUser)
+			Expect(err).To(BeNil())
+			_, err = issuer2.Validate(token)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Issue and Validate test", func() {
+		It("should be expected", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			token, issued, err := issuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			Expect(IsSignedToken(token)).To(BeTrue())
+			// password should never be carried by token
+			payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+			Expect(err).To(BeNil())
+			Expect(string(payload)).NotTo(ContainSubstring("test1-password"))
+			Expect(issued.ExpiresAt - issued.IssuedAt).To(Equal(int64(480 * 60)))
+
+			claims, err := issuer.Validate(token)
+			Expect(err).To(BeNil())
+			Expect(claims.SessionId).To(Equal(issued.SessionId))
+			Expect(claims.UserId).To(Equal("test1id"))
+			Expect(claims.UserName).To(Equal("test1"))
+			Expect(claims.Roles).To(Equal([]string{"role1id", "role2id"}))
+			Expect(claims.Permission).To(Equal(map[string]uint64{"course": 3}))
+			Expect(claims.PasswordChangeRequired).To(BeFalse())
+		})
+
+		It("should carry password change required", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			user := *testUser
+			user.MustChangePassword = true
+			token, _, err := issuer.Issue(&user)
+			Expect(err).To(BeNil())
+			claims, err := issuer.Validate(token)
+			Expect(err).To(BeNil())
+			Expect(claims.PasswordChangeRequired).To(BeTrue())
+		})
+
+		It("should issue a different session id for each login", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			_, claims1, err := issuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			_, claims2, err := issuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			Expect(claims1.SessionId).NotTo(Equal(claims2.SessionId))
+		})
+
+		It("should be rejected due to token expired", func() {
+			issuer := newHMACTokenIssuer([]byte("test-signing-key"), time.Minute)
+			issuer.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+			token, _, err := issuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			issuer.now = time.Now
+			_, err = issuer.Validate(token)
+			Expect(err).To(HaveOccurred())
+			_, ok := err.(*customError.Unauthorized)
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should be rejected due to payload tampered", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			token, _, err := issuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			segments := strings.Split(token, ".")
+			segments[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"jti":"1","sub":"adminid","name":"admin","perm":{"user":63},"iat":1,"exp":99999999999}`))
+			_, err = issuer.Validate(strings.Join(segments, "."))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be rejected due to signed with another key", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			serverConfig.CoreApiConfig.TokenSigningKey = "another-signing-key"
+			anotherIssuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			token, _, err := anotherIssuer.Issue(testUser)
+			Expect(err).To(BeNil())
+			_, err = issuer.Validate(token)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should be rejected due to malformed token", func() {
+			issuer, err := CreateTokenIssuer(serverConfig, HMACTokenIssuer)
+			Expect(err).To(BeNil())
+			_, err = issuer.Validate("a.b")
+			Expect(err).To(HaveOccurred())
+			_, err = issuer.Validate("a.b.c")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("IsSignedToken test", func() {
+		It("should tell legacy token apart", func() {
+			Expect(IsSignedToken(base64.StdEncoding.EncodeToString([]byte("test1:test1")))).To(BeFalse())
+			Expect(IsSignedToken("header.payload.signature")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,134 @@
+package userimport
+
+import (
+	"core-api/pkg/util/xlsx"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	core "core-api/pkg/north/api/user/core/v1"
+)
+
+// format of exported file
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ValueSeparator joins names of several roles or groups of a user in one cell
+const ValueSeparator = ";"
+
+// ExportColumns is header of exported users, it is read back by import
+// password column is always left blank, it has to be filled before file is imported elsewhere
+var ExportColumns = []string{ColumnName, ColumnPassword, ColumnRole, ColumnGroup, ColumnDescription, ColumnEmail}
+
+// MembershipColumns is header of exported group memberships
+var MembershipColumns = []string{ColumnGroup, ColumnName, ColumnEmail}
+
+// Names splits a cell into names of roles or groups
+func Names(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ValueSeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// UserRows turns users into rows under ExportColumns, roles and groups are written by name
+// id is written if name of a role or group is unknown
+func UserRows(users []core.CoreUser, roleNames, groupNames map[string]string) [][]string {
+	rows := [][]string{ExportColumns}
+	for _, user := range users {
+		var roles, groups []string
+		for _, role := range user.Roles {
+			roles = append(roles, nameOf(role.Id, role.Name, roleNames))
+		}
+		for _, group := range user.Groups {
+			groups = append(groups, nameOf(group.Id, group.Name, groupNames))
+		}
+		sort.Strings(roles)
+		sort.Strings(groups)
+		rows = append(rows, []string{user.Name, "", strings.Join(roles, ValueSeparator), strings.Join(groups, ValueSeparator), user.Description, user.Email})
+	}
+	return rows
+}
+
+// MembershipRows turns members of each group into rows under MembershipColumns
+func MembershipRows(groups []core.CoreGroup, members map[string][]core.CoreUser) [][]string {
+	rows := [][]string{MembershipColumns}
+	for _, group := range groups {
+		for _, user := range members[group.Id] {
+			rows = append(rows, []string{group.Name, user.Name, user.Email})
+		}
+	}
+	return rows
+}
+
+func nameOf(id, name string, names map[string]string) string {
+	if name != "" {
+		return name
+	}
+	if found, ok := names[id]; ok {
+		return found
+	}
+	return id
+}
+
+// ContentType is media type of exported file of format
+func ContentType(format string) (string, error) {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8", nil
+	case FormatXLSX:
+		return xlsx.ContentType, nil
+	}
+	return "", fmt.Errorf("invalid format %s expected to be one of csv or xlsx", format)
+}
+
+// Write writes rows as a file of format, sheet name is only used by xlsx
+func Write(w io.Writer, format, sheetName string, rows [][]string) error {
+	switch format {
+	case FormatCSV:
+		// byte order mark lets excel open utf-8 csv, it is skipped by import
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return err
+		}
+		writer := csv.NewWriter(w)
+		for _, row := range rows {
+			escaped := make([]string, len(row))
+			for index, value := range row {
+				escaped[index] = escapeFormula(value)
+			}
+			if err := writer.Write(escaped); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatXLSX:
+		return xlsx.WriteRows(w, sheetName, rows)
+	}
+	return fmt.Errorf("invalid format %s expected to be one of csv or xlsx", format)
+}
+
+// escapeFormula keeps spreadsheet application from running a cell of csv as formula
+// quote is dropped again by import
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func unescapeFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+const formulaPrefixes = "=+-@\t\r"
@@ -0,0 +1,72 @@
+package userimport
+
+import (
+	"bytes"
+	"strings"
+
+	core "core-api/pkg/north/api/user/core/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("user export test", func() {
+	users := []core.CoreUser{
+		{Id: "alice-id", Name: "alice", Email: "alice@school.edu", Password: "secret", Description: "=HYPERLINK(\"http://evil\")",
+			Roles:  []core.CoreRole{{Id: "teacher-id"}, {Id: "student-id"}},
+			Groups: []core.CoreGroup{{Id: "class1-id"}}},
+		{Id: "bob-id", Name: "bob", Roles: []core.CoreRole{{Id: "unknown-id"}}, Groups: []core.CoreGroup{{Id: "class2-id", Name: "class2"}}},
+	}
+	roleNames := map[string]string{"teacher-id": "teacher", "student-id": "student"}
+	groupNames := map[string]string{"class1-id": "class1"}
+
+	It("should turn users into rows without password", func() {
+		rows := UserRows(users, roleNames, groupNames)
+		Expect(rows).To(Equal([][]string{
+			ExportColumns,
+			{"alice", "", "student;teacher", "class1", "=HYPERLINK(\"http://evil\")", "alice@school.edu"},
+			{"bob", "", "unknown-id", "class2", "", ""},
+		}))
+	})
+
+	It("should read exported users back", func() {
+		for _, format := range []string{FormatCSV, FormatXLSX} {
+			buffer := &bytes.Buffer{}
+			Expect(Write(buffer, format, "users", UserRows(users, roleNames, groupNames))).To(BeNil())
+
+			reader := bytes.NewReader(buffer.Bytes())
+			records, err := Parse("users."+format, reader, reader.Size())
+			Expect(err).To(BeNil())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]).To(Equal(Record{Row: 2, Name: "alice", Role: "student;teacher", Group: "class1", Description: "=HYPERLINK(\"http://evil\")", Email: "alice@school.edu"}))
+			Expect(Names(records[0].Role)).To(Equal([]string{"student", "teacher"}))
+			Expect(records[0].Problems()).To(Equal([]string{"password is empty"}))
+		}
+	})
+
+	It("should quote formula in csv", func() {
+		buffer := &bytes.Buffer{}
+		Expect(Write(buffer, FormatCSV, "users", UserRows(users, roleNames, groupNames))).To(BeNil())
+		Expect(strings.HasPrefix(buffer.String(), "\ufeffusername,password,role,group,description,email\n")).To(BeTrue())
+		Expect(buffer.String()).To(ContainSubstring(`"'=HYPERLINK(""http://evil"")"`))
+	})
+
+	It("should turn group members into rows", func() {
+		groups := []core.CoreGroup{{Id: "class1-id", Name: "class1"}, {Id: "class2-id", Name: "class2"}}
+		rows := MembershipRows(groups, map[string][]core.CoreUser{"class1-id": users})
+		Expect(rows).To(Equal([][]string{
+			MembershipColumns,
+			{"class1", "alice", "alice@school.edu"},
+			{"class1", "bob", ""},
+		}))
+	})
+
+	It("should refuse unknown format", func() {
+		_, err := ContentType("pdf")
+		Expect(err).To(HaveOccurred())
+		Expect(Write(&bytes.Buffer{}, "pdf", "users", nil)).To(HaveOccurred())
+		contentType, err := ContentType(FormatXLSX)
+		Expect(err).To(BeNil())
+		Expect(contentType).To(Equal("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"))
+	})
+})
@@ -0,0 +1,222 @@
+package userimport
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/util/xlsx"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// status of a row in import report
+const (
+	StatusCreated = "created"
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+)
+
+// column names a user can be described with, header is matched case insensitive with spaces, '_' and '-' ignored
+const (
+	ColumnName        = "username"
+	ColumnPassword    = "password"
+	ColumnRole        = "role"
+	ColumnGroup       = "group"
+	ColumnDescription = "description"
+	ColumnEmail       = "email"
+)
+
+// legacyColumns is column order of files made before header was read, such file has a header of other names
+var legacyColumns = []string{ColumnName, ColumnPassword, ColumnRole, ColumnGroup, ColumnDescription}
+
+var columnAliases = map[string]string{
+	"username":    ColumnName,
+	"name":        ColumnName,
+	"user":        ColumnName,
+	"login":       ColumnName,
+	"password":    ColumnPassword,
+	"role":        ColumnRole,
+	"rolename":    ColumnRole,
+	"group":       ColumnGroup,
+	"groupname":   ColumnGroup,
+	"class":       ColumnGroup,
+	"description": ColumnDescription,
+	"email":       ColumnEmail,
+	"mail":        ColumnEmail,
+	"用户名":         ColumnName,
+	"密码":          ColumnPassword,
+	"角色":          ColumnRole,
+	"组":           ColumnGroup,
+	"班级":          ColumnGroup,
+	"描述":          ColumnDescription,
+	"邮箱":          ColumnEmail,
+}
+
+// Record is a user read from a row of import file
+type Record struct {
+	// row number in file, header is row 1
+	Row      int
+	Name     string
+	Password string
+	// several roles or groups are joined by ValueSeparator
+	Role        string
+	Group       string
+	Description string
+	Email       string
+}
+
+// Problems lists what is wrong with record before anything is looked up
+func (r Record) Problems() []string {
+	var problems []string
+	if r.Name == "" {
+		problems = append(problems, "username is empty")
+	}
+	if r.Password == "" {
+		problems = append(problems, "password is empty")
+	}
+	if len(Names(r.Role)) == 0 {
+		problems = append(problems, "role is empty")
+	}
+	if len(Names(r.Group)) == 0 {
+		problems = append(problems, "group is empty")
+	}
+	return problems
+}
+
+// Parse reads users from a csv, txt or xlsx file, first row is header
+// blank rows are left out
+func Parse(fileName string, file io.ReaderAt, size int64) ([]Record, error) {
+	var rows [][]string
+	var err error
+	// cell of csv starting like a formula is quoted by export
+	quoted := false
+	switch ext := strings.ToLower(filepath.Ext(fileName)); ext {
+	case ".csv", ".txt":
+		reader := csv.NewReader(io.NewSectionReader(file, 0, size))
+		// rows of different length are reported per row instead of failing whole file
+		reader.FieldsPerRecord = -1
+		quoted = true
+		rows, err = reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse csv file: %w", err)
+		}
+	case ".xlsx":
+		rows, err = xlsx.ReadRows(file, size)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid file extension %s expected to be one of csv, txt or xlsx", ext)
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+
+	columns, err := mapColumns(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for index, row := range rows[1:] {
+		if isBlank(row) {
+			continue
+		}
+		record := Record{Row: index + 2}
+		for column, value := range row {
+			if column >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(value)
+			if quoted {
+				value = unescapeFormula(value)
+			}
+			switch columns[column] {
+			case ColumnName:
+				record.Name = value
+			case ColumnPassword:
+				// password is taken as it is
+				record.Password = row[column]
+			case ColumnRole:
+				record.Role = value
+			case ColumnGroup:
+				record.Group = value
+			case ColumnDescription:
+				record.Description = value
+			case ColumnEmail:
+				record.Email = value
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// mapColumns tells column name of each position of header, unknown column is left blank
+// file without any known column in header is read in legacy column order
+func mapColumns(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for index, title := range header {
+		column, found := columnAliases[normalize(title)]
+		if !found {
+			continue
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column %s is given more than once in header", column)
+		}
+		seen[column] = true
+		columns[index] = column
+	}
+
+	if len(seen) == 0 {
+		return legacyColumns, nil
+	}
+
+	for _, required := range []string{ColumnName, ColumnPassword} {
+		if !seen[required] {
+			return nil, fmt.Errorf("column %s is missing in header", required)
+		}
+	}
+	return columns, nil
+}
+
+func normalize(title string) string {
+	// excel saves utf-8 csv with byte order mark
+	title = strings.TrimPrefix(title, "\ufeff")
+	title = strings.ToLower(strings.TrimSpace(title))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(title)
+}
+
+func isBlank(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// RolePermission is permission given to role created by import
+func RolePermission(serverConfig *config.Config) map[string]uint64 {
+	if serverConfig != nil && serverConfig.AuthConfig != nil && serverConfig.AuthConfig.UserImport != nil && len(serverConfig.AuthConfig.UserImport.DefaultRolePermission) > 0 {
+		return copyPermission(serverConfig.AuthConfig.UserImport.DefaultRolePermission)
+	}
+	// a student can view index, courses and devices and use rag
+	return map[string]uint64{
+		"indexView":         1,
+		"courseStudentView": 1,
+		"deviceStudentView": 31,
+		"rag":               30,
+	}
+}
+
+func copyPermission(permission map[string]uint64) map[string]uint64 {
+	result := make(map[string]uint64, len(permission))
+	for module, value := range permission {
+		result[module] = value
+	}
+	return result
+}
@@ -0,0 +1,13 @@
+package userimport_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUserimport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Userimport Suite")
+}
@@ -0,0 +1,97 @@
+package userimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"core-api/cmd/core-api-server/app/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func parseText(fileName, content string) ([]Record, error) {
+	reader := bytes.NewReader([]byte(content))
+	return Parse(fileName, reader, reader.Size())
+}
+
+var _ = Describe("user import test", func() {
+	Describe("Parse test", func() {
+		It("should map columns by header", func() {
+			records, err := parseText("users.csv", "\ufeffEmail,Group,User Name,Password,Role,Age\n"+
+				"alice@school.edu, class1 ,alice, secret-1 ,student,12\n"+
+				",,,,,\n"+
+				"bob@school.edu,class2,bob,secret-2,teacher\n")
+			Expect(err).To(BeNil())
+			Expect(records).To(Equal([]Record{
+				{Row: 2, Name: "alice", Password: " secret-1 ", Role: "student", Group: "class1", Email: "alice@school.edu"},
+				{Row: 4, Name: "bob", Password: "secret-2", Role: "teacher", Group: "class2", Email: "bob@school.edu"},
+			}))
+		})
+
+		It("should read file of legacy column order", func() {
+			records, err := parseText("users.txt", "账号,口令,r,g,d\nalice,secret-1,student,class1,first student\nbob,secret-2\n")
+			Expect(err).To(BeNil())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]).To(Equal(Record{Row: 2, Name: "alice", Password: "secret-1", Role: "student", Group: "class1", Description: "first student"}))
+			Expect(records[1].Problems()).To(Equal([]string{"role is empty", "group is empty"}))
+		})
+
+		It("should refuse header missing required column", func() {
+			_, err := parseText("users.csv", "username,role,group\nalice,student,class1\n")
+			Expect(err).To(HaveOccurred())
+			_, err = parseText("users.csv", "username,name,password\nalice,alice,secret\n")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should refuse unknown or empty file", func() {
+			_, err := parseText("users.xls", "username,password\n")
+			Expect(err).To(HaveOccurred())
+			_, err = parseText("users.csv", "")
+			Expect(err).To(HaveOccurred())
+			_, err = parseText("users.xlsx", "username,password\n")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should read xlsx file", func() {
+			buffer := &bytes.Buffer{}
+			writer := zip.NewWriter(buffer)
+			parts := map[string]string{
+				"xl/workbook.xml": `<workbook><sheets><sheet name="users"/></sheets></workbook>`,
+				"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+					`<row r="1"><c r="A1" t="inlineStr"><is><t>username</t></is></c><c r="B1" t="inlineStr"><is><t>password</t></is></c><c r="C1" t="inlineStr"><is><t>role</t></is></c><c r="D1" t="inlineStr"><is><t>group</t></is></c></row>` +
+					`<row r="3"><c r="A3" t="inlineStr"><is><t>alice</t></is></c><c r="B3"><v>20240817</v></c><c r="D3" t="inlineStr"><is><t>class1</t></is></c></row>` +
+					`</sheetData></worksheet>`,
+			}
+			for name, content := range parts {
+				part, err := writer.Create(name)
+				Expect(err).To(BeNil())
+				_, err = part.Write([]byte(content))
+				Expect(err).To(BeNil())
+			}
+			Expect(writer.Close()).To(BeNil())
+
+			reader := bytes.NewReader(buffer.Bytes())
+			records, err := Parse("Users.XLSX", reader, reader.Size())
+			Expect(err).To(BeNil())
+			Expect(records).To(Equal([]Record{{Row: 3, Name: "alice", Password: "20240817", Group: "class1"}}))
+			Expect(records[0].Problems()).To(Equal([]string{"role is empty"}))
+		})
+	})
+
+	Describe("RolePermission test", func() {
+		It("should use template unless it is configured", func() {
+			Expect(RolePermission(nil)["deviceStudentView"]).To(Equal(uint64(31)))
+
+			serverConfig := config.DefaultConfig()
+			Expect(RolePermission(serverConfig)["rag"]).To(Equal(uint64(30)))
+
+			serverConfig.AuthConfig.UserImport = &config.UserImportConfig{DefaultRolePermission: map[string]uint64{"course": 1}}
+			permission := RolePermission(serverConfig)
+			Expect(permission).To(Equal(map[string]uint64{"course": 1}))
+
+			// returned permission is a copy
+			permission["course"] = 3
+			Expect(serverConfig.AuthConfig.UserImport.DefaultRolePermission["course"]).To(Equal(uint64(1)))
+		})
+	})
+})
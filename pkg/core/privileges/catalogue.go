@@ -0,0 +1,160 @@
+package privileges
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LocalizedName is human readable name of a module or a permission
+type LocalizedName struct {
+	Zh string `json:"zh"`
+	En string `json:"en"`
+}
+
+// Permission is a named permission of a module and bit it is stored with
+type Permission struct {
+	Name  string        `json:"name"`
+	Bit   uint64        `json:"bit"`
+	Label LocalizedName `json:"label"`
+}
+
+// ModuleCatalogue lists permissions of a module ordered by bit
+type ModuleCatalogue struct {
+	Module      string        `json:"module"`
+	Label       LocalizedName `json:"label"`
+	Permissions []Permission  `json:"permissions"`
+	// all bits of module, a role can not hold other bits
+	FullPermission uint64 `json:"fullPermission"`
+}
+
+var moduleLabels = map[string]LocalizedName{
+	"course":            {Zh: "课程管理", En: "Courses"},
+	"dataset":           {Zh: "数据集管理", En: "Datasets"},
+	"device":            {Zh: "设备管理", En: "Devices"},
+	"user":              {Zh: "用户管理", En: "Users"},
+	"role":              {Zh: "角色管理", En: "Roles"},
+	"rag":               {Zh: "知识库", En: "Knowledge base"},
+	"setting":           {Zh: "系统设置", En: "Settings"},
+	"group":             {Zh: "班级管理", En: "Groups"},
+	"flavor":            {Zh: "规格管理", En: "Flavors"},
+	"indexView":         {Zh: "首页", En: "Home page"},
+	"courseStudentView": {Zh: "学生课程", En: "Student courses"},
+	"deviceStudentView": {Zh: "学生设备", En: "Student devices"},
+	"model":             {Zh: "模型管理", En: "Models"},
+}
+
+var permissionLabels = map[string]LocalizedName{
+	"view_page":                  {Zh: "查看页面", En: "View page"},
+	"list":                       {Zh: "查看列表", En: "List"},
+	"create":                     {Zh: "创建", En: "Create"},
+	"update":                     {Zh: "修改", En: "Update"},
+	"delete":                     {Zh: "删除", En: "Delete"},
+	"manage_other_user_resource": {Zh: "管理他人资源", En: "Manage resources of other users"},
+	"impersonate":                {Zh: "以他人身份操作", En: "Act as another user"},
+	"query":                      {Zh: "问答", En: "Query"},
+	"view_page_learning_center":  {Zh: "查看学习中心", En: "View learning center"},
+	"view_resource_overall":      {Zh: "查看资源总览", En: "View resource overview"},
+}
+
+// http methods are shortcuts for route checks, they are not permissions of their own
+var methodNames = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// Catalogue lists every module of Modules with its named permissions, modules are ordered by name
+func Catalogue() []ModuleCatalogue {
+	moduleNames := make([]string, 0, len(Modules))
+	for moduleName := range Modules {
+		moduleNames = append(moduleNames, moduleName)
+	}
+	sort.Strings(moduleNames)
+
+	result := make([]ModuleCatalogue, 0, len(moduleNames))
+	for _, moduleName := range moduleNames {
+		module := ModuleCatalogue{Module: moduleName, Label: labelOf(moduleLabels, moduleName)}
+		for name, bit := range Modules[moduleName] {
+			if methodNames[name] {
+				continue
+			}
+			module.Permissions = append(module.Permissions, Permission{Name: name, Bit: bit, Label: labelOf(permissionLabels, name)})
+			module.FullPermission |= bit
+		}
+		sort.Slice(module.Permissions, func(i, j int) bool {
+			return module.Permissions[i].Bit < module.Permissions[j].Bit
+		})
+		result = append(result, module)
+	}
+	return result
+}
+
+// label falls back to name so a newly added permission is still shown
+func labelOf(labels map[string]LocalizedName, name string) LocalizedName {
+	if label, found := labels[name]; found {
+		return label
+	}
+	return LocalizedName{Zh: name, En: name}
+}
+
+// Bitmask turns permission names of a module into bits, e.g. ["view_page", "list"] of user is 3
+func Bitmask(moduleName string, names []string) (uint64, error) {
+	modulePermissions, found := Modules[moduleName]
+	if !found {
+		return 0, fmt.Errorf("unknown module %s", moduleName)
+	}
+	var result uint64
+	for _, name := range names {
+		bit, found := modulePermissions[name]
+		if !found || methodNames[name] {
+			return 0, fmt.Errorf("unknown permission %s of module %s", name, moduleName)
+		}
+		result |= bit
+	}
+	return result, nil
+}
+
+// ValidatePermission makes sure every module is known and holds bits of its named permissions only
+func ValidatePermission(permission map[string]uint64) error {
+	var problems []string
+	for moduleName, value := range permission {
+		modulePermissions, found := Modules[moduleName]
+		if !found {
+			problems = append(problems, fmt.Sprintf("unknown module %s", moduleName))
+			continue
+		}
+		var full uint64
+		for _, bit := range modulePermissions {
+			full |= bit
+		}
+		if invalid := value &^ full; invalid != 0 {
+			problems = append(problems, fmt.Sprintf("invalid bits %d of module %s, expected to be within %d", invalid, moduleName, full))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PermissionNames names bits of a module ordered by bit, e.g. 3 of user is ["view_page", "list"]
+// bits without a name are left out
+func PermissionNames(moduleName string, bits uint64) []string {
+	var names []string
+	for _, module := range Catalogue() {
+		if module.Module != moduleName {
+			continue
+		}
+		for _, permission := range module.Permissions {
+			if permission.Bit != 0 && bits&permission.Bit == permission.Bit {
+				names = append(names, permission.Name)
+			}
+		}
+	}
+	return names
+}
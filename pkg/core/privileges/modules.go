@@ -102,6 +102,7 @@ var UserFullPermission = map[string]uint64{
 	"update":                     PermissionUserUpdate,
 	"delete":                     PermissionUserDelete,
 	"manage_other_user_resource": PermissionUserManageOtherUserResource,
+	"impersonate":                PermissionUserImpersonate,
 	http.MethodGet:               PermissionUserList,
 	http.MethodPost:              PermissionUserCreate,
 	http.MethodPut:               PermissionUserUpdate,
@@ -239,6 +240,8 @@ const (
 	PermissionUserUpdate
 	PermissionUserDelete
 	PermissionUserManageOtherUserResource
+	// act as another user with header 'Impersonate-User'
+	PermissionUserImpersonate
 )
 
 const (
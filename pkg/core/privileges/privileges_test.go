@@ -20,7 +20,7 @@ var _ = Describe("privileges test", func() {
 				"group":             31,
 				"course":            63,
 				"dataset":           31,
-				"user":              63,
+				"user":              127,
 				"setting":           31,
 				"deviceStudentView": 31,
 				"device":            63,
@@ -148,4 +148,53 @@ var _ = Describe("privileges test", func() {
 			Expect(err).To(HaveOccurred())
 		})
 	})
+	Describe("Catalogue test", func() {
+		It("should list named permissions of every module without http methods", func() {
+			catalogue := Catalogue()
+			Expect(catalogue).To(HaveLen(len(Modules)))
+			full := (&DefaultPrivilegeProvider{}).SetFullAccess()
+			for _, module := range catalogue {
+				Expect(module.FullPermission).To(Equal(full[module.Module]))
+				Expect(module.Label.En).NotTo(BeEmpty())
+				for _, permission := range module.Permissions {
+					Expect(methodNames).NotTo(HaveKey(permission.Name))
+				}
+			}
+			Expect(catalogue[len(catalogue)-1].Module).To(Equal("user"))
+			user := catalogue[len(catalogue)-1]
+			Expect(user.Permissions[0]).To(Equal(Permission{Name: "view_page", Bit: PermissionUserViewPage, Label: LocalizedName{Zh: "查看页面", En: "View page"}}))
+			Expect(user.Permissions[len(user.Permissions)-1].Name).To(Equal("impersonate"))
+		})
+	})
+	Describe("Bitmask test", func() {
+		It("should turn names into bits", func() {
+			bits, err := Bitmask("deviceStudentView", []string{"view_page", "list", "create", "update", "delete"})
+			Expect(err).To(BeNil())
+			Expect(bits).To(Equal(uint64(31)))
+		})
+		It("should refuse unknown module or name", func() {
+			_, err := Bitmask("unknown", []string{"list"})
+			Expect(err).NotTo(BeNil())
+			_, err = Bitmask("user", []string{"fly"})
+			Expect(err).NotTo(BeNil())
+			_, err = Bitmask("user", []string{http.MethodGet})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+	Describe("ValidatePermission test", func() {
+		It("should accept known bits", func() {
+			Expect(ValidatePermission(map[string]uint64{"user": 127, "indexView": 3, "rag": 0})).To(BeNil())
+		})
+		It("should refuse unknown module or bits", func() {
+			Expect(ValidatePermission(map[string]uint64{"unknown": 1})).To(MatchError(ContainSubstring("unknown module unknown")))
+			Expect(ValidatePermission(map[string]uint64{"user": 128})).To(MatchError(ContainSubstring("invalid bits 128 of module user")))
+		})
+	})
+	Describe("PermissionNames test", func() {
+		It("should name bits of module", func() {
+			Expect(PermissionNames("user", PermissionUserViewPage|PermissionUserList)).To(Equal([]string{"view_page", "list"}))
+			Expect(PermissionNames("user", 0)).To(BeEmpty())
+			Expect(PermissionNames("unknown", 1)).To(BeEmpty())
+		})
+	})
 })
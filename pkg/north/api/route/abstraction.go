@@ -14,6 +14,8 @@ type IRouteProvider interface {
 	// path|method|permission
 	// u no what i mean right?
 	GetRouteAuthorization() map[string]map[string]ModuleAndPermission
+	// SetRouteAuthorization replaces permission of routes, e.g. with route policy of config applied
+	SetRouteAuthorization(authorization map[string]map[string]ModuleAndPermission)
 	GetRoot() *chi.Mux
 }
 
@@ -44,6 +46,8 @@ type ChiRouteBuilder struct {
 type ModuleAndPermission struct {
 	Module     string
 	Permission uint64
+	// administrator of a group may call route without permission, handler makes sure it acts on a member of the group
+	GroupAdmin bool
 }
 
 func (builder *ChiRouteBuilder) Build() (func(r chi.Router), map[string]map[string]ModuleAndPermission) {
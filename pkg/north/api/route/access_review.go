@@ -0,0 +1,151 @@
+package route
+
+import (
+	"core-api/pkg/core/privileges"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// reviewedRoutes are routes and white list auth middleware checks requests with
+// they are set by api server after route policy is applied, access review is not available if auth is disabled
+var reviewedRoutes struct {
+	sync.RWMutex
+	provider  IRouteProvider
+	whiteList WhiteList
+}
+
+// SetAccessReviewRoutes sets routes access review and access matrix answer for
+func SetAccessReviewRoutes(provider IRouteProvider, whiteList WhiteList) {
+	reviewedRoutes.Lock()
+	defer reviewedRoutes.Unlock()
+	reviewedRoutes.provider = provider
+	reviewedRoutes.whiteList = whiteList
+}
+
+func getAccessReviewRoutes() (IRouteProvider, WhiteList, error) {
+	reviewedRoutes.RLock()
+	defer reviewedRoutes.RUnlock()
+	if reviewedRoutes.provider == nil {
+		return nil, nil, fmt.Errorf("route authorization is not available, auth is disabled")
+	}
+	return reviewedRoutes.provider, reviewedRoutes.whiteList, nil
+}
+
+// matchRoutePattern finds pattern of route serving method and path, like auth middleware does
+// route only on white list, e.g. swagger doc, is matched by its pattern
+func matchRoutePattern(provider IRouteProvider, whiteList WhiteList, method, requestPath string) (string, bool) {
+	routeContext := chi.NewRouteContext()
+	if provider.GetRoot().Match(routeContext, method, requestPath) {
+		return routeContext.RoutePattern(), true
+	}
+	for pattern := range whiteList {
+		if pattern == requestPath || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(requestPath, strings.TrimSuffix(pattern, "*"))) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// reviewAccess tells whether user may call method of route pattern, it follows checks of auth middleware
+// roles are roles of user with permission loaded, groupAdmin tells whether user administers any group
+func reviewAccess(authorization map[string]map[string]ModuleAndPermission, whiteList WhiteList, user *coreUserV1.CoreUser, roles []coreUserV1.CoreRole, groupAdmin bool, method, pattern string) coreUserV1.CoreAccessVerdict {
+	verdict := coreUserV1.CoreAccessVerdict{UserId: user.Id, UserName: user.Name, Method: method, Pattern: pattern}
+
+	if methods, found := whiteList[pattern]; found && (methods == nil || slices.Contains(methods, method)) {
+		verdict.Allowed = true
+		verdict.Public = true
+		verdict.Reason = "route is served without authentication"
+		return verdict
+	}
+
+	if pattern == "" {
+		verdict.Reason = "no route matches method and path"
+		return verdict
+	}
+	moduleAndPermission, found := authorization[pattern][method]
+	if !found {
+		verdict.Reason = fmt.Sprintf("method %s of route %s is not registered", method, pattern)
+		return verdict
+	}
+	verdict.Module = moduleAndPermission.Module
+	verdict.Permission = moduleAndPermission.Permission
+	verdict.PermissionNames = privileges.PermissionNames(moduleAndPermission.Module, moduleAndPermission.Permission)
+
+	for _, role := range roles {
+		if granted, found := role.Permission[verdict.Module]; found && granted&verdict.Permission == verdict.Permission {
+			verdict.GrantedBy = append(verdict.GrantedBy, coreUserV1.CoreRole{Id: role.Id, Name: role.Name})
+		}
+	}
+
+	if user.Disabled {
+		verdict.Reason = "user is disabled"
+		return verdict
+	}
+
+	granted, found := user.Permission[verdict.Module]
+	if !found {
+		verdict.MissingPermission = verdict.Permission
+		verdict.Reason = fmt.Sprintf("user has no permission of module %s", verdict.Module)
+	} else if missing := verdict.Permission &^ granted; missing != 0 {
+		verdict.MissingPermission = missing
+		verdict.Reason = fmt.Sprintf("user lacks %s of module %s", strings.Join(missingNames(verdict.Module, missing), ", "), verdict.Module)
+	}
+	if verdict.MissingPermission != 0 {
+		if moduleAndPermission.GroupAdmin && groupAdmin {
+			verdict.Allowed = true
+			verdict.Reason = fmt.Sprintf("%s, allowed for members of groups user administers", verdict.Reason)
+		}
+		return verdict
+	}
+
+	verdict.Allowed = true
+	return verdict
+}
+
+// missingNames names missing bits, bits without a name are given as number
+func missingNames(module string, missing uint64) []string {
+	names := privileges.PermissionNames(module, missing)
+	if len(names) == 0 {
+		return []string{fmt.Sprintf("bits %d", missing)}
+	}
+	return names
+}
+
+// accessMatrix is verdict of user on every method of every registered route ordered by pattern and method
+func accessMatrix(authorization map[string]map[string]ModuleAndPermission, whiteList WhiteList, user *coreUserV1.CoreUser, roles []coreUserV1.CoreRole, groupAdmin bool) *coreUserV1.CoreAccessMatrix {
+	matrix := &coreUserV1.CoreAccessMatrix{UserId: user.Id, UserName: user.Name, Routes: []coreUserV1.CoreAccessVerdict{}}
+	for pattern, methods := range authorization {
+		for method := range methods {
+			verdict := reviewAccess(authorization, whiteList, user, roles, groupAdmin, method, pattern)
+			// user is told once at top of matrix
+			verdict.UserId, verdict.UserName = "", ""
+			matrix.Routes = append(matrix.Routes, verdict)
+		}
+	}
+	sort.Slice(matrix.Routes, func(i, j int) bool {
+		if matrix.Routes[i].Pattern != matrix.Routes[j].Pattern {
+			return matrix.Routes[i].Pattern < matrix.Routes[j].Pattern
+		}
+		return matrix.Routes[i].Method < matrix.Routes[j].Method
+	})
+	return matrix
+}
+
+// reviewedPath is path of url without query string
+func reviewedPath(rawPath string) (string, error) {
+	parsed, err := url.Parse(rawPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %q: %w", rawPath, err)
+	}
+	if parsed.Path == "" || !strings.HasPrefix(parsed.Path, "/") {
+		return "", fmt.Errorf("path %q is expected to start with '/'", rawPath)
+	}
+	return parsed.Path, nil
+}
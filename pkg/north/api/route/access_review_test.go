@@ -0,0 +1,139 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("access review test", func() {
+	var serverConfig *config.Config
+	var admin, teacher *coreUserV1.CoreUser
+	var teacherRole *coreUserV1.CoreRole
+	usersPath := coreApiFullPathPrefix + "/users"
+
+	review := func(requestUser *coreUserV1.CoreUser, post coreUserV1.CoreAccessReview) (*httptest.ResponseRecorder, *coreUserV1.CoreAccessVerdict) {
+		body, err := json.Marshal(post)
+		Expect(err).To(BeNil())
+		request := httptest.NewRequest(http.MethodPost, "/access-review", bytes.NewReader(body))
+		request = request.WithContext(context.WithValue(request.Context(), "core-user", requestUser))
+		recorder := httptest.NewRecorder()
+		CreateAccessReviewHandler(serverConfig)(recorder, request)
+		verdict := &coreUserV1.CoreAccessVerdict{}
+		if recorder.Code == http.StatusOK {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), verdict)).To(Succeed())
+		}
+		return recorder, verdict
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		roles, err := initOrGetRoleProvider(serverConfig)
+		Expect(err).To(BeNil())
+
+		admin, err = users.SearchUserByName("admin", map[string]struct{}{keystone.LoadPermission: {}})
+		Expect(err).To(BeNil())
+		teacherRole, err = roles.CreateRole(&coreUserV1.CoreRole{Name: "teacher", Permission: map[string]uint64{"user": privileges.PermissionUserViewPage}}, nil)
+		Expect(err).To(BeNil())
+		teacher, err = users.CreateUser(&coreUserV1.CoreUser{Name: "teacher", Password: "teacher-password", Roles: []coreUserV1.CoreRole{{Id: teacherRole.Id}}}, nil)
+		Expect(err).To(BeNil())
+		teacher, err = users.GetUser(teacher.Id, nil)
+		Expect(err).To(BeNil())
+
+		provider := NewDefaultRootRoute()
+		provider.RegisterRoute(coreApiFullPathPrefix, GetCoreApiRoute(serverConfig))
+		SetAccessReviewRoutes(provider, WhiteList{"/doc/*": nil, coreApiFullPathPrefix + "/users/login": nil})
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+		SetAccessReviewRoutes(nil, nil)
+	})
+
+	It("should tell which bit is missing", func() {
+		recorder, verdict := review(teacher, coreUserV1.CoreAccessReview{Method: "get", Path: usersPath + "/" + admin.Id + "?x=1"})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(verdict.Allowed).To(BeFalse())
+		Expect(verdict.UserId).To(Equal(teacher.Id))
+		Expect(verdict.Pattern).To(Equal(usersPath + "/{userId}"))
+		Expect(verdict.Module).To(Equal("user"))
+		Expect(verdict.PermissionNames).To(Equal([]string{"list"}))
+		Expect(verdict.MissingPermission).To(Equal(uint64(privileges.PermissionUserList)))
+		Expect(verdict.GrantedBy).To(BeEmpty())
+		Expect(verdict.Reason).To(ContainSubstring("lacks list of module user"))
+	})
+
+	It("should tell roles granting access of another user", func() {
+		recorder, verdict := review(admin, coreUserV1.CoreAccessReview{UserId: teacher.Id, Method: http.MethodGet, Path: coreApiFullPathPrefix + "/users/me"})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(verdict.Allowed).To(BeTrue())
+		Expect(verdict.GrantedBy).To(Equal([]coreUserV1.CoreRole{{Id: teacherRole.Id, Name: "teacher"}}))
+
+		_, verdict = review(admin, coreUserV1.CoreAccessReview{UserId: teacher.Id, Method: http.MethodGet, Path: "/doc/index.html"})
+		Expect(verdict.Allowed).To(BeTrue())
+		Expect(verdict.Public).To(BeTrue())
+
+		_, verdict = review(admin, coreUserV1.CoreAccessReview{UserId: teacher.Id, Method: http.MethodGet, Path: "/nowhere"})
+		Expect(verdict.Allowed).To(BeFalse())
+		Expect(verdict.Pattern).To(BeEmpty())
+	})
+
+	It("should refuse reviewing another user without permission", func() {
+		recorder, _ := review(teacher, coreUserV1.CoreAccessReview{UserId: admin.Id, Method: http.MethodGet, Path: usersPath})
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+		recorder, _ = review(teacher, coreUserV1.CoreAccessReview{Method: http.MethodGet, Path: "users"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should list verdict on every route", func() {
+		request := httptest.NewRequest(http.MethodGet, "/users/"+teacher.Id+"/access-matrix", nil)
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("userId", teacher.Id)
+		request = request.WithContext(context.WithValue(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext), "core-user", admin))
+		recorder := httptest.NewRecorder()
+		CreateGetAccessMatrixHandler(serverConfig)(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		matrix := &coreUserV1.CoreAccessMatrix{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), matrix)).To(Succeed())
+		Expect(matrix.UserName).To(Equal("teacher"))
+		provider, _, err := getAccessReviewRoutes()
+		Expect(err).To(BeNil())
+		total := 0
+		for _, methods := range provider.GetRouteAuthorization() {
+			total += len(methods)
+		}
+		Expect(matrix.Routes).To(HaveLen(total))
+		verdicts := map[string]bool{}
+		for _, verdict := range matrix.Routes {
+			Expect(verdict.UserId).To(BeEmpty())
+			verdicts[verdict.Method+" "+verdict.Pattern] = verdict.Allowed
+		}
+		Expect(verdicts).To(HaveKeyWithValue("GET "+usersPath+"/me", true))
+		Expect(verdicts).To(HaveKeyWithValue("DELETE "+usersPath+"/{userId}", false))
+		Expect(verdicts).To(HaveKeyWithValue("POST "+usersPath+"/login", true))
+	})
+})
@@ -2,6 +2,7 @@ package route
 
 import (
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/share"
 	"core-api/pkg/k8s"
 	summaryCoreV1 "core-api/pkg/north/api/summary/core/v1"
 	"core-api/pkg/south"
@@ -28,6 +29,8 @@ func getOrInitSouthRagHandler(config *config.Config, stopChan <-chan struct{}) *
 	if southRagHandler == nil {
 		southRagHandler = south.NewRAGSouthApiHandler(config, stopChan)
 		go southRagHandler.RunBackgroundCache()
+		share.RegisterOwnerResolver(share.KindConversation, southRagHandler.ConversationOwner)
+		share.RegisterOwnerResolver(share.KindKnowledgeBase, southRagHandler.KnowledgeBaseOwner)
 	}
 	return southRagHandler
 }
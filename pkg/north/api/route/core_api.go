@@ -1,12 +1,37 @@
 package route
 
 import (
+	"bytes"
 	"core-api/cmd/core-api-server/app/config"
 	"core-api/pkg/core/privileges"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	auth "core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/accesstoken"
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/auth/event"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/auth/impersonation"
+	"core-api/pkg/core/auth/lockout"
+	passwordPolicy "core-api/pkg/core/auth/password"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/session"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
+	authToken "core-api/pkg/core/auth/token"
+	"core-api/pkg/core/auth/userimport"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	utilCommon "core-api/pkg/util/common"
+	customErr "core-api/pkg/util/error"
+	httpHelper "core-api/pkg/util/http"
+	"core-api/pkg/util/listing"
 )
 
 var userProvider auth.IUserProvider
@@ -17,25 +42,1110 @@ var coreApiFullPathPrefix = fmt.Sprintf("%s/%s/%s", corePrefix, coreGroup, coreA
 
 func initOrGetUserProvider(serverConfig *config.Config) (auth.IUserProvider, error) {
 	if userProvider == nil {
-		userProvider, _ = auth.CreateUserProvider(serverConfig, auth.KeystoneAuthProvider)
+		provider, err := auth.CreateUserProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		userProvider = provider
 	}
 	return userProvider, nil
 }
 
 func initOrGetGroupProvider(serverConfig *config.Config) (auth.IGroupProvider, error) {
 	if groupProvider == nil {
-		groupProvider, _ = auth.CreateGroupProvider(serverConfig, auth.KeystoneAuthProvider)
+		provider, err := auth.CreateGroupProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		groupProvider = provider
 	}
 	return groupProvider, nil
 }
 
 func initOrGetRoleProvider(serverConfig *config.Config) (auth.IRoleProvider, error) {
 	if roleProvider == nil {
-		roleProvider, _ = auth.CreateRoleProvider(serverConfig, auth.KeystoneAuthProvider)
+		provider, err := auth.CreateRoleProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		roleProvider = provider
 	}
 	return roleProvider, nil
 }
 
+// issueSessionToken issues a session token for user and registers the session
+func issueSessionToken(serverConfig *config.Config, r *http.Request, user *coreUserV1.CoreUser) (*coreUserV1.CoreUserToken, error) {
+	tokenIssuer, err := authToken.InitOrGetTokenIssuer(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionStore, err := session.InitOrGetSessionStore(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	token, claims, err := tokenIssuer.Issue(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newSession := session.SessionFromClaims(claims)
+	newSession.ClientIp = lockout.ClientIp(r)
+	newSession.UserAgent = r.UserAgent()
+	err = sessionStore.Add(newSession)
+	if err != nil {
+		return nil, err
+	}
+
+	return &coreUserV1.CoreUserToken{
+		CoreUser:  *user,
+		Token:     token,
+		ExpiresAt: claims.ExpiresAt,
+	}, nil
+}
+
+// getRequestSessionClaims returns claims of session token carried by request
+// claims is put into context by auth middleware, token is validated here if auth is disabled
+func getRequestSessionClaims(serverConfig *config.Config, r *http.Request) (*authToken.Claims, error) {
+	if claims, ok := r.Context().Value("core-session").(*authToken.Claims); ok {
+		return claims, nil
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !authToken.IsSignedToken(token) {
+		return nil, fmt.Errorf("no session token found in request")
+	}
+
+	tokenIssuer, err := authToken.InitOrGetTokenIssuer(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	return tokenIssuer.Validate(token)
+}
+
+// revokeUserSessions revokes all sessions of user and forgets credential verified before, failure is logged only
+func revokeUserSessions(serverConfig *config.Config, userId string) {
+	credential.InitOrGetCache().RemoveUser(userId)
+
+	sessionStore, err := session.InitOrGetSessionStore(serverConfig)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create session store", "error", err)
+		return
+	}
+
+	count, err := sessionStore.RevokeUserSessions(userId)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to revoke user sessions", "user", userId, "error", err)
+		return
+	}
+	coreApiLog.Logger.Info("user sessions revoked", "user", userId, "total", count)
+}
+
+// getRequestUserId returns id of user who sends request
+// user is put into context by auth middleware, session token is read if auth is disabled
+func getRequestUserId(serverConfig *config.Config, r *http.Request) (string, error) {
+	if user, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser); ok {
+		return user.Id, nil
+	}
+
+	claims, err := getRequestSessionClaims(serverConfig, r)
+	if err != nil {
+		return "", err
+	}
+	return claims.UserId, nil
+}
+
+// getRequestUser returns user who sends request with permission loaded from provider
+// error is written to response if it fails
+func getRequestUser(serverConfig *config.Config, w http.ResponseWriter, r *http.Request) (*coreUserV1.CoreUser, bool) {
+	userId, err := getRequestUserId(serverConfig, r)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+		return nil, false
+	}
+
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		return nil, false
+	}
+
+	user, err := userProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "User of request not found", http.StatusUnauthorized, "", err)
+			return nil, false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+		return nil, false
+	}
+	return user, true
+}
+
+// writeIfAccessTokenRequest writes 403 and returns true if request is made with a personal access token
+// a token is not allowed to manage credentials, otherwise a narrowly scoped token could mint a broader one
+func writeIfAccessTokenRequest(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := r.Context().Value("core-access-token").(*coreUserV1.CorePersonalAccessToken); !ok {
+		return false
+	}
+	httpHelper.WriteCustomErrorAndLog(w, "Personal access token can not be used to manage credentials", http.StatusForbidden, "", fmt.Errorf("request made with personal access token"))
+	return true
+}
+
+// writeIfImpersonatedRequest writes 403 and returns true if request is made on behalf of another user
+// credentials of a user can only be managed by that user
+func writeIfImpersonatedRequest(w http.ResponseWriter, r *http.Request) bool {
+	impersonator := impersonation.ImpersonatorFromRequest(r)
+	if impersonator == nil {
+		return false
+	}
+	httpHelper.WriteCustomErrorAndLog(w, "Impersonated request can not be used to manage credentials", http.StatusForbidden, "", fmt.Errorf("request impersonated by user '%s'", impersonator.Name))
+	return true
+}
+
+// revokeUserAccessTokens deletes all personal access tokens of user, failure is logged only
+func revokeUserAccessTokens(serverConfig *config.Config, userId string) {
+	store, err := accesstoken.InitOrGetAccessTokenStore(serverConfig)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to create personal access token store", "error", err)
+		return
+	}
+
+	count, err := store.RevokeUser(userId)
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to revoke personal access tokens", "user", userId, "error", err)
+		return
+	}
+	coreApiLog.Logger.Info("personal access tokens revoked", "user", userId, "total", count)
+}
+
+// checkNewPassword returns a *passwordPolicy.Violation if password breaks policy or has been used by user before
+// userId is empty for a user that is not created yet
+func checkNewPassword(serverConfig *config.Config, userId, userName, password string) error {
+	policy, err := passwordPolicy.InitOrGetPasswordPolicy(serverConfig)
+	if err != nil {
+		return err
+	}
+
+	if err = policy.Validate(userName, password); err != nil {
+		return err
+	}
+
+	if userId == "" {
+		return nil
+	}
+
+	history, err := passwordPolicy.InitOrGetPasswordHistory(serverConfig)
+	if err != nil {
+		return err
+	}
+
+	reused, err := history.Reused(userId, password)
+	if err != nil {
+		return err
+	}
+	if reused {
+		return &passwordPolicy.Violation{Reasons: []string{"password has been used before"}}
+	}
+	return nil
+}
+
+// writeNewPasswordError writes 400 if password breaks policy, otherwise 500
+func writeNewPasswordError(w http.ResponseWriter, err error) {
+	if passwordPolicy.IsViolation(err) {
+		httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+		return
+	}
+	httpHelper.WriteCustomErrorAndLog(w, "Failed to check password policy", http.StatusInternalServerError, "", err)
+}
+
+// rememberPassword adds password to history of user, failure is logged only
+func rememberPassword(serverConfig *config.Config, userId, password string) {
+	history, err := passwordPolicy.InitOrGetPasswordHistory(serverConfig)
+	if err == nil {
+		err = history.Remember(userId, password)
+	}
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to remember password", "user", userId, "error", err)
+	}
+}
+
+// forgetPasswords drops password history of a deleted user, failure is logged only
+func forgetPasswords(serverConfig *config.Config, userId string) {
+	history, err := passwordPolicy.InitOrGetPasswordHistory(serverConfig)
+	if err == nil {
+		err = history.Forget(userId)
+	}
+	if err != nil {
+		coreApiLog.Logger.Error("Failed to forget password history", "user", userId, "error", err)
+	}
+}
+
+// publishChange tells auth middleware that an identity object is changed, so its user cache is updated at once
+func publishChange(kind event.Kind, action event.Action, id, name string) {
+	event.InitOrGetEventBus().Publish(event.Event{Kind: kind, Action: action, Id: id, Name: name})
+}
+
+// writeIfOtherTenant writes 404 and returns true if object is of a tenant other than tenant of request
+// object of another tenant is reported as not found so its existence is not leaked
+func writeIfOtherTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, kind, id, objectTenant string) bool {
+	if tenant.Visible(tenant.InitOrGetTenantResolver(serverConfig), tenant.FromRequest(r), objectTenant) {
+		return false
+	}
+	httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("%s not found", kind), http.StatusNotFound, "", fmt.Errorf("%s '%s' of tenant '%s' is not visible to tenant '%s'", kind, id, objectTenant, tenant.FromRequest(r)))
+	return true
+}
+
+// checkTenant loads tenant of object and writes error if it can not be seen by request, nothing is loaded if tenant is disabled
+func checkTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, kind, id string, tenantOf func(id string) (string, error)) bool {
+	if tenant.FromRequest(r) == "" {
+		return true
+	}
+
+	objectTenant, err := tenantOf(id)
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("%s not found", kind), http.StatusNotFound, "", err)
+			return false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("Failed to get %s", kind), http.StatusInternalServerError, "", err)
+		return false
+	}
+	return !writeIfOtherTenant(serverConfig, w, r, kind, id, objectTenant)
+}
+
+func checkUserTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, userId string) bool {
+	return checkTenant(serverConfig, w, r, "user", userId, func(id string) (string, error) {
+		userProvider, err := initOrGetUserProvider(serverConfig)
+		if err != nil {
+			return "", err
+		}
+		user, err := userProvider.GetUser(id, nil)
+		if err != nil {
+			return "", err
+		}
+		return user.TenantId, nil
+	})
+}
+
+func checkGroupTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, groupId string) bool {
+	return checkTenant(serverConfig, w, r, "group", groupId, func(id string) (string, error) {
+		groupProvider, err := initOrGetGroupProvider(serverConfig)
+		if err != nil {
+			return "", err
+		}
+		group, err := groupProvider.GetGroup(id, nil)
+		if err != nil {
+			return "", err
+		}
+		return group.TenantId, nil
+	})
+}
+
+func checkRoleTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, roleId string) bool {
+	return checkTenant(serverConfig, w, r, "role", roleId, func(id string) (string, error) {
+		roleProvider, err := initOrGetRoleProvider(serverConfig)
+		if err != nil {
+			return "", err
+		}
+		role, err := roleProvider.GetRole(id, nil)
+		if err != nil {
+			return "", err
+		}
+		return role.TenantId, nil
+	})
+}
+
+// checkBindingTenant makes sure roles and groups bound to a user are of tenant of request
+func checkBindingTenant(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, roles []coreUserV1.CoreRole, groups []coreUserV1.CoreGroup) bool {
+	for _, role := range roles {
+		if !checkRoleTenant(serverConfig, w, r, role.Id) {
+			return false
+		}
+	}
+	for _, group := range groups {
+		if !checkGroupTenant(serverConfig, w, r, group.Id) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterTenant keeps objects of tenant of request
+func filterTenant[T any](serverConfig *config.Config, r *http.Request, items []T, tenantOf func(T) string) []T {
+	return tenant.Filter(tenant.InitOrGetTenantResolver(serverConfig), tenant.FromRequest(r), items, tenantOf)
+}
+
+// importUsers creates users read from uploaded file together with their missing roles and groups
+// a row failing does not stop the others, nothing is written if it is a dry run
+func importUsers(serverConfig *config.Config, r *http.Request, records []userimport.Record, dryRun bool) (*coreUserV1.CoreUserImportResult, error) {
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	roleProvider, err := initOrGetRoleProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	groupProvider, err := initOrGetGroupProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// disabled users are listed as well, their names are taken
+	allUsers, err := userProvider.GetUsers(map[string]struct{}{keystone.IncludeDisabledUsers: {}})
+	if err != nil {
+		return nil, err
+	}
+	allRoles, err := roleProvider.GetRoles(nil)
+	if err != nil {
+		return nil, err
+	}
+	allGroups, err := groupProvider.GetGroups(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// names are only unique inside a tenant, resources of other tenants are invisible to upload
+	tenantId := tenant.FromRequest(r)
+	allUsers = filterTenant(serverConfig, r, allUsers, func(user coreUserV1.CoreUser) string { return user.TenantId })
+	allRoles = filterTenant(serverConfig, r, allRoles, func(role coreUserV1.CoreRole) string { return role.TenantId })
+	allGroups = filterTenant(serverConfig, r, allGroups, func(group coreUserV1.CoreGroup) string { return group.TenantId })
+
+	existingUsers := make(map[string]struct{}, len(allUsers))
+	for _, user := range allUsers {
+		existingUsers[user.Name] = struct{}{}
+	}
+	roleIds := make(map[string]string, len(allRoles))
+	for _, role := range allRoles {
+		roleIds[role.Name] = role.Id
+	}
+	groupIds := make(map[string]string, len(allGroups))
+	for _, group := range allGroups {
+		groupIds[group.Name] = group.Id
+	}
+
+	result := &coreUserV1.CoreUserImportResult{DryRun: dryRun, Rows: []coreUserV1.CoreUserImportRow{}}
+	report := func(record userimport.Record, status, reason string) {
+		result.Rows = append(result.Rows, coreUserV1.CoreUserImportRow{Row: record.Row, Name: record.Name, Status: status, Reason: reason})
+		switch status {
+		case userimport.StatusCreated:
+			result.Created++
+		case userimport.StatusSkipped:
+			result.Skipped++
+		default:
+			result.Failed++
+		}
+	}
+
+	rowsOfName := map[string]int{}
+	for _, record := range records {
+		if problems := record.Problems(); len(problems) > 0 {
+			report(record, userimport.StatusFailed, strings.Join(problems, ", "))
+			continue
+		}
+
+		// data security check
+		// do not create a user if user set role to aes-admin
+		roleNames, groupNames := userimport.Names(record.Role), userimport.Names(record.Group)
+		if slices.Contains(roleNames, "aes-admin") {
+			report(record, userimport.StatusSkipped, "role aes-admin can not be assigned by upload")
+			continue
+		}
+
+		if row, found := rowsOfName[record.Name]; found {
+			report(record, userimport.StatusSkipped, fmt.Sprintf("user is given in row %d already", row))
+			continue
+		}
+		rowsOfName[record.Name] = record.Row
+
+		if _, found := existingUsers[record.Name]; found {
+			report(record, userimport.StatusSkipped, "user already exists")
+			continue
+		}
+
+		if err := checkNewPassword(serverConfig, "", record.Name, record.Password); err != nil {
+			if !passwordPolicy.IsViolation(err) {
+				return nil, err
+			}
+			report(record, userimport.StatusFailed, err.Error())
+			continue
+		}
+
+		groups, err := importGroups(serverConfig, groupProvider, groupNames, groupIds, tenantId, dryRun, result)
+		if err != nil {
+			report(record, userimport.StatusFailed, err.Error())
+			continue
+		}
+		roles, err := importRoles(serverConfig, roleProvider, roleNames, roleIds, tenantId, dryRun, result)
+		if err != nil {
+			report(record, userimport.StatusFailed, err.Error())
+			continue
+		}
+
+		if dryRun {
+			report(record, userimport.StatusCreated, "")
+			continue
+		}
+
+		// password from file is known to whoever prepared it, so user has to change it on first login
+		created, err := userProvider.CreateUser(&coreUserV1.CoreUser{
+			Name:               record.Name,
+			Password:           record.Password,
+			Email:              record.Email,
+			Description:        record.Description,
+			MustChangePassword: true,
+			TenantId:           tenantId,
+			Roles:              roles,
+			Groups:             groups,
+		}, nil)
+		if err != nil {
+			coreApiLog.Logger.Error(fmt.Sprintf("Failed to create user %s", record.Name), "error", err)
+			report(record, userimport.StatusFailed, fmt.Sprintf("failed to create user %s", record.Name))
+			continue
+		}
+		rememberPassword(serverConfig, created.Id, record.Password)
+		publishChange(event.UserKind, event.Created, created.Id, created.Name)
+		existingUsers[record.Name] = struct{}{}
+		report(record, userimport.StatusCreated, "")
+	}
+
+	coreApiLog.Logger.Info("users imported", "dryRun", dryRun, "created", result.Created, "skipped", result.Skipped, "failed", result.Failed)
+	return result, nil
+}
+
+// importGroups looks up groups of an imported user by name and creates missing ones, ids are kept in groupIds
+func importGroups(serverConfig *config.Config, groupProvider auth.IGroupProvider, names []string, groupIds map[string]string, tenantId string, dryRun bool, result *coreUserV1.CoreUserImportResult) ([]coreUserV1.CoreGroup, error) {
+	var groups []coreUserV1.CoreGroup
+	for _, name := range names {
+		id, found := groupIds[name]
+		if !found {
+			if !dryRun {
+				group, err := groupProvider.CreateGroup(&coreUserV1.CoreGroup{
+					Name:        name,
+					TenantId:    tenantId,
+					Description: fmt.Sprintf("Group created by %s at %s", "upload api", time.Now().Format("2006-01-02 15:04:05")),
+				}, nil)
+				if err != nil {
+					coreApiLog.Logger.Error(fmt.Sprintf("Failed to create group %s", name), "error", err)
+					return nil, fmt.Errorf("failed to create group %s", name)
+				}
+				publishChange(event.GroupKind, event.Created, group.Id, group.Name)
+				id = group.Id
+			}
+			groupIds[name] = id
+			result.CreatedGroups = append(result.CreatedGroups, name)
+		}
+		groups = append(groups, coreUserV1.CoreGroup{Id: id})
+	}
+	return groups, nil
+}
+
+// importRoles looks up roles of an imported user by name and creates missing ones with role template, ids are kept in roleIds
+func importRoles(serverConfig *config.Config, roleProvider auth.IRoleProvider, names []string, roleIds map[string]string, tenantId string, dryRun bool, result *coreUserV1.CoreUserImportResult) ([]coreUserV1.CoreRole, error) {
+	var roles []coreUserV1.CoreRole
+	for _, name := range names {
+		id, found := roleIds[name]
+		if !found {
+			if !dryRun {
+				role, err := roleProvider.CreateRole(&coreUserV1.CoreRole{
+					Name:        name,
+					TenantId:    tenantId,
+					Description: fmt.Sprintf("Role created by %s at %s", "upload api", time.Now().Format("2006-01-02 15:04:05")),
+					Permission:  userimport.RolePermission(serverConfig),
+				}, nil)
+				if err != nil {
+					coreApiLog.Logger.Error(fmt.Sprintf("Failed to create role %s", name), "error", err)
+					return nil, fmt.Errorf("failed to create role %s", name)
+				}
+				publishChange(event.RoleKind, event.Created, role.Id, role.Name)
+				id = role.Id
+			}
+			roleIds[name] = id
+			result.CreatedRoles = append(result.CreatedRoles, name)
+		}
+		roles = append(roles, coreUserV1.CoreRole{Id: id})
+	}
+	return roles, nil
+}
+
+// getExportFormat reads format of exported file from query, it is csv if it is not given
+// 400 is written if format is unknown
+func getExportFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = userimport.FormatCSV
+	}
+	if _, err := userimport.ContentType(format); err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+		return "", false
+	}
+	return format, true
+}
+
+// writeExport writes rows as an attachment named after what is exported and today
+func writeExport(w http.ResponseWriter, format, name string, rows [][]string) {
+	buffer := &bytes.Buffer{}
+	if err := userimport.Write(buffer, format, name, rows); err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to write exported file", http.StatusInternalServerError, "", err)
+		return
+	}
+
+	// format is checked before anything is loaded
+	contentType, _ := userimport.ContentType(format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("20060102"), format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
+}
+
+// getRoleAndGroupNames maps id of every role and group to its name
+func getRoleAndGroupNames(serverConfig *config.Config) (map[string]string, map[string]string, error) {
+	roleProvider, err := initOrGetRoleProvider(serverConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupProvider, err := initOrGetGroupProvider(serverConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roles, err := roleProvider.GetRoles(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := groupProvider.GetGroups(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roleNames := make(map[string]string, len(roles))
+	for _, role := range roles {
+		roleNames[role.Id] = role.Name
+	}
+	groupNames := make(map[string]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.Id] = group.Name
+	}
+	return roleNames, groupNames, nil
+}
+
+func groupIdsOf(user coreUserV1.CoreUser) []string {
+	var ids []string
+	for _, group := range user.Groups {
+		ids = append(ids, group.Id)
+	}
+	return ids
+}
+
+func roleIdsOf(user coreUserV1.CoreUser) []string {
+	var ids []string
+	for _, role := range user.Roles {
+		ids = append(ids, role.Id)
+	}
+	return ids
+}
+
+// filterUsersByBinding keeps users bound to any of ids, e.g. ids of groups
+func filterUsersByBinding(users []coreUserV1.CoreUser, ids []string, idsOf func(coreUserV1.CoreUser) []string) []coreUserV1.CoreUser {
+	var result []coreUserV1.CoreUser
+	for _, user := range users {
+		for _, id := range idsOf(user) {
+			if slices.Contains(ids, id) {
+				result = append(result, user)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// operations of user batch
+const (
+	userBatchDelete       = "delete"
+	userBatchSetRoles     = "setRoles"
+	userBatchAddGroups    = "addGroups"
+	userBatchRemoveGroups = "removeGroups"
+	userBatchDisable      = "disable"
+	userBatchEnable       = "enable"
+)
+
+const (
+	// most users a batch can change
+	maxUserBatchSize = 500
+	// calls to identity provider running at the same time for a batch
+	userBatchConcurrency = 8
+)
+
+// userBatch is a checked batch, roles and groups are resolved once from a single snapshot
+type userBatch struct {
+	operation     string
+	userIds       []string
+	roles         []coreUserV1.CoreRole
+	groups        []coreUserV1.CoreGroup
+	requestUserId string
+}
+
+// newUserBatch checks posted batch, returned status tells whether error is caused by request or by server
+func newUserBatch(serverConfig *config.Config, r *http.Request, post *coreUserV1.CoreUserBatch) (*userBatch, int, error) {
+	batch := &userBatch{operation: post.Operation}
+
+	seen := map[string]struct{}{}
+	for _, userId := range post.UserIds {
+		if _, found := seen[userId]; found || userId == "" {
+			continue
+		}
+		seen[userId] = struct{}{}
+		batch.userIds = append(batch.userIds, userId)
+	}
+	if len(batch.userIds) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("userIds is empty")
+	}
+	if len(batch.userIds) > maxUserBatchSize {
+		return nil, http.StatusBadRequest, fmt.Errorf("batch can change at most %d users, got %d", maxUserBatchSize, len(batch.userIds))
+	}
+
+	switch post.Operation {
+	case userBatchDelete, userBatchDisable, userBatchEnable:
+	case userBatchSetRoles:
+		if len(post.RoleIds) == 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("roleIds is empty")
+		}
+		roleProvider, err := initOrGetRoleProvider(serverConfig)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		roles, err := roleProvider.GetRoles(nil)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		roles = filterTenant(serverConfig, r, roles, func(role coreUserV1.CoreRole) string { return role.TenantId })
+		for _, roleId := range post.RoleIds {
+			index := slices.IndexFunc(roles, func(role coreUserV1.CoreRole) bool { return role.Id == roleId })
+			if index < 0 {
+				return nil, http.StatusBadRequest, fmt.Errorf("role %s not found", roleId)
+			}
+			batch.roles = append(batch.roles, coreUserV1.CoreRole{Id: roles[index].Id, Name: roles[index].Name})
+		}
+	case userBatchAddGroups, userBatchRemoveGroups:
+		if len(post.GroupIds) == 0 {
+			return nil, http.StatusBadRequest, fmt.Errorf("groupIds is empty")
+		}
+		// ldap provider leaves groups out of user update
+		if auth.GetAuthProviderType(serverConfig) == auth.LdapAuthProvider {
+			return nil, http.StatusBadRequest, fmt.Errorf("membership of groups is managed by ldap directory")
+		}
+		groupProvider, err := initOrGetGroupProvider(serverConfig)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		groups, err := groupProvider.GetGroups(nil)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		groups = filterTenant(serverConfig, r, groups, func(group coreUserV1.CoreGroup) string { return group.TenantId })
+		for _, groupId := range post.GroupIds {
+			index := slices.IndexFunc(groups, func(group coreUserV1.CoreGroup) bool { return group.Id == groupId })
+			if index < 0 {
+				return nil, http.StatusBadRequest, fmt.Errorf("group %s not found", groupId)
+			}
+			batch.groups = append(batch.groups, coreUserV1.CoreGroup{Id: groups[index].Id, Name: groups[index].Name})
+		}
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid operation %s expected to be one of %s", post.Operation, strings.Join([]string{userBatchDelete, userBatchSetRoles, userBatchAddGroups, userBatchRemoveGroups, userBatchDisable, userBatchEnable}, ", "))
+	}
+
+	requestUserId, err := getRequestUserId(serverConfig, r)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+	batch.requestUserId = requestUserId
+	return batch, http.StatusOK, nil
+}
+
+// run applies batch to every user with bounded concurrency, items of result keep order of user ids
+func (b *userBatch) run(serverConfig *config.Config, r *http.Request) (*coreUserV1.CoreUserBatchResult, error) {
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &coreUserV1.CoreUserBatchResult{Operation: b.operation, Items: make([]coreUserV1.CoreUserBatchItem, len(b.userIds))}
+	utilCommon.ForEachBounded(len(b.userIds), userBatchConcurrency, func(index int) {
+		userId := b.userIds[index]
+		item := coreUserV1.CoreUserBatchItem{UserId: userId, Status: "succeeded"}
+		name, err := b.apply(serverConfig, r, userProvider, userId)
+		item.Name = name
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to apply batch to user", "operation", b.operation, "user", userId, "error", err)
+			item.Status = "failed"
+			item.Reason = err.Error()
+		}
+		result.Items[index] = item
+	})
+
+	var succeeded []string
+	for _, item := range result.Items {
+		if item.Status == "failed" {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+		succeeded = append(succeeded, item.Name)
+	}
+
+	coreApiLog.Logger.Warn("audit: user batch applied", "operation", b.operation, "by", b.requestUserId, "succeeded", result.Succeeded, "failed", result.Failed, "users", succeeded)
+	return result, nil
+}
+
+// apply changes a single user, name of user is returned once it is found
+func (b *userBatch) apply(serverConfig *config.Config, r *http.Request, userProvider auth.IUserProvider, userId string) (string, error) {
+	if userId == b.requestUserId && (b.operation == userBatchDelete || b.operation == userBatchDisable) {
+		return "", fmt.Errorf("operation %s can not be applied to yourself", b.operation)
+	}
+
+	user, err := userProvider.GetUser(userId, nil)
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			return "", fmt.Errorf("user not found")
+		}
+		return "", err
+	}
+	// user of another tenant is reported as not found so its existence is not leaked
+	if !tenant.Visible(tenant.InitOrGetTenantResolver(serverConfig), tenant.FromRequest(r), user.TenantId) {
+		return "", fmt.Errorf("user not found")
+	}
+
+	switch b.operation {
+	case userBatchDelete:
+		if err = userProvider.DeleteUser(userId, nil); err != nil {
+			return user.Name, err
+		}
+		revokeUserSessions(serverConfig, userId)
+		revokeUserAccessTokens(serverConfig, userId)
+		forgetPasswords(serverConfig, userId)
+		publishChange(event.UserKind, event.Deleted, userId, "")
+		return user.Name, nil
+	case userBatchDisable, userBatchEnable:
+		disabled := b.operation == userBatchDisable
+		if err = auth.SetUserDisabled(userProvider, userId, disabled); err != nil {
+			return user.Name, err
+		}
+		if disabled {
+			revokeUserSessions(serverConfig, userId)
+		}
+	case userBatchSetRoles:
+		if err = userProvider.UpdateUser(&coreUserV1.CoreUser{Id: userId, Roles: b.roles}, nil); err != nil {
+			return user.Name, err
+		}
+	case userBatchAddGroups, userBatchRemoveGroups:
+		inBatch := func(groupId string) bool {
+			return slices.ContainsFunc(b.groups, func(group coreUserV1.CoreGroup) bool { return group.Id == groupId })
+		}
+		groups := []coreUserV1.CoreGroup{}
+		for _, group := range user.Groups {
+			if b.operation == userBatchRemoveGroups && inBatch(group.Id) {
+				continue
+			}
+			groups = append(groups, group)
+		}
+		if b.operation == userBatchAddGroups {
+			for _, group := range b.groups {
+				if !slices.ContainsFunc(user.Groups, func(userGroup coreUserV1.CoreGroup) bool { return userGroup.Id == group.Id }) {
+					groups = append(groups, group)
+				}
+			}
+		}
+		if len(groups) == len(user.Groups) {
+			// user is in or out of all groups already
+			return user.Name, nil
+		}
+		if err = userProvider.UpdateUser(&coreUserV1.CoreUser{Id: userId, Groups: groups}, nil); err != nil {
+			return user.Name, err
+		}
+	}
+	publishChange(event.UserKind, event.Updated, userId, user.Name)
+	return user.Name, nil
+}
+
+// writeIfNoPermission writes 403 and returns true if user of request lacks permission
+// it is for handlers whose required permission depends on request body, nothing is checked if auth is disabled
+func writeIfNoPermission(w http.ResponseWriter, r *http.Request, module string, permission uint64) bool {
+	user, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser)
+	if !ok {
+		return false
+	}
+	canAccess, err := (&privileges.DefaultPrivilegeProvider{}).CanAccess(user.Permission, module, permission)
+	if err == nil && canAccess {
+		return false
+	}
+	httpHelper.WriteCustomErrorAndLog(w, "Permission denied", http.StatusForbidden, "", fmt.Errorf("user '%s' lacks permission %d of module '%s'", user.Name, permission, module))
+	return true
+}
+
+// hasPermission tells whether user of request holds permission of module, it is true if auth is disabled
+func hasPermission(r *http.Request, module string, permission uint64) bool {
+	user, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser)
+	if !ok {
+		return true
+	}
+	canAccess, err := (&privileges.DefaultPrivilegeProvider{}).CanAccess(user.Permission, module, permission)
+	return err == nil && canAccess
+}
+
+// writeIfNotGroupAdmin writes 403 and returns true unless user of request holds permission or may administer member
+// as administrator of a group member belongs to, see groupadmin.CanAdminister
+// it is for routes open to group administrators, auth middleware lets any of them through without permission
+func writeIfNotGroupAdmin(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, module string, permission uint64, member *coreUserV1.CoreUser) bool {
+	if hasPermission(r, module, permission) {
+		return false
+	}
+	return !checkGroupAdminOf(serverConfig, w, r, module, permission, member.Id)
+}
+
+// checkGroupAdminOf works like writeIfNotGroupAdmin for handlers that do not load user in path
+// user is loaded with permission only if user of request lacks permission, false is returned if error is written to response
+func checkGroupAdminOf(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, module string, permission uint64, userId string) bool {
+	if hasPermission(r, module, permission) {
+		return true
+	}
+	user := r.Context().Value("core-user").(*coreUserV1.CoreUser)
+	store, err := groupadmin.InitOrGetGroupAdminStore(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get group admin store", http.StatusInternalServerError, "", err)
+		return false
+	}
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		return false
+	}
+	member, err := userProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+			return false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+		return false
+	}
+	if err := groupadmin.CanAdminister(store, user, member); err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Permission denied", http.StatusForbidden, "", fmt.Errorf("user '%s' lacks permission %d of module '%s': %w", user.Name, permission, module, err))
+		return false
+	}
+	coreApiLog.Logger.Warn("audit: group administrator acts on member", "admin", user.Name, "member", member.Name, "method", r.Method, "path", r.URL.Path)
+	return true
+}
+
+// administersGroups tells whether user is administrator of any group
+func administersGroups(serverConfig *config.Config, userId string) (bool, error) {
+	store, err := groupadmin.InitOrGetGroupAdminStore(serverConfig)
+	if err != nil {
+		return false, err
+	}
+	return len(store.GroupsOf(userId)) > 0, nil
+}
+
+// getReviewedUser loads user whose access is reviewed with permission and roles, user of request is reviewed if userId is blank
+// reviewing another user requires listing users and roles, error is written to response if it fails
+func getReviewedUser(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, userId string) (*coreUserV1.CoreUser, []coreUserV1.CoreRole, bool) {
+	requestUserId, err := getRequestUserId(serverConfig, r)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+		return nil, nil, false
+	}
+	if userId == "" {
+		userId = requestUserId
+	}
+	if userId != requestUserId {
+		if writeIfNoPermission(w, r, "user", privileges.PermissionUserList) || writeIfNoPermission(w, r, "role", privileges.PermissionRoleList) {
+			return nil, nil, false
+		}
+		if !checkUserTenant(serverConfig, w, r, userId) {
+			return nil, nil, false
+		}
+	}
+
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+	user, err := userProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+			return nil, nil, false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+
+	roleProvider, err := initOrGetRoleProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create role provider", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+	allRoles, err := roleProvider.GetRoles(nil)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get roles", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+	rolesById := make(map[string]coreUserV1.CoreRole, len(allRoles))
+	for _, role := range allRoles {
+		rolesById[role.Id] = role
+	}
+	var roles []coreUserV1.CoreRole
+	for _, role := range user.Roles {
+		if found, ok := rolesById[role.Id]; ok {
+			roles = append(roles, found)
+		}
+	}
+	return user, roles, true
+}
+
+// resolveRolePermission merges permission names of role into its bits and checks every module and bit is known
+// names are dropped afterwards so only bits are stored
+func resolveRolePermission(role *coreUserV1.CoreRole) error {
+	if len(role.PermissionNames) > 0 && role.Permission == nil {
+		role.Permission = map[string]uint64{}
+	}
+	for module, names := range role.PermissionNames {
+		bits, err := privileges.Bitmask(module, names)
+		if err != nil {
+			return err
+		}
+		role.Permission[module] |= bits
+	}
+	role.PermissionNames = nil
+	return privileges.ValidatePermission(role.Permission)
+}
+
+// checkShareManager writes error and returns false unless user may manage shares of resource, owner of resource is returned
+// owner, user resource is shared with at manage access and user allowed to manage resources of other users qualify
+func checkShareManager(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, store share.IShareStore, user *coreUserV1.CoreUser, kind, resourceId string) (string, bool) {
+	ownerId, err := share.ResourceOwner(kind, resourceId)
+	if err != nil {
+		if share.IsInvalid(err) {
+			httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+			return "", false
+		}
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "Resource not found", http.StatusNotFound, "", err)
+			return "", false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get owner of resource", http.StatusInternalServerError, "", err)
+		return "", false
+	}
+	// build-in resources belong to no user and no tenant
+	if ownerId != "build-in" && !checkUserTenant(serverConfig, w, r, ownerId) {
+		return "", false
+	}
+
+	if ownerId == user.Id || store.Access(kind, resourceId, user.Id, share.GroupIds(user)) == share.AccessManage {
+		return ownerId, true
+	}
+	module, permission, err := share.ManageOtherPermission(kind)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+		return "", false
+	}
+	if writeIfNoPermission(w, r, module, permission) {
+		return "", false
+	}
+	return ownerId, true
+}
+
+// checkShareSubject writes error and returns false if user or group a resource is shared with does not exist or is of another tenant
+// unknown subject kind is left to share store to report
+func checkShareSubject(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, subjectKind, subjectId string) bool {
+	var err error
+	switch subjectKind {
+	case share.SubjectUser:
+		var provider auth.IUserProvider
+		if provider, err = initOrGetUserProvider(serverConfig); err == nil {
+			_, err = provider.GetUser(subjectId, nil)
+		}
+	case share.SubjectGroup:
+		var provider auth.IGroupProvider
+		if provider, err = initOrGetGroupProvider(serverConfig); err == nil {
+			_, err = provider.GetGroup(subjectId, nil)
+		}
+	default:
+		return true
+	}
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("%s to share with not found", subjectKind), http.StatusBadRequest, "", err)
+			return false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("Failed to get %s", subjectKind), http.StatusInternalServerError, "", err)
+		return false
+	}
+
+	if subjectKind == share.SubjectUser {
+		return checkUserTenant(serverConfig, w, r, subjectId)
+	}
+	return checkGroupTenant(serverConfig, w, r, subjectId)
+}
+
+var userListFields = listing.Fields[coreUserV1.CoreUser]{
+	Search: func(user coreUserV1.CoreUser) []string { return []string{user.Name, user.Description, user.Email} },
+	Sort: map[string]func(coreUserV1.CoreUser) string{
+		"name":        func(user coreUserV1.CoreUser) string { return user.Name },
+		"email":       func(user coreUserV1.CoreUser) string { return user.Email },
+		"description": func(user coreUserV1.CoreUser) string { return user.Description },
+	},
+	DefaultSort: "name",
+}
+
+var groupListFields = listing.Fields[coreUserV1.CoreGroup]{
+	Search: func(group coreUserV1.CoreGroup) []string { return []string{group.Name, group.Description} },
+	Sort: map[string]func(coreUserV1.CoreGroup) string{
+		"name":        func(group coreUserV1.CoreGroup) string { return group.Name },
+		"description": func(group coreUserV1.CoreGroup) string { return group.Description },
+	},
+	DefaultSort: "name",
+}
+
+var roleListFields = listing.Fields[coreUserV1.CoreRole]{
+	Search: func(role coreUserV1.CoreRole) []string { return []string{role.Name, role.Description} },
+	Sort: map[string]func(coreUserV1.CoreRole) string{
+		"name":        func(role coreUserV1.CoreRole) string { return role.Name },
+		"description": func(role coreUserV1.CoreRole) string { return role.Description },
+	},
+	DefaultSort: "name",
+}
+
+// writeListPage searches, sorts and pages items as asked by query string, count of matched items is written in header
+// it is applied after items are loaded from provider, so every provider behaves the same
+func writeListPage[T any](w http.ResponseWriter, r *http.Request, items []T, fields listing.Fields[T]) {
+	query, err := listing.Parse(r.URL.Query())
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+		return
+	}
+
+	page, total, err := listing.Apply(items, query, fields)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+		return
+	}
+
+	w.Header().Set(listing.TotalCountHeader, strconv.Itoa(total))
+	httpHelper.WriteResponseEntity(w, page)
+}
+
+// writeLoginBlocked writes 429 with Retry-After header if err is returned by login limiter
+func writeLoginBlocked(w http.ResponseWriter, err error) {
+	blocked := &lockout.Blocked{}
+	if errors.As(err, &blocked) {
+		w.Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+	}
+	httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusTooManyRequests, "", err)
+}
+
 const (
 	corePrefix     = "/apis"
 	coreAPIVersion = "v1"
@@ -54,6 +1164,7 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 				ModuleAndPermission: ModuleAndPermission{
 					Module:     "user",
 					Permission: privileges.PermissionUserList,
+					GroupAdmin: true,
 				},
 			},
 			{
@@ -63,6 +1174,7 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 				ModuleAndPermission: ModuleAndPermission{
 					Module:     "user",
 					Permission: privileges.PermissionUserList,
+					GroupAdmin: true,
 				},
 			},
 			{
@@ -74,6 +1186,199 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 					Permission: 0,
 				},
 			},
+			// single sign-on with openid connect
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/login/oidc",
+				Handler: CreateOIDCLoginHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/login/oidc/callback",
+				Handler: CreateOIDCCallbackHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/login/refresh",
+				Handler: CreateRefreshTokenHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/logout",
+				Handler: CreateLogoutHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// list and revoke sessions of user
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/{userId}/sessions",
+				Handler: CreateGetUserSessionsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserList,
+					GroupAdmin: true,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/users/{userId}/sessions",
+				Handler: CreateDeleteUserSessionsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/users/{userId}/sessions/{sessionId}",
+				Handler: CreateDeleteUserSessionHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
+				},
+			},
+			// current user
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/me",
+				Handler: CreateGetMeHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodPatch,
+				Pattern: "/users/me",
+				Handler: CreatePatchMeHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/me/permissions",
+				Handler: CreateGetMyPermissionsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// change password of current user, a user who must change password can only do this
+			{
+				Method:  http.MethodPut,
+				Pattern: "/users/me/password",
+				Handler: CreateChangeMyPasswordHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// personal access tokens of current user
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/me/tokens",
+				Handler: CreateGetMyAccessTokensHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/me/tokens",
+				Handler: CreateCreateMyAccessTokenHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/users/me/tokens/{tokenId}",
+				Handler: CreateDeleteMyAccessTokenHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// tenants current user can act on
+			{
+				Method:  http.MethodGet,
+				Pattern: "/tenants",
+				Handler: CreateGetTenantsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// identities of single sign-on user is linked to
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/{userId}/oidc-links",
+				Handler: CreateGetOIDCLinksHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserList,
+				},
+			},
+			{
+				Method:  http.MethodPut,
+				Pattern: "/users/{userId}/oidc-links",
+				Handler: CreatePutOIDCLinkHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/users/{userId}/oidc-links",
+				Handler: CreateDeleteOIDCLinksHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+				},
+			},
+			// failed login state of user
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/{userId}/lockout",
+				Handler: CreateGetUserLockoutHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserList,
+					GroupAdmin: true,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/users/{userId}/lockout",
+				Handler: CreateDeleteUserLockoutHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
+				},
+			},
 			{
 				Method:  http.MethodPost,
 				Pattern: "/users",
@@ -101,9 +1406,51 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 				ModuleAndPermission: ModuleAndPermission{
 					Module:     "user",
 					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
+				},
+			},
+			// disabled user can not log in but keeps everything bound to it
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/{userId}/disable",
+				Handler: CreateDisableUserHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
+				},
+			},
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/{userId}/enable",
+				Handler: CreateEnableUserHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+					GroupAdmin: true,
 				},
 			},
-			// upload user from csv or txt
+			// apply one operation to many users
+			{
+				Method:  http.MethodPost,
+				Pattern: "/users/batch",
+				Handler: CreateUserBatchHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserUpdate,
+				},
+			},
+			// export users in layout of upload
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/export",
+				Handler: CreateExportUsersHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: privileges.PermissionUserList,
+				},
+			},
+			// upload user from csv, txt or xlsx
 			{
 				Method:  http.MethodPost,
 				Pattern: "/users/upload",
@@ -197,6 +1544,53 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 					Permission: privileges.PermissionGroupDelete,
 				},
 			},
+			// administrators of group manage its members without global permission
+			{
+				Method:  http.MethodGet,
+				Pattern: "/groups/{groupId}/admins",
+				Handler: CreateGetGroupAdminsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "group",
+					Permission: privileges.PermissionGroupList,
+				},
+			},
+			{
+				Method:  http.MethodPut,
+				Pattern: "/groups/{groupId}/admins/{userId}",
+				Handler: CreateAddGroupAdminHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "group",
+					Permission: privileges.PermissionGroupUpdate,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/groups/{groupId}/admins/{userId}",
+				Handler: CreateRemoveGroupAdminHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "group",
+					Permission: privileges.PermissionGroupUpdate,
+				},
+			},
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/me/administered-groups",
+				Handler: CreateGetAdministeredGroupsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// export members of groups
+			{
+				Method:  http.MethodGet,
+				Pattern: "/groups/export",
+				Handler: CreateExportGroupMembersHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "group",
+					Permission: privileges.PermissionGroupList,
+				},
+			},
 			// count users in each group
 			{
 				Method:  http.MethodGet,
@@ -220,6 +1614,72 @@ func GetCoreApiRoute(config *config.Config) *ChiRouteBuilder {
 					Permission: privileges.PermissionGroupList,
 				},
 			},
+			// tell whether a user may call a route, anyone can review own access
+			{
+				Method:  http.MethodPost,
+				Pattern: "/access-review",
+				Handler: CreateAccessReviewHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodGet,
+				Pattern: "/users/{userId}/access-matrix",
+				Handler: CreateGetAccessMatrixHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// shares of conversations, knowledge bases and devices, access is checked against resource by handlers
+			{
+				Method:  http.MethodGet,
+				Pattern: "/shares",
+				Handler: CreateGetSharesHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodGet,
+				Pattern: "/shares/granted",
+				Handler: CreateGetGrantedSharesHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodPost,
+				Pattern: "/shares",
+				Handler: CreateCreateShareHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			{
+				Method:  http.MethodDelete,
+				Pattern: "/shares/{shareId}",
+				Handler: CreateDeleteShareHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "user",
+					Permission: 0,
+				},
+			},
+			// permission catalogue for role editors
+			{
+				Method:  http.MethodGet,
+				Pattern: "/permissions",
+				Handler: CreateGetPermissionsHandler(config),
+				ModuleAndPermission: ModuleAndPermission{
+					Module:     "role",
+					Permission: 0,
+				},
+			},
 			// get role lists
 			{
 				Method:  http.MethodGet,
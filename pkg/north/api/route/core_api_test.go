@@ -0,0 +1,410 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	auth "core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("user batch test", func() {
+	var serverConfig *config.Config
+	var request *http.Request
+	var admin *coreUserV1.CoreUser
+	var students []*coreUserV1.CoreUser
+	var class1, class2 *coreUserV1.CoreGroup
+	var teacher *coreUserV1.CoreRole
+
+	run := func(post *coreUserV1.CoreUserBatch) *coreUserV1.CoreUserBatchResult {
+		batch, status, err := newUserBatch(serverConfig, request, post)
+		Expect(err).To(BeNil())
+		Expect(status).To(Equal(http.StatusOK))
+		result, err := batch.run(serverConfig, request)
+		Expect(err).To(BeNil())
+		return result
+	}
+
+	getUser := func(id string) *coreUserV1.CoreUser {
+		user, err := userProvider.GetUser(id, nil)
+		Expect(err).To(BeNil())
+		return user
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		groups, err := initOrGetGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+		roles, err := initOrGetRoleProvider(serverConfig)
+		Expect(err).To(BeNil())
+
+		admin, err = users.SearchUserByName("admin", nil)
+		Expect(err).To(BeNil())
+		class1, err = groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class1"}, nil)
+		Expect(err).To(BeNil())
+		class2, err = groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class2"}, nil)
+		Expect(err).To(BeNil())
+		teacher, err = roles.CreateRole(&coreUserV1.CoreRole{Name: "teacher", Permission: map[string]uint64{"course": 1}}, nil)
+		Expect(err).To(BeNil())
+
+		students = nil
+		for _, name := range []string{"student0", "student1", "student2"} {
+			student, err := users.CreateUser(&coreUserV1.CoreUser{Name: name, Password: "student-password", Groups: []coreUserV1.CoreGroup{{Id: class1.Id}}}, nil)
+			Expect(err).To(BeNil())
+			students = append(students, student)
+		}
+
+		request = httptest.NewRequest(http.MethodPost, "/users/batch", nil)
+		request = request.WithContext(context.WithValue(request.Context(), "core-user", admin))
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should add and remove groups and report every user", func() {
+		result := run(&coreUserV1.CoreUserBatch{
+			Operation: userBatchAddGroups,
+			UserIds:   []string{students[0].Id, students[1].Id, "unknown", students[0].Id},
+			GroupIds:  []string{class1.Id, class2.Id},
+		})
+		Expect(result.Succeeded).To(Equal(2))
+		Expect(result.Failed).To(Equal(1))
+		Expect(result.Items).To(HaveLen(3))
+		Expect(result.Items[0]).To(Equal(coreUserV1.CoreUserBatchItem{UserId: students[0].Id, Name: "student0", Status: "succeeded"}))
+		Expect(result.Items[2].Reason).To(Equal("user not found"))
+		Expect(getUser(students[1].Id).Groups).To(HaveLen(2))
+		Expect(getUser(students[2].Id).Groups).To(HaveLen(1))
+
+		result = run(&coreUserV1.CoreUserBatch{
+			Operation: userBatchRemoveGroups,
+			UserIds:   []string{students[0].Id, students[2].Id},
+			GroupIds:  []string{class1.Id},
+		})
+		Expect(result.Succeeded).To(Equal(2))
+		Expect(getUser(students[0].Id).Groups).To(Equal([]coreUserV1.CoreGroup{{Id: class2.Id, Name: "class2"}}))
+		Expect(getUser(students[2].Id).Groups).To(BeEmpty())
+	})
+
+	It("should set roles of users", func() {
+		result := run(&coreUserV1.CoreUserBatch{
+			Operation: userBatchSetRoles,
+			UserIds:   []string{students[0].Id, students[1].Id},
+			RoleIds:   []string{teacher.Id},
+		})
+		Expect(result.Succeeded).To(Equal(2))
+		Expect(getUser(students[1].Id).Roles).To(Equal([]coreUserV1.CoreRole{{Id: teacher.Id, Name: "teacher"}}))
+	})
+
+	It("should disable and delete users but not the one who sends request", func() {
+		result := run(&coreUserV1.CoreUserBatch{
+			Operation: userBatchDisable,
+			UserIds:   []string{students[0].Id, admin.Id},
+		})
+		Expect(result.Succeeded).To(Equal(1))
+		Expect(result.Items[1].Status).To(Equal("failed"))
+		Expect(getUser(students[0].Id).Disabled).To(BeTrue())
+		Expect(getUser(admin.Id).Disabled).To(BeFalse())
+
+		result = run(&coreUserV1.CoreUserBatch{
+			Operation: userBatchDelete,
+			UserIds:   []string{students[0].Id, students[1].Id},
+		})
+		Expect(result.Succeeded).To(Equal(2))
+		_, err := userProvider.GetUser(students[0].Id, nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should refuse invalid batch", func() {
+		var tooMany []string
+		for index := 0; index <= maxUserBatchSize; index++ {
+			tooMany = append(tooMany, fmt.Sprintf("user%d", index))
+		}
+		for _, post := range []*coreUserV1.CoreUserBatch{
+			{Operation: "rename", UserIds: []string{students[0].Id}},
+			{Operation: userBatchDelete},
+			{Operation: userBatchSetRoles, UserIds: []string{students[0].Id}},
+			{Operation: userBatchSetRoles, UserIds: []string{students[0].Id}, RoleIds: []string{"unknown"}},
+			{Operation: userBatchAddGroups, UserIds: []string{students[0].Id}, GroupIds: []string{"unknown"}},
+			{Operation: userBatchDelete, UserIds: tooMany},
+		} {
+			_, status, err := newUserBatch(serverConfig, request, post)
+			Expect(err).To(HaveOccurred())
+			Expect(status).To(Equal(http.StatusBadRequest))
+		}
+	})
+
+	It("should ask for delete permission", func() {
+		recorder := httptest.NewRecorder()
+		limited := *admin
+		limited.Permission = map[string]uint64{"user": privileges.PermissionUserUpdate}
+		limitedRequest := request.WithContext(context.WithValue(request.Context(), "core-user", &limited))
+		Expect(writeIfNoPermission(recorder, limitedRequest, "user", privileges.PermissionUserDelete)).To(BeTrue())
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(writeIfNoPermission(httptest.NewRecorder(), limitedRequest, "user", privileges.PermissionUserUpdate)).To(BeFalse())
+	})
+})
+
+var _ = Describe("refresh token test", func() {
+	var serverConfig *config.Config
+	var student *coreUserV1.CoreUser
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/users/login/refresh", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		CreateRefreshTokenHandler(serverConfig)(recorder, request)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should refuse user disabled after login", func() {
+		userToken, err := issueSessionToken(serverConfig, httptest.NewRequest(http.MethodPost, "/users/login", nil), student)
+		Expect(err).To(BeNil())
+		Expect(refresh(userToken.Token).Code).To(Equal(http.StatusOK))
+
+		userToken, err = issueSessionToken(serverConfig, httptest.NewRequest(http.MethodPost, "/users/login", nil), student)
+		Expect(err).To(BeNil())
+		// disabled in identity provider, sessions are not revoked by core-api
+		Expect(auth.SetUserDisabled(userProvider, student.Id, true)).To(Succeed())
+		Expect(refresh(userToken.Token).Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("should issue only one new token for concurrent refreshes of the same token", func() {
+		userToken, err := issueSessionToken(serverConfig, httptest.NewRequest(http.MethodPost, "/users/login", nil), student)
+		Expect(err).To(BeNil())
+
+		codes := make(chan int, 5)
+		var wg sync.WaitGroup
+		for i := 0; i < cap(codes); i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				codes <- refresh(userToken.Token).Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		var succeeded int
+		for code := range codes {
+			if code == http.StatusOK {
+				succeeded++
+			} else {
+				Expect(code).To(Equal(http.StatusUnauthorized))
+			}
+		}
+		Expect(succeeded).To(Equal(1))
+		Expect(refresh(userToken.Token).Code).To(Equal(http.StatusUnauthorized))
+	})
+})
+
+var _ = Describe("group update test", func() {
+	var serverConfig *config.Config
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should update group in path even if body names another group", func() {
+		groups, err := initOrGetGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+		class1, err := groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class-1"}, nil)
+		Expect(err).To(BeNil())
+		class2, err := groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class-2"}, nil)
+		Expect(err).To(BeNil())
+
+		data, err := json.Marshal(coreUserV1.CoreGroup{Id: class2.Id, Name: "renamed"})
+		Expect(err).To(BeNil())
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("groupId", class1.Id)
+		request := httptest.NewRequest(http.MethodPut, "/groups/"+class1.Id, bytes.NewReader(data))
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+		recorder := httptest.NewRecorder()
+		CreateUpdateGroupHandler(serverConfig)(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		found, err := groups.GetGroup(class1.Id, nil)
+		Expect(err).To(BeNil())
+		Expect(found.Name).To(Equal("renamed"))
+		found, err = groups.GetGroup(class2.Id, nil)
+		Expect(err).To(BeNil())
+		Expect(found.Name).To(Equal("class-2"))
+	})
+})
+
+var _ = Describe("user password update test", func() {
+	var serverConfig *config.Config
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should refuse current or previous password without logging in with it", func() {
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		student, err := users.CreateUser(&coreUserV1.CoreUser{Name: "password-student", Password: "student-password-1"}, nil)
+		Expect(err).To(BeNil())
+		// as auth middleware does when student logs in
+		Expect(credential.InitOrGetCache().Put(student, "student-password-1")).To(Succeed())
+
+		update := func(password string) *httptest.ResponseRecorder {
+			data, err := json.Marshal(coreUserV1.CoreUser{Password: password})
+			Expect(err).To(BeNil())
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add("userId", student.Id)
+			request := httptest.NewRequest(http.MethodPut, "/users/"+student.Id, bytes.NewReader(data))
+			request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+			recorder := httptest.NewRecorder()
+			CreateUpdateUserHandler(serverConfig)(recorder, request)
+			return recorder
+		}
+
+		recorder := update("student-password-1")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("same as the old one"))
+
+		Expect(update("student-password-2").Code).To(Equal(http.StatusOK))
+		_, err = users.LoginUser("password-student", "student-password-2")
+		Expect(err).To(BeNil())
+
+		// credential of last login is forgotten once password is changed, history still knows it
+		Expect(update("student-password-3").Code).To(Equal(http.StatusOK))
+		recorder = update("student-password-2")
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("used before"))
+	})
+})
+
+var _ = Describe("list page test", func() {
+	users := []coreUserV1.CoreUser{
+		{Id: "1", Name: "carol", Email: "carol@school.edu"},
+		{Id: "2", Name: "alice", Description: "class 1"},
+		{Id: "3", Name: "bob", Description: "class 2"},
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+	})
+
+	list := func(query string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		writeListPage(recorder, httptest.NewRequest(http.MethodGet, "/users?"+query, nil), users, userListFields)
+		return recorder
+	}
+
+	It("should write page with total count", func() {
+		recorder := list("q=CLASS&sort=-name&pageSize=1")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("X-Total-Count")).To(Equal("2"))
+		Expect(recorder.Body.String()).To(MatchJSON(`[{"id":"3","name":"bob","description":"class 2"}]`))
+
+		recorder = list("q=school.edu")
+		Expect(recorder.Header().Get("X-Total-Count")).To(Equal("1"))
+
+		recorder = list("q=nobody")
+		Expect(recorder.Body.String()).To(Equal("[]"))
+	})
+
+	It("should refuse invalid query", func() {
+		Expect(list("sort=password").Code).To(Equal(http.StatusBadRequest))
+		Expect(list("page=0").Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("should filter users by role and group", func() {
+		bound := []coreUserV1.CoreUser{
+			{Id: "1", Roles: []coreUserV1.CoreRole{{Id: "teacher"}}, Groups: []coreUserV1.CoreGroup{{Id: "class1"}}},
+			{Id: "2", Roles: []coreUserV1.CoreRole{{Id: "student"}}, Groups: []coreUserV1.CoreGroup{{Id: "class1"}, {Id: "class2"}}},
+		}
+		Expect(filterUsersByBinding(bound, []string{"student", "admin"}, roleIdsOf)).To(Equal(bound[1:]))
+		Expect(filterUsersByBinding(bound, []string{"class1"}, groupIdsOf)).To(Equal(bound))
+		Expect(filterUsersByBinding(bound, []string{"class3"}, groupIdsOf)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("role permission test", func() {
+	It("should merge permission names into bits", func() {
+		role := &coreUserV1.CoreRole{
+			Permission:      map[string]uint64{"user": privileges.PermissionUserViewPage},
+			PermissionNames: map[string][]string{"user": {"list"}, "rag": {"view_page", "query"}},
+		}
+		Expect(resolveRolePermission(role)).To(BeNil())
+		Expect(role.Permission).To(Equal(map[string]uint64{
+			"user": privileges.PermissionUserViewPage | privileges.PermissionUserList,
+			"rag":  privileges.PermissionRagViewPage | privileges.PermissionRagQuery,
+		}))
+		Expect(role.PermissionNames).To(BeNil())
+	})
+
+	It("should refuse unknown module, permission name or bit", func() {
+		Expect(resolveRolePermission(&coreUserV1.CoreRole{PermissionNames: map[string][]string{"unknown": {"list"}}})).NotTo(BeNil())
+		Expect(resolveRolePermission(&coreUserV1.CoreRole{PermissionNames: map[string][]string{"user": {"GET"}}})).NotTo(BeNil())
+		Expect(resolveRolePermission(&coreUserV1.CoreRole{Permission: map[string]uint64{"unknown": 1}})).NotTo(BeNil())
+		Expect(resolveRolePermission(&coreUserV1.CoreRole{Permission: map[string]uint64{"courseStudentView": 2}})).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,199 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("group admin test", func() {
+	var serverConfig *config.Config
+	var admin, teacher, student, outsider *coreUserV1.CoreUser
+	var class *coreUserV1.CoreGroup
+
+	newRequest := func(requestUser *coreUserV1.CoreUser, method, target string, body interface{}, params map[string]string) *http.Request {
+		var reader *bytes.Reader
+		if body != nil {
+			data, err := json.Marshal(body)
+			Expect(err).To(BeNil())
+			reader = bytes.NewReader(data)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		routeContext := chi.NewRouteContext()
+		for key, value := range params {
+			routeContext.URLParams.Add(key, value)
+		}
+		request := httptest.NewRequest(method, target, reader)
+		ctx := context.WithValue(request.Context(), chi.RouteCtxKey, routeContext)
+		return request.WithContext(context.WithValue(ctx, "core-user", requestUser))
+	}
+
+	updateUser := func(requestUser *coreUserV1.CoreUser, userId string, post coreUserV1.CoreUser) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		CreateUpdateUserHandler(serverConfig)(recorder, newRequest(requestUser, http.MethodPut, "/users/"+userId, post, map[string]string{"userId": userId}))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		admin, err = users.SearchUserByName("admin", map[string]struct{}{keystone.LoadPermission: {}})
+		Expect(err).To(BeNil())
+		teacher, err = users.CreateUser(&coreUserV1.CoreUser{Name: "teacher", Password: "teacher-password"}, nil)
+		Expect(err).To(BeNil())
+		// auth middleware puts user of request in context with permission loaded
+		teacher, err = users.GetUser(teacher.Id, map[string]struct{}{keystone.LoadPermission: {}})
+		Expect(err).To(BeNil())
+		student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+		Expect(err).To(BeNil())
+		outsider, err = users.CreateUser(&coreUserV1.CoreUser{Name: "outsider", Password: "outsider-password"}, nil)
+		Expect(err).To(BeNil())
+
+		groups, err := initOrGetGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+		class, err = groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class-1"}, nil)
+		Expect(err).To(BeNil())
+		Expect(groups.AddUserToGroup(student.Id, class.Id)).To(Succeed())
+
+		// administrators are kept by one store for the whole process, ids of users and groups differ in each spec
+		recorder := httptest.NewRecorder()
+		CreateAddGroupAdminHandler(serverConfig)(recorder, newRequest(admin, http.MethodPut, "/groups/"+class.Id+"/admins/"+teacher.Id, nil, map[string]string{"groupId": class.Id, "userId": teacher.Id}))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should let group administrator update member but not other users", func() {
+		Expect(updateUser(teacher, student.Id, coreUserV1.CoreUser{Description: "class-1 student"}).Code).To(Equal(http.StatusOK))
+		Expect(updateUser(teacher, outsider.Id, coreUserV1.CoreUser{Description: "not my student"}).Code).To(Equal(http.StatusForbidden))
+		// administrator of a group is not administrator of itself
+		Expect(updateUser(teacher, teacher.Id, coreUserV1.CoreUser{Description: "teacher"}).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should not let group administrator act on member holding more permission or built-in member", func() {
+		roles, err := initOrGetRoleProvider(serverConfig)
+		Expect(err).To(BeNil())
+		userManager, err := roles.CreateRole(&coreUserV1.CoreRole{Name: "user-manager", Permission: map[string]uint64{"user": privileges.PermissionUserList | privileges.PermissionUserUpdate}}, nil)
+		Expect(err).To(BeNil())
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		anotherTeacher, err := users.CreateUser(&coreUserV1.CoreUser{Name: "another-teacher", Password: "teacher-password", Roles: []coreUserV1.CoreRole{{Id: userManager.Id}}}, nil)
+		Expect(err).To(BeNil())
+		groups, err := initOrGetGroupProvider(serverConfig)
+		Expect(err).To(BeNil())
+		Expect(groups.AddUserToGroup(anotherTeacher.Id, class.Id)).To(Succeed())
+		Expect(groups.AddUserToGroup(admin.Id, class.Id)).To(Succeed())
+
+		Expect(updateUser(teacher, anotherTeacher.Id, coreUserV1.CoreUser{Description: "taken over"}).Code).To(Equal(http.StatusForbidden))
+		Expect(updateUser(teacher, admin.Id, coreUserV1.CoreUser{Description: "taken over"}).Code).To(Equal(http.StatusForbidden))
+		Expect(updateUser(teacher, student.Id, coreUserV1.CoreUser{Description: "class-1 student"}).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should not let group administrator update another user named in body", func() {
+		recorder := updateUser(teacher, student.Id, coreUserV1.CoreUser{Id: admin.Id, Password: "Taken-over-1"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		_, err = users.LoginUser("admin", "admin-password")
+		Expect(err).To(BeNil())
+		_, err = users.LoginUser("admin", "Taken-over-1")
+		Expect(err).To(HaveOccurred())
+	})
+
+	// auth middleware lets a group administrator through every group admin route, so each handler has to check the member itself
+	It("should refuse group administrator on every group admin route for user it does not administer", func() {
+		checked := 0
+		for _, route := range GetCoreApiRoute(serverConfig).MethodHandlers {
+			if !route.ModuleAndPermission.GroupAdmin {
+				continue
+			}
+			target := route.Pattern
+			params := map[string]string{}
+			for name, value := range map[string]string{"userId": outsider.Id, "sessionId": "session-1"} {
+				if strings.Contains(target, "{"+name+"}") {
+					params[name] = value
+					target = strings.ReplaceAll(target, "{"+name+"}", value)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			route.Handler(recorder, newRequest(teacher, route.Method, target, coreUserV1.CoreUser{Description: "not my student"}, params))
+			if _, found := params["userId"]; found {
+				Expect(recorder.Code).To(Equal(http.StatusForbidden), route.Method+" "+route.Pattern)
+			} else {
+				// routes without user in path must leave users out of groups administrator administers
+				Expect(recorder.Body.String()).NotTo(ContainSubstring(outsider.Id), route.Method+" "+route.Pattern)
+			}
+			checked++
+		}
+		Expect(checked).NotTo(BeZero())
+	})
+
+	It("should not let group administrator change roles or groups of member", func() {
+		Expect(updateUser(teacher, student.Id, coreUserV1.CoreUser{Groups: []coreUserV1.CoreGroup{{Id: class.Id}}}).Code).To(Equal(http.StatusForbidden))
+		Expect(updateUser(teacher, student.Id, coreUserV1.CoreUser{Roles: []coreUserV1.CoreRole{{Id: "admin"}}}).Code).To(Equal(http.StatusForbidden))
+		Expect(updateUser(admin, student.Id, coreUserV1.CoreUser{Description: "changed by admin"}).Code).To(Equal(http.StatusOK))
+	})
+
+	It("should list only members to group administrator", func() {
+		recorder := httptest.NewRecorder()
+		CreateGetUsersHandler(serverConfig)(recorder, newRequest(teacher, http.MethodGet, "/users", nil, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var listed []coreUserV1.CoreUser
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &listed)).To(Succeed())
+		Expect(listed).To(HaveLen(1))
+		Expect(listed[0].Id).To(Equal(student.Id))
+	})
+
+	It("should list administered groups and stop delegation once administrator is removed", func() {
+		recorder := httptest.NewRecorder()
+		CreateGetAdministeredGroupsHandler(serverConfig)(recorder, newRequest(teacher, http.MethodGet, "/users/me/administered-groups", nil, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		var administered []coreUserV1.CoreGroup
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &administered)).To(Succeed())
+		Expect(administered).To(HaveLen(1))
+		Expect(administered[0].Id).To(Equal(class.Id))
+
+		params := map[string]string{"groupId": class.Id, "userId": teacher.Id}
+		recorder = httptest.NewRecorder()
+		CreateRemoveGroupAdminHandler(serverConfig)(recorder, newRequest(admin, http.MethodDelete, "/groups/"+class.Id+"/admins/"+teacher.Id, nil, params))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder = httptest.NewRecorder()
+		CreateRemoveGroupAdminHandler(serverConfig)(recorder, newRequest(admin, http.MethodDelete, "/groups/"+class.Id+"/admins/"+teacher.Id, nil, params))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+
+		Expect(updateUser(teacher, student.Id, coreUserV1.CoreUser{Description: "class-1 student"}).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("should not designate administrator of a group that does not exist", func() {
+		recorder := httptest.NewRecorder()
+		CreateAddGroupAdminHandler(serverConfig)(recorder, newRequest(admin, http.MethodPut, "/groups/not-exist/admins/"+teacher.Id, nil, map[string]string{"groupId": "not-exist", "userId": teacher.Id}))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -2,7 +2,18 @@ package route
 
 import (
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/accesstoken"
+	"core-api/pkg/core/auth/credential"
+	"core-api/pkg/core/auth/event"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/auth/lockout"
+	"core-api/pkg/core/auth/oidc"
 	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/session"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
+	"core-api/pkg/core/auth/userimport"
 	"core-api/pkg/core/privileges"
 	coreApiLog "core-api/pkg/logger"
 	chatV1 "core-api/pkg/north/api/chat/core/v1"
@@ -18,20 +29,20 @@ import (
 	"core-api/pkg/south"
 	customErr "core-api/pkg/util/error"
 	httpHelper "core-api/pkg/util/http"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
+	"net/url"
 	openhydraConfig "open-hydra-server-api/cmd/open-hydra-server/app/config"
 	courseV1 "open-hydra-server-api/pkg/apis/open-hydra-api/course/core/v1"
 	datasetV1 "open-hydra-server-api/pkg/apis/open-hydra-api/dataset/core/v1"
 	deviceV1 "open-hydra-server-api/pkg/apis/open-hydra-api/device/core/v1"
 	summaryV1 "open-hydra-server-api/pkg/apis/open-hydra-api/summary/core/v1"
-	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -67,7 +78,6 @@ due to swag init command cannot merge annotation from multiple files
 // @Produce  json
 // @Param userId path string true "user id"
 // @Param loadPermission query string false "load permission or not e.g. ?loadPermission=1"
-// @Param loadPassword query string false "load password or not e.g. ?loadPassword=1"
 // @Success 200 {object} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
@@ -95,15 +105,9 @@ func CreateGetUserHandler(config *config.Config) func(w http.ResponseWriter, r *
 			options = map[string]struct{}{keystone.LoadPermission: {}}
 		}
 
-		loadPassword := r.URL.Query().Get("loadPassword")
-		if loadPassword != "" {
-			options = map[string]struct{}{keystone.LoadPasswd: {}}
-		}
-
 		if !config.CoreApiConfig.DisableAuth {
-			// is allow show other user password
-			_, _, err := south.SouthAuthorizationControlWithUser(r, w, "user", privileges.PermissionUserManageOtherUserResource, userId, "user")
-			if err != nil {
+			_, canAccess, err := south.SouthAuthorizationControlWithGroupAdmin(config, r, w, "user", privileges.PermissionUserManageOtherUserResource, userId, "user")
+			if err != nil || !canAccess {
 				return
 			}
 		}
@@ -116,10 +120,98 @@ func CreateGetUserHandler(config *config.Config) func(w http.ResponseWriter, r *
 			return
 		}
 
+		if writeIfOtherTenant(config, w, r, "user", userId, user.TenantId) {
+			return
+		}
+
 		httpHelper.WriteResponseEntity(w, user)
 	}
 }
 
+// GET user lockout
+// @tags user
+// @Summary show failed login state of user
+// @Description show failed login count and whether user is temporarily locked out
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreLockout
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/lockout  [get]
+func CreateGetUserLockoutHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loginLimiter, ok := getLockoutUser(config, w, r, privileges.PermissionUserList)
+		if !ok {
+			return
+		}
+		httpHelper.WriteResponseEntity(w, loginLimiter.GetUserLockout(user.Name))
+	}
+}
+
+// DELETE user lockout
+// @tags user
+// @Summary unlock user
+// @Description clear failed logins and lockout of user, state before unlock is returned
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreLockout
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/lockout  [delete]
+func CreateDeleteUserLockoutHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loginLimiter, ok := getLockoutUser(config, w, r, privileges.PermissionUserUpdate)
+		if !ok {
+			return
+		}
+		httpHelper.WriteResponseEntity(w, loginLimiter.Unlock(user.Name))
+	}
+}
+
+// getLockoutUser resolves user in path, error is written to response if false is returned
+// user of request lacking permission has to administer a group of user in path
+func getLockoutUser(config *config.Config, w http.ResponseWriter, r *http.Request, permission uint64) (*coreUserV1.CoreUser, lockout.ILoginLimiter, bool) {
+	userId := chi.URLParam(r, "userId")
+	if userId == "" {
+		httpHelper.WriteCustomErrorAndLog(w, "Missing user id", http.StatusBadRequest, "", nil)
+		return nil, nil, false
+	}
+
+	userProvider, err := initOrGetUserProvider(config)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+
+	loginLimiter, err := lockout.InitOrGetLoginLimiter(config)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create login limiter", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+
+	user, err := userProvider.GetUser(userId, nil)
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+			return nil, nil, false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+		return nil, nil, false
+	}
+
+	if writeIfOtherTenant(config, w, r, "user", userId, user.TenantId) {
+		return nil, nil, false
+	}
+	if writeIfNotGroupAdmin(config, w, r, "user", permission, user) {
+		return nil, nil, false
+	}
+	return user, loginLimiter, true
+}
+
 // DELETE user
 // @tags user
 // @Summary delete user with given id
@@ -147,22 +239,127 @@ func CreateDeleteUserHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+
 		err = userProvider.DeleteUser(userId, nil)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to delete user", http.StatusInternalServerError, "", err)
 			return
 		}
 
+		revokeUserSessions(config, userId)
+		revokeUserAccessTokens(config, userId)
+		forgetPasswords(config, userId)
+		publishChange(event.UserKind, event.Deleted, userId, "")
+
 		httpHelper.WriteResponseEntity(w, &coreUserV1.CoreUser{
 			Id: userId,
 		})
 	}
 }
 
+// POST disable user
+// @tags user
+// @Summary disable user with given id
+// @Description disabled user can not log in and its sessions are revoked, roles, groups and resources of user are kept
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreUser
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/disable  [post]
+func CreateDisableUserHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setUserDisabled(config, w, r, true)
+	}
+}
+
+// POST enable user
+// @tags user
+// @Summary enable user with given id
+// @Description enabled user can log in again, its personal access tokens work again
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreUser
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/enable  [post]
+func CreateEnableUserHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setUserDisabled(config, w, r, false)
+	}
+}
+
+// setUserDisabled disables or enables user of path and writes user changed
+// sessions of a disabled user are revoked, personal access tokens are kept and refused until user is enabled
+func setUserDisabled(serverConfig *config.Config, w http.ResponseWriter, r *http.Request, disabled bool) {
+	userId := chi.URLParam(r, "userId")
+	if userId == "" {
+		http.Error(w, "missing user id", http.StatusBadRequest)
+		return
+	}
+
+	requestUserId, err := getRequestUserId(serverConfig, r)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+		return
+	}
+	if disabled && requestUserId == userId {
+		httpHelper.WriteCustomErrorAndLog(w, "User can not disable itself", http.StatusBadRequest, "", fmt.Errorf("user '%s' tries to disable itself", userId))
+		return
+	}
+
+	userProvider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		return
+	}
+
+	userFound, err := userProvider.GetUser(userId, nil)
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+			return
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+		return
+	}
+
+	if writeIfOtherTenant(serverConfig, w, r, "user", userId, userFound.TenantId) {
+		return
+	}
+	if writeIfNotGroupAdmin(serverConfig, w, r, "user", privileges.PermissionUserUpdate, userFound) {
+		return
+	}
+
+	err = auth.SetUserDisabled(userProvider, userId, disabled)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to change disabled state of user", http.StatusInternalServerError, "", err)
+		return
+	}
+
+	if disabled {
+		revokeUserSessions(serverConfig, userId)
+		coreApiLog.Logger.Warn("audit: user disabled", "user", userFound.Name, "by", requestUserId)
+	} else {
+		coreApiLog.Logger.Warn("audit: user enabled", "user", userFound.Name, "by", requestUserId)
+	}
+	publishChange(event.UserKind, event.Updated, userId, userFound.Name)
+
+	userFound.Disabled = disabled
+	httpHelper.WriteResponseEntity(w, userFound)
+}
+
 // PUT update user
 // @tags user
 // @Summary update user
-// @Description update user
+// @Description update user, a new password has to meet password policy, set mustChangePassword to make user change it on next login
 // @Accept  json
 // @Produce  json
 // @Param userId path string true "user id"
@@ -195,13 +392,17 @@ func CreateUpdateUserHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
-		userPost.Id = userId
-
 		err = json.Unmarshal(result, userPost)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
 			return
 		}
+		// checks below are made against user in path, so body must not name another user
+		if userPost.Id != "" && userPost.Id != userId {
+			httpHelper.WriteCustomErrorAndLog(w, "User id in body does not match user id in path", http.StatusBadRequest, "", fmt.Errorf("user id '%s' in body does not match '%s' in path", userPost.Id, userId))
+			return
+		}
+		userPost.Id = userId
 
 		userFound, err := userProvider.GetUser(userId, nil)
 		if err != nil {
@@ -214,12 +415,37 @@ func CreateUpdateUserHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if writeIfOtherTenant(config, w, r, "user", userId, userFound.TenantId) {
+			return
+		}
+		if writeIfNotGroupAdmin(config, w, r, "user", privileges.PermissionUserUpdate, userFound) {
+			return
+		}
+		// group administrator could otherwise grant itself more through a member or move member out of its reach
+		if !hasPermission(r, "user", privileges.PermissionUserUpdate) && (len(userPost.Roles) > 0 || len(userPost.Groups) > 0 || len(userPost.Permission) > 0) {
+			httpHelper.WriteCustomErrorAndLog(w, "Group administrator can not change roles or groups of user", http.StatusForbidden, "", fmt.Errorf("group administrator tries to change roles or groups of user '%s'", userFound.Name))
+			return
+		}
+
+		if !checkBindingTenant(config, w, r, userPost.Roles, userPost.Groups) {
+			return
+		}
+		// tenant of user can not be changed
+		userPost.TenantId = userFound.TenantId
+
 		if userPost.Password != "" {
 			// ensure new changed password is not the same as the old one
-			if userPost.Password == userFound.Password {
+			// it is compared with credential verified at last login, logging in with it would count as a login of user
+			// previous passwords are checked against password history below
+			if verifiedUserId, found := credential.InitOrGetCache().Verify(userFound.Name, userPost.Password); found && verifiedUserId == userId {
 				httpHelper.WriteCustomErrorAndLog(w, "Password is the same as the old one", http.StatusBadRequest, "", fmt.Errorf("password is the same as the old one"))
 				return
 			}
+
+			if err = checkNewPassword(config, userId, userFound.Name, userPost.Password); err != nil {
+				writeNewPasswordError(w, err)
+				return
+			}
 		}
 
 		err = userProvider.UpdateUser(userPost, nil)
@@ -228,838 +454,2471 @@ func CreateUpdateUserHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if userPost.Password != "" {
+			rememberPassword(config, userId, userPost.Password)
+			// session issued with old password should not survive password change
+			revokeUserSessions(config, userId)
+		}
+		publishChange(event.UserKind, event.Updated, userId, userFound.Name)
+
 		httpHelper.WriteResponseEntity(w, userPost)
 	}
 }
 
-// GET group list
-// @tags group
-// @Summary list all groups
-// @Description list all groups
+// GET current user
+// @tags user
+// @Summary show current user
+// @Description show user who sends request with permission loaded
+// @Produce  json
+// @Success 200 {object} coreUserV1.CoreUser
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/me  [get]
+func CreateGetMeHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+		httpHelper.WriteResponseEntity(w, user)
+	}
+}
+
+// PATCH current user
+// @tags user
+// @Summary update profile of current user
+// @Description update email and description of current user, other fields are rejected, empty field is left unchanged
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} coreUserV1.CoreGroup
+// @Param request body coreUserV1.CoreUserProfile true "profile"
+// @Success 200 {object} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
-// @Failure 403 {object} httpHelper.CustomError
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups  [get]
-func CreateGetGroupsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me  [patch]
+func CreatePatchMeHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+
+		profile := &coreUserV1.CoreUserProfile{}
+		// roles, groups and password can not be changed here, so unknown field is an error instead of being ignored
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(profile)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body, only email and description can be changed", http.StatusBadRequest, "", err)
 			return
 		}
-		groups, err := groupProvider.GetGroups(nil)
+
+		userProvider, err := initOrGetUserProvider(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		if groups == nil {
-			groups = []coreUserV1.CoreGroup{}
+		err = userProvider.UpdateUser(&coreUserV1.CoreUser{
+			Id:          user.Id,
+			Email:       profile.Email,
+			Description: profile.Description,
+		}, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to update user", http.StatusInternalServerError, "", err)
+			return
 		}
+		publishChange(event.UserKind, event.Updated, user.Id, user.Name)
 
-		httpHelper.WriteResponseEntity(w, groups)
+		user, ok = getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+		httpHelper.WriteResponseEntity(w, user)
 	}
 }
 
-// GET group detail
-// @tags group
-// @Summary get detail of group with given username
-// @Description get detail of group with given username
-// @Accept  json
+// GET permissions of current user
+// @tags user
+// @Summary show permissions of current user
+// @Description show for every module whether each named permission is granted to current user
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Success 200 {object} coreUserV1.CoreGroup
-// @Failure 400 {object} httpHelper.CustomError
-// @Failure 403 {object} httpHelper.CustomError
-// @Failure 404 {object} httpHelper.CustomError
+// @Success 200 {object} coreUserV1.CoreModulePermission
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [get]
-func CreateGetGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me/permissions  [get]
+func CreateGetMyPermissionsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
-			return
+		// module not known to provider is not granted at all
+		permission := privileges.ModulesNoPermission()
+		for moduleName, modulePermission := range user.Permission {
+			permission[moduleName] = modulePermission
 		}
 
-		group, err := groupProvider.GetGroup(groupId, nil)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
-			return
+		priProvider := &privileges.DefaultPrivilegeProvider{}
+		result := coreUserV1.CoreModulePermission{}
+		for moduleName := range privileges.Modules {
+			modulePermission, err := priProvider.ModulePermission(permission, moduleName)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get module permission", http.StatusInternalServerError, "", err)
+				return
+			}
+			result[moduleName] = modulePermission
 		}
 
-		httpHelper.WriteResponseEntity(w, group)
+		httpHelper.WriteResponseEntity(w, result)
 	}
 }
 
-// POST create group
-// @tags group
-// @Summary create group
-// @Description create group
+// GET permission catalogue
+// @tags role
+// @Summary list permissions
+// @Description list every module with its named permissions, bits and labels, for role editors to build permission of a role
+// @Produce  json
+// @Success 200 {array} privileges.ModuleCatalogue
+// @Failure 401 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/permissions  [get]
+func CreateGetPermissionsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpHelper.WriteResponseEntity(w, privileges.Catalogue())
+	}
+}
+
+// POST access review
+// @tags user
+// @Summary review access of a user to a route
+// @Description tell whether a user may call method and path, with matched route pattern, required module and bits and roles granting them
+// @Description user of request is reviewed if userId is left blank, reviewing another user requires listing users and roles
 // @Accept  json
 // @Produce  json
-// @Param request body coreUserV1.CoreGroup true "group post"
-// @Success 200 {object} coreUserV1.CoreGroup
+// @Param request body coreUserV1.CoreAccessReview true "access review"
+// @Success 200 {object} coreUserV1.CoreAccessVerdict
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups  [post]
-func CreateCreateGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/access-review  [post]
+func CreateAccessReviewHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
+		review := &coreUserV1.CoreAccessReview{}
+		if err := json.NewDecoder(r.Body).Decode(review); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+		method := strings.ToUpper(strings.TrimSpace(review.Method))
+		if method == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Method is required", http.StatusBadRequest, "", fmt.Errorf("method is empty"))
+			return
+		}
+		path, err := reviewedPath(strings.TrimSpace(review.Path))
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Invalid path", http.StatusBadRequest, "", err)
 			return
 		}
 
-		groupPost := &coreUserV1.CoreGroup{}
-		// convert request body to string
-		result, err := io.ReadAll(r.Body)
+		provider, whiteList, err := getAccessReviewRoutes()
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Access review is not available", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		err = json.Unmarshal(result, groupPost)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+		user, roles, ok := getReviewedUser(config, w, r, review.UserId)
+		if !ok {
 			return
 		}
 
-		groupCreated, err := groupProvider.CreateGroup(groupPost, nil)
+		groupAdmin, err := administersGroups(config, user.Id)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups user administers", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, groupCreated)
+		pattern, _ := matchRoutePattern(provider, whiteList, method, path)
+		verdict := reviewAccess(provider.GetRouteAuthorization(), whiteList, user, roles, groupAdmin, method, pattern)
+		verdict.Path = path
+		httpHelper.WriteResponseEntity(w, verdict)
 	}
 }
 
-// PUT update group
-// @tags group
-// @Summary update group
-// @Description update group
-// @Accept  json
+// GET access matrix
+// @tags user
+// @Summary list access of a user to every route
+// @Description list verdict of a user on every method of every registered route, reviewing another user requires listing users and roles
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Param request body coreUserV1.CoreGroup true "group post"
-// @Success 200 {object} coreUserV1.CoreGroup
-// @Failure 400 {object} httpHelper.CustomError
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreAccessMatrix
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [put]
-func CreateUpdateGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/access-matrix  [get]
+func CreateGetAccessMatrixHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
-			return
-		}
-
-		groupPost := &coreUserV1.CoreGroup{}
-		// convert request body to string
-		result, err := io.ReadAll(r.Body)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
 			return
 		}
 
-		err = json.Unmarshal(result, groupPost)
+		provider, whiteList, err := getAccessReviewRoutes()
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Access matrix is not available", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		user, roles, ok := getReviewedUser(config, w, r, userId)
+		if !ok {
 			return
 		}
 
-		err = groupProvider.UpdateGroup(groupPost, nil)
+		groupAdmin, err := administersGroups(config, user.Id)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to update group", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups user administers", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, groupPost)
+		httpHelper.WriteResponseEntity(w, accessMatrix(provider.GetRouteAuthorization(), whiteList, user, roles, groupAdmin))
 	}
 }
 
-// DELETE delete group
-// @tags group
-// @Summary delete group
-// @Description delete group
-// @Accept  json
+// GET shares of a resource
+// @tags user
+// @Summary list shares of a resource
+// @Description list users and groups a conversation, knowledge base or device is shared with
+// @Description owner, user resource is shared with at manage access and user allowed to manage resources of other users can list them
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Success 200 {object} coreUserV1.CoreGroup
+// @Param resourceKind query string true "one of conversation, knowledge_base and device"
+// @Param resourceId query string true "resource id, device is identified by name of user it belongs to"
+// @Success 200 {array} coreUserV1.CoreShare
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [delete]
-func CreateDeleteGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/shares  [get]
+func CreateGetSharesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		kind, resourceId := r.URL.Query().Get("resourceKind"), r.URL.Query().Get("resourceId")
+		if kind == "" || resourceId == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Resource kind and resource id are required", http.StatusBadRequest, "", fmt.Errorf("resource kind or resource id is empty"))
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
 			return
 		}
 
-		err = groupProvider.DeleteGroup(groupId, nil)
+		store, err := share.InitOrGetShareStore(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to delete group", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create share store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, nil)
+		if _, ok := checkShareManager(config, w, r, store, user, kind, resourceId); !ok {
+			return
+		}
+
+		shares, err := store.List(kind, resourceId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list shares", http.StatusInternalServerError, "", err)
+			return
+		}
+		httpHelper.WriteResponseEntity(w, shares)
 	}
 }
 
-// GET user in group
-// @tags group
-// @Summary list all users in group
-// @Description list all users in group
-// @Accept  json
+// GET shares granted to current user
+// @tags user
+// @Summary list resources shared with me
+// @Description list shares granted to current user directly or through one of groups
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Success 200 {array} coreUserV1.CoreUser
-// @Failure 400 {object} httpHelper.CustomError
-// @Failure 403 {object} httpHelper.CustomError
-// @Failure 404 {object} httpHelper.CustomError
+// @Success 200 {array} coreUserV1.CoreShare
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users  [get]
-func CreateGetGroupUsersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/shares/granted  [get]
+func CreateGetGrantedSharesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		store, err := share.InitOrGetShareStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create share store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		users, err := groupProvider.GetGroupUsers(groupId, nil)
+		shares, err := store.ListGranted(user.Id, share.GroupIds(user))
 		if err != nil {
-			if _, ok := err.(*customErr.NotFound); ok {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users from group due to resource not found", http.StatusNotFound, "", err)
-				return
-			}
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group users", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list shares", http.StatusInternalServerError, "", err)
 			return
 		}
-
-		if users == nil {
-			users = []coreUserV1.CoreUser{}
-		}
-
-		httpHelper.WriteResponseEntity(w, users)
+		httpHelper.WriteResponseEntity(w, shares)
 	}
 }
 
-// Get user not in group
-// @tags group
-// @Summary list all users not in group
-// @Description list all users not in group
+// POST share a resource
+// @tags user
+// @Summary share a resource
+// @Description grant a user or members of a group read, use or manage access to a conversation, knowledge base or device
+// @Description access of a user or group the resource is already shared with is replaced
 // @Accept  json
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Success 200 {array} coreUserV1.CoreUser
+// @Param request body coreUserV1.CoreShare true "resourceKind, resourceId, subjectKind, subjectId and access"
+// @Success 200 {object} coreUserV1.CoreShare
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/not-in-group/list  [get]
-func CreateGetGroupUsersNotInGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/shares  [post]
+func CreateCreateShareHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
+		sharePost := &coreUserV1.CoreShare{}
+		if err := json.NewDecoder(r.Body).Decode(sharePost); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+		if sharePost.ResourceKind == "" || sharePost.ResourceId == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Resource kind and resource id are required", http.StatusBadRequest, "", fmt.Errorf("resource kind or resource id is empty"))
+			return
+		}
+
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+
+		store, err := share.InitOrGetShareStore(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create share store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		ownerId, ok := checkShareManager(config, w, r, store, user, sharePost.ResourceKind, sharePost.ResourceId)
+		if !ok {
+			return
+		}
+		if !checkShareSubject(config, w, r, sharePost.SubjectKind, sharePost.SubjectId) {
 			return
 		}
 
-		users, err := groupProvider.GetGroupUsers(groupId, map[string]struct{}{keystone.ReverseGetGroupUsers: {}})
+		sharePost.OwnerId = ownerId
+		sharePost.CreatedBy = user.Id
+		created, err := store.Put(sharePost)
 		if err != nil {
-			if _, ok := err.(*customErr.NotFound); ok {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users not in group due to resource not found", http.StatusNotFound, "", err)
+			if share.IsInvalid(err) {
+				httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
 				return
 			}
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group users not in", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to share resource", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		if users == nil {
-			users = []coreUserV1.CoreUser{}
-		}
-
-		httpHelper.WriteResponseEntity(w, users)
+		coreApiLog.Logger.Warn("audit: resource shared", "kind", created.ResourceKind, "resource", created.ResourceId, "subjectKind", created.SubjectKind, "subject", created.SubjectId, "access", created.Access, "by", user.Name)
+		httpHelper.WriteResponseEntity(w, created)
 	}
 }
 
-// PUT add user to group
-// @tags group
-// @Summary add user to group
-// @Description add user to group
-// @Accept  json
+// DELETE share of a resource
+// @tags user
+// @Summary remove share of a resource
+// @Description revoke access a share grants, user a resource is shared with can remove own share as well
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Param userId path string true "user id"
-// @Success 200 {object} coreUserV1.CoreUser
-// @Failure 400 {object} httpHelper.CustomError
+// @Param shareId path string true "share id"
+// @Success 200 {object} coreUserV1.CoreShare
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/{userId}  [put]
-func CreateAddUserToGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/shares/{shareId}  [delete]
+func CreateDeleteShareHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		shareId := chi.URLParam(r, "shareId")
+		if shareId == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Missing share id", http.StatusBadRequest, "", nil)
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
 			return
 		}
 
-		userId := chi.URLParam(r, "userId")
-		if userId == "" {
-			http.Error(w, "missing user id", http.StatusBadRequest)
+		store, err := share.InitOrGetShareStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create share store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		err = groupProvider.AddUserToGroup(userId, groupId)
+		found, err := store.Get(shareId)
 		if err != nil {
-			if _, ok := err.(*customErr.NotFound); ok {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to add user to group due to resource not found", http.StatusNotFound, "", err)
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Share not found", http.StatusNotFound, "", err)
 				return
 			}
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to add user to group", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get share", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, nil)
+		ownShare := found.SubjectKind == share.SubjectUser && found.SubjectId == user.Id
+		if !ownShare {
+			if _, ok := checkShareManager(config, w, r, store, user, found.ResourceKind, found.ResourceId); !ok {
+				return
+			}
+		}
+
+		if err := store.Delete(shareId); err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Share not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to remove share", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		coreApiLog.Logger.Warn("audit: resource share removed", "kind", found.ResourceKind, "resource", found.ResourceId, "subjectKind", found.SubjectKind, "subject", found.SubjectId, "by", user.Name)
+		httpHelper.WriteResponseEntity(w, found)
 	}
 }
 
-// DELETE remove user from group
-// @tags group
-// @Summary remove user from group
-// @Description remove user from group
-// @Accept  json
+// GET tenants
+// @tags user
+// @Summary list tenants
+// @Description list tenants current user can act on, super admin gets all tenants other user gets own tenant only
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Param userId path string true "user id"
-// @Success 200 {object} coreUserV1.CoreUser
-// @Failure 400 {object} httpHelper.CustomError
+// @Success 200 {array} coreUserV1.CoreTenant
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/tenants  [get]
+func CreateGetTenantsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+
+		resolver := tenant.InitOrGetTenantResolver(config)
+		var tenantIds []string
+		if resolver.IsSuperAdmin(user) {
+			tenantIds = resolver.Tenants()
+		} else {
+			tenantIds = []string{resolver.TenantOf(user.TenantId)}
+		}
+
+		result := []coreUserV1.CoreTenant{}
+		for _, tenantId := range tenantIds {
+			result = append(result, coreUserV1.CoreTenant{
+				Id:      tenantId,
+				Default: tenantId == resolver.DefaultTenant(),
+			})
+		}
+
+		httpHelper.WriteResponseEntity(w, result)
+	}
+}
+
+// GET personal access tokens of current user
+// @tags user
+// @Summary list my personal access tokens
+// @Description list personal access tokens of current user that have not expired, secret is never returned
+// @Produce  json
+// @Success 200 {array} coreUserV1.CorePersonalAccessToken
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
-// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/{userId}  [delete]
-func CreateRemoveUserFromGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me/tokens  [get]
+func CreateGetMyAccessTokensHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		if writeIfAccessTokenRequest(w, r) || writeIfImpersonatedRequest(w, r) {
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		userId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
 			return
 		}
 
-		userId := chi.URLParam(r, "userId")
-		if userId == "" {
-			http.Error(w, "missing user id", http.StatusBadRequest)
+		store, err := accesstoken.InitOrGetAccessTokenStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create personal access token store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		err = groupProvider.RemoveUserFromGroup(userId, groupId)
+		tokens, err := store.List(userId)
 		if err != nil {
-			if _, ok := err.(*customErr.NotFound); ok {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to remove user from group due to resource not found", http.StatusNotFound, "", err)
-				return
-			}
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to remove user from group", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list personal access tokens", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, nil)
+		httpHelper.WriteResponseEntity(w, tokens)
 	}
 }
 
-// GET group summary
-// @tags group
-// @Summary show group summary
-// @Description show group summary
+// POST personal access token of current user
+// @tags user
+// @Summary create my personal access token
+// @Description create a named and expiring token scoped to modules and permissions granted to current user
+// @Description carry it in header 'Authorization: Bearer <token>', secret is only returned in this response
 // @Accept  json
 // @Produce  json
-// @Success 200 {object} coreUserV1.CoreGroupSummary
+// @Param request body coreUserV1.CorePersonalAccessToken true "name, scope and expiresInDays"
+// @Success 200 {object} coreUserV1.CorePersonalAccessToken
 // @Failure 400 {object} httpHelper.CustomError
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/summary/all/count  [get]
-func CreateGetGroupSummaryHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me/tokens  [post]
+func CreateCreateMyAccessTokenHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
+		if writeIfAccessTokenRequest(w, r) || writeIfImpersonatedRequest(w, r) {
+			return
+		}
+
+		user, ok := getRequestUser(config, w, r)
+		if !ok {
+			return
+		}
+
+		tokenPost := &coreUserV1.CorePersonalAccessToken{}
+		result, err := io.ReadAll(r.Body)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
 			return
 		}
 
-		summary, err := groupProvider.GetGroupSummary(nil)
+		err = json.Unmarshal(result, tokenPost)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group summary", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, summary)
+		store, err := accesstoken.InitOrGetAccessTokenStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create personal access token store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		created, err := store.Create(user, tokenPost)
+		if err != nil {
+			if accesstoken.IsInvalid(err) {
+				httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create personal access token", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		coreApiLog.Logger.Warn("audit: personal access token created", "user", user.Name, "token", created.Id, "name", created.Name, "expiresAt", created.ExpiresAt)
+		httpHelper.WriteResponseEntity(w, created)
 	}
 }
 
-// PUT add users to group
-// @tags group
-// @Summary add users to group
-// @Description add users to group
-// @Accept  json
+// DELETE personal access token of current user
+// @tags user
+// @Summary revoke my personal access token
+// @Description revoke personal access token of current user, the token is rejected afterwards
 // @Produce  json
-// @Param groupId path string true "group id"
-// @Param request body []coreUserV1.CoreUser true "users to add to group"
-// @Success 200 {object} CustomErrorUsersAddToGroup
-// @Failure 400 {object} httpHelper.CustomError
+// @Param tokenId path string true "token id"
+// @Success 200 {object} coreUserV1.CorePersonalAccessToken
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users  [put]
-func CreateAddUsersToGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me/tokens/{tokenId}  [delete]
+func CreateDeleteMyAccessTokenHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		groupProvider, err := initOrGetGroupProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+		if writeIfAccessTokenRequest(w, r) || writeIfImpersonatedRequest(w, r) {
 			return
 		}
 
-		groupId := chi.URLParam(r, "groupId")
-		if groupId == "" {
-			http.Error(w, "missing group id", http.StatusBadRequest)
+		tokenId := chi.URLParam(r, "tokenId")
+		if tokenId == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Missing token id", http.StatusBadRequest, "", nil)
 			return
 		}
 
-		users := []coreUserV1.CoreUser{}
-		// convert request body to string
-		result, err := io.ReadAll(r.Body)
+		userId, err := getRequestUserId(config, r)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
 			return
 		}
 
-		err = json.Unmarshal(result, &users)
+		store, err := accesstoken.InitOrGetAccessTokenStore(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create personal access token store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		successes, failed, err := groupProvider.AddUsersToGroup(groupId, users)
+		err = store.Revoke(userId, tokenId)
 		if err != nil {
-			coreApiLog.Logger.Error("Failed to add users to group but will return 200", "error", err)
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Token not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to revoke personal access token", http.StatusInternalServerError, "", err)
+			return
 		}
 
-		httpHelper.WriteResponseEntity(w, &CustomErrorUsersAddToGroup{
-			Successes: successes,
-			Failed:    failed,
-		})
+		coreApiLog.Logger.Warn("audit: personal access token revoked", "user", userId, "token", tokenId)
+		httpHelper.WriteResponseEntity(w, &coreUserV1.CorePersonalAccessToken{Id: tokenId, UserId: userId})
 	}
 }
 
-// GET user list
+// PUT change password of current user
 // @tags user
-// @Summary list all users
-// @Description list all users
+// @Summary change my password
+// @Description change password of current user, old password is verified and new password has to meet password policy
+// @Description all sessions of user are revoked and a new session token is returned
 // @Accept  json
 // @Produce  json
-// @Param name query string false "filter with username e.g. ?name=ZhangSan"
-// @Param group query string false "filter with group e.g. ?group=id1&group=id2"
-// @Success 200 {array} coreUserV1.CoreUser
+// @Param request body coreUserV1.CorePasswordChange true "old and new password"
+// @Success 200 {object} coreUserV1.CoreUserToken
 // @Failure 400 {object} httpHelper.CustomError
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 429 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/users  [get]
-func CreateGetUsersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/me/password  [put]
+func CreateChangeMyPasswordHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userProvider, err := initOrGetUserProvider(config)
+		if writeIfAccessTokenRequest(w, r) || writeIfImpersonatedRequest(w, r) {
+			return
+		}
+
+		userId, err := getRequestUserId(config, r)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
 			return
 		}
 
-		var users []coreUserV1.CoreUser
+		passwordPost := &coreUserV1.CorePasswordChange{}
+		result, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			return
+		}
 
-		// get query name
-		name := r.URL.Query().Get("name")
-		if name != "" {
-			userFound, err := userProvider.SearchUserByName(name, nil)
-			if err != nil {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to search user by name", http.StatusInternalServerError, "", err)
-				return
-			}
-			users = []coreUserV1.CoreUser{*userFound}
-		} else {
-			usersFound, err := userProvider.GetUsers(nil)
-			if err != nil {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users", http.StatusInternalServerError, "", err)
+		err = json.Unmarshal(result, passwordPost)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		if passwordPost.OldPassword == "" || passwordPost.NewPassword == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Missing old or new password", http.StatusBadRequest, "", nil)
+			return
+		}
+
+		if passwordPost.OldPassword == passwordPost.NewPassword {
+			httpHelper.WriteCustomErrorAndLog(w, "Password is the same as the old one", http.StatusBadRequest, "", fmt.Errorf("password is the same as the old one"))
+			return
+		}
+
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userFound, err := userProvider.GetUser(userId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "User of request not found", http.StatusUnauthorized, "", err)
 				return
 			}
-			users = usersFound
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+			return
 		}
 
-		// get query groups
-		// filter users by group
-		groups := r.URL.Query()["group"]
-		if groups != nil {
-			flatGroups := map[string]struct{}{}
-			for _, group := range groups {
-				flatGroups[group] = struct{}{}
-			}
-			var filterGroups []coreUserV1.CoreUser
-			for _, user := range users {
-				for _, group := range user.Groups {
-					if _, ok := flatGroups[group.Id]; ok {
-						filterGroups = append(filterGroups, user)
-						break
-					}
-				}
+		// verifying old password is a login attempt, it is limited the same way
+		loginLimiter, err := lockout.InitOrGetLoginLimiter(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create login limiter", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		clientIp := lockout.ClientIp(r)
+		if err = loginLimiter.Check(userFound.Name, clientIp); err != nil {
+			writeLoginBlocked(w, err)
+			return
+		}
+
+		if _, err = auth.LoginTenantUser(userProvider, userFound.TenantId, userFound.Name, passwordPost.OldPassword); err != nil {
+			if customErr.IsUnauthorized(err) {
+				loginLimiter.RecordFailure(userFound.Name, clientIp)
+				httpHelper.WriteCustomErrorAndLog(w, "Old password is incorrect", http.StatusBadRequest, "", err)
+				return
 			}
-			users = filterGroups
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to verify old password", http.StatusInternalServerError, "", err)
+			return
 		}
+		loginLimiter.RecordSuccess(userFound.Name, clientIp)
 
-		if len(users) == 0 {
-			users = []coreUserV1.CoreUser{}
+		if err = checkNewPassword(config, userId, userFound.Name, passwordPost.NewPassword); err != nil {
+			writeNewPasswordError(w, err)
+			return
 		}
 
-		httpHelper.WriteResponseEntity(w, users)
+		// mustChangePassword is cleared along with the new password
+		err = userProvider.UpdateUser(&coreUserV1.CoreUser{Id: userId, Password: passwordPost.NewPassword}, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to change password", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		rememberPassword(config, userId, passwordPost.NewPassword)
+		revokeUserSessions(config, userId)
+		publishChange(event.UserKind, event.Updated, userId, userFound.Name)
+		coreApiLog.Logger.Warn("audit: password changed by user", "user", userFound.Name)
+
+		user, err := userProvider.GetUser(userId, map[string]struct{}{keystone.LoadPermission: {}})
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userToken, err := issueSessionToken(config, r, user)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to issue session token", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, userToken)
 	}
 }
 
-// GET device list
-// @tags device
-// @Summary show device list
-// @Description show device list
+// GET group list
+// @tags group
+// @Summary list all groups
+// @Description list all groups, sortable by name and description
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} deviceV1.Device
+// @Param q query string false "case insensitive substring of name or description e.g. ?q=class"
+// @Param sort query string false "field to sort by, '-' for descending order e.g. ?sort=-name"
+// @Param page query int false "1 based page, whole list is returned if page and pageSize are not given e.g. ?page=2"
+// @Param pageSize query int false "items per page, default to 20 and at most 500 e.g. ?pageSize=50"
+// @Success 200 {array} coreUserV1.CoreGroup
+// @Header 200 {int} X-Total-Count "count of matched items before paging"
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/devices  [get]
-func CreateOpenhydraGetDevicesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups  [get]
+func CreateGetGroupsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		groups, err := groupProvider.GetGroups(nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groups = filterTenant(config, r, groups, func(group coreUserV1.CoreGroup) string { return group.TenantId })
+		writeListPage(w, r, groups, groupListFields)
 	}
 }
 
-// GET device detail
-// @tags device
-// @Summary get detail of device with given username
-// @Description get detail of device with given username
+// GET group detail
+// @tags group
+// @Summary get detail of group with given username
+// @Description get detail of group with given username
 // @Accept  json
 // @Produce  json
-// @Param userId path string true "user id"
-// @Success 200 {object} deviceV1.Device
+// @Param groupId path string true "group id"
+// @Success 200 {object} coreUserV1.CoreGroup
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/devices/{userId}  [get]
-func CreateOpenhydraGetDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [get]
+func CreateGetGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		group, err := groupProvider.GetGroup(groupId, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if writeIfOtherTenant(config, w, r, "group", groupId, group.TenantId) {
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, group)
 	}
 }
 
-// POST create device
-// @tags device
-// @Summary create device
-// @Description create device
+// POST create group
+// @tags group
+// @Summary create group
+// @Description create group
 // @Accept  json
 // @Produce  json
-// @Param request body deviceV1.Device true "device post"
-// @Success 200 {object} deviceV1.Device
+// @Param request body coreUserV1.CoreGroup true "group post"
+// @Success 200 {object} coreUserV1.CoreGroup
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/devices  [post]
-func CreateOpenhydraCreateDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups  [post]
+func CreateCreateGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupPost := &coreUserV1.CoreGroup{}
+		// convert request body to string
+		result, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		err = json.Unmarshal(result, groupPost)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		groupPost.TenantId = tenant.FromRequest(r)
+		groupCreated, err := groupProvider.CreateGroup(groupPost, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group", http.StatusInternalServerError, "", err)
+			return
+		}
+		publishChange(event.GroupKind, event.Created, groupCreated.Id, groupCreated.Name)
+
+		httpHelper.WriteResponseEntity(w, groupCreated)
 	}
 }
 
-// DELETE delete device
-// @tags device
-// @Summary delete device with given username
-// @Description delete device with given username
+// PUT update group
+// @tags group
+// @Summary update group
+// @Description update group
 // @Accept  json
 // @Produce  json
-// @Param userId path string true "user id"
-// @Success 200 {object} deviceV1.Device
+// @Param groupId path string true "group id"
+// @Param request body coreUserV1.CoreGroup true "group post"
+// @Success 200 {object} coreUserV1.CoreGroup
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/devices/{userId}  [delete]
-func CreateOpenhydraDeleteDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [put]
+func CreateUpdateGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupPost := &coreUserV1.CoreGroup{}
+		// convert request body to string
+		result, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		err = json.Unmarshal(result, groupPost)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		groupPost.Id = groupId
+
+		groupFound, err := groupProvider.GetGroup(groupId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Group not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if writeIfOtherTenant(config, w, r, "group", groupId, groupFound.TenantId) {
+			return
+		}
+		// tenant of group can not be changed
+		groupPost.TenantId = groupFound.TenantId
+
+		err = groupProvider.UpdateGroup(groupPost, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to update group", http.StatusInternalServerError, "", err)
+			return
+		}
+		publishChange(event.GroupKind, event.Updated, groupId, groupPost.Name)
+
+		httpHelper.WriteResponseEntity(w, groupPost)
 	}
 }
 
-// GET course list
-// @tags course
-// @Summary show course list
-// @Description show course list
+// DELETE delete group
+// @tags group
+// @Summary delete group
+// @Description delete group
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} courseV1.Course
+// @Param groupId path string true "group id"
+// @Success 200 {object} coreUserV1.CoreGroup
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/courses  [get]
-func CreateOpenhydraGetCoursesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}  [delete]
+func CreateDeleteGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-	}
-}
-
-// GET course detail
-// @tags course
-// @Summary show course detail
-// @Description show course detail
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		err = groupProvider.DeleteGroup(groupId, nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to delete group", http.StatusInternalServerError, "", err)
+			return
+		}
+		publishChange(event.GroupKind, event.Deleted, groupId, "")
+
+		httpHelper.WriteResponseEntity(w, nil)
+	}
+}
+
+// GET user in group
+// @tags group
+// @Summary list all users in group
+// @Description list all users in group
 // @Accept  json
 // @Produce  json
-// @Param courseId path string true "course id"
-// @Success 200 {object} courseV1.Course
+// @Param groupId path string true "group id"
+// @Success 200 {array} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/courses/{courseId}  [get]
-func CreateOpenhydraGetCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users  [get]
+func CreateGetGroupUsersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		users, err := groupProvider.GetGroupUsers(groupId, nil)
+		if err != nil {
+			if _, ok := err.(*customErr.NotFound); ok {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users from group due to resource not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group users", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if users == nil {
+			users = []coreUserV1.CoreUser{}
+		}
+
+		httpHelper.WriteResponseEntity(w, users)
 	}
 }
 
-// POST create course
-// @tags course
-// @Summary create course
-// @Description create course
+// Get user not in group
+// @tags group
+// @Summary list all users not in group
+// @Description list all users not in group
 // @Accept  json
 // @Produce  json
-// @Param request body courseV1.Course true "course post"
-// @Success 200 {object} courseV1.Course
+// @Param groupId path string true "group id"
+// @Success 200 {array} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/courses  [post]
-func CreateOpenhydraCreateCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/not-in-group/list  [get]
+func CreateGetGroupUsersNotInGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		users, err := groupProvider.GetGroupUsers(groupId, map[string]struct{}{keystone.ReverseGetGroupUsers: {}})
+		if err != nil {
+			if _, ok := err.(*customErr.NotFound); ok {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users not in group due to resource not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group users not in", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		users = filterTenant(config, r, users, func(user coreUserV1.CoreUser) string { return user.TenantId })
+		if users == nil {
+			users = []coreUserV1.CoreUser{}
+		}
+
+		httpHelper.WriteResponseEntity(w, users)
 	}
 }
 
-// DELETE delete course
-// @tags course
-// @Summary delete course
-// @Description delete course
+// PUT add user to group
+// @tags group
+// @Summary add user to group
+// @Description add user to group
 // @Accept  json
 // @Produce  json
-// @Param courseId path string true "course id"
-// @Success 200 {object} courseV1.Course
+// @Param groupId path string true "group id"
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/courses/{courseId}  [delete]
-func CreateOpenhydraDeleteCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/{userId}  [put]
+func CreateAddUserToGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) || !checkUserTenant(config, w, r, userId) {
+			return
+		}
+
+		err = groupProvider.AddUserToGroup(userId, groupId)
+		if err != nil {
+			if _, ok := err.(*customErr.NotFound); ok {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to add user to group due to resource not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to add user to group", http.StatusInternalServerError, "", err)
+			return
+		}
+		publishChange(event.UserKind, event.Updated, userId, "")
+
+		httpHelper.WriteResponseEntity(w, nil)
 	}
 }
 
-// GET dataset list
-// @tags dataset
-// @Summary show dataset list
-// @Description show dataset list
+// DELETE remove user from group
+// @tags group
+// @Summary remove user from group
+// @Description remove user from group
 // @Accept  json
 // @Produce  json
-// @Success 200 {array} datasetV1.Dataset
+// @Param groupId path string true "group id"
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreUser
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/datasets  [get]
-func CreateOpenhydraGetDatasetsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users/{userId}  [delete]
+func CreateRemoveUserFromGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) || !checkUserTenant(config, w, r, userId) {
+			return
+		}
+
+		err = groupProvider.RemoveUserFromGroup(userId, groupId)
+		if err != nil {
+			if _, ok := err.(*customErr.NotFound); ok {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to remove user from group due to resource not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to remove user from group", http.StatusInternalServerError, "", err)
+			return
+		}
+		publishChange(event.UserKind, event.Updated, userId, "")
+
+		httpHelper.WriteResponseEntity(w, nil)
+	}
+}
+
+// GET administrators of group
+// @tags group
+// @Summary list administrators of group
+// @Description list users designated administrator of group, they manage members of the group without global permission
+// @Produce  json
+// @Param groupId path string true "group id"
+// @Success 200 {array} coreUserV1.CoreGroupAdmin
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/admins  [get]
+func CreateGetGroupAdminsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		store, err := groupadmin.InitOrGetGroupAdminStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group admin store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		admins, err := store.ListAdmins(groupId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list administrators of group", http.StatusInternalServerError, "", err)
+			return
+		}
+		httpHelper.WriteResponseEntity(w, admins)
 	}
 }
 
-// GET dataset detail
-// @tags dataset
-// @Summary show dataset detail
-// @Description show dataset detail
+// PUT designate administrator of group
+// @tags group
+// @Summary designate user administrator of group
+// @Description administrator of group views, updates, disables and enables members of the group, ends their sessions and manages their devices and conversations
+// @Description roles and groups of members can not be changed by administrator of group, designating an administrator again changes nothing
+// @Produce  json
+// @Param groupId path string true "group id"
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreGroupAdmin
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/admins/{userId}  [put]
+func CreateAddGroupAdminHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupId := chi.URLParam(r, "groupId")
+		userId := chi.URLParam(r, "userId")
+		if groupId == "" || userId == "" {
+			http.Error(w, "missing group id or user id", http.StatusBadRequest)
+			return
+		}
+
+		requestUserId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		group, err := groupProvider.GetGroup(groupId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Group not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
+			return
+		}
+		if writeIfOtherTenant(config, w, r, "group", groupId, group.TenantId) {
+			return
+		}
+
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		user, err := userProvider.GetUser(userId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+			return
+		}
+		if writeIfOtherTenant(config, w, r, "user", userId, user.TenantId) {
+			return
+		}
+
+		store, err := groupadmin.InitOrGetGroupAdminStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group admin store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		admin, err := store.Add(&coreUserV1.CoreGroupAdmin{GroupId: groupId, UserId: userId, UserName: user.Name, CreatedBy: requestUserId})
+		if err != nil {
+			if groupadmin.IsInvalid(err) {
+				httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to designate administrator of group", http.StatusInternalServerError, "", err)
+			return
+		}
+		coreApiLog.Logger.Warn("audit: group administrator designated", "group", group.Name, "user", user.Name, "by", requestUserId)
+
+		httpHelper.WriteResponseEntity(w, admin)
+	}
+}
+
+// DELETE administrator of group
+// @tags group
+// @Summary take user off administrators of group
+// @Description take user off administrators of group, membership of user is not changed
+// @Produce  json
+// @Param groupId path string true "group id"
+// @Param userId path string true "user id"
+// @Success 200 {object} coreUserV1.CoreGroupAdmin
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/admins/{userId}  [delete]
+func CreateRemoveGroupAdminHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupId := chi.URLParam(r, "groupId")
+		userId := chi.URLParam(r, "userId")
+		if groupId == "" || userId == "" {
+			http.Error(w, "missing group id or user id", http.StatusBadRequest)
+			return
+		}
+
+		requestUserId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		store, err := groupadmin.InitOrGetGroupAdminStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group admin store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		err = store.Remove(groupId, userId)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Administrator of group not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to remove administrator of group", http.StatusInternalServerError, "", err)
+			return
+		}
+		coreApiLog.Logger.Warn("audit: group administrator removed", "group", groupId, "user", userId, "by", requestUserId)
+
+		httpHelper.WriteResponseEntity(w, &coreUserV1.CoreGroupAdmin{GroupId: groupId, UserId: userId})
+	}
+}
+
+// GET groups current user administers
+// @tags user
+// @Summary list groups current user administers
+// @Description list groups current user is designated administrator of, a group deleted meanwhile is left out
+// @Produce  json
+// @Success 200 {array} coreUserV1.CoreGroup
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/me/administered-groups  [get]
+func CreateGetAdministeredGroupsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		store, err := groupadmin.InitOrGetGroupAdminStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group admin store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groups := []coreUserV1.CoreGroup{}
+		for _, groupId := range store.GroupsOf(userId) {
+			group, err := groupProvider.GetGroup(groupId, nil)
+			if err != nil {
+				if customErr.IsNotFound(err) {
+					continue
+				}
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
+				return
+			}
+			groups = append(groups, *group)
+		}
+		httpHelper.WriteResponseEntity(w, groups)
+	}
+}
+
+// GET group summary
+// @tags group
+// @Summary show group summary
+// @Description show group summary
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} coreUserV1.CoreGroupSummary
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/summary/all/count  [get]
+func CreateGetGroupSummaryHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		summary, err := groupProvider.GetGroupSummary(nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group summary", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if tenant.FromRequest(r) != "" {
+			groups, err := groupProvider.GetGroups(nil)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups", http.StatusInternalServerError, "", err)
+				return
+			}
+			tenantGroups := map[string]struct{}{}
+			for _, group := range filterTenant(config, r, groups, func(group coreUserV1.CoreGroup) string { return group.TenantId }) {
+				tenantGroups[group.Id] = struct{}{}
+			}
+			counts := []coreUserV1.CoreGroupSummaryDetail{}
+			for _, count := range summary.Counts {
+				if _, found := tenantGroups[count.Id]; found {
+					counts = append(counts, count)
+				}
+			}
+			summary.Counts = counts
+		}
+
+		httpHelper.WriteResponseEntity(w, summary)
+	}
+}
+
+// PUT add users to group
+// @tags group
+// @Summary add users to group
+// @Description add users to group
+// @Accept  json
+// @Produce  json
+// @Param groupId path string true "group id"
+// @Param request body []coreUserV1.CoreUser true "users to add to group"
+// @Success 200 {object} CustomErrorUsersAddToGroup
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/{groupId}/users  [put]
+func CreateAddUsersToGroupHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		groupId := chi.URLParam(r, "groupId")
+		if groupId == "" {
+			http.Error(w, "missing group id", http.StatusBadRequest)
+			return
+		}
+
+		users := []coreUserV1.CoreUser{}
+		// convert request body to string
+		result, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		err = json.Unmarshal(result, &users)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		if !checkGroupTenant(config, w, r, groupId) {
+			return
+		}
+
+		// users of another tenant are reported as failed
+		var otherTenantUsers []coreUserV1.CoreUser
+		if tenant.FromRequest(r) != "" {
+			userProvider, err := initOrGetUserProvider(config)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+				return
+			}
+
+			var tenantUsers []coreUserV1.CoreUser
+			for _, user := range users {
+				userFound, err := userProvider.GetUser(user.Id, nil)
+				if err != nil || !tenant.Visible(tenant.InitOrGetTenantResolver(config), tenant.FromRequest(r), userFound.TenantId) {
+					otherTenantUsers = append(otherTenantUsers, user)
+					continue
+				}
+				tenantUsers = append(tenantUsers, user)
+			}
+			users = tenantUsers
+		}
+
+		successes, failed, err := groupProvider.AddUsersToGroup(groupId, users)
+		if err != nil {
+			coreApiLog.Logger.Error("Failed to add users to group but will return 200", "error", err)
+		}
+		failed = append(failed, otherTenantUsers...)
+		for _, user := range successes {
+			publishChange(event.UserKind, event.Updated, user.Id, user.Name)
+		}
+
+		httpHelper.WriteResponseEntity(w, &CustomErrorUsersAddToGroup{
+			Successes: successes,
+			Failed:    failed,
+		})
+	}
+}
+
+// GET user list
+// @tags user
+// @Summary list all users
+// @Description list all users, sortable by name, email and description, q searches email as well
+// @Accept  json
+// @Produce  json
+// @Param name query string false "filter with username e.g. ?name=ZhangSan"
+// @Param group query string false "filter with group e.g. ?group=id1&group=id2"
+// @Param role query string false "filter with role e.g. ?role=id1&role=id2"
+// @Param includeDisabled query bool false "include disabled users e.g. ?includeDisabled=true"
+// @Param q query string false "case insensitive substring of name, description or email e.g. ?q=class"
+// @Param sort query string false "field to sort by, '-' for descending order e.g. ?sort=-name"
+// @Param page query int false "1 based page, whole list is returned if page and pageSize are not given e.g. ?page=2"
+// @Param pageSize query int false "items per page, default to 20 and at most 500 e.g. ?pageSize=50"
+// @Success 200 {array} coreUserV1.CoreUser
+// @Header 200 {int} X-Total-Count "count of matched items before paging"
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users  [get]
+func CreateGetUsersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		var users []coreUserV1.CoreUser
+
+		// disabled users are left out unless they are asked for
+		var options map[string]struct{}
+		if r.URL.Query().Get("includeDisabled") == "true" {
+			options = map[string]struct{}{keystone.IncludeDisabledUsers: {}}
+		}
+
+		// get query name
+		name := r.URL.Query().Get("name")
+		if name != "" {
+			userFound, err := auth.SearchTenantUserByName(userProvider, tenant.FromRequest(r), name, options)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to search user by name", http.StatusInternalServerError, "", err)
+				return
+			}
+			users = []coreUserV1.CoreUser{*userFound}
+		} else {
+			usersFound, err := userProvider.GetUsers(options)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get users", http.StatusInternalServerError, "", err)
+				return
+			}
+			users = usersFound
+		}
+		users = filterTenant(config, r, users, func(user coreUserV1.CoreUser) string { return user.TenantId })
+
+		// group administrator lacking permission only sees members of groups it administers
+		if !hasPermission(r, "user", privileges.PermissionUserList) {
+			store, err := groupadmin.InitOrGetGroupAdminStore(config)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get group admin store", http.StatusInternalServerError, "", err)
+				return
+			}
+			users = filterUsersByBinding(users, store.GroupsOf(r.Context().Value("core-user").(*coreUserV1.CoreUser).Id), groupIdsOf)
+		}
+
+		// get query groups
+		// filter users by group
+		groups := r.URL.Query()["group"]
+		if groups != nil {
+			users = filterUsersByBinding(users, groups, groupIdsOf)
+		}
+
+		// filter users by role
+		roles := r.URL.Query()["role"]
+		if roles != nil {
+			users = filterUsersByBinding(users, roles, roleIdsOf)
+		}
+
+		writeListPage(w, r, users, userListFields)
+	}
+}
+
+// GET device list
+// @tags device
+// @Summary show device list
+// @Description show device list
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} deviceV1.Device
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/devices  [get]
+func CreateOpenhydraGetDevicesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// GET device detail
+// @tags device
+// @Summary get detail of device with given username
+// @Description get detail of device with given username
+// @Accept  json
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} deviceV1.Device
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/devices/{userId}  [get]
+func CreateOpenhydraGetDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// POST create device
+// @tags device
+// @Summary create device
+// @Description create device
+// @Accept  json
+// @Produce  json
+// @Param request body deviceV1.Device true "device post"
+// @Success 200 {object} deviceV1.Device
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/devices  [post]
+func CreateOpenhydraCreateDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// DELETE delete device
+// @tags device
+// @Summary delete device with given username
+// @Description delete device with given username
+// @Accept  json
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {object} deviceV1.Device
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/devices/{userId}  [delete]
+func CreateOpenhydraDeleteDeviceHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// GET course list
+// @tags course
+// @Summary show course list
+// @Description show course list
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} courseV1.Course
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/courses  [get]
+func CreateOpenhydraGetCoursesHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// GET course detail
+// @tags course
+// @Summary show course detail
+// @Description show course detail
+// @Accept  json
+// @Produce  json
+// @Param courseId path string true "course id"
+// @Success 200 {object} courseV1.Course
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/courses/{courseId}  [get]
+func CreateOpenhydraGetCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// POST create course
+// @tags course
+// @Summary create course
+// @Description create course
+// @Accept  json
+// @Produce  json
+// @Param request body courseV1.Course true "course post"
+// @Success 200 {object} courseV1.Course
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/courses  [post]
+func CreateOpenhydraCreateCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// DELETE delete course
+// @tags course
+// @Summary delete course
+// @Description delete course
+// @Accept  json
+// @Produce  json
+// @Param courseId path string true "course id"
+// @Success 200 {object} courseV1.Course
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/courses/{courseId}  [delete]
+func CreateOpenhydraDeleteCourseHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// GET dataset list
+// @tags dataset
+// @Summary show dataset list
+// @Description show dataset list
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} datasetV1.Dataset
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/datasets  [get]
+func CreateOpenhydraGetDatasetsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// GET dataset detail
+// @tags dataset
+// @Summary show dataset detail
+// @Description show dataset detail
+// @Accept  json
+// @Produce  json
+// @Param datasetId path string true "dataset id"
+// @Success 200 {object} datasetV1.Dataset
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/datasets/{datasetId}  [get]
+func CreateOpenhydraGetDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// POST create dataset
+// @tags dataset
+// @Summary create dataset
+// @Description create dataset
+// @Accept  json
+// @Produce  json
+// @Param request body datasetV1.Dataset true "login params"
+// @Success 200 {object} datasetV1.Dataset
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/datasets  [post]
+func CreateOpenhydraCreateDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// DELETE delete dataset
+// @tags dataset
+// @Summary delete dataset
+// @Description delete dataset
+// @Accept  json
+// @Produce  json
+// @Param datasetId path string true "dataset id"
+// @Success 200 {object} datasetV1.Dataset
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/open-hydra-server.openhydra.io/v1/datasets/{datasetId}  [delete]
+func CreateOpenhydraDeleteDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	// for calling to south api we only define a fake handler
+	// to generate swagger doc
+	return func(w http.ResponseWriter, r *http.Request) {
+	}
+}
+
+// POST user login
+// @tags user
+// @Summary user login
+// @Description user login, set tenantId to log in a user of tenant other than default one
+// @Accept  json
+// @Produce  json
+// @Param request body coreUserV1.CoreUser true "login params"
+// @Success 200 {object} coreUserV1.CoreUserToken
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 429 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/login  [post]
+func CreateLoginHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		userPost := &coreUserV1.CoreUser{}
+		// convert request body to string
+		result, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		err = json.Unmarshal(result, userPost)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+
+		if userPost.Name == "" || userPost.Password == "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Missing user name or password", http.StatusBadRequest, "", nil)
+			return
+		}
+
+		if userPost.TenantId != "" && !tenant.InitOrGetTenantResolver(config).Serves(userPost.TenantId) {
+			httpHelper.WriteCustomErrorAndLog(w, "Tenant is not served", http.StatusBadRequest, "", fmt.Errorf("tenant '%s' is not served", userPost.TenantId))
+			return
+		}
+
+		loginLimiter, err := lockout.InitOrGetLoginLimiter(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create login limiter", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		clientIp := lockout.ClientIp(r)
+		if err = loginLimiter.Check(userPost.Name, clientIp); err != nil {
+			writeLoginBlocked(w, err)
+			return
+		}
+
+		// user name is only unique in its tenant, user of default tenant leaves tenantId blank
+		user, err := auth.LoginTenantUser(userProvider, userPost.TenantId, userPost.Name, userPost.Password)
+		if err != nil {
+			// check err is unauthorized
+			if _, ok := err.(*customErr.Unauthorized); ok {
+				loginLimiter.RecordFailure(userPost.Name, clientIp)
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to login user", http.StatusInternalServerError, "", err)
+			return
+		}
+		loginLimiter.RecordSuccess(userPost.Name, clientIp)
+
+		userToken, err := issueSessionToken(config, r, user)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to issue session token", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, userToken)
+	}
+}
+
+// GET oidc login
+// @tags user
+// @Summary start single sign-on login
+// @Description redirect browser to openid connect identity provider, authorization code flow with PKCE is used
+// @Success 302
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/login/oidc  [get]
+func CreateOIDCLoginHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		oidcClient, err := oidc.InitOrGetOIDCClient(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Single sign-on is not available", http.StatusNotFound, "", err)
+			return
+		}
+
+		redirectUrl, sealedLogin, err := oidcClient.AuthCodeURL(r.Context())
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to start single sign-on", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		// callback is accepted only from the browser that starts the login
+		http.SetCookie(w, oidc.LoginCookie(config.AuthConfig.OIDC, sealedLogin))
+		http.Redirect(w, r, redirectUrl, http.StatusFound)
+	}
+}
+
+// GET oidc login callback
+// @tags user
+// @Summary finish single sign-on login
+// @Description identity provider redirects browser here, user is provisioned on first login and a session token is issued
+// @Produce  json
+// @Param code query string true "authorization code"
+// @Param state query string true "state"
+// @Success 200 {object} coreUserV1.CoreUserToken
+// @Success 302
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/login/oidc/callback  [get]
+func CreateOIDCCallbackHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		oidcClient, err := oidc.InitOrGetOIDCClient(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Single sign-on is not available", http.StatusNotFound, "", err)
+			return
+		}
+
+		if idpError := r.URL.Query().Get("error"); idpError != "" {
+			httpHelper.WriteCustomErrorAndLog(w, "Identity provider rejected login", http.StatusUnauthorized, "", fmt.Errorf("%s: %s", idpError, r.URL.Query().Get("error_description")))
+			return
+		}
+
+		loginCookie, err := r.Cookie(oidc.LoginCookieName)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", fmt.Errorf("login cookie is missing, login has to be started from this browser"))
+			return
+		}
+		http.SetCookie(w, oidc.LoginCookie(config.AuthConfig.OIDC, ""))
+
+		identity, err := oidcClient.Exchange(r.Context(), r.URL.Query().Get("code"), r.URL.Query().Get("state"), loginCookie.Value)
+		if err != nil {
+			if customErr.IsUnauthorized(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to finish single sign-on", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		roleProvider, err := initOrGetRoleProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create role provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		links, err := oidc.InitOrGetLinkStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create oidc link store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		user, err := oidc.Provision(config.AuthConfig.OIDC, identity, links, userProvider, roleProvider, groupProvider)
+		if err != nil {
+			if customErr.IsUnauthorized(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to provision user", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userToken, err := issueSessionToken(config, r, user)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to issue session token", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if config.AuthConfig.OIDC.PostLoginRedirectUrl != "" {
+			// fragment is never sent to any server so token does not end up in access log
+			fragment := url.Values{
+				"token":     {userToken.Token},
+				"expiresAt": {fmt.Sprintf("%d", userToken.ExpiresAt)},
+			}
+			http.Redirect(w, r, config.AuthConfig.OIDC.PostLoginRedirectUrl+"#"+fragment.Encode(), http.StatusFound)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, userToken)
+	}
+}
+
+// GET oidc links of user
+// @tags user
+// @Summary list identities user is linked to
+// @Description list issuer and subject of openid connect identity provider user is linked to, single sign-on logs in linked user only
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {array} coreUserV1.CoreOIDCLink
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/oidc-links  [get]
+func CreateGetOIDCLinksHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+
+		links, err := oidc.InitOrGetLinkStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create oidc link store", http.StatusInternalServerError, "", err)
+			return
+		}
+		httpHelper.WriteResponseEntity(w, links.LinksOf(userId))
+	}
+}
+
+// PUT oidc link of user
+// @tags user
+// @Summary link user to identity
+// @Description link user to subject of openid connect identity provider, e.g. an existing user who starts to log in with single sign-on
+// @Description issuer defaults to issuer of config, a subject linked to another user is rejected
+// @Accept  json
+// @Produce  json
+// @Param userId path string true "user id"
+// @Param request body coreUserV1.CoreOIDCLink true "link"
+// @Success 200 {object} coreUserV1.CoreOIDCLink
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/oidc-links  [put]
+func CreatePutOIDCLinkHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		linkPost := &coreUserV1.CoreOIDCLink{}
+		if err := json.NewDecoder(r.Body).Decode(linkPost); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			return
+		}
+		if linkPost.Issuer == "" && config.AuthConfig.OIDC != nil {
+			linkPost.Issuer = config.AuthConfig.OIDC.IssuerUrl
+		}
+
+		requestUserId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+		user, err := userProvider.GetUser(userId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "User not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+			return
+		}
+		if writeIfOtherTenant(config, w, r, "user", userId, user.TenantId) {
+			return
+		}
+
+		links, err := oidc.InitOrGetLinkStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create oidc link store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		link, err := links.Link(&coreUserV1.CoreOIDCLink{Issuer: linkPost.Issuer, Subject: linkPost.Subject, UserId: userId, UserName: user.Name, CreatedBy: requestUserId})
+		if err != nil {
+			if oidc.IsInvalid(err) {
+				httpHelper.WriteCustomErrorAndLog(w, err.Error(), http.StatusBadRequest, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to link user", http.StatusInternalServerError, "", err)
+			return
+		}
+		coreApiLog.Logger.Warn("audit: user linked to identity", "user", user.Name, "issuer", link.Issuer, "subject", link.Subject, "by", requestUserId)
+
+		httpHelper.WriteResponseEntity(w, link)
+	}
+}
+
+// DELETE oidc links of user
+// @tags user
+// @Summary unlink user from identities
+// @Description remove every link of user to openid connect identity provider, user can not log in with single sign-on until linked again
+// @Produce  json
+// @Param userId path string true "user id"
+// @Success 200 {array} coreUserV1.CoreOIDCLink
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/oidc-links  [delete]
+func CreateDeleteOIDCLinksHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		requestUserId, err := getRequestUserId(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+
+		links, err := oidc.InitOrGetLinkStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create oidc link store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		removed := links.LinksOf(userId)
+		if _, err := links.DeleteUser(userId); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to unlink user", http.StatusInternalServerError, "", err)
+			return
+		}
+		coreApiLog.Logger.Warn("audit: user unlinked from identities", "user", userId, "total", len(removed), "by", requestUserId)
+
+		httpHelper.WriteResponseEntity(w, removed)
+	}
+}
+
+// POST refresh session token
+// @tags user
+// @Summary exchange a valid session token for a new one
+// @Description exchange a valid session token for a new one, the old session is revoked and permission is reloaded
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} coreUserV1.CoreUserToken
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 401 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/login/refresh  [post]
+func CreateRefreshTokenHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := getRequestSessionClaims(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get session from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		sessionStore, err := session.InitOrGetSessionStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create session store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userProvider, err := initOrGetUserProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		// reload user so role changes are reflected in new token
+		user, err := userProvider.GetUser(claims.UserId, map[string]struct{}{keystone.LoadPermission: {}})
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "User of session not found", http.StatusUnauthorized, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get user", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		// user may be disabled in identity provider without core-api revoking its sessions, same as login
+		if user.Disabled {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", fmt.Errorf("user '%s' is disabled", user.Name))
+			return
+		}
+
+		// old session is revoked before new token is issued, only one of requests refreshing the same token gets a new one
+		// session may be unknown to store after restart, token of it is still revoked
+		err = sessionStore.RevokeIfActive(session.SessionFromClaims(claims))
+		if err != nil {
+			if customErr.IsUnauthorized(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Session has been revoked", http.StatusUnauthorized, "", fmt.Errorf("session %s has been revoked", claims.SessionId))
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to revoke refreshed session", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		userToken, err := issueSessionToken(config, r, user)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to issue session token", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, userToken)
+	}
+}
+
+// POST user logout
+// @tags user
+// @Summary revoke current session
+// @Description revoke session of the token carried by request, the token is rejected afterwards
 // @Accept  json
 // @Produce  json
-// @Param datasetId path string true "dataset id"
-// @Success 200 {object} datasetV1.Dataset
-// @Failure 400 {object} httpHelper.CustomError
-// @Failure 403 {object} httpHelper.CustomError
-// @Failure 404 {object} httpHelper.CustomError
+// @Success 200 {object} coreUserV1.CoreSession
+// @Failure 401 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/datasets/{datasetId}  [get]
-func CreateOpenhydraGetDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/users/logout  [post]
+func CreateLogoutHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := getRequestSessionClaims(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get session from request", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		sessionStore, err := session.InitOrGetSessionStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create session store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		toRevoke := session.SessionFromClaims(claims)
+		err = sessionStore.Touch(toRevoke)
+		if err != nil {
+			// already revoked
+			httpHelper.WriteCustomErrorAndLog(w, "Session has been revoked", http.StatusUnauthorized, "", err)
+			return
+		}
+
+		err = sessionStore.Revoke(claims.SessionId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to revoke session", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, toRevoke)
 	}
 }
 
-// POST create dataset
-// @tags dataset
-// @Summary create dataset
-// @Description create dataset
+// GET user sessions
+// @tags user
+// @Summary list active sessions of user
+// @Description list active sessions of user
 // @Accept  json
 // @Produce  json
-// @Param request body datasetV1.Dataset true "login params"
-// @Success 200 {object} datasetV1.Dataset
+// @Param userId path string true "user id"
+// @Success 200 {array} coreUserV1.CoreSession
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/datasets  [post]
-func CreateOpenhydraCreateDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/sessions  [get]
+func CreateGetUserSessionsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+		if !checkGroupAdminOf(config, w, r, "user", privileges.PermissionUserList, userId) {
+			return
+		}
+
+		sessionStore, err := session.InitOrGetSessionStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create session store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		sessions, err := sessionStore.ListUserSessions(userId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list user sessions", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, sessions)
 	}
 }
 
-// DELETE delete dataset
-// @tags dataset
-// @Summary delete dataset
-// @Description delete dataset
+// DELETE user sessions
+// @tags user
+// @Summary revoke all sessions of user
+// @Description revoke all sessions of user, e.g. when credential of user is leaked
 // @Accept  json
 // @Produce  json
-// @Param datasetId path string true "dataset id"
-// @Success 200 {object} datasetV1.Dataset
+// @Param userId path string true "user id"
+// @Success 200 {array} coreUserV1.CoreSession
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
-// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/open-hydra-server.openhydra.io/v1/datasets/{datasetId}  [delete]
-func CreateOpenhydraDeleteDatasetHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
-	// for calling to south api we only define a fake handler
-	// to generate swagger doc
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/sessions  [delete]
+func CreateDeleteUserSessionsHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		userId := chi.URLParam(r, "userId")
+		if userId == "" {
+			http.Error(w, "missing user id", http.StatusBadRequest)
+			return
+		}
+
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+		if !checkGroupAdminOf(config, w, r, "user", privileges.PermissionUserUpdate, userId) {
+			return
+		}
+
+		sessionStore, err := session.InitOrGetSessionStore(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create session store", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		sessions, err := sessionStore.ListUserSessions(userId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to list user sessions", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		_, err = sessionStore.RevokeUserSessions(userId)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to revoke user sessions", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		httpHelper.WriteResponseEntity(w, sessions)
 	}
 }
 
-// POST user login
+// DELETE user session
 // @tags user
-// @Summary user login
-// @Description user login
+// @Summary revoke a session of user
+// @Description revoke a session of user
 // @Accept  json
 // @Produce  json
-// @Param request body coreUserV1.CoreUser true "login params"
-// @Success 200 {object} coreUserV1.CoreUser
+// @Param userId path string true "user id"
+// @Param sessionId path string true "session id"
+// @Success 200 {object} coreUserV1.CoreSession
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
-// @Router /apis/core-api.openhydra.io/v1/users/login  [post]
-func CreateLoginHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+// @Router /apis/core-api.openhydra.io/v1/users/{userId}/sessions/{sessionId}  [delete]
+func CreateDeleteUserSessionHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userProvider, err := initOrGetUserProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
+		userId := chi.URLParam(r, "userId")
+		sessionId := chi.URLParam(r, "sessionId")
+		if userId == "" || sessionId == "" {
+			http.Error(w, "missing user id or session id", http.StatusBadRequest)
 			return
 		}
-		userPost := &coreUserV1.CoreUser{}
-		// convert request body to string
-		result, err := io.ReadAll(r.Body)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to read request body", http.StatusBadRequest, "", err)
+
+		if !checkUserTenant(config, w, r, userId) {
+			return
+		}
+		if !checkGroupAdminOf(config, w, r, "user", privileges.PermissionUserUpdate, userId) {
 			return
 		}
 
-		err = json.Unmarshal(result, userPost)
+		sessionStore, err := session.InitOrGetSessionStore(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal request body", http.StatusBadRequest, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create session store", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		if userPost.Name == "" || userPost.Password == "" {
-			httpHelper.WriteCustomErrorAndLog(w, "Missing user name or password", http.StatusBadRequest, "", nil)
+		toRevoke, err := sessionStore.GetSession(sessionId)
+		if err != nil || toRevoke.UserId != userId {
+			httpHelper.WriteCustomErrorAndLog(w, "Session not found", http.StatusNotFound, "", fmt.Errorf("session %s of user %s not found", sessionId, userId))
 			return
 		}
 
-		user, err := userProvider.LoginUser(userPost.Name, userPost.Password)
+		err = sessionStore.Revoke(sessionId)
 		if err != nil {
-			// check err is unauthorized
-			if _, ok := err.(*customErr.Unauthorized); ok {
-				httpHelper.WriteCustomErrorAndLog(w, "Failed to login user due to unauthorized", http.StatusUnauthorized, "", err)
-				return
-			}
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to login user", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to revoke session", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		httpHelper.WriteResponseEntity(w, user)
+		httpHelper.WriteResponseEntity(w, toRevoke)
 	}
 }
 
 // POST user create
 // @tags user
 // @Summary create user
-// @Description create user
+// @Description create user, password has to meet password policy, set mustChangePassword to make user change it on first login
 // @Accept  json
 // @Produce  json
 // @Param request body coreUserV1.CoreUser true "user post"
@@ -1091,6 +2950,20 @@ func CreateCreateUserHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		// user is created in tenant of request, roles and groups bound to it have to be of the same tenant
+		userPost.TenantId = tenant.FromRequest(r)
+		// a user is disabled by disable api only
+		userPost.Disabled = false
+		if !checkBindingTenant(config, w, r, userPost.Roles, userPost.Groups) {
+			return
+		}
+
+		if err = checkNewPassword(config, "", userPost.Name, userPost.Password); err != nil {
+			writeNewPasswordError(w, err)
+			return
+		}
+
+		password := userPost.Password
 		retUserPost, err := userProvider.CreateUser(userPost, nil)
 		if err != nil {
 			// check error is not found error
@@ -1101,6 +2974,9 @@ func CreateCreateUserHandler(config *config.Config) func(w http.ResponseWriter,
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user", http.StatusInternalServerError, "", err)
 			return
 		}
+		rememberPassword(config, retUserPost.Id, password)
+		retUserPost.Password = ""
+		publishChange(event.UserKind, event.Created, retUserPost.Id, retUserPost.Name)
 
 		httpHelper.WriteResponseEntity(w, retUserPost)
 	}
@@ -1153,10 +3029,15 @@ func CreateGetFlavorHandler(config *config.Config) func(w http.ResponseWriter, r
 // GET role list
 // @tags role
 // @Summary show role list
-// @Description show role list
+// @Description show role list, sortable by name and description
 // @Accept  json
 // @Produce  json
+// @Param q query string false "case insensitive substring of name or description e.g. ?q=class"
+// @Param sort query string false "field to sort by, '-' for descending order e.g. ?sort=-name"
+// @Param page query int false "1 based page, whole list is returned if page and pageSize are not given e.g. ?page=2"
+// @Param pageSize query int false "items per page, default to 20 and at most 500 e.g. ?pageSize=50"
 // @Success 200 {array} coreUserV1.CoreRole
+// @Header 200 {int} X-Total-Count "count of matched items before paging"
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
@@ -1174,11 +3055,8 @@ func CreateGetRolesHandler(config *config.Config) func(w http.ResponseWriter, r
 			return
 		}
 
-		if roles == nil {
-			roles = []coreUserV1.CoreRole{}
-		}
-
-		httpHelper.WriteResponseEntity(w, roles)
+		roles = filterTenant(config, r, roles, func(role coreUserV1.CoreRole) string { return role.TenantId })
+		writeListPage(w, r, roles, roleListFields)
 	}
 }
 
@@ -1215,6 +3093,10 @@ func CreateGetRoleHandler(config *config.Config) func(w http.ResponseWriter, r *
 			return
 		}
 
+		if writeIfOtherTenant(config, w, r, "role", roleId, role.TenantId) {
+			return
+		}
+
 		httpHelper.WriteResponseEntity(w, role)
 	}
 }
@@ -1222,7 +3104,7 @@ func CreateGetRoleHandler(config *config.Config) func(w http.ResponseWriter, r *
 // POST create role
 // @tags role
 // @Summary create role
-// @Description create role
+// @Description create role, permission is given as bits or as names listed by GET /permissions e.g. {"permissionNames": {"user": ["view_page", "list"]}}
 // @Accept  json
 // @Produce  json
 // @Param request body coreUserV1.CoreRole true "role post"
@@ -1253,11 +3135,18 @@ func CreateCreateRoleHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if err = resolveRolePermission(rolePost); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Invalid role permission", http.StatusBadRequest, "", err)
+			return
+		}
+
+		rolePost.TenantId = tenant.FromRequest(r)
 		role, err := roleProvider.CreateRole(rolePost, nil)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to create role", http.StatusInternalServerError, "", err)
 			return
 		}
+		publishChange(event.RoleKind, event.Created, role.Id, role.Name)
 
 		httpHelper.WriteResponseEntity(w, role)
 	}
@@ -1266,7 +3155,7 @@ func CreateCreateRoleHandler(config *config.Config) func(w http.ResponseWriter,
 // PUT update role
 // @tags role
 // @Summary update role
-// @Description update role
+// @Description update role, permission is given as bits or as names listed by GET /permissions e.g. {"permissionNames": {"user": ["view_page", "list"]}}
 // @Accept  json
 // @Produce  json
 // @Param roleId path string true "role id"
@@ -1305,13 +3194,35 @@ func CreateUpdateRoleHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if err = resolveRolePermission(rolePost); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Invalid role permission", http.StatusBadRequest, "", err)
+			return
+		}
+
 		rolePost.Id = roleId
 
+		roleFound, err := roleProvider.GetRole(roleId, nil)
+		if err != nil {
+			if customErr.IsNotFound(err) {
+				httpHelper.WriteCustomErrorAndLog(w, "Role not found", http.StatusNotFound, "", err)
+				return
+			}
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get role", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		if writeIfOtherTenant(config, w, r, "role", roleId, roleFound.TenantId) {
+			return
+		}
+		// tenant of role can not be changed
+		rolePost.TenantId = roleFound.TenantId
+
 		err = roleProvider.UpdateRole(rolePost, nil)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to update role", http.StatusInternalServerError, "", err)
 			return
 		}
+		publishChange(event.RoleKind, event.Updated, roleId, rolePost.Name)
 
 		httpHelper.WriteResponseEntity(w, rolePost)
 	}
@@ -1344,11 +3255,16 @@ func CreateDeleteRoleHandler(config *config.Config) func(w http.ResponseWriter,
 			return
 		}
 
+		if !checkRoleTenant(config, w, r, roleId) {
+			return
+		}
+
 		err = roleProvider.DeleteRole(roleId, nil)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to delete role", http.StatusInternalServerError, "", err)
 			return
 		}
+		publishChange(event.RoleKind, event.Deleted, roleId, "")
 
 		httpHelper.WriteResponseEntity(w, nil)
 	}
@@ -2173,12 +4089,17 @@ func CreateDeleteKnowledgeBaseFileHandler(config *config.Config) func(w http.Res
 
 // POST upload users
 // @tags user
-// @Summary upload users from csv or txt split by comma
-// @Description upload users from csv or txt split by comma
+// @Summary upload users from csv, txt or xlsx file
+// @Description upload users from csv, txt or xlsx file, first row is header naming columns username, password, role, group, description and email
+// @Description a file whose header names none of them is read in legacy column order username, password, role, group and description
+// @Description several roles or groups of a user are joined by ';', file exported by export api can be uploaded once passwords are filled
+// @Description missing roles and groups are created, existing users are skipped, passwords have to meet password policy and users have to change them on first login
+// @Description nothing is written with dryRun=true, outcome of every row is reported either way
 // @Accept  multipart/form-data
 // @Produce  json
-// @Param file body string true "file uploads"
-// @Success 200 {array} string
+// @Param file formData file true "csv, txt or xlsx file"
+// @Param dryRun query bool false "validate file without creating anything e.g. ?dryRun=true"
+// @Success 200 {object} coreUserV1.CoreUserImportResult
 // @Failure 400 {object} httpHelper.CustomError
 // @Failure 403 {object} httpHelper.CustomError
 // @Failure 500 {object} httpHelper.CustomError
@@ -2188,232 +4109,213 @@ func CreateUploadUsersHandler(config *config.Config) func(w http.ResponseWriter,
 
 		// this is a very expensive operation, we need to check the user,role,group at the same time
 		// this operation should be called at the very begin of system init
-		// all resource load from csv file will be created if not exist
-		// all resource load from csv will be ignored if already exist
+		// all resource load from file will be created if not exist
+		// users load from file will be skipped if already exist
+
+		dryRun := r.URL.Query().Get("dryRun") == "true"
 
 		file, fileHeader, err := r.FormFile("file")
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to get file from request", http.StatusBadRequest, "", err)
 			return
 		}
+		defer file.Close()
 
-		fileExt := filepath.Ext(fileHeader.Filename)
-
-		if fileExt != ".csv" && fileExt != ".txt" {
-			httpHelper.WriteCustomErrorAndLog(w, "Invalid file extension expected to one of csv or txt", http.StatusBadRequest, "", fmt.Errorf("invalid file extension %s", fileExt))
+		records, err := userimport.Parse(fileHeader.Filename, file, fileHeader.Size)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("Failed to read uploaded file: %s", err.Error()), http.StatusBadRequest, "", err)
 			return
 		}
 
-		reader := csv.NewReader(file)
-		records, err := reader.ReadAll()
+		result, err := importUsers(config, r, records, dryRun)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to parse CSV file", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to import users", http.StatusInternalServerError, "", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		httpHelper.WriteResponseEntity(w, result)
+	}
+}
+
+// GET export users
+// @tags user
+// @Summary export users to csv or xlsx file
+// @Description export users in column layout of upload api so file can be uploaded to another install, roles and groups of a user are joined by ';'
+// @Description passwords are never exported, password column is left blank and has to be filled before file is uploaded
+// @Produce  text/csv
+// @Produce  application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx, default to csv e.g. ?format=xlsx"
+// @Param group query string false "filter with group id e.g. ?group=id1&group=id2"
+// @Param role query string false "filter with role id e.g. ?role=id1&role=id2"
+// @Param includeDisabled query bool false "include disabled users e.g. ?includeDisabled=true"
+// @Success 200 {file} file
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/export  [get]
+func CreateExportUsersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := getExportFormat(w, r)
+		if !ok {
 			return
 		}
 
-		// get all users
 		userProvider, err := initOrGetUserProvider(config)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to create user provider", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		// get all roles
-		roleProvider, err := initOrGetRoleProvider(config)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create role provider", http.StatusInternalServerError, "", err)
-			return
+		var options map[string]struct{}
+		if r.URL.Query().Get("includeDisabled") == "true" {
+			options = map[string]struct{}{keystone.IncludeDisabledUsers: {}}
 		}
 
-		// get all group
-		groupProvider, err := initOrGetGroupProvider(config)
+		users, err := userProvider.GetUsers(options)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get users", http.StatusInternalServerError, "", err)
 			return
 		}
+		users = filterTenant(config, r, users, func(user coreUserV1.CoreUser) string { return user.TenantId })
 
-		allUsers, err := userProvider.GetUsers(nil)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get all users", http.StatusInternalServerError, "", err)
-			return
+		groups := r.URL.Query()["group"]
+		if groups != nil {
+			users = filterUsersByBinding(users, groups, groupIdsOf)
 		}
 
-		allRoles, err := roleProvider.GetRoles(nil)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get all roles", http.StatusInternalServerError, "", err)
-			return
+		roles := r.URL.Query()["role"]
+		if roles != nil {
+			users = filterUsersByBinding(users, roles, roleIdsOf)
 		}
 
-		allGroup, err := groupProvider.GetGroups(nil)
+		roleNames, groupNames, err := getRoleAndGroupNames(config)
 		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to get all groups", http.StatusInternalServerError, "", err)
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get roles and groups", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		flatUser := make(map[string]coreUserV1.CoreUser)
-		for _, user := range allUsers {
-			flatUser[user.Name] = user
-		}
+		sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+
+		requestUserId, _ := getRequestUserId(config, r)
+		coreApiLog.Logger.Warn("audit: users exported", "by", requestUserId, "format", format, "total", len(users))
 
-		flatRoles := make(map[string]coreUserV1.CoreRole)
-		for _, role := range allRoles {
-			flatRoles[role.Name] = role
+		writeExport(w, format, "users", userimport.UserRows(users, roleNames, groupNames))
+	}
+}
+
+// GET export group members
+// @tags group
+// @Summary export members of groups to csv or xlsx file
+// @Description export members of groups, each row is a group name and a user name
+// @Produce  text/csv
+// @Produce  application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx, default to csv e.g. ?format=xlsx"
+// @Param group query string false "filter with group id e.g. ?group=id1&group=id2"
+// @Success 200 {file} file
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 404 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/groups/export  [get]
+func CreateExportGroupMembersHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, ok := getExportFormat(w, r)
+		if !ok {
+			return
 		}
 
-		flatGroup := make(map[string]coreUserV1.CoreGroup)
-		for _, group := range allGroup {
-			flatGroup[group.Name] = group
+		groupProvider, err := initOrGetGroupProvider(config)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to create group provider", http.StatusInternalServerError, "", err)
+			return
 		}
 
-		var result []string
-		for index, record := range records {
-			if index == 0 {
-				// skip column name
-				continue
-			}
+		groups, err := groupProvider.GetGroups(nil)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to get groups", http.StatusInternalServerError, "", err)
+			return
+		}
+		groups = filterTenant(config, r, groups, func(group coreUserV1.CoreGroup) string { return group.TenantId })
 
-			// 0 = username
-			// 1 = password
-			// 2 = role
-			// 3 = group
-			// 4 = description
-			var recordIssue []string
-
-			// data security check
-			// do not create a user if user set role to aes-admin
-			if record[2] == "aes-admin" {
-				coreApiLog.Logger.Warn("skip this user due to role is aes-admin is forbidden")
-				continue
+		if groupIds := r.URL.Query()["group"]; groupIds != nil {
+			var selected []coreUserV1.CoreGroup
+			for _, group := range groups {
+				if slices.Contains(groupIds, group.Id) {
+					selected = append(selected, group)
+				}
 			}
-
-			if len(record) != 5 {
-				httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("Invalid record format expected column to be 5 got %d", len(record)), http.StatusBadRequest, "", fmt.Errorf("invalid record format expected column to be 5 got %d", len(record)))
+			if len(selected) != len(groupIds) {
+				httpHelper.WriteCustomErrorAndLog(w, "Group not found", http.StatusNotFound, "", fmt.Errorf("some of groups %v are not found", groupIds))
 				return
 			}
+			groups = selected
+		}
 
-			if record[0] == "" {
-				recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-				recordIssue = append(recordIssue, "username is empty")
-			}
-
-			if record[1] == "" {
+		sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
 
-				if len(recordIssue) == 0 {
-					recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-				}
-				recordIssue = append(recordIssue, "password is empty")
+		members := make(map[string][]coreUserV1.CoreUser, len(groups))
+		total := 0
+		for _, group := range groups {
+			users, err := groupProvider.GetGroupUsers(group.Id, nil)
+			if err != nil {
+				httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("Failed to get users of group %s", group.Name), http.StatusInternalServerError, "", err)
+				return
 			}
+			sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+			members[group.Id] = users
+			total += len(users)
+		}
 
-			if record[2] == "" {
-				if len(recordIssue) == 0 {
-					recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-				}
-				recordIssue = append(recordIssue, "role is empty")
-			}
+		requestUserId, _ := getRequestUserId(config, r)
+		coreApiLog.Logger.Warn("audit: group members exported", "by", requestUserId, "format", format, "groups", len(groups), "total", total)
 
-			if record[3] == "" {
-				if len(recordIssue) == 0 {
-					recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-				}
-				recordIssue = append(recordIssue, "group is empty")
-			}
+		writeExport(w, format, "group-members", userimport.MembershipRows(groups, members))
+	}
+}
 
-			// check group exist
-			if _, ok := flatGroup[record[3]]; !ok {
-				// create group then
-				group, err := groupProvider.CreateGroup(&coreUserV1.CoreGroup{
-					Name:        record[3],
-					Description: fmt.Sprintf("Group created by %s at %s", "upload api", time.Now().Format("2006-01-02 15:04:05")),
-				}, nil)
-				if err != nil {
-					if len(recordIssue) == 0 {
-						recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-					}
-					recordIssue = append(recordIssue, fmt.Sprintf("failed to create group %s", record[3]))
-					result = append(result, strings.Join(recordIssue, ","))
-					coreApiLog.Logger.Error(fmt.Sprintf("Failed to create group %s", record[3]), "error", err)
-					continue
-				}
-				coreApiLog.Logger.Debug(fmt.Sprintf("Created group %s", record[3]))
-				// add back to cache
-				flatGroup[record[3]] = *group
-			} else {
-				coreApiLog.Logger.Debug(fmt.Sprintf("Group %s already exist skip it", record[3]))
-			}
+// POST batch of users
+// @tags user
+// @Summary apply one operation to many users
+// @Description operation is one of delete, setRoles, addGroups, removeGroups, disable and enable, a failed user does not stop the others
+// @Description roles and groups are looked up once for whole batch, delete needs permission delete of module user as well
+// @Accept  json
+// @Produce  json
+// @Param request body coreUserV1.CoreUserBatch true "batch"
+// @Success 200 {object} coreUserV1.CoreUserBatchResult
+// @Failure 400 {object} httpHelper.CustomError
+// @Failure 403 {object} httpHelper.CustomError
+// @Failure 500 {object} httpHelper.CustomError
+// @Router /apis/core-api.openhydra.io/v1/users/batch  [post]
+func CreateUserBatchHandler(config *config.Config) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		post := &coreUserV1.CoreUserBatch{}
+		if err := httpHelper.ParseJsonBody(r, post); err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to parse request body", http.StatusBadRequest, "", err)
+			return
+		}
 
-			// check role
-			if _, ok := flatRoles[record[2]]; !ok {
-				// create role then
-				role, err := roleProvider.CreateRole(&coreUserV1.CoreRole{
-					Name:        record[2],
-					Description: fmt.Sprintf("Role created by %s at %s", "upload api", time.Now().Format("2006-01-02 15:04:05")),
-					Permission: map[string]uint64{
-						"indexView":         1,
-						"courseStudentView": 1,
-						"deviceStudentView": 31,
-						"rag":               30,
-					},
-				}, nil)
-				if err != nil {
-					if len(recordIssue) == 0 {
-						recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-					}
-					recordIssue = append(recordIssue, fmt.Sprintf("failed to create role %s", record[2]))
-					result = append(result, strings.Join(recordIssue, ","))
-					coreApiLog.Logger.Error(fmt.Sprintf("Failed to create role %s", record[2]), "error", err)
-					continue
-				}
-				coreApiLog.Logger.Debug(fmt.Sprintf("Created role %s", record[2]))
-				// add back to cache
-				flatRoles[record[2]] = *role
-			} else {
-				coreApiLog.Logger.Debug(fmt.Sprintf("Role %s already exist skip it", record[2]))
-			}
+		if post.Operation == userBatchDelete && writeIfNoPermission(w, r, "user", privileges.PermissionUserDelete) {
+			return
+		}
 
-			// check user
-			if _, ok := flatUser[record[0]]; !ok {
-				// create user then
-				roleId := flatRoles[record[2]].Id
-				groupId := flatGroup[record[3]].Id
-				_, err := userProvider.CreateUser(&coreUserV1.CoreUser{
-					Name:     record[0],
-					Password: record[1],
-					Roles: []coreUserV1.CoreRole{
-						{
-							Id: roleId,
-						},
-					},
-					Groups: []coreUserV1.CoreGroup{
-						{
-							Id: groupId,
-						},
-					},
-					Description: record[4],
-				}, nil)
-				if err != nil {
-					if len(recordIssue) == 0 {
-						recordIssue = append(recordIssue, fmt.Sprintf("user %s failed to create due to:", record[0]))
-					}
-					recordIssue = append(recordIssue, fmt.Sprintf("failed to create user %s", record[0]))
-					result = append(result, strings.Join(recordIssue, ","))
-					coreApiLog.Logger.Error(fmt.Sprintf("Failed to create user %s", record[0]), "error", err)
-					continue
-				}
-				coreApiLog.Logger.Debug(fmt.Sprintf("Created user %s", record[0]))
-				flatUser[record[0]] = coreUserV1.CoreUser{}
-			} else {
-				coreApiLog.Logger.Debug(fmt.Sprintf("User %s already exist skip it", record[0]))
+		batch, status, err := newUserBatch(config, r, post)
+		if err != nil {
+			message := err.Error()
+			if status == http.StatusInternalServerError {
+				message = "Failed to prepare batch"
 			}
+			httpHelper.WriteCustomErrorAndLog(w, message, status, "", err)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if len(result) > 0 {
-			w.WriteHeader(http.StatusInternalServerError)
-			httpHelper.WriteResponseEntity(w, result)
-		} else {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("[]"))
+		result, err := batch.run(config, r)
+		if err != nil {
+			httpHelper.WriteCustomErrorAndLog(w, "Failed to apply batch", http.StatusInternalServerError, "", err)
+			return
 		}
 
+		httpHelper.WriteResponseEntity(w, result)
 	}
 }
@@ -0,0 +1,83 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("oidc link test", func() {
+	var serverConfig *config.Config
+	var admin, teacher, student *coreUserV1.CoreUser
+
+	newRequest := func(method, userId string, body interface{}) *http.Request {
+		data, err := json.Marshal(body)
+		Expect(err).To(BeNil())
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("userId", userId)
+		request := httptest.NewRequest(method, "/users/"+userId+"/oidc-links", bytes.NewReader(data))
+		ctx := context.WithValue(request.Context(), chi.RouteCtxKey, routeContext)
+		return request.WithContext(context.WithValue(ctx, "core-user", admin))
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		serverConfig.AuthConfig.OIDC = &config.OIDCConfig{IssuerUrl: "https://idp.example.edu/"}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		admin, err = users.SearchUserByName("admin", map[string]struct{}{keystone.LoadPermission: {}})
+		Expect(err).To(BeNil())
+		teacher, err = users.CreateUser(&coreUserV1.CoreUser{Name: "teacher", Password: "teacher-password"}, nil)
+		Expect(err).To(BeNil())
+		student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should link user to subject of configured issuer once", func() {
+		// links are kept by one store for the whole process, ids of users differ in each spec
+		subject := "subject-of-" + teacher.Id
+		recorder := httptest.NewRecorder()
+		CreatePutOIDCLinkHandler(serverConfig)(recorder, newRequest(http.MethodPut, teacher.Id, coreUserV1.CoreOIDCLink{Subject: subject}))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		link := &coreUserV1.CoreOIDCLink{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), link)).To(Succeed())
+		Expect(link.Issuer).To(Equal("https://idp.example.edu"))
+		Expect(link.CreatedBy).To(Equal(admin.Id))
+
+		recorder = httptest.NewRecorder()
+		CreatePutOIDCLinkHandler(serverConfig)(recorder, newRequest(http.MethodPut, student.Id, coreUserV1.CoreOIDCLink{Subject: subject}))
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder = httptest.NewRecorder()
+		CreateDeleteOIDCLinksHandler(serverConfig)(recorder, newRequest(http.MethodDelete, teacher.Id, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		recorder = httptest.NewRecorder()
+		CreateGetOIDCLinksHandler(serverConfig)(recorder, newRequest(http.MethodGet, teacher.Id, nil))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring(subject))
+	})
+})
@@ -2,6 +2,7 @@ package route
 
 import (
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/share"
 	"core-api/pkg/core/privileges"
 	"core-api/pkg/south"
 	"fmt"
@@ -19,6 +20,7 @@ var openhydraFullPathPrefix = fmt.Sprintf("%s/%s/%s", openhydraPrefix, openhydra
 func GetOpenhydraRoute(config *config.Config) *ChiRouteBuilder {
 	// init handler by passing the config
 	openhydraHandler := south.NewOpenhydraSouthAPIHandler(config)
+	share.RegisterOwnerResolver(share.KindDevice, openhydraHandler.DeviceOwner)
 	return &ChiRouteBuilder{
 		PathPrefix: openhydraFullPathPrefix,
 		MethodHandlers: []ChiSubRouteBuilder{
@@ -0,0 +1,187 @@
+package route
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WhiteList tells methods of each route pattern served without authentication, nil methods means every method
+type WhiteList map[string][]string
+
+// LoadRoutePolicyRules returns rules of config followed by rules of policy file
+func LoadRoutePolicyRules(policyConfig *config.RoutePolicyConfig) ([]config.RoutePolicyRule, error) {
+	if policyConfig == nil {
+		return nil, nil
+	}
+	rules := append([]config.RoutePolicyRule{}, policyConfig.Rules...)
+	if policyConfig.Path == "" {
+		return rules, nil
+	}
+
+	content, err := os.ReadFile(policyConfig.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read route policy file %s: %w", policyConfig.Path, err)
+	}
+	policyFile := &config.RoutePolicyConfig{}
+	if err := yaml.Unmarshal(content, policyFile); err != nil {
+		return nil, fmt.Errorf("failed to parse route policy file %s: %w", policyConfig.Path, err)
+	}
+	return append(rules, policyFile.Rules...), nil
+}
+
+// ApplyRoutePolicy checks rules against routes registered by provider and applies them
+// authorization of provider is replaced, white list with rules applied is returned
+// nothing is changed if any rule is invalid
+func ApplyRoutePolicy(provider IRouteProvider, whiteList WhiteList, rules []config.RoutePolicyRule) (WhiteList, error) {
+	registered := provider.GetRouteAuthorization()
+
+	authorization := make(map[string]map[string]ModuleAndPermission, len(registered))
+	for pattern, methods := range registered {
+		authorization[pattern] = make(map[string]ModuleAndPermission, len(methods))
+		for method, moduleAndPermission := range methods {
+			authorization[pattern][method] = moduleAndPermission
+		}
+	}
+	result := make(WhiteList, len(whiteList))
+	for pattern, methods := range whiteList {
+		result[pattern] = methods
+	}
+
+	for index, rule := range rules {
+		if err := applyRoutePolicyRule(authorization, result, rule); err != nil {
+			return nil, fmt.Errorf("route policy rule %d of %s: %w", index+1, rule.Pattern, err)
+		}
+		coreApiLog.Logger.Info("route policy rule applied", "pattern", rule.Pattern, "methods", rule.Methods)
+	}
+
+	provider.SetRouteAuthorization(authorization)
+	return result, nil
+}
+
+func applyRoutePolicyRule(authorization map[string]map[string]ModuleAndPermission, whiteList WhiteList, rule config.RoutePolicyRule) error {
+	if rule.Pattern == "" {
+		return fmt.Errorf("pattern is empty")
+	}
+	routeMethods, registered := authorization[rule.Pattern]
+	_, whiteListed := whiteList[rule.Pattern]
+	if !registered && !whiteListed {
+		return fmt.Errorf("route is not registered")
+	}
+
+	changesPermission := rule.Module != "" || rule.Permission != nil || len(rule.PermissionNames) > 0
+	if !changesPermission && rule.Public == nil {
+		return fmt.Errorf("rule changes nothing")
+	}
+	if rule.Permission != nil && len(rule.PermissionNames) > 0 {
+		return fmt.Errorf("permission and permission names can not be given together")
+	}
+	// route served outside of core-api routes, e.g. swagger doc, only has a place on white list
+	if !registered && (changesPermission || len(rule.Methods) > 0) {
+		return fmt.Errorf("route is only on white list, its permission and methods can not be changed")
+	}
+	if !registered && !*rule.Public {
+		return fmt.Errorf("route has no permission to check, it can not be taken off white list")
+	}
+
+	var methods []string
+	for _, method := range rule.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if _, found := routeMethods[method]; !found {
+			return fmt.Errorf("method %s is not registered", method)
+		}
+		methods = append(methods, method)
+	}
+	if len(methods) == 0 {
+		for method := range routeMethods {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+	}
+
+	if changesPermission {
+		for _, method := range methods {
+			moduleAndPermission, err := policyPermission(routeMethods[method], rule)
+			if err != nil {
+				return err
+			}
+			routeMethods[method] = moduleAndPermission
+		}
+	}
+
+	if rule.Public != nil {
+		setWhiteListed(whiteList, rule.Pattern, methods, *rule.Public, routeMethods)
+	}
+	return nil
+}
+
+// policyPermission is permission of a route method after rule is applied
+func policyPermission(current ModuleAndPermission, rule config.RoutePolicyRule) (ModuleAndPermission, error) {
+	result := current
+	if rule.Module != "" {
+		result.Module = rule.Module
+	}
+	if _, found := privileges.Modules[result.Module]; !found {
+		return result, fmt.Errorf("unknown module %s", result.Module)
+	}
+
+	switch {
+	case rule.Permission != nil:
+		result.Permission = *rule.Permission
+	case len(rule.PermissionNames) > 0:
+		bits, err := privileges.Bitmask(result.Module, rule.PermissionNames)
+		if err != nil {
+			return result, err
+		}
+		result.Permission = bits
+	case rule.Module != "" && rule.Module != current.Module:
+		// bits of one module mean nothing in another
+		return result, fmt.Errorf("permission is required when module is changed")
+	}
+
+	if err := privileges.ValidatePermission(map[string]uint64{result.Module: result.Permission}); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// setWhiteListed puts methods of pattern on white list or takes them off
+func setWhiteListed(whiteList WhiteList, pattern string, methods []string, public bool, routeMethods map[string]ModuleAndPermission) {
+	current, whiteListed := whiteList[pattern]
+	if whiteListed && current == nil && routeMethods == nil {
+		// route outside of core-api routes stays open to every method
+		return
+	}
+
+	allowed := map[string]bool{}
+	if whiteListed && current == nil {
+		for method := range routeMethods {
+			allowed[method] = true
+		}
+	}
+	for _, method := range current {
+		allowed[method] = true
+	}
+	for _, method := range methods {
+		allowed[method] = public
+	}
+
+	var result []string
+	for method, isAllowed := range allowed {
+		if isAllowed {
+			result = append(result, method)
+		}
+	}
+	if len(result) == 0 {
+		delete(whiteList, pattern)
+		return
+	}
+	sort.Strings(result)
+	whiteList[pattern] = result
+}
@@ -0,0 +1,112 @@
+package route
+
+import (
+	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("route policy test", func() {
+	var provider IRouteProvider
+	var whiteList WhiteList
+	var public, private = true, false
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		provider = NewDefaultRootRoute()
+		provider.RegisterRoute("/v1", &ChiRouteBuilder{
+			PathPrefix: "/v1",
+			MethodHandlers: []ChiSubRouteBuilder{
+				{Method: http.MethodGet, Pattern: "/users", Handler: noop, ModuleAndPermission: ModuleAndPermission{Module: "user", Permission: privileges.PermissionUserList}},
+				{Method: http.MethodPost, Pattern: "/users", Handler: noop, ModuleAndPermission: ModuleAndPermission{Module: "user", Permission: privileges.PermissionUserCreate}},
+				{Method: http.MethodGet, Pattern: "/versions", Handler: noop, ModuleAndPermission: ModuleAndPermission{Module: "setting", Permission: 0}},
+				{Method: http.MethodPost, Pattern: "/versions", Handler: noop, ModuleAndPermission: ModuleAndPermission{Module: "setting", Permission: privileges.PermissionSettingCreate}},
+			},
+		})
+		whiteList = WhiteList{"/doc/*": nil, "/v1/versions": nil}
+	})
+
+	It("should change permission of given methods only", func() {
+		permission := uint64(privileges.PermissionUserList | privileges.PermissionUserViewPage)
+		result, err := ApplyRoutePolicy(provider, whiteList, []config.RoutePolicyRule{
+			{Pattern: "/v1/users", Methods: []string{"get"}, Permission: &permission},
+			{Pattern: "/v1/users", Methods: []string{http.MethodPost}, Module: "role", PermissionNames: []string{"create"}},
+		})
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(whiteList))
+		Expect(provider.GetRouteAuthorization()["/v1/users"]).To(Equal(map[string]ModuleAndPermission{
+			http.MethodGet:  {Module: "user", Permission: permission},
+			http.MethodPost: {Module: "role", Permission: privileges.PermissionRoleCreate},
+		}))
+	})
+
+	It("should open and close methods of white list", func() {
+		result, err := ApplyRoutePolicy(provider, whiteList, []config.RoutePolicyRule{
+			{Pattern: "/v1/versions", Methods: []string{http.MethodPost}, Public: &private},
+			{Pattern: "/v1/users", Methods: []string{http.MethodGet}, Public: &public},
+			{Pattern: "/doc/*", Public: &public},
+		})
+		Expect(err).To(BeNil())
+		Expect(result).To(Equal(WhiteList{
+			"/doc/*":       nil,
+			"/v1/versions": {http.MethodGet},
+			"/v1/users":    {http.MethodGet},
+		}))
+		// white list given is not changed
+		Expect(whiteList["/v1/versions"]).To(BeNil())
+
+		result, err = ApplyRoutePolicy(provider, result, []config.RoutePolicyRule{{Pattern: "/v1/versions", Public: &private}})
+		Expect(err).To(BeNil())
+		Expect(result).NotTo(HaveKey("/v1/versions"))
+	})
+
+	It("should fail on unknown pattern, method, module or bits and change nothing", func() {
+		tooMany := uint64(1 << 10)
+		invalid := [][]config.RoutePolicyRule{
+			{{Pattern: "/v1/unknown", Public: &public}},
+			{{Pattern: "/v1/users", Methods: []string{http.MethodDelete}, Public: &public}},
+			{{Pattern: "/v1/users", Module: "unknown", PermissionNames: []string{"list"}}},
+			{{Pattern: "/v1/users", Module: "role"}},
+			{{Pattern: "/v1/users", Permission: &tooMany}},
+			{{Pattern: "/v1/users", PermissionNames: []string{"fly"}}},
+			{{Pattern: "/v1/users"}},
+			{{Pattern: "/doc/*", Public: &private}},
+			{{Pattern: "/doc/*", Module: "user", PermissionNames: []string{"list"}}},
+			{{Pattern: "/v1/users", Methods: []string{http.MethodGet}, Public: &public}, {Pattern: "/v1/missing", Public: &public}},
+		}
+		for _, rules := range invalid {
+			_, err := ApplyRoutePolicy(provider, whiteList, rules)
+			Expect(err).NotTo(BeNil(), "rules %v", rules)
+		}
+		Expect(provider.GetRouteAuthorization()["/v1/users"][http.MethodGet]).To(Equal(ModuleAndPermission{Module: "user", Permission: privileges.PermissionUserList}))
+	})
+
+	It("should load rules of config followed by rules of file", func() {
+		policyFile := filepath.Join(GinkgoT().TempDir(), "route-policy.yaml")
+		Expect(os.WriteFile(policyFile, []byte(`
+rules:
+  - pattern: /v1/users
+    methods: [GET]
+    permissionNames: [view_page, list]
+`), 0600)).To(Succeed())
+
+		rules, err := LoadRoutePolicyRules(&config.RoutePolicyConfig{
+			Path:  policyFile,
+			Rules: []config.RoutePolicyRule{{Pattern: "/v1/versions", Public: &private}},
+		})
+		Expect(err).To(BeNil())
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Pattern).To(Equal("/v1/versions"))
+		Expect(rules[1].PermissionNames).To(Equal([]string{"view_page", "list"}))
+
+		_, err = LoadRoutePolicyRules(&config.RoutePolicyConfig{Path: filepath.Join(GinkgoT().TempDir(), "missing.yaml")})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -34,7 +34,6 @@ func (r *DefaultRouteProvider) RegisterRoute(path string, routeBuilder *ChiRoute
 
 func (r *DefaultRouteProvider) AddCommonMiddlewares() {
 	r.root.Use(middleware.RequestID)
-	r.root.Use(middleware.RealIP)
 	r.root.Use(middleware.Logger)
 	r.root.Use(middleware.Recoverer)
 	r.root.Use(middleware.SetHeader("Content-Type", "application/json"))
@@ -48,6 +47,10 @@ func (r *DefaultRouteProvider) GetRouteAuthorization() map[string]map[string]Mod
 	return r.PermissionMap
 }
 
+func (r *DefaultRouteProvider) SetRouteAuthorization(authorization map[string]map[string]ModuleAndPermission) {
+	r.PermissionMap = authorization
+}
+
 func (r *DefaultRouteProvider) GetRoot() *chi.Mux {
 	return r.root
 }
@@ -0,0 +1,171 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"core-api/cmd/core-api-server/app/config"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/share"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("share test", func() {
+	var serverConfig *config.Config
+	var admin, teacher, student *coreUserV1.CoreUser
+	var conversationId string
+
+	withUser := func(request *http.Request, requestUser *coreUserV1.CoreUser) *http.Request {
+		return request.WithContext(context.WithValue(request.Context(), "core-user", requestUser))
+	}
+
+	createShare := func(requestUser *coreUserV1.CoreUser, post coreUserV1.CoreShare) (*httptest.ResponseRecorder, *coreUserV1.CoreShare) {
+		body, err := json.Marshal(post)
+		Expect(err).To(BeNil())
+		recorder := httptest.NewRecorder()
+		CreateCreateShareHandler(serverConfig)(recorder, withUser(httptest.NewRequest(http.MethodPost, "/shares", bytes.NewReader(body)), requestUser))
+		created := &coreUserV1.CoreShare{}
+		if recorder.Code == http.StatusOK {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), created)).To(Succeed())
+		}
+		return recorder, created
+	}
+
+	listShares := func(requestUser *coreUserV1.CoreUser) (*httptest.ResponseRecorder, []coreUserV1.CoreShare) {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/shares?resourceKind=conversation&resourceId="+conversationId, nil)
+		CreateGetSharesHandler(serverConfig)(recorder, withUser(request, requestUser))
+		var shares []coreUserV1.CoreShare
+		if recorder.Code == http.StatusOK {
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &shares)).To(Succeed())
+		}
+		return recorder, shares
+	}
+
+	deleteShare := func(requestUser *coreUserV1.CoreUser, shareId string) *httptest.ResponseRecorder {
+		routeContext := chi.NewRouteContext()
+		routeContext.URLParams.Add("shareId", shareId)
+		request := httptest.NewRequest(http.MethodDelete, "/shares/"+shareId, nil)
+		request = request.WithContext(context.WithValue(request.Context(), chi.RouteCtxKey, routeContext))
+		recorder := httptest.NewRecorder()
+		CreateDeleteShareHandler(serverConfig)(recorder, withUser(request, requestUser))
+		return recorder
+	}
+
+	BeforeEach(func() {
+		coreApiLog.InitLogger("DEBUG")
+		serverConfig = config.DefaultConfig()
+		serverConfig.AuthConfig.Provider = "local"
+		serverConfig.AuthConfig.Local = &config.LocalConfig{
+			Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+			BootstrapAdminPassword: "admin-password",
+		}
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+
+		users, err := initOrGetUserProvider(serverConfig)
+		Expect(err).To(BeNil())
+		admin, err = users.SearchUserByName("admin", map[string]struct{}{keystone.LoadPermission: {}})
+		Expect(err).To(BeNil())
+		teacher, err = users.CreateUser(&coreUserV1.CoreUser{Name: "teacher", Password: "teacher-password"}, nil)
+		Expect(err).To(BeNil())
+		student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+		Expect(err).To(BeNil())
+
+		// shares are kept by one store for the whole process, each spec shares its own conversation
+		conversationId = "conversation-of-" + teacher.Id
+		owners := map[string]string{conversationId: teacher.Id}
+		share.RegisterOwnerResolver(share.KindConversation, func(resourceId string) (string, error) {
+			ownerId, found := owners[resourceId]
+			if !found {
+				return "", customErr.NewNotFound(http.StatusNotFound, "conversation not found")
+			}
+			return ownerId, nil
+		})
+	})
+
+	AfterEach(func() {
+		userProvider, groupProvider, roleProvider = nil, nil, nil
+	})
+
+	It("should let owner share and list shares of a resource", func() {
+		recorder, created := createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(created.Id).NotTo(BeEmpty())
+		Expect(created.OwnerId).To(Equal(teacher.Id))
+		Expect(created.CreatedBy).To(Equal(teacher.Id))
+
+		recorder, shares := listShares(teacher)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(shares).To(HaveLen(1))
+		Expect(shares[0].SubjectId).To(Equal(student.Id))
+
+		granted := httptest.NewRecorder()
+		CreateGetGrantedSharesHandler(serverConfig)(granted, withUser(httptest.NewRequest(http.MethodGet, "/shares/granted", nil), student))
+		Expect(granted.Code).To(Equal(http.StatusOK))
+		Expect(granted.Body.String()).To(ContainSubstring(conversationId))
+	})
+
+	It("should not let user without manage access share or list shares", func() {
+		recorder, _ := createShare(student, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessManage})
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder, _ = listShares(student)
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+		// manage access lets student share on
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessManage})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		recorder, shares := listShares(student)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(shares).To(HaveLen(1))
+		Expect(shares[0].Access).To(Equal(share.AccessManage))
+	})
+
+	It("should let user managing resources of others share", func() {
+		recorder, created := createShare(admin, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessUse})
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(created.OwnerId).To(Equal(teacher.Id))
+		Expect(created.CreatedBy).To(Equal(admin.Id))
+	})
+
+	It("should reject invalid shares", func() {
+		recorder, _ := createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: "not-exist", Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: "write"})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: teacher.Id, Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: "course", ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+
+		recorder, _ = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: "not-exist", SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessRead})
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should let subject leave a share and owner remove it", func() {
+		_, created := createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: student.Id, Access: share.AccessRead})
+		Expect(deleteShare(student, created.Id).Code).To(Equal(http.StatusOK))
+		Expect(deleteShare(student, created.Id).Code).To(Equal(http.StatusNotFound))
+
+		_, created = createShare(teacher, coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, SubjectKind: share.SubjectUser, SubjectId: admin.Id, Access: share.AccessRead})
+		Expect(deleteShare(student, created.Id).Code).To(Equal(http.StatusForbidden))
+		Expect(deleteShare(teacher, created.Id).Code).To(Equal(http.StatusOK))
+
+		_, shares := listShares(teacher)
+		Expect(shares).To(BeEmpty())
+	})
+})
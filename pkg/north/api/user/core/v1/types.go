@@ -11,6 +11,178 @@ type CoreUser struct {
 	Groups      []CoreGroup       `json:"groups,omitempty"`
 	Permission  map[string]uint64 `json:"permission,omitempty"`
 	UnEditable  bool              `json:"uneditable,omitempty"`
+	// tenant user belongs to, it is set by core-api and can not be changed
+	TenantId string `json:"tenantId,omitempty"`
+	// user has to set a new password before using anything else, session of user is restricted until then
+	MustChangePassword bool `json:"mustChangePassword,omitempty"`
+	// disabled user keeps roles, groups and resources but can not log in, it is changed by disable and enable api only
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// CoreUserProfile holds fields of current user that can be changed by user itself
+type CoreUserProfile struct {
+	Email       string `json:"email,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CoreModulePermission tells for every module whether each named permission is granted, e.g. {"user": {"list": true}}
+type CoreModulePermission map[string]map[string]bool
+
+// CorePasswordChange is posted by a user to change own password
+type CorePasswordChange struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+// CoreUserToken is returned by login api
+// token should be carried in header 'Authorization: Bearer <token>' for further requests
+type CoreUserToken struct {
+	CoreUser
+	Token     string `json:"token,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
+
+// CoreSession is a login session backed by a session token
+type CoreSession struct {
+	Id         string `json:"id"`
+	UserId     string `json:"userId"`
+	UserName   string `json:"userName"`
+	ClientIp   string `json:"clientIp,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+	IssuedAt   int64  `json:"issuedAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	LastSeenAt int64  `json:"lastSeenAt,omitempty"`
+}
+
+// CorePersonalAccessToken is a named and expiring token a user creates for scripts
+// permission of a request made with it is permission of user limited to scope
+type CorePersonalAccessToken struct {
+	Id     string `json:"id,omitempty"`
+	UserId string `json:"userId,omitempty"`
+	Name   string `json:"name"`
+	// module name to permission bits, e.g. {"course": 3}
+	Scope map[string]uint64 `json:"scope"`
+	// lifetime of token on create
+	ExpiresInDays int   `json:"expiresInDays,omitempty"`
+	CreatedAt     int64 `json:"createdAt,omitempty"`
+	ExpiresAt     int64 `json:"expiresAt,omitempty"`
+	LastUsedAt    int64 `json:"lastUsedAt,omitempty"`
+	// secret is only returned once when token is created
+	Token string `json:"token,omitempty"`
+}
+
+// CoreLockout is failed login state of a user name
+type CoreLockout struct {
+	UserName string `json:"userName"`
+	Failures int    `json:"failures"`
+	Locked   bool   `json:"locked"`
+	// unix time lockout ends, zero if it is not locked
+	LockedUntil int64 `json:"lockedUntil,omitempty"`
+}
+
+// CoreUserImportResult is report of a user upload, nothing is written when it is a dry run
+type CoreUserImportResult struct {
+	DryRun  bool `json:"dryRun"`
+	Created int  `json:"created"`
+	Skipped int  `json:"skipped"`
+	Failed  int  `json:"failed"`
+	// roles and groups created for users, or to be created when it is a dry run
+	CreatedRoles  []string            `json:"createdRoles,omitempty"`
+	CreatedGroups []string            `json:"createdGroups,omitempty"`
+	Rows          []CoreUserImportRow `json:"rows"`
+}
+
+// CoreUserImportRow is outcome of a row of uploaded file
+type CoreUserImportRow struct {
+	// row number in file, header is row 1
+	Row  int    `json:"row"`
+	Name string `json:"name,omitempty"`
+	// one of created, skipped and failed
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CoreUserBatch applies one operation to many users
+type CoreUserBatch struct {
+	UserIds []string `json:"userIds"`
+	// one of delete, setRoles, addGroups, removeGroups, disable and enable
+	Operation string `json:"operation"`
+	// roles replacing roles of users on setRoles
+	RoleIds []string `json:"roleIds,omitempty"`
+	// groups added or removed on addGroups and removeGroups
+	GroupIds []string `json:"groupIds,omitempty"`
+}
+
+// CoreUserBatchResult tells outcome of a batch for every user, a failed user does not stop the others
+type CoreUserBatchResult struct {
+	Operation string              `json:"operation"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Items     []CoreUserBatchItem `json:"items"`
+}
+
+type CoreUserBatchItem struct {
+	UserId string `json:"userId"`
+	Name   string `json:"name,omitempty"`
+	// one of succeeded and failed
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// CoreShare grants a user or members of a group access to a resource of another user
+type CoreShare struct {
+	Id string `json:"id,omitempty"`
+	// one of conversation, knowledge_base and device, device is identified by name of user it belongs to
+	ResourceKind string `json:"resourceKind"`
+	ResourceId   string `json:"resourceId"`
+	// user owning resource, it is looked up by core-api
+	OwnerId string `json:"ownerId,omitempty"`
+	// one of user and group
+	SubjectKind string `json:"subjectKind"`
+	SubjectId   string `json:"subjectId"`
+	// one of read, use and manage, each includes the ones before it
+	Access    string `json:"access"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+}
+
+// CoreAccessReview asks whether a user may call a route
+type CoreAccessReview struct {
+	// user reviewed, user of request if it is left blank
+	UserId string `json:"userId,omitempty"`
+	Method string `json:"method"`
+	// path of request e.g. /apis/core-api.openhydra.io/v1/users/123, query string is ignored
+	Path string `json:"path"`
+}
+
+// CoreAccessVerdict tells whether a user may call a route and why
+type CoreAccessVerdict struct {
+	// user is left out in access matrix
+	UserId   string `json:"userId,omitempty"`
+	UserName string `json:"userName,omitempty"`
+	Method   string `json:"method"`
+	Path     string `json:"path,omitempty"`
+	// registered route pattern path is matched to, it is empty if no route matches
+	Pattern string `json:"pattern,omitempty"`
+	Allowed bool   `json:"allowed"`
+	// public route is served without authentication
+	Public bool `json:"public,omitempty"`
+	// module and bits route requires
+	Module          string   `json:"module,omitempty"`
+	Permission      uint64   `json:"permission"`
+	PermissionNames []string `json:"permissionNames,omitempty"`
+	// bits of module user lacks
+	MissingPermission uint64 `json:"missingPermission,omitempty"`
+	// roles of user holding every required bit of module
+	GrantedBy []CoreRole `json:"grantedBy,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// CoreAccessMatrix is verdict of a user on every registered route
+type CoreAccessMatrix struct {
+	UserId   string              `json:"userId"`
+	UserName string              `json:"userName,omitempty"`
+	Routes   []CoreAccessVerdict `json:"routes"`
 }
 
 // swagger:response roleUpdate
@@ -19,7 +191,10 @@ type CoreRole struct {
 	Name        string            `json:"name,omitempty"`
 	Description string            `json:"description,omitempty"`
 	Permission  map[string]uint64 `json:"permission,omitempty"`
-	UnEditable  bool              `json:"uneditable,omitempty"`
+	// permission given by names of GET /permissions e.g. {"user": ["view_page", "list"]}, it is turned into bits of permission on create and update
+	PermissionNames map[string][]string `json:"permissionNames,omitempty"`
+	UnEditable      bool                `json:"uneditable,omitempty"`
+	TenantId        string              `json:"tenantId,omitempty"`
 }
 
 type CoreGroup struct {
@@ -27,6 +202,33 @@ type CoreGroup struct {
 	Name        string `json:"name,omitempty"`
 	Description string `json:"description,omitempty"`
 	TagColor    string `json:"tagColor,omitempty"`
+	TenantId    string `json:"tenantId,omitempty"`
+}
+
+// CoreGroupAdmin designates a user administrator of a group, such user manages members of the group without global permission
+type CoreGroupAdmin struct {
+	GroupId   string `json:"groupId"`
+	UserId    string `json:"userId"`
+	UserName  string `json:"userName,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+}
+
+// CoreOIDCLink binds a user to subject of an openid connect identity provider, single sign-on logs in linked user only
+type CoreOIDCLink struct {
+	Issuer    string `json:"issuer,omitempty"`
+	Subject   string `json:"subject"`
+	UserId    string `json:"userId,omitempty"`
+	UserName  string `json:"userName,omitempty"`
+	CreatedBy string `json:"createdBy,omitempty"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+}
+
+// CoreTenant is a school or keystone domain served by core-api
+type CoreTenant struct {
+	Id string `json:"id"`
+	// default tenant holds users of a single tenant install and super admins
+	Default bool `json:"default,omitempty"`
 }
 
 type CoreGroupSummary struct {
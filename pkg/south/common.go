@@ -3,8 +3,14 @@ package south
 import (
 	"core-api/cmd/core-api-server/app/config"
 	auth "core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/groupadmin"
+	keystone "core-api/pkg/core/auth/provider/keystone/train"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
 	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
 	coreUserV1 "core-api/pkg/north/api/user/core/v1"
+	customErr "core-api/pkg/util/error"
 	httpHelper "core-api/pkg/util/http"
 	"crypto/tls"
 	"crypto/x509"
@@ -33,14 +39,22 @@ var (
 
 func initOrGetUserProvider(serverConfig *config.Config) (auth.IUserProvider, error) {
 	if userProvider == nil {
-		userProvider, _ = auth.CreateUserProvider(serverConfig, auth.KeystoneAuthProvider)
+		provider, err := auth.CreateUserProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		userProvider = provider
 	}
 	return userProvider, nil
 }
 
 func initOrGetGroupProvider(serverConfig *config.Config) (auth.IGroupProvider, error) {
 	if groupProvider == nil {
-		groupProvider, _ = auth.CreateGroupProvider(serverConfig, auth.KeystoneAuthProvider)
+		provider, err := auth.CreateGroupProvider(serverConfig, auth.GetAuthProviderType(serverConfig))
+		if err != nil {
+			return nil, err
+		}
+		groupProvider = provider
 	}
 	return groupProvider, nil
 }
@@ -174,6 +188,90 @@ func SouthAuthorizationControlWithUser(r *http.Request, w http.ResponseWriter, m
 	return userInMiddle, true, nil
 }
 
+// SouthAuthorizationControlWithShare works like SouthAuthorizationControlWithUser
+// in addition user the resource is shared with at given access or above is let through
+func SouthAuthorizationControlWithShare(serverConfig *config.Config, r *http.Request, w http.ResponseWriter, moduleName string, permissionRequired uint64, userIdToCompareLogin, resourceName, resourceId, access string) (*coreUserV1.CoreUser, bool, error) {
+	userInMiddle, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser)
+	if ok && userInMiddle.Id != userIdToCompareLogin && resourceId != "" {
+		store, err := share.InitOrGetShareStore(serverConfig)
+		if err != nil {
+			if w != nil {
+				httpHelper.WriteCustomErrorAndLog(w, "Failed to get share store", http.StatusInternalServerError, "", err)
+			}
+			return userInMiddle, false, err
+		}
+		if share.Covers(store.Access(resourceName, resourceId, userInMiddle.Id, share.GroupIds(userInMiddle)), access) {
+			return userInMiddle, true, nil
+		}
+	}
+	// knowledge bases are not managed by group administrators, they may be built by a whole class
+	if resourceName == share.KindConversation || resourceName == share.KindDevice {
+		return SouthAuthorizationControlWithGroupAdmin(serverConfig, r, w, moduleName, permissionRequired, userIdToCompareLogin, resourceName)
+	}
+	return SouthAuthorizationControlWithUser(r, w, moduleName, permissionRequired, userIdToCompareLogin, resourceName)
+}
+
+// SouthAuthorizationControlWithGroupAdmin works like SouthAuthorizationControlWithUser
+// in addition administrator of a group owner of resource belongs to is let through
+func SouthAuthorizationControlWithGroupAdmin(serverConfig *config.Config, r *http.Request, w http.ResponseWriter, moduleName string, permissionRequired uint64, userIdToCompareLogin, resourceName string) (*coreUserV1.CoreUser, bool, error) {
+	userInMiddle, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser)
+	if ok && userInMiddle.Id != userIdToCompareLogin && userIdToCompareLogin != "" {
+		if _, canAccess, err := SouthAuthorizationControl(r, moduleName, permissionRequired); err != nil || !canAccess {
+			administers, err := southAdministers(serverConfig, userInMiddle, userIdToCompareLogin)
+			if err != nil {
+				if w != nil {
+					httpHelper.WriteCustomErrorAndLog(w, "Failed to check group administrator", http.StatusInternalServerError, "", err)
+				}
+				return userInMiddle, false, err
+			}
+			if administers {
+				coreApiLog.Logger.Warn("audit: group administrator acts on member", "admin", userInMiddle.Name, "member", userIdToCompareLogin, "resource", resourceName, "method", r.Method, "path", r.URL.Path)
+				return userInMiddle, true, nil
+			}
+		}
+	}
+	return SouthAuthorizationControlWithUser(r, w, moduleName, permissionRequired, userIdToCompareLogin, resourceName)
+}
+
+// southAdministers tells whether user administers a group owner belongs to and may act on owner, owner is only loaded if user administers any group
+func southAdministers(serverConfig *config.Config, user *coreUserV1.CoreUser, ownerId string) (bool, error) {
+	store, err := groupadmin.InitOrGetGroupAdminStore(serverConfig)
+	if err != nil {
+		return false, err
+	}
+	if len(store.GroupsOf(user.Id)) == 0 {
+		return false, nil
+	}
+
+	provider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		return false, err
+	}
+	owner, err := provider.GetUser(ownerId, map[string]struct{}{keystone.LoadPermission: {}})
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := groupadmin.CanAdminister(store, user, owner); err != nil {
+		coreApiLog.Logger.Debug("group administrator refused", "error", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// SouthDeleteShares removes shares of a deleted resource, failure is logged only as resource is already gone
+func SouthDeleteShares(serverConfig *config.Config, resourceName, resourceId string) {
+	store, err := share.InitOrGetShareStore(serverConfig)
+	if err == nil {
+		_, err = store.DeleteResource(resourceName, resourceId)
+	}
+	if err != nil {
+		coreApiLog.Logger.Warn("Failed to remove shares of deleted resource", "error", err, "resource", resourceName, "resourceId", resourceId)
+	}
+}
+
 func SouthAuthorizationControl(r *http.Request, moduleName string, permissionRequired uint64) (*coreUserV1.CoreUser, bool, error) {
 	priProvider := &privileges.DefaultPrivilegeProvider{}
 	userInMiddle, ok := r.Context().Value("core-user").(*coreUserV1.CoreUser)
@@ -184,6 +282,111 @@ func SouthAuthorizationControl(r *http.Request, moduleName string, permissionReq
 	return userInMiddle, canAccess, err
 }
 
+// SouthTenantVisible tells whether resource owned by given user is visible to tenant of request
+// build-in resources are shared by all tenants, resource of a deleted user belongs to default tenant
+func SouthTenantVisible(serverConfig *config.Config, r *http.Request, ownerUserId string) (bool, error) {
+	requestTenant := tenant.FromRequest(r)
+	if requestTenant == "" || ownerUserId == "build-in" {
+		return true, nil
+	}
+
+	provider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		return false, err
+	}
+
+	ownerTenant := ""
+	owner, err := provider.GetUser(ownerUserId, nil)
+	if err != nil {
+		if !customErr.IsNotFound(err) {
+			return false, err
+		}
+	} else {
+		ownerTenant = owner.TenantId
+	}
+
+	return tenant.Visible(tenant.InitOrGetTenantResolver(serverConfig), requestTenant, ownerTenant), nil
+}
+
+// SouthTenantControl writes not found when resource is owned by a user of another tenant
+// resource of other tenant is reported as not found rather than forbidden so its existence is not leaked
+func SouthTenantControl(serverConfig *config.Config, r *http.Request, w http.ResponseWriter, ownerUserId, resourceName string) bool {
+	visible, err := SouthTenantVisible(serverConfig, r, ownerUserId)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to check tenant of resource", http.StatusInternalServerError, "", err)
+		return false
+	}
+	if !visible {
+		httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("%s not found", resourceName), http.StatusNotFound, "", fmt.Errorf("%s of user '%s' is not in tenant '%s'", resourceName, ownerUserId, tenant.FromRequest(r)))
+		return false
+	}
+	return true
+}
+
+// SouthGroupTenantControl writes not found when group does not exist or belongs to another tenant
+func SouthGroupTenantControl(serverConfig *config.Config, r *http.Request, w http.ResponseWriter, groupId string) bool {
+	provider, err := initOrGetGroupProvider(serverConfig)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get group provider", http.StatusInternalServerError, "", err)
+		return false
+	}
+
+	group, err := provider.GetGroup(groupId, nil)
+	if err != nil {
+		if customErr.IsNotFound(err) {
+			httpHelper.WriteCustomErrorAndLog(w, "group not found", http.StatusNotFound, "", err)
+			return false
+		}
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get group", http.StatusInternalServerError, "", err)
+		return false
+	}
+
+	requestTenant := tenant.FromRequest(r)
+	if !tenant.Visible(tenant.InitOrGetTenantResolver(serverConfig), requestTenant, group.TenantId) {
+		httpHelper.WriteCustomErrorAndLog(w, "group not found", http.StatusNotFound, "", fmt.Errorf("group '%s' is not in tenant '%s'", groupId, requestTenant))
+		return false
+	}
+	return true
+}
+
+// SouthTenantVisibleUserNames returns whether resource owned by user of a name is visible to tenant of request
+// users are listed once, so a list of resources is filtered without a lookup per resource
+// owner that does not exist belongs to default tenant, same as SouthTenantVisible
+func SouthTenantVisibleUserNames(serverConfig *config.Config, r *http.Request) (func(userName string) bool, error) {
+	requestTenant := tenant.FromRequest(r)
+	if requestTenant == "" {
+		return func(string) bool { return true }, nil
+	}
+
+	provider, err := initOrGetUserProvider(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	users, err := provider.GetUsers(map[string]struct{}{keystone.IncludeDisabledUsers: {}})
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := tenant.InitOrGetTenantResolver(serverConfig)
+	// user names are unique within a tenant only
+	tenantsOfName := map[string][]string{}
+	for _, user := range users {
+		tenantsOfName[user.Name] = append(tenantsOfName[user.Name], user.TenantId)
+	}
+	return func(userName string) bool {
+		tenantIds, found := tenantsOfName[userName]
+		if !found {
+			return tenant.Visible(resolver, requestTenant, "")
+		}
+		for _, tenantId := range tenantIds {
+			if tenant.Visible(resolver, requestTenant, tenantId) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
 func GetFileChatFileName(ragFilePath, kbId string) (string, error) {
 	destinationDir := filepath.Join(ragFilePath, "data", "temp", kbId)
 
@@ -3,6 +3,8 @@ package south
 import (
 	"bytes"
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
 	"core-api/pkg/core/privileges"
 	customErr "core-api/pkg/util/error"
 	httpHelper "core-api/pkg/util/http"
@@ -37,27 +39,35 @@ func (h *OpenhydraSouthAPIHandler) GetDevices(w http.ResponseWriter, r *http.Req
 
 	groupParams := r.URL.Query()
 	groups := groupParams["group"]
-	if len(groups) > 0 {
-		rawDevicesList := &deviceV1.DeviceList{}
-		err := json.Unmarshal(devicesList, rawDevicesList)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal devices", http.StatusInternalServerError, "FailedToUnmarshalDevices", err)
+	if len(groups) == 0 && tenant.FromRequest(r) == "" {
+		w.Write(devicesList)
+		return
+	}
+
+	// group of another tenant is reported as not found
+	for _, group := range groups {
+		if !SouthGroupTenantControl(h.config, r, w, group) {
 			return
 		}
+	}
 
+	rawDevicesList := &deviceV1.DeviceList{}
+	err = json.Unmarshal(devicesList, rawDevicesList)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal devices", http.StatusInternalServerError, "FailedToUnmarshalDevices", err)
+		return
+	}
+
+	var flatGroupUsers map[string]struct{}
+	if len(groups) > 0 {
 		groupProvider, err := initOrGetGroupProvider(h.config)
 		if err != nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to get group provider", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		result := &deviceV1.DeviceList{}
-		result.Kind = "List"
-		result.APIVersion = "v1"
-		flatGroups := map[string]struct{}{}
-		flatGroupUsers := map[string]struct{}{}
+		flatGroupUsers = map[string]struct{}{}
 		for _, group := range groups {
-			flatGroups[group] = struct{}{}
 			groupUsers, err := groupProvider.GetGroupUsers(group, nil)
 			if err != nil {
 				httpHelper.WriteCustomErrorAndLog(w, "Failed to get group users", http.StatusInternalServerError, "", err)
@@ -67,22 +77,40 @@ func (h *OpenhydraSouthAPIHandler) GetDevices(w http.ResponseWriter, r *http.Req
 				flatGroupUsers[user.Name] = struct{}{}
 			}
 		}
+	}
 
-		for _, device := range rawDevicesList.Items {
-			if _, ok := flatGroupUsers[device.Spec.ChineseName]; ok {
-				result.Items = append(result.Items, device)
+	// devices of users of other tenants are left out
+	visible, err := SouthTenantVisibleUserNames(h.config, r)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to check tenant of devices", http.StatusInternalServerError, "", err)
+		return
+	}
+
+	result := &deviceV1.DeviceList{}
+	result.Kind = "List"
+	result.APIVersion = "v1"
+	for _, device := range rawDevicesList.Items {
+		if flatGroupUsers != nil {
+			if _, ok := flatGroupUsers[device.Spec.ChineseName]; !ok {
+				continue
 			}
 		}
 
-		entityToWrite, err := json.Marshal(result)
-		if err != nil {
-			httpHelper.WriteCustomErrorAndLog(w, "Failed to marshal devices", http.StatusInternalServerError, "FailedToMarshalDevices", err)
-			return
+		owner := device.Spec.OpenHydraUsername
+		if owner == "" {
+			owner = device.Spec.ChineseName
 		}
-		w.Write(entityToWrite)
-	} else {
-		w.Write(devicesList)
+		if visible(owner) {
+			result.Items = append(result.Items, device)
+		}
+	}
+
+	entityToWrite, err := json.Marshal(result)
+	if err != nil {
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to marshal devices", http.StatusInternalServerError, "FailedToMarshalDevices", err)
+		return
 	}
+	w.Write(entityToWrite)
 }
 
 func (h *OpenhydraSouthAPIHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
@@ -111,7 +139,11 @@ func (h *OpenhydraSouthAPIHandler) GetDevice(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		_, canAccess, err := SouthAuthorizationControlWithUser(r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, "device")
+		// device of another tenant is reported as not found, even to one holding permission to manage other user resource
+		if !SouthTenantControl(h.config, r, w, user.Id, share.KindDevice) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, share.KindDevice, userId, share.AccessRead)
 		if err != nil {
 			return
 		}
@@ -128,6 +160,20 @@ func (h *OpenhydraSouthAPIHandler) GetDevice(w http.ResponseWriter, r *http.Requ
 	w.Write(device)
 }
 
+// DeviceOwner returns id of user a device belongs to, device is named after user
+// it is owner resolver of device shares
+func (h *OpenhydraSouthAPIHandler) DeviceOwner(userName string) (string, error) {
+	userProvider, err := initOrGetUserProvider(h.config)
+	if err != nil {
+		return "", err
+	}
+	user, err := userProvider.SearchUserByName(userName, nil)
+	if err != nil {
+		return "", err
+	}
+	return user.Id, nil
+}
+
 func (h *OpenhydraSouthAPIHandler) CreateDevice(w http.ResponseWriter, r *http.Request) {
 
 	devicePost := &deviceV1.Device{}
@@ -161,7 +207,11 @@ func (h *OpenhydraSouthAPIHandler) CreateDevice(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		_, canAccess, err := SouthAuthorizationControlWithUser(r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, "device")
+		// device of another tenant is reported as not found, even to one holding permission to manage other user resource
+		if !SouthTenantControl(h.config, r, w, user.Id, share.KindDevice) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, share.KindDevice, devicePost.Spec.OpenHydraUsername, share.AccessUse)
 		if err != nil || !canAccess {
 			return
 		}
@@ -219,7 +269,11 @@ func (h *OpenhydraSouthAPIHandler) DeleteDevice(w http.ResponseWriter, r *http.R
 			return
 		}
 
-		_, canAccess, err := SouthAuthorizationControlWithUser(r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, "device")
+		// device of another tenant is reported as not found, even to one holding permission to manage other user resource
+		if !SouthTenantControl(h.config, r, w, user.Id, share.KindDevice) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "device", privileges.PermissionDeviceManageOtherUserResource, user.Id, share.KindDevice, userId, share.AccessManage)
 		if err != nil || !canAccess {
 			return
 		}
@@ -3,6 +3,8 @@ package south
 import (
 	"bytes"
 	"core-api/cmd/core-api-server/app/config"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
 	"core-api/pkg/core/privileges"
 	coreApiLog "core-api/pkg/logger"
 	chatV1 "core-api/pkg/north/api/chat/core/v1"
@@ -382,8 +384,11 @@ func (h *RAGSouthApiHandler) GetConversationById(w http.ResponseWriter, r *http.
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversation.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversation.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversation.UserID, share.KindConversation, conversationId, share.AccessRead)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -402,6 +407,30 @@ func (h *RAGSouthApiHandler) GetConversationById(w http.ResponseWriter, r *http.
 	httpHelper.WriteResponseEntity(w, conversation)
 }
 
+// ConversationOwner returns id of user owning conversation, it is owner resolver of conversation shares
+func (h *RAGSouthApiHandler) ConversationOwner(conversationId string) (string, error) {
+	body, _, status, err := common.CommonRequest(fmt.Sprintf("%s/conversations/%s", h.config.Rag.Endpoint, conversationId), http.MethodGet, "", nil, nil, false, true, 3*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if status == http.StatusNotFound {
+		return "", customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Conversation %s not found", conversationId))
+	}
+	if status != http.StatusOK {
+		return "", fmt.Errorf("failed to get conversation %s with status code %d", conversationId, status)
+	}
+
+	conversation := &conversationV1.Conversation{}
+	err = json.Unmarshal(body, conversation)
+	if err != nil {
+		return "", err
+	}
+	if conversation.UserID == "" {
+		return "", customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Conversation %s not found", conversationId))
+	}
+	return conversation.UserID, nil
+}
+
 func (h *RAGSouthApiHandler) GetConversationByIdToModel(conversationId string) (*conversationV1.Conversation, error) {
 	body, _, _, err := common.CommonRequest(fmt.Sprintf("%s/conversations/%s", h.config.Rag.Endpoint, conversationId), http.MethodGet, "", nil, nil, false, true, 3*time.Second)
 	if err != nil {
@@ -429,8 +458,11 @@ func (h *RAGSouthApiHandler) DeleteConversation(w http.ResponseWriter, r *http.R
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversationFound.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, share.KindConversation, conversationFound.ID, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -446,6 +478,8 @@ func (h *RAGSouthApiHandler) DeleteConversation(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	SouthDeleteShares(h.config, share.KindConversation, conversationFound.ID)
+
 	if conversationFound.TempKBId != "" && conversationFound.ChatType == "file_chat" {
 		// delete temp chat file of file chat conversation
 		err := os.RemoveAll(filepath.Join(h.config.Rag.FileChatPath, "data", "temp", conversationFound.TempKBId))
@@ -496,8 +530,11 @@ func (h *RAGSouthApiHandler) PatchConversation(w http.ResponseWriter, r *http.Re
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversationFound.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, share.KindConversation, conversationId, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -531,7 +568,15 @@ func (h *RAGSouthApiHandler) getConversationOfUser(userId, chatType string, head
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, canAccess, err := SouthAuthorizationControlWithUser(r, nil, "rag", privileges.PermissionRagManageOtherUserResource, userId, "conversation")
+		visible, err := SouthTenantVisible(h.config, r, userId)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		if !visible {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("user '%s' is not in tenant '%s'", userId, tenant.FromRequest(r))
+		}
+
+		_, canAccess, err := SouthAuthorizationControlWithGroupAdmin(h.config, r, nil, "rag", privileges.PermissionRagManageOtherUserResource, userId, share.KindConversation)
 		if err != nil {
 			return nil, nil, http.StatusInternalServerError, err
 		}
@@ -577,7 +622,7 @@ func (h *RAGSouthApiHandler) GetConversationOfUser(w http.ResponseWriter, r *htt
 
 	resultBytes, headers, status, err := h.getConversationOfUser(chi.URLParam(r, "userId"), r.URL.Query().Get("chatType"), r.Header, r)
 	if err != nil {
-		httpHelper.WriteCustomErrorAndLog(w, "Failed to get conversation of user", http.StatusInternalServerError, "", err)
+		httpHelper.WriteCustomErrorAndLog(w, "Failed to get conversation of user", status, "", err)
 		return
 	}
 
@@ -635,8 +680,11 @@ func (h *RAGSouthApiHandler) DeleteConversationOfUser(w http.ResponseWriter, r *
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, userId, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, userId, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithGroupAdmin(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, userId, share.KindConversation)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -695,8 +743,11 @@ func (h *RAGSouthApiHandler) PatchKnowledgeBase(w http.ResponseWriter, r *http.R
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, kb.Data.UserID, "knowledge_base")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, kb.Data.UserID, "knowledge_base") {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, kb.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -774,8 +825,11 @@ func (h *RAGSouthApiHandler) DeleteKnowledgeBase(w http.ResponseWriter, r *http.
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, kb.Data.KbId, "knowledge_base")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, kb.Data.UserID, "knowledge_base") {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, kb.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -791,6 +845,8 @@ func (h *RAGSouthApiHandler) DeleteKnowledgeBase(w http.ResponseWriter, r *http.
 		return
 	}
 
+	SouthDeleteShares(h.config, share.KindKnowledgeBase, kbId)
+
 	httpHelper.WriteResponseEntity(w, kb.Data)
 	w.WriteHeader(status)
 }
@@ -816,7 +872,7 @@ func (h *RAGSouthApiHandler) CreateKnowledgeBase(w http.ResponseWriter, r *http.
 		return
 	}
 
-	_, err = userProvider.GetUser(kb.UserID, nil)
+	owner, err := userProvider.GetUser(kb.UserID, nil)
 	if err != nil {
 		// check is not found
 		if customErr.IsNotFound(err) {
@@ -827,6 +883,12 @@ func (h *RAGSouthApiHandler) CreateKnowledgeBase(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// knowledge base can only be created for a user of same tenant
+	if !tenant.Visible(tenant.InitOrGetTenantResolver(h.config), tenant.FromRequest(r), owner.TenantId) {
+		httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("User with id: %s not found", kb.UserID), http.StatusNotFound, "", fmt.Errorf("user '%s' is not in tenant '%s'", kb.UserID, tenant.FromRequest(r)))
+		return
+	}
+
 	body, _, status, err := common.CommonRequest(fmt.Sprintf("%s/knowledge_base/create_knowledge_base", h.config.Rag.Endpoint), r.Method, "", body, r.Header, false, true, 3*time.Second)
 	if err != nil {
 		httpHelper.WriteCustomErrorAndLog(w, "Failed to create knowledge base", http.StatusInternalServerError, "", err)
@@ -891,6 +953,11 @@ func (h *RAGSouthApiHandler) GetKnowledgeBases(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if !tenant.Visible(tenant.InitOrGetTenantResolver(h.config), tenant.FromRequest(r), user.TenantId) {
+		httpHelper.WriteCustomErrorAndLog(w, fmt.Sprintf("User with id: %s not found", userId), http.StatusNotFound, "", fmt.Errorf("user '%s' is not in tenant '%s'", userId, tenant.FromRequest(r)))
+		return
+	}
+
 	if !h.config.CoreApiConfig.DisableAuth {
 		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, userId, "knowledge_base")
 		if err != nil {
@@ -923,7 +990,7 @@ func (h *RAGSouthApiHandler) GetKnowledgeBases(w http.ResponseWriter, r *http.Re
 		appendKbSet := strings.Split(additional, ",")
 		for _, kbName := range appendKbSet {
 			if kbName == "publicKB" {
-				publicKbs, err := h.GetPublicKnowledgeBasesToModel(map[string]struct{}{userId: {}})
+				publicKbs, err := h.GetPublicKnowledgeBasesToModel(map[string]struct{}{userId: {}}, tenant.FromRequest(r))
 				if err != nil {
 					httpHelper.WriteCustomErrorAndLog(w, "Failed to get public knowledge bases", http.StatusInternalServerError, "", err)
 					return
@@ -969,7 +1036,8 @@ func (h *RAGSouthApiHandler) GetKnowledgeBases(w http.ResponseWriter, r *http.Re
 }
 
 // get public knowledge base to mode
-func (h *RAGSouthApiHandler) GetPublicKnowledgeBasesToModel(excludesByUserId map[string]struct{}) ([]knowledgeBaseV1.KnowledgeBase, error) {
+// public knowledge bases of other tenants are left out when tenantId is set
+func (h *RAGSouthApiHandler) GetPublicKnowledgeBasesToModel(excludesByUserId map[string]struct{}, tenantId string) ([]knowledgeBaseV1.KnowledgeBase, error) {
 	body, _, status, err := common.CommonRequest(fmt.Sprintf("%s/knowledge_base/list_public_knowledge_base", h.config.Rag.Endpoint), http.MethodGet, "", nil, nil, false, true, 3*time.Second)
 	if err != nil {
 		return nil, err
@@ -1004,14 +1072,21 @@ func (h *RAGSouthApiHandler) GetPublicKnowledgeBasesToModel(excludesByUserId map
 		return nil, fmt.Errorf("failed to convert user cache")
 	}
 
+	resolver := tenant.InitOrGetTenantResolver(h.config)
 	for index := range tmpWrapper.Data {
 		if _, ok := excludesByUserId[tmpWrapper.Data[index].UserID]; ok {
 			continue
 		}
 
 		if user, ok := convertedUserCache[tmpWrapper.Data[index].UserID]; ok {
+			if !tenant.Visible(resolver, tenantId, user.TenantId) {
+				continue
+			}
 			tmpWrapper.Data[index].Username = user.Name
 		} else {
+			if tmpWrapper.Data[index].UserID != "build-in" && !tenant.Visible(resolver, tenantId, "") {
+				continue
+			}
 			if tmpWrapper.Data[index].UserID == "build-in" {
 				tmpWrapper.Data[index].Username = "build-in"
 			} else {
@@ -1049,21 +1124,33 @@ func (h *RAGSouthApiHandler) GetPublicKnowledgeBases(w http.ResponseWriter, r *h
 		return
 	}
 
+	// public knowledge bases of other tenants are left out
+	requestTenant := tenant.FromRequest(r)
+	resolver := tenant.InitOrGetTenantResolver(h.config)
+	kbs := []knowledgeBaseV1.KnowledgeBase{}
 	for index, kb := range tmpWrapper.Data {
 		if kb.UserID == "build-in" {
 			tmpWrapper.Data[index].Username = "build-in"
+			kbs = append(kbs, tmpWrapper.Data[index])
 			continue
 		}
 		user, err := uerProvider.GetUser(kb.UserID, nil)
 		if err != nil {
 			coreApiLog.Logger.Warn("Failed to get user, will fall back to un-referenced", "error", err, "userId", kb.UserID)
-			tmpWrapper.Data[index].Username = "un-referenced"
+			if tenant.Visible(resolver, requestTenant, "") {
+				tmpWrapper.Data[index].Username = "un-referenced"
+				kbs = append(kbs, tmpWrapper.Data[index])
+			}
+			continue
+		}
+		if !tenant.Visible(resolver, requestTenant, user.TenantId) {
 			continue
 		}
 		tmpWrapper.Data[index].Username = user.Name
+		kbs = append(kbs, tmpWrapper.Data[index])
 	}
 
-	kbList, err := json.Marshal(tmpWrapper.Data)
+	kbList, err := json.Marshal(kbs)
 	if err != nil {
 		httpHelper.WriteCustomErrorAndLog(w, "Failed to marshal knowledge bases", http.StatusInternalServerError, "", err)
 		return
@@ -1163,8 +1250,11 @@ func (h *RAGSouthApiHandler) GetConversationMessages(w http.ResponseWriter, r *h
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversationFound.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, share.KindConversation, conversationId, share.AccessRead)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -1207,8 +1297,11 @@ func (h *RAGSouthApiHandler) GetConversationMessageById(w http.ResponseWriter, r
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversationFound.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversationFound.UserID, share.KindConversation, conversationId, share.AccessRead)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -1276,8 +1369,11 @@ func (h *RAGSouthApiHandler) FileChat(w http.ResponseWriter, r *http.Request) {
 
 	// block user to access other user resource
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversation.UserID, "conversation")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, conversation.UserID, share.KindConversation) {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, conversation.UserID, share.KindConversation, chatPost.ConversationId, share.AccessUse)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -1336,8 +1432,11 @@ func (h *RAGSouthApiHandler) UploadFileKnowledgeBase(w http.ResponseWriter, r *h
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, "knowledge_base")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, kbInfo.Data.UserID, "knowledge_base") {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -1637,6 +1736,30 @@ func (h *RAGSouthApiHandler) getKnowledgeBaseDetailToModel(kbId string) ([]byte,
 	return body, header, status, nil
 }
 
+// KnowledgeBaseOwner returns id of user owning knowledge base, it is owner resolver of knowledge base shares
+func (h *RAGSouthApiHandler) KnowledgeBaseOwner(kbId string) (string, error) {
+	body, _, status, err := h.getKnowledgeBaseDetailToModel(kbId)
+	if err != nil {
+		if status == http.StatusNotFound {
+			return "", customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Knowledge base %s not found", kbId))
+		}
+		return "", err
+	}
+
+	kbInfo := &struct {
+		knowledgeBaseV1.KnowledgeBaseCommonResult
+		Data *knowledgeBaseV1.KnowledgeBase `json:"data"`
+	}{}
+	err = json.Unmarshal(body, kbInfo)
+	if err != nil {
+		return "", err
+	}
+	if kbInfo.Data == nil {
+		return "", customErr.NewNotFound(http.StatusNotFound, fmt.Sprintf("Knowledge base %s not found", kbId))
+	}
+	return kbInfo.Data.UserID, nil
+}
+
 func (h *RAGSouthApiHandler) GetKnowledgeBaseDetailToModel(kbId string, r *http.Request, w http.ResponseWriter) (*knowledgeBaseV1.KnowledgeBase, error) {
 
 	body, _, _, err := h.getKnowledgeBaseDetailToModel(kbId)
@@ -1653,8 +1776,18 @@ func (h *RAGSouthApiHandler) GetKnowledgeBaseDetailToModel(kbId string, r *http.
 		return nil, err
 	}
 
+	if !h.config.CoreApiConfig.DisableAuth {
+		visible, err := SouthTenantVisible(h.config, r, kbInfo.Data.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, fmt.Errorf("no permission to access knowledge base %s", kbId)
+		}
+	}
+
 	if !h.config.CoreApiConfig.DisableAuth && kbInfo.Data.IsPrivate {
-		loginUser, canAccess, err := SouthAuthorizationControlWithUser(r, nil, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, "knowledge_base")
+		loginUser, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, nil, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessUse)
 		if err != nil {
 			return nil, err
 		}
@@ -1712,6 +1845,10 @@ func (h *RAGSouthApiHandler) GetKnowledgeBaseDetail(w http.ResponseWriter, r *ht
 		return
 	}
 
+	if !h.config.CoreApiConfig.DisableAuth && !SouthTenantControl(h.config, r, w, result.Data.UserID, "knowledge_base") {
+		return
+	}
+
 	if result.Data.UserID == "build-in" {
 		result.Data.Username = "build-in"
 	} else {
@@ -1851,15 +1988,19 @@ func (h *RAGSouthApiHandler) GetKBFiles(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		kbInfo := &knowledgeBaseV1.KnowledgeBase{}
+		// detail is wrapped in data like other responses of rag
+		kbInfo := &struct {
+			knowledgeBaseV1.KnowledgeBaseCommonResult
+			Data *knowledgeBaseV1.KnowledgeBase `json:"data"`
+		}{}
 		err = json.Unmarshal(kbBody, kbInfo)
-		if err != nil {
+		if err != nil || kbInfo.Data == nil {
 			httpHelper.WriteCustomErrorAndLog(w, "Failed to unmarshal knowledge base detail", http.StatusInternalServerError, "", err)
 			return
 		}
 
-		_, _, err = SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.UserID, "knowledge_base")
-		if err != nil {
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessRead)
+		if err != nil || !canAccess {
 			return
 		}
 	}
@@ -1917,8 +2058,11 @@ func (h *RAGSouthApiHandler) DeleteKBFiles(w http.ResponseWriter, r *http.Reques
 	}
 
 	if !h.config.CoreApiConfig.DisableAuth {
-		_, _, err := SouthAuthorizationControlWithUser(r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, "knowledge_base")
-		if err != nil {
+		if !SouthTenantControl(h.config, r, w, kbInfo.Data.UserID, "knowledge_base") {
+			return
+		}
+		_, canAccess, err := SouthAuthorizationControlWithShare(h.config, r, w, "rag", privileges.PermissionRagManageOtherUserResource, kbInfo.Data.UserID, share.KindKnowledgeBase, kbId, share.AccessManage)
+		if err != nil || !canAccess {
 			return
 		}
 	}
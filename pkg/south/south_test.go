@@ -2,11 +2,21 @@ package south
 
 import (
 	"bytes"
+	"context"
 	"core-api/cmd/core-api-server/app/config"
+	auth "core-api/pkg/core/auth"
+	"core-api/pkg/core/auth/groupadmin"
+	"core-api/pkg/core/auth/share"
+	"core-api/pkg/core/auth/tenant"
+	"core-api/pkg/core/privileges"
+	coreApiLog "core-api/pkg/logger"
+	coreUserV1 "core-api/pkg/north/api/user/core/v1"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"time"
 
 	"core-api/pkg/util/common"
@@ -14,6 +24,7 @@ import (
 	courseV1 "open-hydra-server-api/pkg/apis/open-hydra-api/course/core/v1"
 
 	"github.com/emicklei/go-restful"
+	"github.com/go-chi/chi/v5"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -147,4 +158,253 @@ var _ = Describe("South Api test", func() {
 			Expect(result.Items[1].Spec.CreatedBy).To(Equal("test2"))
 		})
 	})
+
+	Describe("SouthAuthorizationControlWithShare test", func() {
+		var shareConfig *config.Config
+		var owner, reader, manager *coreUserV1.CoreUser
+		var conversationId string
+		// shares are kept by one store for the whole process, each spec shares its own conversation
+		specCount := 0
+
+		authorize := func(requestUser *coreUserV1.CoreUser, access string) (int, bool) {
+			request := httptest.NewRequest(http.MethodGet, "/conversations/"+conversationId, nil)
+			request = request.WithContext(context.WithValue(request.Context(), "core-user", requestUser))
+			recorder := httptest.NewRecorder()
+			_, canAccess, err := SouthAuthorizationControlWithShare(shareConfig, request, recorder, "rag", privileges.PermissionRagManageOtherUserResource, owner.Id, share.KindConversation, conversationId, access)
+			Expect(err).To(BeNil())
+			return recorder.Code, canAccess
+		}
+
+		BeforeEach(func() {
+			coreApiLog.InitLogger("DEBUG")
+			shareConfig = config.DefaultConfig()
+			specCount++
+			owner = &coreUserV1.CoreUser{Id: fmt.Sprintf("owner-%d", specCount)}
+			reader = &coreUserV1.CoreUser{Id: "reader", Permission: map[string]uint64{"rag": privileges.PermissionRagList}, Groups: []coreUserV1.CoreGroup{{Id: fmt.Sprintf("class-%d", specCount)}}}
+			manager = &coreUserV1.CoreUser{Id: "manager", Permission: map[string]uint64{"rag": privileges.PermissionRagManageOtherUserResource}}
+			conversationId = "conversation-of-" + owner.Id
+
+			store, err := share.InitOrGetShareStore(shareConfig)
+			Expect(err).To(BeNil())
+			_, err = store.Put(&coreUserV1.CoreShare{ResourceKind: share.KindConversation, ResourceId: conversationId, OwnerId: owner.Id, SubjectKind: share.SubjectGroup, SubjectId: reader.Groups[0].Id, Access: share.AccessRead})
+			Expect(err).To(BeNil())
+		})
+
+		It("should let owner and user managing resources of others through", func() {
+			_, canAccess := authorize(owner, share.AccessManage)
+			Expect(canAccess).To(BeTrue())
+			_, canAccess = authorize(manager, share.AccessManage)
+			Expect(canAccess).To(BeTrue())
+		})
+
+		It("should let user through up to access shared with group", func() {
+			_, canAccess := authorize(reader, share.AccessRead)
+			Expect(canAccess).To(BeTrue())
+			code, canAccess := authorize(reader, share.AccessUse)
+			Expect(canAccess).To(BeFalse())
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+	})
+	Describe("SouthAuthorizationControlWithGroupAdmin test", func() {
+		var adminConfig *config.Config
+		var teacher, student, outsider *coreUserV1.CoreUser
+
+		authorize := func(requestUser *coreUserV1.CoreUser, ownerId, resourceName string) (int, bool) {
+			request := httptest.NewRequest(http.MethodDelete, "/devices/"+ownerId, nil)
+			request = request.WithContext(context.WithValue(request.Context(), "core-user", requestUser))
+			recorder := httptest.NewRecorder()
+			_, canAccess, err := SouthAuthorizationControlWithShare(adminConfig, request, recorder, "device", privileges.PermissionDeviceManageOtherUserResource, ownerId, resourceName, ownerId, share.AccessManage)
+			Expect(err).To(BeNil())
+			return recorder.Code, canAccess
+		}
+
+		BeforeEach(func() {
+			coreApiLog.InitLogger("DEBUG")
+			adminConfig = config.DefaultConfig()
+			adminConfig.AuthConfig.Provider = "local"
+			adminConfig.AuthConfig.Local = &config.LocalConfig{
+				Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+				BootstrapAdminPassword: "admin-password",
+			}
+			userProvider, groupProvider = nil, nil
+
+			users, err := initOrGetUserProvider(adminConfig)
+			Expect(err).To(BeNil())
+			teacher, err = users.CreateUser(&coreUserV1.CoreUser{Name: "teacher", Password: "teacher-password"}, nil)
+			Expect(err).To(BeNil())
+			student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+			Expect(err).To(BeNil())
+			outsider, err = users.CreateUser(&coreUserV1.CoreUser{Name: "outsider", Password: "outsider-password"}, nil)
+			Expect(err).To(BeNil())
+			teacher.Permission = map[string]uint64{"device": privileges.PermissionDeviceList}
+
+			groups, err := initOrGetGroupProvider(adminConfig)
+			Expect(err).To(BeNil())
+			class, err := groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class-1"}, nil)
+			Expect(err).To(BeNil())
+			Expect(groups.AddUserToGroup(student.Id, class.Id)).To(Succeed())
+
+			// administrators are kept by one store for the whole process, ids of users and groups differ in each spec
+			store, err := groupadmin.InitOrGetGroupAdminStore(adminConfig)
+			Expect(err).To(BeNil())
+			_, err = store.Add(&coreUserV1.CoreGroupAdmin{GroupId: class.Id, UserId: teacher.Id})
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			userProvider, groupProvider = nil, nil
+		})
+
+		It("should let group administrator manage device of member only", func() {
+			_, canAccess := authorize(teacher, student.Id, share.KindDevice)
+			Expect(canAccess).To(BeTrue())
+			code, canAccess := authorize(teacher, outsider.Id, share.KindDevice)
+			Expect(canAccess).To(BeFalse())
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should not let group administrator manage device of built-in member or member holding more permission", func() {
+			users, err := initOrGetUserProvider(adminConfig)
+			Expect(err).To(BeNil())
+			admin, err := users.SearchUserByName("admin", nil)
+			Expect(err).To(BeNil())
+			roles, err := auth.CreateRoleProvider(adminConfig, auth.GetAuthProviderType(adminConfig))
+			Expect(err).To(BeNil())
+			deviceManager, err := roles.CreateRole(&coreUserV1.CoreRole{Name: "device-manager", Permission: map[string]uint64{"device": privileges.PermissionDeviceManageOtherUserResource}}, nil)
+			Expect(err).To(BeNil())
+			anotherTeacher, err := users.CreateUser(&coreUserV1.CoreUser{Name: "another-teacher", Password: "teacher-password", Roles: []coreUserV1.CoreRole{{Id: deviceManager.Id}}}, nil)
+			Expect(err).To(BeNil())
+			groups, err := initOrGetGroupProvider(adminConfig)
+			Expect(err).To(BeNil())
+			class, err := groups.SearchGroupByName("class-1", nil)
+			Expect(err).To(BeNil())
+			Expect(groups.AddUserToGroup(admin.Id, class.Id)).To(Succeed())
+			Expect(groups.AddUserToGroup(anotherTeacher.Id, class.Id)).To(Succeed())
+
+			code, canAccess := authorize(teacher, admin.Id, share.KindDevice)
+			Expect(canAccess).To(BeFalse())
+			Expect(code).To(Equal(http.StatusForbidden))
+			code, canAccess = authorize(teacher, anotherTeacher.Id, share.KindDevice)
+			Expect(canAccess).To(BeFalse())
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+
+		It("should not let group administrator manage knowledge base of member", func() {
+			code, canAccess := authorize(teacher, student.Id, share.KindKnowledgeBase)
+			Expect(canAccess).To(BeFalse())
+			Expect(code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("tenant control test", func() {
+		var tenantConfig *config.Config
+		var student, otherTenantAdmin *coreUserV1.CoreUser
+		var rag *httptest.Server
+
+		newRequest := func(method, target, tenantId string, params map[string]string) *http.Request {
+			routeContext := chi.NewRouteContext()
+			for key, value := range params {
+				routeContext.URLParams.Add(key, value)
+			}
+			request := httptest.NewRequest(method, target, nil)
+			ctx := context.WithValue(request.Context(), chi.RouteCtxKey, routeContext)
+			ctx = context.WithValue(ctx, "core-user", otherTenantAdmin)
+			return request.WithContext(tenant.WithTenant(ctx, tenantId))
+		}
+
+		BeforeEach(func() {
+			coreApiLog.InitLogger("DEBUG")
+			tenantConfig = config.DefaultConfig()
+			tenantConfig.CoreApiConfig.DisableAuth = false
+			tenantConfig.AuthConfig.Provider = "local"
+			tenantConfig.AuthConfig.Local = &config.LocalConfig{
+				Path:                   filepath.Join(GinkgoT().TempDir(), "identity.db"),
+				BootstrapAdminPassword: "admin-password",
+			}
+			userProvider, groupProvider = nil, nil
+
+			users, err := initOrGetUserProvider(tenantConfig)
+			Expect(err).To(BeNil())
+			student, err = users.CreateUser(&coreUserV1.CoreUser{Name: "student", Password: "student-password"}, nil)
+			Expect(err).To(BeNil())
+			// administrator of another tenant holds every permission, tenant still hides resources of this one
+			otherTenantAdmin = &coreUserV1.CoreUser{Id: "other-tenant-admin", Name: "other-tenant-admin", TenantId: "school-b", Permission: privileges.ModulesFullPermission()}
+
+			rag = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/conversations/users/"+student.Id {
+					w.Write([]byte(`[{"id":"conversation-1","user_id":"` + student.Id + `"}]`))
+					return
+				}
+				w.Write([]byte(`{"id":"conversation-1","user_id":"` + student.Id + `"}`))
+			}))
+			tenantConfig.Rag = &config.Rag{Endpoint: rag.URL}
+		})
+
+		AfterEach(func() {
+			rag.Close()
+			userProvider, groupProvider = nil, nil
+		})
+
+		It("should report device of another tenant as not found", func() {
+			handler := NewOpenhydraSouthAPIHandler(tenantConfig)
+			for _, handle := range []http.HandlerFunc{handler.GetDevice, handler.DeleteDevice} {
+				recorder := httptest.NewRecorder()
+				handle(recorder, newRequest(http.MethodGet, "/devices/student", "school-b", map[string]string{"userId": "student"}))
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			}
+
+			body, err := json.Marshal(map[string]interface{}{"spec": map[string]string{"openHydraUsername": "student"}})
+			Expect(err).To(BeNil())
+			request := newRequest(http.MethodPost, "/devices", "school-b", nil)
+			request.Body = io.NopCloser(bytes.NewReader(body))
+			recorder := httptest.NewRecorder()
+			handler.CreateDevice(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("should leave devices and groups of another tenant out of device list", func() {
+			kube := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"kind":"DeviceList","items":[{"metadata":{"name":"student"},"spec":{"openHydraUsername":"student","chineseName":"student"}}]}`))
+			}))
+			defer kube.Close()
+			tenantConfig.KubeConfig = &config.KubeConfig{RestConfig: &rest.Config{Host: kube.URL}}
+
+			groups, err := initOrGetGroupProvider(tenantConfig)
+			Expect(err).To(BeNil())
+			class, err := groups.CreateGroup(&coreUserV1.CoreGroup{Name: "class-1"}, nil)
+			Expect(err).To(BeNil())
+			Expect(groups.AddUserToGroup(student.Id, class.Id)).To(Succeed())
+
+			listDevices := func(target, tenantId string) *httptest.ResponseRecorder {
+				recorder := httptest.NewRecorder()
+				NewOpenhydraSouthAPIHandler(tenantConfig).GetDevices(recorder, newRequest(http.MethodGet, target, tenantId, nil))
+				return recorder
+			}
+
+			recorder := listDevices("/devices", "school-b")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).NotTo(ContainSubstring("student"))
+			Expect(listDevices("/devices?group="+class.Id, "school-b").Code).To(Equal(http.StatusNotFound))
+
+			defaultTenant := tenant.InitOrGetTenantResolver(tenantConfig).DefaultTenant()
+			Expect(listDevices("/devices", defaultTenant).Body.String()).To(ContainSubstring("student"))
+			recorder = listDevices("/devices?group="+class.Id, defaultTenant)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring("student"))
+		})
+
+		It("should report conversation of another tenant as not found", func() {
+			handler := NewRAGSouthApiHandler(tenantConfig, nil)
+			params := map[string]string{"conversationId": "conversation-1", "userId": student.Id}
+			for _, handle := range []http.HandlerFunc{handler.GetConversationById, handler.DeleteConversation, handler.PatchConversation, handler.GetConversationMessages, handler.GetConversationOfUser, handler.DeleteConversationOfUser} {
+				recorder := httptest.NewRecorder()
+				handle(recorder, newRequest(http.MethodGet, "/conversations/conversation-1", "school-b", params))
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.GetConversationById(recorder, newRequest(http.MethodGet, "/conversations/conversation-1", tenant.InitOrGetTenantResolver(tenantConfig).DefaultTenant(), params))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+		})
+	})
 })
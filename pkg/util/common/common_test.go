@@ -2,6 +2,7 @@ package common
 
 import (
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/emicklei/go-restful"
@@ -65,4 +66,26 @@ var _ = Describe("common help func test", func() {
 			Expect(string(result)).To(Equal("test"))
 		})
 	})
+
+	Describe("ForEachBounded test", func() {
+		It("should call work for every index with bounded concurrency", func() {
+			var running, most int32
+			done := make([]bool, 50)
+			ForEachBounded(len(done), 4, func(index int) {
+				now := atomic.AddInt32(&running, 1)
+				for {
+					seen := atomic.LoadInt32(&most)
+					if now <= seen || atomic.CompareAndSwapInt32(&most, seen, now) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				done[index] = true
+				atomic.AddInt32(&running, -1)
+			})
+			Expect(done).NotTo(ContainElement(false))
+			Expect(most).To(BeNumerically("<=", 4))
+			Expect(most).To(BeNumerically(">", 1))
+		})
+	})
 })
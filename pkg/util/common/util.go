@@ -21,6 +21,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/emicklei/go-restful"
@@ -315,3 +316,23 @@ func SplitTrimFilter(s string, sep string) []string {
 	}
 	return strList
 }
+
+// ForEachBounded calls work for every index in [0, count) with at most limit calls running at the same time
+// it returns after all calls are done
+func ForEachBounded(count, limit int, work func(index int)) {
+	if limit < 1 {
+		limit = 1
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	for index := 0; index < count; index++ {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			work(index)
+		}(index)
+	}
+	wg.Wait()
+}
@@ -21,4 +21,18 @@ var _ = Describe("error test", func() {
 			Expect(result).To(BeFalse())
 		})
 	})
+
+	Describe("IsUnauthorized test", func() {
+		It("should return true if the error is unauthorized", func() {
+			err := NewUnauthorized(401, "unauthorized")
+			result := IsUnauthorized(err)
+			Expect(result).To(BeTrue())
+		})
+
+		It("should return false if the error is not unauthorized", func() {
+			err := NewNotFound(404, "not found")
+			result := IsUnauthorized(err)
+			Expect(result).To(BeFalse())
+		})
+	})
 })
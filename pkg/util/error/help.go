@@ -9,3 +9,13 @@ func IsNotFound(err error) bool {
 	}
 	return false
 }
+
+func IsUnauthorized(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(*Unauthorized); ok {
+		return e.Code == 401
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package listing
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// page size used if page is given without page size
+	DefaultPageSize = 20
+	MaxPageSize     = 500
+	// page past it would overflow offset of its first item
+	MaxPage = math.MaxInt / MaxPageSize
+	// response header carrying count of items matched before paging
+	TotalCountHeader = "X-Total-Count"
+)
+
+// Query is search, sorting and paging asked by query string of a list request
+// e.g. ?q=class&sort=-name&page=2&pageSize=50
+type Query struct {
+	// case insensitive substring searched in fields of item
+	Search string
+	// field to sort by, it is empty if no sorting is asked
+	SortBy     string
+	Descending bool
+	// 1 based page, 0 means whole list is returned
+	Page     int
+	PageSize int
+}
+
+// Fields tells how items of type T are searched and sorted
+type Fields[T any] struct {
+	// texts of item searched by q, e.g. name and description
+	Search func(T) []string
+	// sortable fields by name of query
+	Sort map[string]func(T) string
+	// field to sort by if paging is asked without sorting, so pages are stable
+	DefaultSort string
+}
+
+// Parse reads query from query string
+func Parse(values url.Values) (*Query, error) {
+	query := &Query{Search: strings.TrimSpace(values.Get("q"))}
+
+	if sortBy := strings.TrimSpace(values.Get("sort")); sortBy != "" {
+		query.Descending = strings.HasPrefix(sortBy, "-")
+		query.SortBy = strings.TrimPrefix(sortBy, "-")
+	}
+
+	page, err := positive(values, "page")
+	if err != nil {
+		return nil, err
+	}
+	pageSize, err := positive(values, "pageSize")
+	if err != nil {
+		return nil, err
+	}
+	if pageSize > MaxPageSize {
+		return nil, fmt.Errorf("pageSize %d is larger than %d", pageSize, MaxPageSize)
+	}
+	if page > MaxPage {
+		return nil, fmt.Errorf("page %d is larger than %d", page, MaxPage)
+	}
+
+	if page == 0 && pageSize > 0 {
+		page = 1
+	}
+	if page > 0 && pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	query.Page = page
+	query.PageSize = pageSize
+	return query, nil
+}
+
+func positive(values url.Values, key string) (int, error) {
+	value := values.Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%s %q is expected to be a positive number", key, value)
+	}
+	return number, nil
+}
+
+// Apply searches, sorts and pages items, total is count of items matched before paging
+// items is not changed, a page past the end is empty
+func Apply[T any](items []T, query *Query, fields Fields[T]) ([]T, int, error) {
+	sortBy := query.SortBy
+	if sortBy == "" && query.Page > 0 {
+		sortBy = fields.DefaultSort
+	}
+	var key func(T) string
+	if sortBy != "" {
+		var found bool
+		key, found = fields.Sort[sortBy]
+		if !found {
+			names := make([]string, 0, len(fields.Sort))
+			for name := range fields.Sort {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, 0, fmt.Errorf("can not sort by %s, expected to be one of %s", sortBy, strings.Join(names, ", "))
+		}
+	}
+
+	matched := make([]T, 0, len(items))
+	search := strings.ToLower(query.Search)
+	for _, item := range items {
+		if search == "" || fields.Search == nil || contains(fields.Search(item), search) {
+			matched = append(matched, item)
+		}
+	}
+
+	if key != nil {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if query.Descending {
+				return key(matched[j]) < key(matched[i])
+			}
+			return key(matched[i]) < key(matched[j])
+		})
+	}
+
+	total := len(matched)
+	if query.Page == 0 {
+		return matched, total, nil
+	}
+	// compared before multiplying, so a query not read by Parse can not overflow
+	if query.Page-1 >= (total+query.PageSize-1)/query.PageSize {
+		return []T{}, total, nil
+	}
+	start := (query.Page - 1) * query.PageSize
+	end := start + query.PageSize
+	if end > total {
+		end = total
+	}
+	return matched[start:end], total, nil
+}
+
+func contains(texts []string, search string) bool {
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), search) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package listing
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type item struct {
+	name        string
+	description string
+}
+
+var fields = Fields[item]{
+	Search: func(i item) []string { return []string{i.name, i.description} },
+	Sort: map[string]func(item) string{
+		"name":        func(i item) string { return i.name },
+		"description": func(i item) string { return i.description },
+	},
+	DefaultSort: "name",
+}
+
+var items = []item{
+	{"carol", "Teacher of class 2"},
+	{"alice", "student of class 1"},
+	{"dave", "student of class 2"},
+	{"bob", "student of class 1"},
+}
+
+func names(items []item) []string {
+	var result []string
+	for _, i := range items {
+		result = append(result, i.name)
+	}
+	return result
+}
+
+func parse(query string) *Query {
+	values, err := url.ParseQuery(query)
+	Expect(err).To(BeNil())
+	parsed, err := Parse(values)
+	Expect(err).To(BeNil())
+	return parsed
+}
+
+var _ = Describe("listing test", func() {
+	Describe("Parse test", func() {
+		It("should read query", func() {
+			Expect(*parse("q=+class+&sort=-name&page=2&pageSize=50")).To(Equal(Query{Search: "class", SortBy: "name", Descending: true, Page: 2, PageSize: 50}))
+			Expect(*parse("")).To(Equal(Query{}))
+			Expect(*parse("page=3")).To(Equal(Query{Page: 3, PageSize: DefaultPageSize}))
+			Expect(*parse("pageSize=5")).To(Equal(Query{Page: 1, PageSize: 5}))
+		})
+
+		It("should refuse invalid paging", func() {
+			for _, query := range []string{"page=0", "page=-1", "page=x", "pageSize=501", fmt.Sprintf("page=%d", MaxPage+1), fmt.Sprintf("page=%d&pageSize=%d", math.MaxInt, MaxPageSize)} {
+				values, _ := url.ParseQuery(query)
+				_, err := Parse(values)
+				Expect(err).To(HaveOccurred(), query)
+			}
+		})
+	})
+
+	Describe("Apply test", func() {
+		It("should keep order of whole list unless sorting is asked", func() {
+			result, total, err := Apply(items, parse(""), fields)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(4))
+			Expect(names(result)).To(Equal([]string{"carol", "alice", "dave", "bob"}))
+		})
+
+		It("should search case insensitive and sort", func() {
+			result, total, err := Apply(items, parse("q=CLASS+2&sort=-name"), fields)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(2))
+			Expect(names(result)).To(Equal([]string{"dave", "carol"}))
+
+			result, _, err = Apply(items, parse("sort=description"), fields)
+			Expect(err).To(BeNil())
+			Expect(names(result)).To(Equal([]string{"carol", "alice", "bob", "dave"}))
+		})
+
+		It("should page by default sort", func() {
+			result, total, err := Apply(items, parse("page=2&pageSize=3"), fields)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(4))
+			Expect(names(result)).To(Equal([]string{"dave"}))
+
+			result, total, err = Apply(items, parse("page=3&pageSize=3"), fields)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(4))
+			Expect(result).To(BeEmpty())
+			Expect(result).NotTo(BeNil())
+		})
+
+		It("should return empty page instead of overflowing", func() {
+			result, total, err := Apply(items, parse(fmt.Sprintf("page=%d&pageSize=%d", MaxPage, MaxPageSize)), fields)
+			Expect(err).To(BeNil())
+			Expect(total).To(Equal(4))
+			Expect(result).To(BeEmpty())
+
+			result, _, err = Apply(items, &Query{Page: math.MaxInt, PageSize: MaxPageSize}, fields)
+			Expect(err).To(BeNil())
+			Expect(result).To(BeEmpty())
+		})
+
+		It("should refuse unknown sort field", func() {
+			_, _, err := Apply(items, parse("sort=age"), fields)
+			Expect(err).To(MatchError(ContainSubstring("description, name")))
+		})
+	})
+})
@@ -0,0 +1,226 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// a xlsx file is a zip of xml parts, only parts needed to read cell text of first sheet are parsed
+// styles, formulas and dates are ignored, cell is read as text it is stored with
+
+const (
+	workbookPart      = "xl/workbook.xml"
+	workbookRelsPart  = "xl/_rels/workbook.xml.rels"
+	sharedStringsPart = "xl/sharedStrings.xml"
+	defaultWorksheet  = "xl/worksheets/sheet1.xml"
+	maxColumns        = 16384
+)
+
+type workbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		Id   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type relationships struct {
+	Relationships []struct {
+		Id     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// richText is text of shared string or inline string, it is either plain or split into runs
+type richText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t richText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var builder strings.Builder
+	for _, run := range t.Runs {
+		builder.WriteString(run.Text)
+	}
+	return builder.String()
+}
+
+type sharedStrings struct {
+	Items []richText `xml:"si"`
+}
+
+type worksheet struct {
+	Rows []struct {
+		Ref   int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline richText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadRows returns text of cells of first sheet, index of returned rows is row number minus 1
+// blank rows in between are kept as empty rows so row numbers can be reported back to user
+func ReadRows(reader io.ReaderAt, size int64) ([][]string, error) {
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a xlsx file: %w", err)
+	}
+
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	sheetPart, err := firstSheetPart(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	var strs sharedStrings
+	if _, found := parts[sharedStringsPart]; found {
+		if err := decodePart(parts, sharedStringsPart, &strs); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet worksheet
+	if err := decodePart(parts, sheetPart, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		rowIndex := len(rows)
+		if row.Ref > 0 {
+			rowIndex = row.Ref - 1
+		}
+		if rowIndex < len(rows) {
+			return nil, fmt.Errorf("row %d of sheet is out of order", row.Ref)
+		}
+		for len(rows) < rowIndex {
+			rows = append(rows, nil)
+		}
+
+		var cells []string
+		for _, cell := range row.Cells {
+			column := len(cells)
+			if cell.Ref != "" {
+				column, err = columnIndex(cell.Ref)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if column < len(cells) {
+				return nil, fmt.Errorf("cell %s of sheet is out of order", cell.Ref)
+			}
+			for len(cells) < column {
+				cells = append(cells, "")
+			}
+
+			var text string
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(strings.TrimSpace(cell.Value))
+				if err != nil || index < 0 || index >= len(strs.Items) {
+					return nil, fmt.Errorf("cell %s refers to unknown shared string %q", cell.Ref, cell.Value)
+				}
+				text = strs.Items[index].String()
+			case "inlineStr":
+				text = cell.Inline.String()
+			default:
+				text = cell.Value
+			}
+			cells = append(cells, text)
+		}
+		rows = append(rows, cells)
+	}
+
+	return rows, nil
+}
+
+// firstSheetPart looks up part of first sheet listed in workbook
+func firstSheetPart(parts map[string]*zip.File) (string, error) {
+	if _, found := parts[workbookPart]; !found {
+		return "", fmt.Errorf("not a xlsx file: %s is missing", workbookPart)
+	}
+
+	var book workbook
+	if err := decodePart(parts, workbookPart, &book); err != nil {
+		return "", err
+	}
+	if len(book.Sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheet")
+	}
+
+	if _, found := parts[workbookRelsPart]; found {
+		var rels relationships
+		if err := decodePart(parts, workbookRelsPart, &rels); err != nil {
+			return "", err
+		}
+		for _, rel := range rels.Relationships {
+			if rel.Id != book.Sheets[0].Id {
+				continue
+			}
+			target := rel.Target
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(target, "/")
+			} else {
+				target = path.Join("xl", target)
+			}
+			if _, found := parts[target]; found {
+				return target, nil
+			}
+		}
+	}
+
+	if _, found := parts[defaultWorksheet]; found {
+		return defaultWorksheet, nil
+	}
+	return "", fmt.Errorf("sheet %s is not found in workbook", book.Sheets[0].Name)
+}
+
+func decodePart(parts map[string]*zip.File, name string, v interface{}) error {
+	file, found := parts[name]
+	if !found {
+		return fmt.Errorf("not a xlsx file: %s is missing", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer reader.Close()
+
+	if err := xml.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// columnIndex turns cell reference like 'AB12' into zero based column index
+func columnIndex(ref string) (int, error) {
+	index := 0
+	letters := 0
+	for _, char := range ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		index = index*26 + int(char-'A') + 1
+		letters++
+	}
+	if letters == 0 || index > maxColumns {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return index - 1, nil
+}
@@ -0,0 +1,113 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// zipOf packs parts the way a spreadsheet application stores them
+func zipOf(parts map[string]string) *bytes.Reader {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for name, content := range parts {
+		part, err := writer.Create(name)
+		Expect(err).To(BeNil())
+		_, err = part.Write([]byte(content))
+		Expect(err).To(BeNil())
+	}
+	Expect(writer.Close()).To(BeNil())
+	return bytes.NewReader(buffer.Bytes())
+}
+
+const testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="users" sheetId="1" r:id="rId3"/><sheet name="other" sheetId="2" r:id="rId1"/></sheets>
+</workbook>`
+
+const testRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+</Relationships>`
+
+const testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>username</t></si><si><t>password</t></si><si><r><t>ali</t></r><r><t>ce</t></r></si>
+</sst>`
+
+const testSheet = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3"><v>12345678</v></c></row>
+<row r="4"><c r="B4" t="inlineStr"><is><t>bob</t></is></c></row>
+</sheetData>
+</worksheet>`
+
+var _ = Describe("xlsx reader test", func() {
+	It("should read first sheet of workbook", func() {
+		reader := zipOf(map[string]string{
+			workbookPart:               testWorkbook,
+			workbookRelsPart:           testRels,
+			sharedStringsPart:          testSharedStrings,
+			"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1"><v>wrong</v></c></row></sheetData></worksheet>`,
+			"xl/worksheets/sheet2.xml": testSheet,
+			"[Content_Types].xml":      `<Types/>`,
+			"docProps/app.xml":         `<Properties/>`,
+		})
+
+		rows, err := ReadRows(reader, reader.Size())
+		Expect(err).To(BeNil())
+		Expect(rows).To(Equal([][]string{
+			{"username", "password"},
+			nil,
+			{"alice", "", "12345678"},
+			{"", "bob"},
+		}))
+	})
+
+	It("should fall back to sheet1 without relationships", func() {
+		reader := zipOf(map[string]string{
+			workbookPart:               testWorkbook,
+			sharedStringsPart:          testSharedStrings,
+			"xl/worksheets/sheet1.xml": testSheet,
+		})
+
+		rows, err := ReadRows(reader, reader.Size())
+		Expect(err).To(BeNil())
+		Expect(rows).To(HaveLen(4))
+	})
+
+	It("should refuse file which is not xlsx", func() {
+		reader := bytes.NewReader([]byte("username,password\nalice,secret"))
+		_, err := ReadRows(reader, reader.Size())
+		Expect(err).To(HaveOccurred())
+
+		reader = zipOf(map[string]string{"word/document.xml": "<document/>"})
+		_, err = ReadRows(reader, reader.Size())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should refuse unknown shared string", func() {
+		reader := zipOf(map[string]string{
+			workbookPart:               testWorkbook,
+			"xl/worksheets/sheet1.xml": testSheet,
+		})
+		_, err := ReadRows(reader, reader.Size())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should turn cell reference into column index", func() {
+		index, err := columnIndex("A1")
+		Expect(err).To(BeNil())
+		Expect(index).To(Equal(0))
+		index, err = columnIndex("AB12")
+		Expect(err).To(BeNil())
+		Expect(index).To(Equal(27))
+		_, err = columnIndex("12")
+		Expect(err).To(HaveOccurred())
+	})
+})
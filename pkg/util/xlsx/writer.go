@@ -0,0 +1,98 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ContentType is media type of xlsx file
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+const contentTypesXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+
+const rootRelsXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const workbookXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const workbookRelsXml = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+// WriteRows writes rows as a workbook of a single sheet, every cell is stored as inline text
+// text is never read as formula or number by spreadsheet application
+func WriteRows(w io.Writer, sheetName string, rows [][]string) error {
+	archive := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(contentTypesXml)},
+		{"_rels/.rels", []byte(rootRelsXml)},
+		{workbookPart, []byte(fmt.Sprintf(workbookXml, escape(sheetName)))},
+		{workbookRelsPart, []byte(workbookRelsXml)},
+		{defaultWorksheet, sheetXml(rows)},
+	}
+	for _, part := range parts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", part.name, err)
+		}
+		if _, err := writer.Write(part.content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", part.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+func sheetXml(rows [][]string) []byte {
+	buffer := &bytes.Buffer{}
+	buffer.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	buffer.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for rowIndex, row := range rows {
+		fmt.Fprintf(buffer, `<row r="%d">`, rowIndex+1)
+		for column, value := range row {
+			if value == "" {
+				continue
+			}
+			fmt.Fprintf(buffer, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, columnName(column), rowIndex+1, escape(value))
+		}
+		buffer.WriteString(`</row>`)
+	}
+	buffer.WriteString(`</sheetData></worksheet>`)
+	return buffer.Bytes()
+}
+
+// escape makes text safe to be put in xml, characters xml does not allow are replaced
+func escape(text string) string {
+	builder := &strings.Builder{}
+	// writing to strings.Builder never fails
+	_ = xml.EscapeText(builder, []byte(text))
+	return builder.String()
+}
+
+// columnName turns zero based column index into letters like 'AB'
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
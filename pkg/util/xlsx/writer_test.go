@@ -0,0 +1,41 @@
+package xlsx
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("xlsx writer test", func() {
+	It("should write rows that are read back", func() {
+		rows := [][]string{
+			{"username", "password", "role"},
+			{"alice", "", "student;teacher"},
+			{"<b>&bob</b>", " =1+1 ", "学生"},
+		}
+		buffer := &bytes.Buffer{}
+		Expect(WriteRows(buffer, "users & groups", rows)).To(BeNil())
+
+		read, err := ReadRows(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+		Expect(err).To(BeNil())
+		Expect(read).To(Equal([][]string{
+			{"username", "password", "role"},
+			{"alice", "", "student;teacher"},
+			{"<b>&bob</b>", " =1+1 ", "学生"},
+		}))
+	})
+
+	It("should name columns like spreadsheet does", func() {
+		Expect(columnName(0)).To(Equal("A"))
+		Expect(columnName(25)).To(Equal("Z"))
+		Expect(columnName(26)).To(Equal("AA"))
+		Expect(columnName(701)).To(Equal("ZZ"))
+		Expect(columnName(702)).To(Equal("AAA"))
+		for _, index := range []int{0, 27, 701, 16383} {
+			read, err := columnIndex(columnName(index) + "1")
+			Expect(err).To(BeNil())
+			Expect(read).To(Equal(index))
+		}
+	})
+})